@@ -15,7 +15,7 @@ var backgroundProcessCmd = &cobra.Command{
 			return err
 		}
 
-		service, err := service.NewService(logger)
+		service, err := service.NewService(cmd.Context(), logger)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go-template/src/core/db"
+	"go-template/src/core/db/contract"
+)
+
+var checkDBCmd = &cobra.Command{
+	Use:   "check-db",
+	Short: "Run the database contract checks against the configured database, rolling back every change",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger, err := getLogger()
+		if err != nil {
+			return err
+		}
+
+		dbConfig, err := db.InitConfig()
+		if err != nil {
+			return err
+		}
+
+		// activity logs must be written in the transaction of the check
+		viper.Set("Database.ActivityLog.Async", false)
+
+		database, err := db.New(dbConfig, logger)
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		results, err := contract.Run(context.Background(), database)
+		if err != nil {
+			return err
+		}
+
+		report, _ := json.MarshalIndent(results, "", "  ")
+		fmt.Fprintln(os.Stdout, string(report))
+
+		failed := 0
+		for _, result := range results {
+			if !result.Passed {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d database checks failed on %s", failed, len(results), dbConfig.DBType)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkDBCmd)
+}
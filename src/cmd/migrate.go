@@ -1,7 +1,11 @@
 package cmd
 
 import (
-	_ "github.com/jinzhu/gorm/dialects/postgres"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
 	"github.com/spf13/cobra"
 	"go-template/src/core/db"
 )
@@ -9,26 +13,102 @@ import (
 var migrateDBCmd = &cobra.Command{
 	Use: "migrate-db",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		number, _ := cmd.Flags().GetInt("number")
+		to, _ := cmd.Flags().GetInt("to")
+		if !cmd.Flags().Changed("to") && cmd.Flags().Changed("number") {
+			to, _ = cmd.Flags().GetInt("number")
+		}
+		steps, _ := cmd.Flags().GetInt("steps")
 		dryRun, _ := cmd.Flags().GetBool("dry-run")
 
-		forceMigrate, _ := cmd.Flags().GetBool("force-migrate")
+		reset, _ := cmd.Flags().GetBool("reset")
+		ignoreDrift, _ := cmd.Flags().GetBool("ignore-drift")
+
+		logger, err := getLogger()
+		if err != nil {
+			return err
+		}
+
+		dbConfig, err := db.InitConfig()
+		if err != nil {
+			return err
+		}
+
+		return db.Migrate(context.Background(), dbConfig, logger, db.MigrateOptions{
+			DryRun:      dryRun,
+			To:          to,
+			Steps:       steps,
+			Reset:       reset,
+			IgnoreDrift: ignoreDrift,
+		})
+	},
+}
+
+var migrateDBStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "List applied and pending migrations; fails when an applied migration changed",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger, err := getLogger()
+		if err != nil {
+			return err
+		}
+
+		dbConfig, err := db.InitConfig()
+		if err != nil {
+			return err
+		}
+
+		result, err := db.MigrationsStatus(context.Background(), dbConfig, logger)
+		if err != nil {
+			return err
+		}
+
+		report, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Fprintln(os.Stdout, string(report))
+
+		for _, migration := range result {
+			if migration.State == db.MigrationChanged {
+				return fmt.Errorf("migration %d %q changed after it was applied", migration.Number, migration.Name)
+			}
+		}
+		return nil
+	},
+}
+
+var migrateDBNewCmd = &cobra.Command{
+	Use:   "new <name>",
+	Short: "Create the next numbered up and down SQL migration files",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, _ := cmd.Flags().GetString("dir")
 
 		dbConfig, err := db.InitConfig()
 		if err != nil {
 			return err
 		}
 
-		db.Migrate(dbConfig, dryRun, number, forceMigrate)
+		up, down, err := db.NewMigration(dbConfig, dir, args[0])
+		if err != nil {
+			return err
+		}
 
+		fmt.Fprintln(os.Stdout, up)
+		fmt.Fprintln(os.Stdout, down)
 		return nil
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(migrateDBCmd)
+	migrateDBCmd.AddCommand(migrateDBStatusCmd)
+	migrateDBCmd.AddCommand(migrateDBNewCmd)
+
+	migrateDBNewCmd.Flags().String("dir", "", "directory of the SQL migrations (default is the embedded directory, from the repository root)")
 
-	migrateDBCmd.Flags().Int("number", -1, "the migration to run forwards until; if not set, will run all migrations")
+	migrateDBCmd.Flags().Int("to", -1, "the migration to end at, rolling back when it is below the latest applied one; if not set, will run all migrations")
+	migrateDBCmd.Flags().Int("number", -1, "the migration to run forwards until")
+	migrateDBCmd.Flags().MarkDeprecated("number", "use --to")
+	migrateDBCmd.Flags().Int("steps", 0, "apply this many migrations, or roll back this many when negative")
 	migrateDBCmd.Flags().Bool("dry-run", false, "print out migrations to be applied without running them")
-	migrateDBCmd.Flags().Bool("force-migrate", false, "drop all the tables before migrate the database")
+	migrateDBCmd.Flags().Bool("ignore-drift", false, "only warn when an applied migration changed, instead of failing")
+	migrateDBCmd.Flags().Bool("reset", false, "drop Database.PostgreSQL.Schema, or roll back every migration when using the public schema or MySQL/TiDB, before migrating")
 }
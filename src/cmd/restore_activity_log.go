@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go-template/src/service"
+)
+
+var restoreActivityLogCmd = &cobra.Command{
+	Use:   "restore-activity-log",
+	Short: "Restore an archived activity_log month from MinIO into a table for investigation",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		monthFlag, _ := cmd.Flags().GetString("month")
+		table, _ := cmd.Flags().GetString("table")
+
+		month, err := time.Parse("2006-01", monthFlag)
+		if err != nil {
+			return errors.Wrap(err, "--month must be YYYY-MM")
+		}
+
+		logger, err := getLogger()
+		if err != nil {
+			return err
+		}
+
+		service, err := service.NewService(cmd.Context(), logger)
+		if err != nil {
+			return err
+		}
+		defer service.Close()
+
+		table, rows, err := service.NewContext(nil).RestoreActivityLogArchive(month, table)
+		if err != nil {
+			return err
+		}
+
+		logger.Infof("Restored %d rows into table %s, drop it when the investigation is done", rows, table)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(restoreActivityLogCmd)
+
+	restoreActivityLogCmd.Flags().String("month", "", "the archived month to restore, YYYY-MM")
+	restoreActivityLogCmd.Flags().String("table", "", "the table to create, default activity_log_restore_YYYYMM")
+	_ = restoreActivityLogCmd.MarkFlagRequired("month")
+}
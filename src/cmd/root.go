@@ -3,6 +3,8 @@ package cmd
 import (
 	"fmt"
 	"go-template/src/core/log"
+	"go-template/src/core/redact"
+	"log/slog"
 	"os"
 	"strings"
 
@@ -97,9 +99,29 @@ func getLogger() (log.Logger, error) {
 		return nil, err
 	}
 
-	logger, err := log.NewLogger(configLogger, log.InstanceZapLogger)
+	logger, err := log.NewLogger(configLogger, configLogger.Instance)
 	if err != nil {
 		return nil, err
 	}
+
+	// Mask secrets and PII in structured fields of every log line
+	redactConfig, err := redact.InitConfig()
+	if err != nil {
+		return nil, err
+	}
+	redactor, err := redact.New(redactConfig)
+	if err != nil {
+		return nil, err
+	}
+	log.SetFieldFilter(func(fields log.Fields) log.Fields {
+		return redactor.Fields(fields)
+	})
+
+	// Route libraries logging through log/slog to the same backend
+	slog.SetDefault(log.NewSlogLogger(logger))
+
+	// Toggle debug logging with `kill -USR1 <pid>`
+	log.HandleLevelSignal(configLogger, logger)
+
 	return logger, nil
 }
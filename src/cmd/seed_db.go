@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go-template/src/core/db"
+	"go-template/src/core/seed"
+)
+
+var seedDBCmd = &cobra.Command{
+	Use:   "seed-db",
+	Short: "Upsert the fixtures of a profile into the database",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// no default, seeding a production database must be deliberate
+		profile, _ := cmd.Flags().GetString("profile")
+		if profile == "" {
+			return errors.New("--profile is required, e.g. dev, uat or prod")
+		}
+		files, _ := cmd.Flags().GetStringSlice("file")
+		noDefaults, _ := cmd.Flags().GetBool("no-defaults")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		tables, err := seed.Load(files, profile, noDefaults)
+		if err != nil {
+			return err
+		}
+
+		logger, err := getLogger()
+		if err != nil {
+			return err
+		}
+
+		dbConfig, err := db.InitConfig()
+		if err != nil {
+			return err
+		}
+
+		database, err := db.New(dbConfig, logger)
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		changes, err := database.Seed(context.Background(), tables, dryRun)
+		if err != nil {
+			return err
+		}
+
+		seed.WriteDiff(os.Stdout, changes)
+		if dryRun {
+			fmt.Fprintf(os.Stdout, "dry run, profile %s: would %s\n", profile, seed.Summary(changes))
+		} else {
+			fmt.Fprintf(os.Stdout, "profile %s: %s\n", profile, seed.Summary(changes))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(seedDBCmd)
+
+	seedDBCmd.Flags().String("profile", "", "fixture profile, e.g. dev, uat or prod (required)")
+	_ = seedDBCmd.MarkFlagRequired("profile")
+	seedDBCmd.Flags().StringSlice("file", nil, "fixture file or directory to load after the embedded defaults, repeatable")
+	seedDBCmd.Flags().Bool("no-defaults", false, "skip the embedded default fixtures")
+	seedDBCmd.Flags().Bool("dry-run", false, "print the changes without committing them")
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"go-template/src/core/audit"
+)
+
+var sendAuditEventCmd = &cobra.Command{
+	Use:   "send-audit-event",
+	Short: "Send a test event to the configured audit sinks",
+	Long: "Send a test event to the configured audit sinks, e.g. to check the SIEM " +
+		"receives it. Only the sinks are started, the database is not needed.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		eventType, _ := cmd.Flags().GetString("type")
+		userName, _ := cmd.Flags().GetString("user")
+
+		logger, err := getLogger()
+		if err != nil {
+			return err
+		}
+
+		config, err := audit.InitConfig()
+		if err != nil {
+			return err
+		}
+		if config.Syslog != nil && !config.Syslog.Enabled {
+			fmt.Fprintln(os.Stderr, "Audit.Syslog.Enabled is false, enabling it for this test")
+			config.Syslog.Enabled = true
+		}
+
+		sink, err := audit.New(config, logger)
+		if err != nil {
+			return err
+		}
+
+		sink.Send(&audit.Event{
+			Type:     audit.EventType(eventType),
+			Outcome:  audit.OutcomeSuccess,
+			Message:  "Test event from send-audit-event",
+			UserName: userName,
+		})
+
+		// Close waits until the queued event is sent
+		return sink.Close()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sendAuditEventCmd)
+
+	sendAuditEventCmd.Flags().String("type", string(audit.EventLogin), "event type: login, login_failed, session_revoked or admin_action")
+	sendAuditEventCmd.Flags().String("user", "test-user", "user name of the event")
+}
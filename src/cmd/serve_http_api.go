@@ -1,9 +1,10 @@
 package cmd
 
 import (
-	"context"
-
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go-template/src/core/db"
 	"go-template/src/core/handlers/routes"
 	"go-template/src/otel"
 	"go-template/src/service"
@@ -19,17 +20,24 @@ var serveAPICmd = &cobra.Command{
 			return err
 		}
 
-		service, err := service.NewService(logger)
-		if err != nil {
-			return err
+		autoMigrate, _ := cmd.Flags().GetBool("auto-migrate")
+		if !cmd.Flags().Changed("auto-migrate") {
+			autoMigrate = viper.GetBool("Database.AutoMigrate")
 		}
+		if autoMigrate {
+			dbConfig, err := db.InitConfig()
+			if err != nil {
+				return err
+			}
 
-		config, err := routes.InitConfig()
-		if err != nil {
-			return err
+			// replicas starting together wait on the migration lock
+			if err := db.Migrate(cmd.Context(), dbConfig, logger, db.MigrateOptions{To: -1}); err != nil {
+				return errors.Wrap(err, "auto-migrate")
+			}
 		}
 
-		ctx := context.Background()
+		// the tracer comes first, so the startup calls of NewService are exported
+		ctx := cmd.Context()
 		tp, err := otel.Init(ctx)
 		if err != nil {
 			return err
@@ -40,6 +48,27 @@ var serveAPICmd = &cobra.Command{
 			}
 		}()
 
+		service, err := service.NewService(ctx, logger)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := service.Close(); err != nil {
+				logger.Errorf("Failed to close service: %v", err)
+			}
+		}()
+
+		// inserts fail without a partition for the current month, do not rely
+		// on the background process alone
+		if err := service.NewContext(nil).EnsureActivityLogPartitions(); err != nil {
+			logger.Errorf("Failed to create activity log partitions: %+v", err)
+		}
+
+		config, err := routes.InitConfig()
+		if err != nil {
+			return err
+		}
+
 		routes.NewRouter(config, logger, service)
 
 		return nil
@@ -48,4 +77,6 @@ var serveAPICmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(serveAPICmd)
+
+	serveAPICmd.Flags().Bool("auto-migrate", false, "apply pending migrations before serving (default Database.AutoMigrate)")
 }
@@ -0,0 +1,118 @@
+package audit
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"go-template/src/core/model"
+	"go-template/src/core/utils"
+)
+
+// Activity log rows are chained per UTC day: every row stores the hash of
+// the previous row of the same day and its own hash covering that link and
+// its content, so editing, removing or reordering a row breaks every later
+// link. Checkpoints sign the last link with an RSA key kept outside the
+// database, so the chain cannot be silently recomputed either.
+
+// Precision is the precision of created_time in Postgres. Timestamps are
+// truncated to it before hashing so the hash survives a round trip.
+const Precision = time.Microsecond
+
+// ChainDate returns the day whose chain createdTime belongs to
+func ChainDate(createdTime time.Time) time.Time {
+	t := createdTime.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// hashedContent fixes the fields and their order
+type hashedContent struct {
+	ChainSeq       int64    `json:"chain_seq"`
+	RequestNo      string   `json:"request_no"`
+	TraceID        string   `json:"trace_id"`
+	SpanID         string   `json:"span_id"`
+	ServiceCode    string   `json:"service_code"`
+	UserID         *int64   `json:"user_id"`
+	AzureUserID    *string  `json:"azure_user_id"`
+	EmailAddress   *string  `json:"email_address"`
+	UserRoles      []string `json:"user_roles"`
+	HTTPMethod     string   `json:"http_method"`
+	RequestURI     string   `json:"request_uri"`
+	HTTPStatusCode int      `json:"http_status_code"`
+	ResponseCode   *int     `json:"response_code"`
+	DurationMs     int64    `json:"duration_ms"`
+	IPAddress      string   `json:"ip_address"`
+	UserAgent      *string  `json:"user_agent"`
+	RequestBody    *string  `json:"request_body"`
+	ResponseBody   *string  `json:"response_body"`
+	CreatedTime    string   `json:"created_time"`
+}
+
+// Hash returns the hex SHA-256 of prevHash followed by the canonical JSON of
+// the audited fields of activityLog
+func Hash(prevHash string, activityLog *model.ActivityLog) string {
+	userRoles := activityLog.UserRoles
+	if userRoles == nil {
+		userRoles = []string{}
+	}
+
+	content, _ := json.Marshal(hashedContent{
+		ChainSeq:       activityLog.ChainSeq,
+		RequestNo:      activityLog.RequestNo,
+		TraceID:        activityLog.TraceID,
+		SpanID:         activityLog.SpanID,
+		ServiceCode:    activityLog.ServiceCode,
+		UserID:         activityLog.UserID,
+		AzureUserID:    activityLog.AzureUserID,
+		EmailAddress:   activityLog.EmailAddress,
+		UserRoles:      userRoles,
+		HTTPMethod:     activityLog.HTTPMethod,
+		RequestURI:     activityLog.RequestURI,
+		HTTPStatusCode: activityLog.HTTPStatusCode,
+		ResponseCode:   activityLog.ResponseCode,
+		DurationMs:     activityLog.DurationMs,
+		IPAddress:      activityLog.IPAddress,
+		UserAgent:      activityLog.UserAgent,
+		RequestBody:    activityLog.RequestBody,
+		ResponseBody:   activityLog.ResponseBody,
+		CreatedTime:    activityLog.CreatedTime.UTC().Truncate(Precision).Format(time.RFC3339Nano),
+	})
+
+	hash := sha256.New()
+	hash.Write([]byte(prevHash))
+	hash.Write([]byte{'\n'})
+	hash.Write(content)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// checkpointMessage is what a checkpoint signature covers
+func checkpointMessage(checkpoint *model.ActivityLogCheckpoint) []byte {
+	return []byte(fmt.Sprintf("activity_log:%s:%d:%s",
+		checkpoint.ChainDate.Format(time.DateOnly), checkpoint.ChainSeq, checkpoint.RowHash))
+}
+
+// SignCheckpoint sets the signature of checkpoint
+func SignCheckpoint(checkpoint *model.ActivityLogCheckpoint, keyID string, privateKey *rsa.PrivateKey) error {
+	signature, err := utils.SignWithPrivateKey(checkpointMessage(checkpoint), privateKey)
+	if err != nil {
+		return errors.Wrap(err, "unable to sign activity log checkpoint")
+	}
+
+	checkpoint.KeyID = keyID
+	checkpoint.Signature = base64.StdEncoding.EncodeToString(signature)
+	return nil
+}
+
+// VerifyCheckpoint checks the signature of checkpoint
+func VerifyCheckpoint(checkpoint *model.ActivityLogCheckpoint, publicKey *rsa.PublicKey) error {
+	signature, err := base64.StdEncoding.DecodeString(checkpoint.Signature)
+	if err != nil {
+		return errors.Wrap(err, "invalid checkpoint signature encoding")
+	}
+	return utils.VerifyWithPublicKey(checkpointMessage(checkpoint), signature, publicKey)
+}
@@ -0,0 +1,154 @@
+package audit
+
+import (
+	"os"
+	"time"
+
+	"github.com/spf13/viper"
+	"go-template/src/core/log"
+)
+
+type Config struct {
+	Syslog *SyslogConfig
+}
+
+// SyslogConfig forwards audit events to a SIEM over syslog (RFC 5424).
+// Network is udp, tcp or tls; tcp and tls use octet-counting framing.
+type SyslogConfig struct {
+	Enabled  bool
+	Network  string
+	Address  string
+	Format   string
+	Facility int
+	AppName  string
+	Hostname string
+
+	QueueSize            int
+	DialTimeout          time.Duration
+	WriteTimeout         time.Duration
+	ReconnectInterval    time.Duration
+	MaxReconnectInterval time.Duration
+	CloseTimeout         time.Duration
+
+	TLS TLSConfig
+	CEF CEFConfig
+}
+
+type TLSConfig struct {
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	ServerName         string
+	InsecureSkipVerify bool
+}
+
+// CEFConfig fills the Device Vendor, Product and Version of the CEF header
+type CEFConfig struct {
+	Vendor  string
+	Product string
+	Version string
+}
+
+func InitConfig() (*Config, error) {
+	syslogAddress := viper.GetString("AUDIT_SYSLOG_ADDRESS")
+	if syslogAddress == "" {
+		syslogAddress = viper.GetString("Audit.Syslog.Address")
+	}
+
+	syslogConfig := &SyslogConfig{
+		Enabled:  viper.GetBool("Audit.Syslog.Enabled"),
+		Network:  viper.GetString("Audit.Syslog.Network"),
+		Address:  syslogAddress,
+		Format:   viper.GetString("Audit.Syslog.Format"),
+		Facility: viper.GetInt("Audit.Syslog.Facility"),
+		AppName:  viper.GetString("Audit.Syslog.AppName"),
+		Hostname: viper.GetString("Audit.Syslog.Hostname"),
+
+		QueueSize:            viper.GetInt("Audit.Syslog.QueueSize"),
+		DialTimeout:          viper.GetDuration("Audit.Syslog.DialTimeout"),
+		WriteTimeout:         viper.GetDuration("Audit.Syslog.WriteTimeout"),
+		ReconnectInterval:    viper.GetDuration("Audit.Syslog.ReconnectInterval"),
+		MaxReconnectInterval: viper.GetDuration("Audit.Syslog.MaxReconnectInterval"),
+		CloseTimeout:         viper.GetDuration("Audit.Syslog.CloseTimeout"),
+
+		TLS: TLSConfig{
+			CAFile:             viper.GetString("Audit.Syslog.TLS.CAFile"),
+			CertFile:           viper.GetString("Audit.Syslog.TLS.CertFile"),
+			KeyFile:            viper.GetString("Audit.Syslog.TLS.KeyFile"),
+			ServerName:         viper.GetString("Audit.Syslog.TLS.ServerName"),
+			InsecureSkipVerify: viper.GetBool("Audit.Syslog.TLS.InsecureSkipVerify"),
+		},
+		CEF: CEFConfig{
+			Vendor:  viper.GetString("Audit.Syslog.CEF.Vendor"),
+			Product: viper.GetString("Audit.Syslog.CEF.Product"),
+			Version: viper.GetString("Audit.Syslog.CEF.Version"),
+		},
+	}
+
+	if syslogConfig.Network == "" {
+		syslogConfig.Network = "udp"
+	}
+	if syslogConfig.Address == "" {
+		syslogConfig.Address = "localhost:514"
+	}
+	if syslogConfig.Format == "" {
+		syslogConfig.Format = FormatCEF
+	}
+	if syslogConfig.Facility <= 0 {
+		// log audit
+		syslogConfig.Facility = 13
+	}
+	if syslogConfig.AppName == "" {
+		syslogConfig.AppName = "go-template"
+	}
+	if syslogConfig.Hostname == "" {
+		syslogConfig.Hostname, _ = os.Hostname()
+	}
+	if syslogConfig.QueueSize <= 0 {
+		syslogConfig.QueueSize = 1000
+	}
+	if syslogConfig.DialTimeout <= 0 {
+		syslogConfig.DialTimeout = 5 * time.Second
+	}
+	if syslogConfig.WriteTimeout <= 0 {
+		syslogConfig.WriteTimeout = 5 * time.Second
+	}
+	if syslogConfig.ReconnectInterval <= 0 {
+		syslogConfig.ReconnectInterval = time.Second
+	}
+	if syslogConfig.MaxReconnectInterval < syslogConfig.ReconnectInterval {
+		syslogConfig.MaxReconnectInterval = time.Minute
+	}
+	if syslogConfig.CloseTimeout <= 0 {
+		syslogConfig.CloseTimeout = 5 * time.Second
+	}
+	if syslogConfig.CEF.Vendor == "" {
+		syslogConfig.CEF.Vendor = "go-template"
+	}
+	if syslogConfig.CEF.Product == "" {
+		syslogConfig.CEF.Product = syslogConfig.AppName
+	}
+	if syslogConfig.CEF.Version == "" {
+		syslogConfig.CEF.Version = "1.0"
+	}
+
+	return &Config{
+		Syslog: syslogConfig,
+	}, nil
+}
+
+// New returns the configured sinks. The Postgres activity log is written
+// separately; these only forward security events.
+func New(config *Config, logger log.Logger) (Sink, error) {
+	sinks := make(Sinks, 0)
+
+	if config.Syslog != nil && config.Syslog.Enabled {
+		sink, err := NewSyslogSink(config.Syslog, logger)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+
+	return sinks, nil
+}
@@ -0,0 +1,137 @@
+package audit
+
+import (
+	"strconv"
+	"time"
+
+	"go-template/src/core/model"
+)
+
+// EventType is a security relevant event forwarded to the audit sinks
+type EventType string
+
+const (
+	EventLogin          EventType = "login"
+	EventLoginFailed    EventType = "login_failed"
+	EventSessionRevoked EventType = "session_revoked"
+	EventAdminAction    EventType = "admin_action"
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// severities follow CEF, 0 (lowest) to 10
+var eventSeverity = map[EventType]int{
+	EventLogin:          3,
+	EventLoginFailed:    6,
+	EventSessionRevoked: 3,
+	EventAdminAction:    5,
+}
+
+var eventName = map[EventType]string{
+	EventLogin:          "User logged in",
+	EventLoginFailed:    "Login failed",
+	EventSessionRevoked: "Session revoked",
+	EventAdminAction:    "Admin action",
+}
+
+type Event struct {
+	Type           EventType         `json:"type"`
+	Time           time.Time         `json:"time"`
+	Outcome        string            `json:"outcome"`
+	Message        string            `json:"message,omitempty"`
+	UserID         string            `json:"user_id,omitempty"`
+	UserName       string            `json:"user_name,omitempty"`
+	EmailAddress   string            `json:"email_address,omitempty"`
+	UserRoles      []string          `json:"user_roles,omitempty"`
+	SourceIP       string            `json:"source_ip,omitempty"`
+	UserAgent      string            `json:"user_agent,omitempty"`
+	ServiceCode    string            `json:"service_code,omitempty"`
+	HTTPMethod     string            `json:"http_method,omitempty"`
+	RequestURI     string            `json:"request_uri,omitempty"`
+	HTTPStatusCode int               `json:"http_status_code,omitempty"`
+	RequestNo      string            `json:"request_no,omitempty"`
+	TraceID        string            `json:"trace_id,omitempty"`
+	Extra          map[string]string `json:"extra,omitempty"`
+}
+
+// Severity returns the CEF severity of the event, failures rank one higher
+func (e *Event) Severity() int {
+	severity, ok := eventSeverity[e.Type]
+	if !ok {
+		severity = 5
+	}
+	if e.Outcome == OutcomeFailure && severity < 10 {
+		severity++
+	}
+	return severity
+}
+
+// Name returns a human readable name of the event type
+func (e *Event) Name() string {
+	if name, ok := eventName[e.Type]; ok {
+		return name
+	}
+	return string(e.Type)
+}
+
+// AdminActionEvent builds the admin action event of an activity log row
+func AdminActionEvent(activityLog *model.ActivityLog) *Event {
+	event := &Event{
+		Type:           EventAdminAction,
+		Time:           activityLog.CreatedTime,
+		Outcome:        OutcomeSuccess,
+		UserRoles:      activityLog.UserRoles,
+		SourceIP:       activityLog.IPAddress,
+		ServiceCode:    activityLog.ServiceCode,
+		HTTPMethod:     activityLog.HTTPMethod,
+		RequestURI:     activityLog.RequestURI,
+		HTTPStatusCode: activityLog.HTTPStatusCode,
+		RequestNo:      activityLog.RequestNo,
+		TraceID:        activityLog.TraceID,
+	}
+	if activityLog.HTTPStatusCode >= 400 {
+		event.Outcome = OutcomeFailure
+	}
+	if activityLog.UserID != nil {
+		event.UserID = strconv.FormatInt(*activityLog.UserID, 10)
+	}
+	if activityLog.AzureUserID != nil {
+		event.UserName = *activityLog.AzureUserID
+	}
+	if activityLog.EmailAddress != nil {
+		event.EmailAddress = *activityLog.EmailAddress
+	}
+	if activityLog.UserAgent != nil {
+		event.UserAgent = *activityLog.UserAgent
+	}
+	return event
+}
+
+// Sink receives audit events. Send must not block the caller; a sink that
+// cannot keep up drops events and reports them itself.
+type Sink interface {
+	Send(event *Event)
+	Close() error
+}
+
+// Sinks sends every event to each of its sinks
+type Sinks []Sink
+
+func (sinks Sinks) Send(event *Event) {
+	for _, sink := range sinks {
+		sink.Send(event)
+	}
+}
+
+func (sinks Sinks) Close() error {
+	var firstErr error
+	for _, sink := range sinks {
+		if err := sink.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,122 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	FormatCEF  = "cef"
+	FormatJSON = "json"
+)
+
+// Formatter renders an event as the message part of a syslog line
+type Formatter interface {
+	Format(event *Event) ([]byte, error)
+}
+
+func NewFormatter(format string, config CEFConfig) (Formatter, error) {
+	switch strings.ToLower(format) {
+	case "", FormatCEF:
+		return &cefFormatter{config: config}, nil
+	case FormatJSON:
+		return jsonFormatter{}, nil
+	default:
+		return nil, fmt.Errorf("unknown audit event format %q, expected cef or json", format)
+	}
+}
+
+type jsonFormatter struct{}
+
+func (jsonFormatter) Format(event *Event) ([]byte, error) {
+	return json.Marshal(struct {
+		*Event
+		Name     string `json:"name"`
+		Severity int    `json:"severity"`
+	}{
+		Event:    event,
+		Name:     event.Name(),
+		Severity: event.Severity(),
+	})
+}
+
+// cefFormatter writes ArcSight Common Event Format:
+// CEF:0|Vendor|Product|Version|SignatureID|Name|Severity|Extension
+type cefFormatter struct {
+	config CEFConfig
+}
+
+func (f *cefFormatter) Format(event *Event) ([]byte, error) {
+	var b strings.Builder
+
+	b.WriteString("CEF:0|")
+	for _, field := range []string{
+		f.config.Vendor,
+		f.config.Product,
+		f.config.Version,
+		string(event.Type),
+		event.Name(),
+		strconv.Itoa(event.Severity()),
+	} {
+		b.WriteString(cefHeaderEscaper.Replace(field))
+		b.WriteByte('|')
+	}
+
+	extension := []cefField{
+		{key: "rt", value: strconv.FormatInt(event.Time.UnixMilli(), 10)},
+		{key: "outcome", value: event.Outcome},
+		{key: "suid", value: event.UserID},
+		{key: "suser", value: event.UserName},
+		{key: "duser", value: event.EmailAddress},
+		{key: "src", value: event.SourceIP},
+		{key: "requestClientApplication", value: event.UserAgent},
+		{key: "requestMethod", value: event.HTTPMethod},
+		{key: "request", value: event.RequestURI},
+		{key: "msg", value: event.Message},
+		{key: "cs1", label: "requestNo", value: event.RequestNo},
+		{key: "cs2", label: "traceId", value: event.TraceID},
+		{key: "cs3", label: "serviceCode", value: event.ServiceCode},
+		{key: "cs4", label: "roles", value: strings.Join(event.UserRoles, ",")},
+	}
+	if event.HTTPStatusCode != 0 {
+		extension = append(extension, cefField{key: "cn1", label: "httpStatusCode", value: strconv.Itoa(event.HTTPStatusCode)})
+	}
+
+	// custom extensions in a stable order
+	extraKeys := make([]string, 0, len(event.Extra))
+	for key := range event.Extra {
+		extraKeys = append(extraKeys, key)
+	}
+	sort.Strings(extraKeys)
+	for _, key := range extraKeys {
+		extension = append(extension, cefField{key: key, value: event.Extra[key]})
+	}
+
+	fields := make([]string, 0, len(extension))
+	for _, field := range extension {
+		if field.value == "" {
+			continue
+		}
+		if field.label != "" {
+			fields = append(fields, field.key+"Label="+cefExtensionEscaper.Replace(field.label))
+		}
+		fields = append(fields, field.key+"="+cefExtensionEscaper.Replace(field.value))
+	}
+	b.WriteString(strings.Join(fields, " "))
+
+	return []byte(b.String()), nil
+}
+
+type cefField struct {
+	key   string
+	label string
+	value string
+}
+
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+)
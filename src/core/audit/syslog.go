@@ -0,0 +1,330 @@
+package audit
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"go-template/src/core/log"
+)
+
+// SyslogSink queues events and writes them to a syslog receiver from a
+// single goroutine. When the receiver is unreachable it reconnects with
+// exponential backoff while the queue fills; once the queue is full new
+// events are dropped and counted.
+type SyslogSink struct {
+	config    *SyslogConfig
+	logger    log.Logger
+	formatter Formatter
+	tlsConfig *tls.Config
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan *Event
+
+	conn net.Conn
+	// connClosed is closed when the receiver closes the stream conn
+	connClosed chan struct{}
+	quit       chan struct{}
+	stopped    chan struct{}
+
+	sent    atomic.Int64
+	dropped atomic.Int64
+}
+
+// SyslogSinkStats counts the events handled since start
+type SyslogSinkStats struct {
+	Queued  int   `json:"queued"`
+	Sent    int64 `json:"sent"`
+	Dropped int64 `json:"dropped"`
+}
+
+func NewSyslogSink(config *SyslogConfig, logger log.Logger) (*SyslogSink, error) {
+	switch config.Network {
+	case "udp", "tcp", "tls":
+	default:
+		return nil, fmt.Errorf("unknown audit syslog network %q, expected udp, tcp or tls", config.Network)
+	}
+
+	formatter, err := NewFormatter(config.Format, config.CEF)
+	if err != nil {
+		return nil, err
+	}
+
+	sink := &SyslogSink{
+		config:    config,
+		logger:    logger.WithFields(log.Fields{"package": "audit", "sink": "syslog"}),
+		formatter: formatter,
+		queue:     make(chan *Event, config.QueueSize),
+		quit:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+
+	if config.Network == "tls" {
+		sink.tlsConfig, err = newTLSConfig(config.TLS)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	go sink.run()
+
+	return sink, nil
+}
+
+// Send queues event without blocking, dropping it when the queue is full
+func (s *SyslogSink) Send(event *Event) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		s.drop(event)
+		return
+	}
+
+	select {
+	case s.queue <- event:
+	default:
+		s.drop(event)
+	}
+}
+
+func (s *SyslogSink) drop(event *Event) {
+	dropped := s.dropped.Add(1)
+	// log the first drop and then every hundredth so an outage does not flood the log
+	if dropped%100 == 1 {
+		s.logger.Warnf("Audit syslog queue is full or closed, dropped %s event (%d dropped so far)", event.Type, dropped)
+	}
+}
+
+func (s *SyslogSink) Stats() SyslogSinkStats {
+	return SyslogSinkStats{
+		Queued:  len(s.queue),
+		Sent:    s.sent.Load(),
+		Dropped: s.dropped.Load(),
+	}
+}
+
+// Close stops accepting events and sends the queued ones, giving up after
+// CloseTimeout
+func (s *SyslogSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.queue)
+	s.mu.Unlock()
+
+	select {
+	case <-s.stopped:
+	case <-time.After(s.config.CloseTimeout):
+		close(s.quit)
+		<-s.stopped
+	}
+
+	if remaining := len(s.queue); remaining > 0 {
+		s.dropped.Add(int64(remaining))
+		s.logger.Warnf("Audit syslog closed with %d unsent events", remaining)
+	}
+
+	return nil
+}
+
+func (s *SyslogSink) run() {
+	defer close(s.stopped)
+	defer s.disconnect()
+
+	backoff := s.config.ReconnectInterval
+	for event := range s.queue {
+		message, err := s.message(event)
+		if err != nil {
+			s.logger.Errorf("Unable to format audit event %s: %+v", event.Type, err)
+			s.dropped.Add(1)
+			continue
+		}
+
+		for {
+			err := s.write(message)
+			if err == nil {
+				s.sent.Add(1)
+				backoff = s.config.ReconnectInterval
+				break
+			}
+
+			s.logger.Warnf("Unable to send audit event to %s://%s, retrying in %s: %v",
+				s.config.Network, s.config.Address, backoff, err)
+			s.disconnect()
+
+			select {
+			case <-s.quit:
+				// the event being retried is lost with the queued ones
+				s.dropped.Add(1)
+				return
+			case <-time.After(backoff):
+			}
+
+			backoff *= 2
+			if backoff > s.config.MaxReconnectInterval {
+				backoff = s.config.MaxReconnectInterval
+			}
+		}
+	}
+}
+
+func (s *SyslogSink) write(message []byte) error {
+	// a write into a stream the receiver closed succeeds and is lost
+	select {
+	case <-s.connClosed:
+		s.logger.Infof("Audit syslog receiver %s closed the connection, reconnecting", s.config.Address)
+		s.disconnect()
+	default:
+	}
+
+	if s.conn == nil {
+		conn, err := s.dial()
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+		if s.config.Network != "udp" {
+			s.connClosed = make(chan struct{})
+			go watchClose(conn, s.connClosed)
+		}
+	}
+
+	if err := s.conn.SetWriteDeadline(time.Now().Add(s.config.WriteTimeout)); err != nil {
+		return err
+	}
+
+	// udp sends one message per datagram, streams use octet counting (RFC 6587)
+	if s.config.Network != "udp" {
+		message = append([]byte(strconv.Itoa(len(message))+" "), message...)
+	}
+
+	_, err := s.conn.Write(message)
+	return err
+}
+
+func (s *SyslogSink) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: s.config.DialTimeout}
+
+	if s.config.Network == "tls" {
+		return tls.DialWithDialer(dialer, "tcp", s.config.Address, s.tlsConfig)
+	}
+	return dialer.Dial(s.config.Network, s.config.Address)
+}
+
+// watchClose closes closed when conn ends. Receivers never send anything,
+// so the read only returns once the connection is closed by either side.
+func watchClose(conn net.Conn, closed chan struct{}) {
+	defer close(closed)
+	_, _ = io.Copy(io.Discard, conn)
+}
+
+func (s *SyslogSink) disconnect() {
+	if s.conn != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+		s.connClosed = nil
+	}
+}
+
+// message renders event as an RFC 5424 line:
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+func (s *SyslogSink) message(event *Event) ([]byte, error) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	body, err := s.formatter.Format(event)
+	if err != nil {
+		return nil, err
+	}
+
+	header := fmt.Sprintf("<%d>1 %s %s %s %d %s - ",
+		s.config.Facility*8+syslogSeverity(event),
+		event.Time.UTC().Format(rfc5424Time),
+		syslogHeaderField(s.config.Hostname, 255),
+		syslogHeaderField(s.config.AppName, 48),
+		os.Getpid(),
+		syslogHeaderField(string(event.Type), 32),
+	)
+
+	return append([]byte(header), body...), nil
+}
+
+// rfc5424Time is the TIMESTAMP of RFC 5424, which allows at most microseconds
+const rfc5424Time = "2006-01-02T15:04:05.000000Z07:00"
+
+// syslogSeverity maps the CEF severity to the syslog one
+func syslogSeverity(event *Event) int {
+	switch severity := event.Severity(); {
+	case severity >= 8:
+		return 2 // critical
+	case severity >= 7:
+		return 4 // warning
+	case severity >= 4:
+		return 5 // notice
+	default:
+		return 6 // informational
+	}
+}
+
+// syslogHeaderField returns value as printable US-ASCII without spaces,
+// or the NILVALUE
+func syslogHeaderField(value string, maxLen int) string {
+	value = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, value)
+	if value == "" {
+		return "-"
+	}
+	if len(value) > maxLen {
+		value = value[:maxLen]
+	}
+	return value
+}
+
+func newTLSConfig(config TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         config.ServerName,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+
+	if config.CAFile != "" {
+		caCert, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read audit syslog CA file")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no certificate found in %s", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.CertFile != "" || config.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to load audit syslog client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,259 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go-template/src/core/log"
+)
+
+func newTestSyslogSink(t *testing.T, network, address string) *SyslogSink {
+	t.Helper()
+
+	logger, err := log.NewLogger(nil, log.InstanceZapLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sink, err := NewSyslogSink(&SyslogConfig{
+		Enabled:              true,
+		Network:              network,
+		Address:              address,
+		Format:               FormatJSON,
+		Facility:             10,
+		AppName:              "go-template",
+		Hostname:             "test host",
+		QueueSize:            10,
+		DialTimeout:          time.Second,
+		WriteTimeout:         time.Second,
+		ReconnectInterval:    10 * time.Millisecond,
+		MaxReconnectInterval: 50 * time.Millisecond,
+		CloseTimeout:         time.Second,
+	}, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sink
+}
+
+func testEvent(message string) *Event {
+	return &Event{
+		Type:     EventLoginFailed,
+		Time:     time.Date(2025, 1, 2, 3, 4, 5, 6789, time.FixedZone("UTC+1", 3600)),
+		Outcome:  OutcomeFailure,
+		Message:  message,
+		UserName: "test-user",
+	}
+}
+
+// checkMessage checks the RFC 5424 header of a failed login and returns the
+// message of its JSON body
+func checkMessage(t *testing.T, message string) string {
+	t.Helper()
+
+	// authpriv (10) and warning (4): a failed login ranks 7 in CEF
+	header := fmt.Sprintf("<84>1 2025-01-02T02:04:05.000006Z testhost go-template %d login_failed - ", os.Getpid())
+	if !strings.HasPrefix(message, header) {
+		t.Fatalf("message = %q, want the header %q", message, header)
+	}
+
+	body := struct {
+		Message  string `json:"message"`
+		Severity int    `json:"severity"`
+	}{}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(message, header)), &body); err != nil {
+		t.Fatalf("message body is not JSON: %v", err)
+	}
+	if body.Severity != 7 {
+		t.Errorf("severity = %d, want 7", body.Severity)
+	}
+	return body.Message
+}
+
+// readFrame reads one octet-counted message (RFC 6587)
+func readFrame(t *testing.T, r *bufio.Reader) string {
+	t.Helper()
+
+	length, err := r.ReadString(' ')
+	if err != nil {
+		t.Fatalf("unable to read the message length: %v", err)
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(length, " "))
+	if err != nil {
+		t.Fatalf("message length %q is not a number", length)
+	}
+
+	message := make([]byte, n)
+	if _, err := io.ReadFull(r, message); err != nil {
+		t.Fatalf("unable to read a %d byte message: %v", n, err)
+	}
+	return string(message)
+}
+
+func accept(t *testing.T, listener net.Listener) net.Conn {
+	t.Helper()
+
+	if err := listener.(*net.TCPListener).SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("the sink did not connect: %v", err)
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func TestSyslogSinkUDP(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	sink := newTestSyslogSink(t, "udp", listener.LocalAddr().String())
+	defer sink.Close()
+
+	sink.Send(testEvent("first"))
+	sink.Send(testEvent("second"))
+
+	for _, want := range []string{"first", "second"} {
+		if err := listener.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, 64*1024)
+		n, _, err := listener.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("no datagram received: %v", err)
+		}
+		// one message per datagram, without octet counting
+		if got := checkMessage(t, string(buf[:n])); got != want {
+			t.Errorf("received %q, want %q", got, want)
+		}
+	}
+}
+
+func TestSyslogSinkTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	sink := newTestSyslogSink(t, "tcp", listener.Addr().String())
+	defer sink.Close()
+
+	sink.Send(testEvent("first"))
+	sink.Send(testEvent("second"))
+
+	conn := accept(t, listener)
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	for _, want := range []string{"first", "second"} {
+		if got := checkMessage(t, readFrame(t, reader)); got != want {
+			t.Errorf("received %q, want %q", got, want)
+		}
+	}
+}
+
+func TestSyslogSinkReconnect(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+
+	sink := newTestSyslogSink(t, "tcp", address)
+	defer sink.Close()
+
+	sink.Send(testEvent("before restart"))
+	conn := accept(t, listener)
+	if got := checkMessage(t, readFrame(t, bufio.NewReader(conn))); got != "before restart" {
+		t.Fatalf("received %q, want %q", got, "before restart")
+	}
+
+	// the receiver restarts, dropping the connection of the sink
+	_ = conn.Close()
+	_ = listener.Close()
+	listener, err = net.Listen("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	// give the sink a moment to see the end of its connection
+	time.Sleep(100 * time.Millisecond)
+
+	sink.Send(testEvent("after restart"))
+	conn = accept(t, listener)
+	defer conn.Close()
+	if got := checkMessage(t, readFrame(t, bufio.NewReader(conn))); got != "after restart" {
+		t.Errorf("received %q, want %q", got, "after restart")
+	}
+
+	if stats := sink.Stats(); stats.Sent != 2 || stats.Dropped != 0 {
+		t.Errorf("stats = %+v, want 2 sent and none dropped", stats)
+	}
+}
+
+func TestSyslogSinkQueueFull(t *testing.T) {
+	// a closed listener leaves an address nothing answers on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	_ = listener.Close()
+
+	logger, err := log.NewLogger(nil, log.InstanceZapLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink, err := NewSyslogSink(&SyslogConfig{
+		Network:              "tcp",
+		Address:              address,
+		Format:               FormatJSON,
+		QueueSize:            2,
+		DialTimeout:          time.Second,
+		WriteTimeout:         time.Second,
+		ReconnectInterval:    time.Hour,
+		MaxReconnectInterval: time.Hour,
+		CloseTimeout:         50 * time.Millisecond,
+	}, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// one event is retried, two are queued, the rest is dropped
+	const events = 10
+	for i := 0; i < events; i++ {
+		sink.Send(testEvent(strconv.Itoa(i)))
+	}
+
+	if stats := sink.Stats(); stats.Sent != 0 || stats.Dropped < events-3 {
+		t.Errorf("stats = %+v, want nothing sent and at least %d dropped", stats, events-3)
+	}
+
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if stats := sink.Stats(); stats.Sent != 0 || stats.Dropped != events {
+		t.Errorf("stats after Close = %+v, want every event dropped", stats)
+	}
+
+	// events sent after Close are dropped too
+	sink.Send(testEvent("closed"))
+	if dropped := sink.Stats().Dropped; dropped != events+1 {
+		t.Errorf("dropped = %d after sending to a closed sink, want %d", dropped, events+1)
+	}
+}
@@ -1,29 +1,37 @@
 package azure_ad
 
 import (
+	"context"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	kiotaauth "github.com/microsoft/kiota-authentication-azure-go"
 	graph "github.com/microsoftgraph/msgraph-sdk-go"
+	graphcore "github.com/microsoftgraph/msgraph-sdk-go-core"
 	"go-template/src/core/log"
+	"go-template/src/otel"
 )
 
 type AzureADService interface {
-	CreateUserAD(params CreateUserRequest) (string, error)
-	GetListGroup() ([]GetGroupResponse, error)
-	GetUserListGroup(azureUserID string) ([]GetGroupResponse, error)
-	AzureLogin(params AzureLoginParams) (*ProfileMeResponse, string, error)
-	GetProfileMe(accessToken string) (*ProfileMeResponse, error)
-	GetMeProfilePic(accessToken string) (string, error)
-	AddUserToGroup(azureUserID string, azureGroupID string) error
-	RemoveUserFromGroup(azureUserID string, azureGroupID string) error
-	EnableUserToAzureAD(azureUserID string, enable bool) error
-	DeleteUserToAzureAD(azureUserID string) error
-	AzureLoginWithAccessToken(params AzureLoginWithADAccessTokenParams) (*ProfileMeResponse, string, error)
+	CreateUserAD(ctx context.Context, params CreateUserRequest) (string, error)
+	GetListGroup(ctx context.Context) ([]GetGroupResponse, error)
+	GetUserListGroup(ctx context.Context, azureUserID string) ([]GetGroupResponse, error)
+	AzureLogin(ctx context.Context, params AzureLoginParams) (*ProfileMeResponse, string, error)
+	GetProfileMe(ctx context.Context, accessToken string) (*ProfileMeResponse, error)
+	GetMeProfilePic(ctx context.Context, accessToken string) (string, error)
+	AddUserToGroup(ctx context.Context, azureUserID string, azureGroupID string) error
+	RemoveUserFromGroup(ctx context.Context, azureUserID string, azureGroupID string) error
+	EnableUserToAzureAD(ctx context.Context, azureUserID string, enable bool) error
+	DeleteUserToAzureAD(ctx context.Context, azureUserID string) error
+	AzureLoginWithAccessToken(ctx context.Context, params AzureLoginWithADAccessTokenParams) (*ProfileMeResponse, string, error)
 }
 
 type AzureADServiceClient struct {
 	logger       log.Logger
 	app          *azidentity.ClientSecretCredential
 	graphService *graph.GraphServiceClient
+	httpClient   *http.Client
 	config       *Config
 }
 
@@ -40,26 +48,40 @@ func New(config *Config, logger log.Logger) (azureADServiceClient *AzureADServic
 	// logger.Debugf("\n AD Connection TenantID : %s \n", config.TenantID)
 	// logger.Debugf("\n AD Connection GraphEndpoint : %s \n", config.GraphEndpoint)
 
-	azureADServiceClient.app = initMSALApp(config)
+	azureADServiceClient.httpClient = otel.NewHTTPClient()
+	azureADServiceClient.app = initMSALApp(config, azureADServiceClient.httpClient)
 	azureADServiceClient.graphService = initGraphClient(azureADServiceClient.app)
 
 	return azureADServiceClient, nil
 }
 
-func initMSALApp(config *Config) *azidentity.ClientSecretCredential {
+func initMSALApp(config *Config, httpClient *http.Client) *azidentity.ClientSecretCredential {
 	cred, _ := azidentity.NewClientSecretCredential(
 		config.TenantID,
 		config.ClientID,
 		config.ClientSecret,
-		nil,
+		&azidentity.ClientSecretCredentialOptions{
+			ClientOptions: azcore.ClientOptions{
+				Transport: httpClient,
+			},
+		},
 	)
 
 	return cred
 }
 
+// initGraphClient builds the Graph client on top of the SDK default middleware
+// pipeline, wrapped with the tracing transport so Graph calls show up as spans.
 func initGraphClient(app *azidentity.ClientSecretCredential) *graph.GraphServiceClient {
-	graphClient, _ := graph.NewGraphServiceClientWithCredentials(
-		app, nil)
+	authProvider, _ := kiotaauth.NewAzureIdentityAuthenticationProviderWithScopes(
+		app, []string{"https://graph.microsoft.com/.default"})
+
+	clientOptions := graph.GetDefaultClientOptions()
+	httpClient := graphcore.GetDefaultClient(&clientOptions)
+	httpClient.Transport = otel.NewTransport(httpClient.Transport)
+
+	adapter, _ := graph.NewGraphRequestAdapterWithParseNodeFactoryAndSerializationWriterFactoryAndHttpClient(
+		authProvider, nil, nil, httpClient)
 
-	return graphClient
+	return graph.NewGraphServiceClient(adapter)
 }
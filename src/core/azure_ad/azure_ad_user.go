@@ -17,7 +17,7 @@ type CreateUserResponse struct {
 	UserID string `json:"user_id"`
 }
 
-func (AzureADServiceClient *AzureADServiceClient) CreateUserAD(params CreateUserRequest) (string, error) {
+func (AzureADServiceClient *AzureADServiceClient) CreateUserAD(ctx context.Context, params CreateUserRequest) (string, error) {
 	requestBody := graphmodels.NewUser()
 	accountEnabled := true
 	requestBody.SetAccountEnabled(&accountEnabled)
@@ -34,7 +34,7 @@ func (AzureADServiceClient *AzureADServiceClient) CreateUserAD(params CreateUser
 	passwordProfile.SetPassword(&password)
 	requestBody.SetPasswordProfile(passwordProfile)
 
-	users, err := AzureADServiceClient.graphService.Users().Post(context.Background(), requestBody, nil)
+	users, err := AzureADServiceClient.graphService.Users().Post(ctx, requestBody, nil)
 	if err != nil {
 		return "", err
 	}
@@ -42,12 +42,12 @@ func (AzureADServiceClient *AzureADServiceClient) CreateUserAD(params CreateUser
 	return *users.GetId(), nil
 }
 
-func (AzureADServiceClient *AzureADServiceClient) EnableUserToAzureAD(azureUserID string, enable bool) error {
+func (AzureADServiceClient *AzureADServiceClient) EnableUserToAzureAD(ctx context.Context, azureUserID string, enable bool) error {
 	requestBody := graphmodels.NewUser()
 	accountEnabled := enable
 	requestBody.SetAccountEnabled(&accountEnabled)
 
-	_, err := AzureADServiceClient.graphService.Users().ByUserId(azureUserID).Patch(context.Background(), requestBody, nil)
+	_, err := AzureADServiceClient.graphService.Users().ByUserId(azureUserID).Patch(ctx, requestBody, nil)
 	if err != nil {
 		return err
 	}
@@ -55,8 +55,8 @@ func (AzureADServiceClient *AzureADServiceClient) EnableUserToAzureAD(azureUserI
 	return nil
 }
 
-func (AzureADServiceClient *AzureADServiceClient) DeleteUserToAzureAD(azureUserID string) error {
-	err := AzureADServiceClient.graphService.Users().ByUserId(azureUserID).Delete(context.Background(), nil)
+func (AzureADServiceClient *AzureADServiceClient) DeleteUserToAzureAD(ctx context.Context, azureUserID string) error {
+	err := AzureADServiceClient.graphService.Users().ByUserId(azureUserID).Delete(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -21,7 +21,7 @@ type GetGroupResponse struct {
 	GroupDescription string
 }
 
-func (AzureADServiceClient *AzureADServiceClient) GetListGroup() ([]GetGroupResponse, error) {
+func (AzureADServiceClient *AzureADServiceClient) GetListGroup(ctx context.Context) ([]GetGroupResponse, error) {
 	//Create options to set $top=999
 	top := int32(999)
 	requestParams := &groups.GroupsRequestBuilderGetQueryParameters{
@@ -34,7 +34,7 @@ func (AzureADServiceClient *AzureADServiceClient) GetListGroup() ([]GetGroupResp
 	request := AzureADServiceClient.graphService.Groups()
 	var resp []GetGroupResponse
 
-	responsible, err := request.Get(context.Background(), requestConfig)
+	responsible, err := request.Get(ctx, requestConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -65,7 +65,7 @@ func (AzureADServiceClient *AzureADServiceClient) GetListGroup() ([]GetGroupResp
 		nextPageRequest := groups.NewGroupsRequestBuilder(*nextLink, AzureADServiceClient.graphService.RequestAdapter)
 
 		// Fetch the next page
-		nextResponse, err := nextPageRequest.Get(context.Background(), nil)
+		nextResponse, err := nextPageRequest.Get(ctx, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -97,9 +97,9 @@ func (AzureADServiceClient *AzureADServiceClient) GetListGroup() ([]GetGroupResp
 	return resp, nil
 }
 
-func (AzureADServiceClient *AzureADServiceClient) GetUserListGroup(azureUserID string) ([]GetGroupResponse, error) {
+func (AzureADServiceClient *AzureADServiceClient) GetUserListGroup(ctx context.Context, azureUserID string) ([]GetGroupResponse, error) {
 
-	responsible, err := AzureADServiceClient.graphService.Users().ByUserId(azureUserID).MemberOf().Get(context.Background(), nil)
+	responsible, err := AzureADServiceClient.graphService.Users().ByUserId(azureUserID).MemberOf().Get(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -118,12 +118,12 @@ func (AzureADServiceClient *AzureADServiceClient) GetUserListGroup(azureUserID s
 	return resp, nil
 }
 
-func (AzureADServiceClient *AzureADServiceClient) AddUserToGroup(azureUserID string, azureGroupID string) error {
+func (AzureADServiceClient *AzureADServiceClient) AddUserToGroup(ctx context.Context, azureUserID string, azureGroupID string) error {
 	requestBody := graphmodels.NewReferenceCreate()
 	odataId := fmt.Sprintf("https://graph.microsoft.com/v1.0/directoryObjects/%s", azureUserID)
 	requestBody.SetOdataId(&odataId)
 
-	err := AzureADServiceClient.graphService.Groups().ByGroupId(azureGroupID).Members().Ref().Post(context.Background(), requestBody, nil)
+	err := AzureADServiceClient.graphService.Groups().ByGroupId(azureGroupID).Members().Ref().Post(ctx, requestBody, nil)
 	if err != nil {
 		AzureADServiceClient.logger.Errorf("AddUserToGroup Error : %s", err)
 		return err
@@ -132,8 +132,8 @@ func (AzureADServiceClient *AzureADServiceClient) AddUserToGroup(azureUserID str
 	return nil
 }
 
-func (AzureADServiceClient *AzureADServiceClient) RemoveUserFromGroup(azureUserID string, azureGroupID string) error {
-	err := AzureADServiceClient.graphService.Groups().ByGroupId(azureGroupID).Members().ByDirectoryObjectId(azureUserID).Ref().Delete(context.Background(), nil)
+func (AzureADServiceClient *AzureADServiceClient) RemoveUserFromGroup(ctx context.Context, azureUserID string, azureGroupID string) error {
+	err := AzureADServiceClient.graphService.Groups().ByGroupId(azureGroupID).Members().ByDirectoryObjectId(azureUserID).Ref().Delete(ctx, nil)
 	if err != nil {
 		return err
 	}
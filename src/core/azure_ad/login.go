@@ -1,6 +1,7 @@
 package azure_ad
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -37,7 +38,7 @@ type AzureLoginParams struct {
 	//AccessToken string
 }
 
-func (AzureADServiceClient *AzureADServiceClient) AzureLogin(params AzureLoginParams) (*ProfileMeResponse, string, error) {
+func (AzureADServiceClient *AzureADServiceClient) AzureLogin(ctx context.Context, params AzureLoginParams) (*ProfileMeResponse, string, error) {
 
 	azureURL := "https://login.microsoftonline.com"
 	tenantID := "/" + AzureADServiceClient.config.TenantID
@@ -53,12 +54,11 @@ func (AzureADServiceClient *AzureADServiceClient) AzureLogin(params AzureLoginPa
 	u.Path = tenantID + resource
 	urlStr := u.String()
 
-	client := &http.Client{}
-	r, _ := http.NewRequest(http.MethodPost, urlStr, strings.NewReader(data.Encode()))
+	r, _ := http.NewRequestWithContext(ctx, http.MethodPost, urlStr, strings.NewReader(data.Encode()))
 	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
 
-	resp, err := client.Do(r)
+	resp, err := AzureADServiceClient.httpClient.Do(r)
 	if err != nil {
 		return nil, "", err
 	}
@@ -83,12 +83,12 @@ func (AzureADServiceClient *AzureADServiceClient) AzureLogin(params AzureLoginPa
 		return nil, "", errors.New("Invalid code")
 	}
 
-	profile, err := AzureADServiceClient.GetProfileMe(token.AccessToken)
+	profile, err := AzureADServiceClient.GetProfileMe(ctx, token.AccessToken)
 	if err != nil {
 		return nil, "", err
 	}
 
-	profilePic, err := AzureADServiceClient.GetMeProfilePic(token.AccessToken)
+	profilePic, err := AzureADServiceClient.GetMeProfilePic(ctx, token.AccessToken)
 	if err != nil {
 		return nil, "", err
 	}
@@ -102,13 +102,13 @@ type AzureLoginWithADAccessTokenParams struct {
 	//AccessToken string
 }
 
-func (AzureADServiceClient *AzureADServiceClient) AzureLoginWithAccessToken(params AzureLoginWithADAccessTokenParams) (*ProfileMeResponse, string, error) {
-	profile, err := AzureADServiceClient.GetProfileMe(params.AccessToken)
+func (AzureADServiceClient *AzureADServiceClient) AzureLoginWithAccessToken(ctx context.Context, params AzureLoginWithADAccessTokenParams) (*ProfileMeResponse, string, error) {
+	profile, err := AzureADServiceClient.GetProfileMe(ctx, params.AccessToken)
 	if err != nil {
 		return nil, "", err
 	}
 
-	profilePic, err := AzureADServiceClient.GetMeProfilePic(params.AccessToken)
+	profilePic, err := AzureADServiceClient.GetMeProfilePic(ctx, params.AccessToken)
 	if err != nil {
 		return nil, "", err
 	}
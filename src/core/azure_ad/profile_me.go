@@ -1,6 +1,7 @@
 package azure_ad
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -37,14 +38,13 @@ type ProfileMeError struct {
 	} `json:"error"`
 }
 
-func (AzureADServiceClient *AzureADServiceClient) GetProfileMe(accessToken string) (*ProfileMeResponse, error) {
+func (AzureADServiceClient *AzureADServiceClient) GetProfileMe(ctx context.Context, accessToken string) (*ProfileMeResponse, error) {
 	graphUrl := "https://graph.microsoft.com/v1.0/me"
-	req, _ := http.NewRequest(http.MethodGet, graphUrl, nil)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, graphUrl, nil)
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 
-	httpClient := &http.Client{}
-	resp, err := httpClient.Do(req)
+	resp, err := AzureADServiceClient.httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -71,14 +71,13 @@ func (AzureADServiceClient *AzureADServiceClient) GetProfileMe(accessToken strin
 	return &profile, nil
 }
 
-func (AzureADServiceClient *AzureADServiceClient) GetMeProfilePic(accessToken string) (string, error) {
+func (AzureADServiceClient *AzureADServiceClient) GetMeProfilePic(ctx context.Context, accessToken string) (string, error) {
 	graphUrl := "https://graph.microsoft.com/v1.0/me/photo/$value"
-	req, _ := http.NewRequest(http.MethodGet, graphUrl, nil)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, graphUrl, nil)
 	req.Header.Set("Content-Type", "image/jpg")
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 
-	httpClient := &http.Client{}
-	resp, err := httpClient.Do(req)
+	resp, err := AzureADServiceClient.httpClient.Do(req)
 	if err != nil {
 		return "", err
 	}
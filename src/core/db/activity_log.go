@@ -1,5 +1,28 @@
 package db
 
+import (
+	"context"
+	"io"
+	"time"
+
+	"go-template/src/core/listquery"
+	"go-template/src/core/model"
+)
+
 type DBActivityLogInterface interface {
-	CreateActivityLog(serviceCode, requestNo string, requestBody, responseBody []byte) error
+	CreateActivityLog(ctx context.Context, activityLog *model.ActivityLog) error
+	InquiryActivityLog(ctx context.Context, filter model.ActivityLogFilter, list *listquery.List) ([]*model.ActivityLog, *model.Pagination, error)
+
+	EnsureActivityLogPartitions(ctx context.Context, from time.Time, months int) ([]string, error)
+	ListActivityLogPartitions(ctx context.Context) ([]*model.ActivityLogPartition, error)
+	ExportActivityLogPartition(ctx context.Context, month time.Time, w io.Writer) (int64, error)
+	DropActivityLogPartition(ctx context.Context, month time.Time) error
+	RestoreActivityLogArchive(ctx context.Context, tableName string, r io.Reader) (int64, error)
+
+	ListActivityLogChainHeads(ctx context.Context, from, to time.Time) ([]*model.ActivityLogChainHead, error)
+	ListUncheckpointedActivityLogChainHeads(ctx context.Context) ([]*model.ActivityLogChainHead, error)
+	WalkActivityLogChain(ctx context.Context, day time.Time, fn func(activityLog *model.ActivityLog) error) error
+	CreateActivityLogCheckpoint(ctx context.Context, checkpoint *model.ActivityLogCheckpoint) error
+	ListActivityLogCheckpoints(ctx context.Context, day time.Time) ([]*model.ActivityLogCheckpoint, error)
+	ListActivityLogCheckpointDays(ctx context.Context, from, to time.Time) ([]time.Time, error)
 }
@@ -1,14 +1,15 @@
 package db
 
 import (
+	"context"
 	"time"
 
 	"go-template/src/core/model"
 )
 
 type DBApiKeysInterface interface {
-	InsertApiKeys(apiKeysList []model.ApiKey, isRoot bool) error
-	VerifyApiKey(key string, newExpireTime time.Time) ([]*model.ApiKey, error)
-	DeleteExpireApiKey() error
-	DeleteApiKey(key string) error
+	InsertApiKeys(ctx context.Context, apiKeysList []model.ApiKey, isRoot bool) error
+	VerifyApiKey(ctx context.Context, key string, newExpireTime time.Time) ([]*model.ApiKey, error)
+	DeleteExpireApiKey(ctx context.Context) error
+	DeleteApiKey(ctx context.Context, key string) error
 }
@@ -22,8 +22,7 @@ func InitConfig() (*Config, error) {
 		panic("Database.Type not set")
 	} else {
 		switch config.DBType {
-		case "postgres":
-		// case "tidb":
+		case "postgres", "mysql", "tidb", "memory":
 		default:
 			panic("Unsupported database type")
 		}
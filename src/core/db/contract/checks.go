@@ -0,0 +1,411 @@
+package contract
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"go-template/src/core/audit"
+	"go-template/src/core/db"
+	"go-template/src/core/listquery"
+	"go-template/src/core/model"
+	"go-template/src/core/seed"
+)
+
+var activityLogListSchema = &listquery.Schema{
+	Fields: map[string]listquery.Field{
+		"id":           {Column: "id", Type: listquery.Int, Sortable: true},
+		"created_time": {Column: "created_time", Type: listquery.Time, Sortable: true},
+	},
+	DefaultSort:  "-created_time",
+	TieBreaker:   "id",
+	DefaultLimit: 20,
+	MaxLimit:     500,
+}
+
+func newApiKey(expireTime time.Time) model.ApiKey {
+	return model.ApiKey{
+		Key:          uniqueName("key"),
+		AzureUserID:  uniqueName("azure-user"),
+		UserID:       42,
+		EmailAddress: "contract@example.com",
+		UserRoleName: "ADMIN_ROOT",
+		ExpireTime:   expireTime.UTC().Truncate(time.Second),
+	}
+}
+
+func checkApiKeys(ctx context.Context, tx db.DB) error {
+	apiKey := newApiKey(time.Now().Add(time.Hour))
+	if err := tx.InsertApiKeys(ctx, []model.ApiKey{apiKey}, false); err != nil {
+		return err
+	}
+
+	newExpireTime := time.Now().Add(2 * time.Hour).UTC().Truncate(time.Second)
+	result, err := tx.VerifyApiKey(ctx, apiKey.Key, newExpireTime)
+	if err != nil {
+		return err
+	}
+	if len(result) != 1 {
+		return fmt.Errorf("VerifyApiKey returned %d keys, expected 1", len(result))
+	}
+	if result[0].Key != apiKey.Key || result[0].UserID != apiKey.UserID || result[0].EmailAddress != apiKey.EmailAddress {
+		return fmt.Errorf("VerifyApiKey returned %+v, expected %+v", result[0], apiKey)
+	}
+	if !result[0].ExpireTime.Equal(newExpireTime) {
+		return fmt.Errorf("VerifyApiKey left expire_time at %s, expected %s", result[0].ExpireTime, newExpireTime)
+	}
+
+	if err := tx.DeleteApiKey(ctx, apiKey.Key); err != nil {
+		return err
+	}
+	result, err = tx.VerifyApiKey(ctx, apiKey.Key, newExpireTime)
+	if err != nil {
+		return err
+	}
+	if len(result) != 0 {
+		return fmt.Errorf("VerifyApiKey returned a deleted key")
+	}
+
+	return nil
+}
+
+func checkDeleteExpiredApiKeys(ctx context.Context, tx db.DB) error {
+	expired := newApiKey(time.Now().Add(-time.Hour))
+	valid := newApiKey(time.Now().Add(time.Hour))
+	if err := tx.InsertApiKeys(ctx, []model.ApiKey{expired, valid}, false); err != nil {
+		return err
+	}
+
+	// an expired key is not extended
+	result, err := tx.VerifyApiKey(ctx, expired.Key, time.Now().Add(time.Hour))
+	if err != nil {
+		return err
+	}
+	if len(result) != 1 || !result[0].ExpireTime.Equal(expired.ExpireTime) {
+		return fmt.Errorf("VerifyApiKey extended an expired key")
+	}
+
+	if err := tx.DeleteExpireApiKey(ctx); err != nil {
+		return err
+	}
+
+	result, err = tx.VerifyApiKey(ctx, expired.Key, time.Now().Add(time.Hour))
+	if err != nil {
+		return err
+	}
+	if len(result) != 0 {
+		return fmt.Errorf("DeleteExpireApiKey left the expired key")
+	}
+
+	result, err = tx.VerifyApiKey(ctx, valid.Key, valid.ExpireTime)
+	if err != nil {
+		return err
+	}
+	if len(result) != 1 {
+		return fmt.Errorf("DeleteExpireApiKey deleted a valid key")
+	}
+
+	return nil
+}
+
+// createActivityLogs creates count rows sharing a new request number
+func createActivityLogs(ctx context.Context, tx db.DB, count int) ([]*model.ActivityLog, error) {
+	requestNo := uniqueName("request")
+	userID := int64(42)
+	responseCode := 0
+	userAgent := "contract"
+
+	activityLogs := make([]*model.ActivityLog, 0, count)
+	for i := 0; i < count; i++ {
+		activityLog := &model.ActivityLog{
+			RequestNo:      requestNo,
+			ServiceCode:    "CONTRACT",
+			UserID:         &userID,
+			UserRoles:      []string{"ADMIN_ROOT", "USER"},
+			HTTPMethod:     "GET",
+			RequestURI:     fmt.Sprintf("/contract/%d", i),
+			HTTPStatusCode: 200,
+			ResponseCode:   &responseCode,
+			DurationMs:     int64(i),
+			IPAddress:      "127.0.0.1",
+			UserAgent:      &userAgent,
+			CreatedTime:    time.Now().Add(time.Duration(i) * time.Millisecond),
+		}
+		if err := tx.CreateActivityLog(ctx, activityLog); err != nil {
+			return nil, err
+		}
+		activityLogs = append(activityLogs, activityLog)
+	}
+
+	return activityLogs, nil
+}
+
+func checkActivityLog(ctx context.Context, tx db.DB) error {
+	created, err := createActivityLogs(ctx, tx, 3)
+	if err != nil {
+		return err
+	}
+
+	list, err := activityLogListSchema.Compile(&listquery.Request{Limit: 2})
+	if err != nil {
+		return err
+	}
+
+	filter := model.ActivityLogFilter{RequestNo: created[0].RequestNo}
+	result, pagination, err := tx.InquiryActivityLog(ctx, filter, list)
+	if err != nil {
+		return err
+	}
+	if pagination.Total != 3 || !pagination.HasMore {
+		return fmt.Errorf("InquiryActivityLog returned total %d, has more %t, expected 3, true", pagination.Total, pagination.HasMore)
+	}
+	if len(result) != 2 {
+		return fmt.Errorf("InquiryActivityLog returned %d rows, expected the limit of 2", len(result))
+	}
+
+	// newest first
+	last := created[len(created)-1]
+	if result[0].RequestURI != last.RequestURI || !result[0].CreatedTime.Equal(last.CreatedTime) {
+		return fmt.Errorf("InquiryActivityLog returned %s at %s first, expected %s at %s",
+			result[0].RequestURI, result[0].CreatedTime, last.RequestURI, last.CreatedTime)
+	}
+	if len(result[0].UserRoles) != 2 || result[0].UserRoles[0] != "ADMIN_ROOT" || result[0].UserRoles[1] != "USER" {
+		return fmt.Errorf("InquiryActivityLog returned user roles %v", result[0].UserRoles)
+	}
+	if result[0].UserID == nil || *result[0].UserID != 42 || result[0].EmailAddress != nil {
+		return fmt.Errorf("InquiryActivityLog did not keep nullable columns")
+	}
+
+	return nil
+}
+
+func checkActivityLogChain(ctx context.Context, tx db.DB) error {
+	created, err := createActivityLogs(ctx, tx, 2)
+	if err != nil {
+		return err
+	}
+
+	day := audit.ChainDate(created[0].CreatedTime)
+	found := 0
+	var prevSeq int64
+	var prevHash string
+	err = tx.WalkActivityLogChain(ctx, day, func(activityLog *model.ActivityLog) error {
+		if activityLog.ChainSeq != prevSeq+1 || activityLog.PrevHash != prevHash {
+			return fmt.Errorf("row %d does not follow row %d", activityLog.ChainSeq, prevSeq)
+		}
+		if activityLog.RowHash != audit.Hash(activityLog.PrevHash, activityLog) {
+			return fmt.Errorf("row %d does not match its hash as read back", activityLog.ChainSeq)
+		}
+		if activityLog.RequestNo == created[0].RequestNo {
+			found++
+		}
+		prevSeq = activityLog.ChainSeq
+		prevHash = activityLog.RowHash
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if found != len(created) {
+		return fmt.Errorf("WalkActivityLogChain returned %d of the %d rows created", found, len(created))
+	}
+
+	heads, err := tx.ListActivityLogChainHeads(ctx, day, day)
+	if err != nil {
+		return err
+	}
+	if len(heads) != 1 || heads[0].LastSeq != prevSeq || heads[0].LastHash != prevHash {
+		return fmt.Errorf("ListActivityLogChainHeads does not end at row %d", prevSeq)
+	}
+
+	return nil
+}
+
+func checkActivityLogCheckpoint(ctx context.Context, tx db.DB) error {
+	created, err := createActivityLogs(ctx, tx, 1)
+	if err != nil {
+		return err
+	}
+	day := audit.ChainDate(created[0].CreatedTime)
+
+	heads, err := tx.ListUncheckpointedActivityLogChainHeads(ctx)
+	if err != nil {
+		return err
+	}
+	var head *model.ActivityLogChainHead
+	for _, h := range heads {
+		if h.ChainDate.Equal(day) {
+			head = h
+		}
+	}
+	if head == nil {
+		return fmt.Errorf("ListUncheckpointedActivityLogChainHeads is missing %s", day.Format(time.DateOnly))
+	}
+
+	checkpoint := &model.ActivityLogCheckpoint{
+		ChainDate: head.ChainDate,
+		ChainSeq:  head.LastSeq,
+		RowHash:   head.LastHash,
+		KeyID:     "contract",
+		Signature: "contract",
+	}
+	// a second checkpoint of the same link is ignored
+	for i := 0; i < 2; i++ {
+		if err := tx.CreateActivityLogCheckpoint(ctx, checkpoint); err != nil {
+			return err
+		}
+	}
+
+	checkpoints, err := tx.ListActivityLogCheckpoints(ctx, day)
+	if err != nil {
+		return err
+	}
+	matching := 0
+	for _, c := range checkpoints {
+		if c.ChainSeq == head.LastSeq && c.RowHash == head.LastHash {
+			matching++
+		}
+	}
+	if matching != 1 {
+		return fmt.Errorf("ListActivityLogCheckpoints returned %d checkpoints of row %d, expected 1", matching, head.LastSeq)
+	}
+
+	days, err := tx.ListActivityLogCheckpointDays(ctx, day, day)
+	if err != nil {
+		return err
+	}
+	if len(days) != 1 || !days[0].Equal(day) {
+		return fmt.Errorf("ListActivityLogCheckpointDays(%s, %s) returned %v", day.Format(time.DateOnly), day.Format(time.DateOnly), days)
+	}
+
+	heads, err = tx.ListUncheckpointedActivityLogChainHeads(ctx)
+	if err != nil {
+		return err
+	}
+	for _, h := range heads {
+		if h.ChainDate.Equal(day) {
+			return fmt.Errorf("ListUncheckpointedActivityLogChainHeads still lists %s", day.Format(time.DateOnly))
+		}
+	}
+
+	return nil
+}
+
+func checkDataChangeLog(ctx context.Context, tx db.DB) error {
+	result, pagination, err := tx.InquiryDataChangeLog(ctx, "api_keys", uniqueName("record"), 1, 20)
+	if err != nil {
+		return err
+	}
+	if len(result) != 0 || pagination.Total != 0 || pagination.HasMore {
+		return fmt.Errorf("InquiryDataChangeLog returned changes of a record that never existed")
+	}
+	return nil
+}
+
+func checkSavepoint(ctx context.Context, tx db.DB) error {
+	outer := newApiKey(time.Now().Add(time.Hour))
+	inner := newApiKey(time.Now().Add(time.Hour))
+	if err := tx.InsertApiKeys(ctx, []model.ApiKey{outer}, false); err != nil {
+		return err
+	}
+
+	errInner := errors.New("inner rollback")
+	err := tx.WithTx(ctx, func(tx db.DB) error {
+		if err := tx.InsertApiKeys(ctx, []model.ApiKey{inner}, false); err != nil {
+			return err
+		}
+		return errInner
+	})
+	if !errors.Is(err, errInner) {
+		return fmt.Errorf("nested WithTx returned %v, expected the error of fn", err)
+	}
+
+	for key, expected := range map[string]int{outer.Key: 1, inner.Key: 0} {
+		result, err := tx.VerifyApiKey(ctx, key, time.Now().Add(time.Hour))
+		if err != nil {
+			return err
+		}
+		if len(result) != expected {
+			return fmt.Errorf("found %d rows of key %s after the savepoint rolled back, expected %d", len(result), key, expected)
+		}
+	}
+
+	return nil
+}
+
+func checkRollback(ctx context.Context, database db.DB) error {
+	apiKey := newApiKey(time.Now().Add(time.Hour))
+	err := database.WithTx(ctx, func(tx db.DB) error {
+		if err := tx.InsertApiKeys(ctx, []model.ApiKey{apiKey}, false); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		return fmt.Errorf("WithTx returned %v, expected the error of fn", err)
+	}
+
+	result, err := database.VerifyApiKey(ctx, apiKey.Key, time.Now().Add(time.Hour))
+	if err != nil {
+		return err
+	}
+	if len(result) != 0 {
+		// leave the database as it was
+		_ = database.DeleteApiKey(ctx, apiKey.Key)
+		return fmt.Errorf("a rolled back insert was committed")
+	}
+
+	return nil
+}
+
+func checkReadOnly(ctx context.Context, database db.DB) error {
+	apiKey := newApiKey(time.Now().Add(time.Hour))
+	err := database.WithTx(ctx, func(tx db.DB) error {
+		return tx.InsertApiKeys(ctx, []model.ApiKey{apiKey}, false)
+	}, db.WithReadOnly())
+	if err == nil {
+		_ = database.DeleteApiKey(ctx, apiKey.Key)
+		return fmt.Errorf("a read-only transaction accepted an insert")
+	}
+
+	return nil
+}
+
+func checkSeed(ctx context.Context, tx db.DB) error {
+	apiKey := newApiKey(time.Now().Add(time.Hour))
+	tables := []*seed.Table{{
+		Table: "api_keys",
+		Key:   []string{"key"},
+		Rows: []map[string]any{{
+			"key":            apiKey.Key,
+			"azure_user_id":  apiKey.AzureUserID,
+			"user_id":        apiKey.UserID,
+			"email_address":  apiKey.EmailAddress,
+			"user_role_name": apiKey.UserRoleName,
+			"expire_time":    apiKey.ExpireTime,
+		}},
+	}}
+
+	expected := []string{seed.ActionInsert, seed.ActionInsert, seed.ActionUnchanged}
+	for i, dryRun := range []bool{true, false, false} {
+		changes, err := tx.Seed(ctx, tables, dryRun)
+		if err != nil {
+			return err
+		}
+		if len(changes) != 1 || changes[0].Action != expected[i] {
+			return fmt.Errorf("Seed run %d returned %s, expected %s", i+1, seed.Summary(changes), expected[i])
+		}
+	}
+
+	tables[0].Rows[0]["email_address"] = "changed@example.com"
+	changes, err := tx.Seed(ctx, tables, false)
+	if err != nil {
+		return err
+	}
+	if len(changes) != 1 || changes[0].Action != seed.ActionUpdate || len(changes[0].Columns) != 1 {
+		return fmt.Errorf("Seed of a changed row returned %s, expected one updated column", seed.Summary(changes))
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+// Package contract checks that a db.DB behaves the way the service layer
+// relies on, so every backend is held to the same behaviour. Each check runs
+// in a transaction that is rolled back, leaving the database as it was.
+package contract
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"go-template/src/core/db"
+)
+
+// Check is one behaviour of db.DB. Run gets a transaction of the database
+// under test and returns an error describing the first difference found.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context, tx db.DB) error
+	// OwnTx checks get the database itself and must leave it as it was
+	OwnTx bool
+}
+
+type Result struct {
+	Name       string `json:"name"`
+	Passed     bool   `json:"passed"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// Checks lists every check, in the order Run runs them
+var Checks = []Check{
+	{Name: "api_keys/insert_verify_delete", Run: checkApiKeys},
+	{Name: "api_keys/delete_expired", Run: checkDeleteExpiredApiKeys},
+	{Name: "activity_log/create_inquiry", Run: checkActivityLog},
+	{Name: "activity_log/hash_chain", Run: checkActivityLogChain},
+	{Name: "activity_log/checkpoint", Run: checkActivityLogCheckpoint},
+	{Name: "data_change_log/inquiry", Run: checkDataChangeLog},
+	{Name: "tx/savepoint_rollback", Run: checkSavepoint},
+	{Name: "tx/rollback", Run: checkRollback, OwnTx: true},
+	{Name: "tx/read_only", Run: checkReadOnly, OwnTx: true},
+	{Name: "seed/upsert", Run: checkSeed},
+}
+
+var errRollback = errors.New("contract check rollback")
+
+// Run runs every check against database, each in its own transaction, and
+// returns their results. It fails only when a transaction can not be made.
+func Run(ctx context.Context, database db.DB) ([]*Result, error) {
+	results := make([]*Result, 0, len(Checks))
+
+	for _, check := range Checks {
+		result := &Result{Name: check.Name}
+		start := time.Now()
+
+		var checkErr error
+		if check.OwnTx {
+			checkErr = check.Run(ctx, database)
+		} else {
+			err := database.WithTx(ctx, func(tx db.DB) error {
+				checkErr = check.Run(ctx, tx)
+				return errRollback
+			}, db.WithMaxRetries(0))
+			if err != nil && !errors.Is(err, errRollback) {
+				return results, errors.Wrapf(err, "unable to run check %s", check.Name)
+			}
+		}
+
+		result.DurationMs = time.Since(start).Milliseconds()
+		result.Passed = checkErr == nil
+		if checkErr != nil {
+			result.Error = checkErr.Error()
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// uniqueName returns a value no real row uses, so checks do not depend on
+// the data already in the database
+func uniqueName(prefix string) string {
+	return fmt.Sprintf("contract-%s-%d", prefix, time.Now().UnixNano())
+}
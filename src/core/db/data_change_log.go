@@ -0,0 +1,10 @@
+package db
+
+import (
+	"context"
+	"go-template/src/core/model"
+)
+
+type DBDataChangeLogInterface interface {
+	InquiryDataChangeLog(ctx context.Context, tableName, recordID string, page, limit int64) ([]*model.DataChangeLog, *model.Pagination, error)
+}
@@ -1,10 +1,11 @@
 package db
 
 import (
+	"context"
 	"errors"
-	"github.com/jackc/pgx/v5"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"go-template/src/core/db/memory"
+	"go-template/src/core/db/mysql"
 	"go-template/src/core/db/postgresql"
 	"go-template/src/core/log"
 )
@@ -12,14 +13,153 @@ import (
 type DB interface {
 	DBApiKeysInterface
 	DBActivityLogInterface
+	DBDataChangeLogInterface
+	DBSeedInterface
+
+	// WithTx runs fn in a transaction, committing when it returns nil and
+	// rolling back on an error or a panic. Every method of tx runs in the
+	// transaction; WithTx on tx opens a savepoint.
+	WithTx(ctx context.Context, fn func(tx DB) error, opts ...TxOption) error
 
 	Close() error
 }
 
+// PostgresqlDB adapts postgresql.PostgresqlDB to DB
 type PostgresqlDB struct {
-	logger log.Logger
-	DB     *pgxpool.Pool
-	Tx     pgx.Tx
+	*postgresql.PostgresqlDB
+}
+
+func (pgdb *PostgresqlDB) WithTx(ctx context.Context, fn func(tx DB) error, opts ...TxOption) error {
+	return pgdb.PostgresqlDB.WithTx(ctx, func(tx *postgresql.PostgresqlDB) error {
+		return fn(&PostgresqlDB{tx})
+	}, opts...)
+}
+
+// MySQLDB adapts mysql.MySQLDB to DB, for the mysql and tidb database types
+type MySQLDB struct {
+	*mysql.MySQLDB
+}
+
+func (mydb *MySQLDB) WithTx(ctx context.Context, fn func(tx DB) error, opts ...TxOption) error {
+	return mydb.MySQLDB.WithTx(ctx, func(tx *mysql.MySQLDB) error {
+		return fn(&MySQLDB{tx})
+	}, mysqlTxOptions(opts)...)
+}
+
+// MemoryDB adapts memory.MemoryDB to DB, for the memory database type
+type MemoryDB struct {
+	*memory.MemoryDB
+}
+
+func (mdb *MemoryDB) WithTx(ctx context.Context, fn func(tx DB) error, opts ...TxOption) error {
+	memoryOpts := make([]memory.TxOption, 0, 1)
+	if applyTxOptions(opts).ReadOnly {
+		memoryOpts = append(memoryOpts, memory.WithReadOnly())
+	}
+
+	return mdb.MemoryDB.WithTx(ctx, func(tx *memory.MemoryDB) error {
+		return fn(&MemoryDB{tx})
+	}, memoryOpts...)
+}
+
+// applyTxOptions returns the options opts set; MaxRetries is -1 when none
+// sets it
+func applyTxOptions(opts []TxOption) postgresql.TxOptions {
+	options := postgresql.TxOptions{MaxRetries: -1}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// mysqlTxOptions converts opts, which are postgresql's, to the mysql ones
+// they set
+func mysqlTxOptions(opts []TxOption) []mysql.TxOption {
+	options := applyTxOptions(opts)
+
+	result := make([]mysql.TxOption, 0, 3)
+	if options.IsolationLevel != "" {
+		result = append(result, mysql.WithIsolationLevel(mysql.IsolationLevel(options.IsolationLevel)))
+	}
+	if options.ReadOnly {
+		result = append(result, mysql.WithReadOnly())
+	}
+	if options.MaxRetries != -1 {
+		result = append(result, mysql.WithMaxRetries(options.MaxRetries))
+	}
+	return result
+}
+
+type (
+	IsolationLevel = postgresql.IsolationLevel
+	TxOption       = postgresql.TxOption
+)
+
+const (
+	ReadCommitted  = postgresql.ReadCommitted
+	RepeatableRead = postgresql.RepeatableRead
+	Serializable   = postgresql.Serializable
+)
+
+// WithIsolationLevel sets the isolation level of the transaction
+func WithIsolationLevel(level IsolationLevel) TxOption {
+	return postgresql.WithIsolationLevel(level)
+}
+
+// WithReadOnly starts a read-only transaction
+func WithReadOnly() TxOption {
+	return postgresql.WithReadOnly()
+}
+
+// WithMaxRetries reruns the transaction up to maxRetries times after a
+// serialization failure or a deadlock
+func WithMaxRetries(maxRetries int) TxOption {
+	return postgresql.WithMaxRetries(maxRetries)
+}
+
+// Actor identifies who makes a change, see ContextWithActor
+type Actor = postgresql.Actor
+
+// ContextWithActor returns a copy of ctx carrying the acting user. Changes
+// made with it are attributed to actor in data_change_log. The mysql and
+// tidb types have no triggers and ignore it.
+func ContextWithActor(ctx context.Context, actor Actor) context.Context {
+	return postgresql.ContextWithActor(ctx, actor)
+}
+
+// ContextWithPrimary returns a copy of ctx whose reads go to the primary
+// instead of a replica, to read back what was just written. Types without
+// replicas ignore it.
+func ContextWithPrimary(ctx context.Context) context.Context {
+	return postgresql.ContextWithPrimary(ctx)
+}
+
+type (
+	PoolStats              = postgresql.PoolStats
+	ReplicaStatus          = postgresql.ReplicaStatus
+	ActivityLogWriterStats = postgresql.ActivityLogWriterStats
+)
+
+// Stats describes the connections of a database for the health check
+type Stats struct {
+	Pool              *PoolStats              `json:"pool"`
+	Replicas          []*ReplicaStatus        `json:"replicas,omitempty"`
+	ActivityLogWriter *ActivityLogWriterStats `json:"activity_log_writer,omitempty"`
+}
+
+// GetStats returns the stats of database, nil for the types other than
+// postgres
+func GetStats(database DB) *Stats {
+	pgdb, ok := database.(*PostgresqlDB)
+	if !ok {
+		return nil
+	}
+
+	return &Stats{
+		Pool:              pgdb.PoolStats(),
+		Replicas:          pgdb.ReplicaStatus(),
+		ActivityLogWriter: pgdb.ActivityLogWriterStats(),
+	}
 }
 
 func New(config *Config, logger log.Logger) (db DB, err error) {
@@ -30,7 +170,33 @@ func New(config *Config, logger log.Logger) (db DB, err error) {
 			return nil, err
 		}
 
-		return postgresql.New(dbConfig, logger)
+		pgdb, err := postgresql.New(dbConfig, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		return &PostgresqlDB{pgdb}, nil
+
+	case "mysql", "tidb":
+		dbConfig, err := mysql.InitConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		mydb, err := mysql.New(dbConfig, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		return &MySQLDB{mydb}, nil
+
+	case "memory":
+		mdb, err := memory.New(logger)
+		if err != nil {
+			return nil, err
+		}
+
+		return &MemoryDB{mdb}, nil
 	}
 
 	return nil, errors.New("unsupported database type")
@@ -0,0 +1,301 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go-template/src/core/audit"
+	"go-template/src/core/listquery"
+	"go-template/src/core/model"
+)
+
+// CreateActivityLog appends activityLog to the hash chain of its day, always
+// on the request path. Like Postgres, a row outside every partition is
+// rejected.
+func (mdb *MemoryDB) CreateActivityLog(ctx context.Context, activityLog *model.ActivityLog) error {
+	if activityLog.CreatedTime.IsZero() {
+		activityLog.CreatedTime = time.Now()
+	}
+
+	return mdb.write(ctx, func(s *state) error {
+		activityLog.CreatedTime = activityLog.CreatedTime.Truncate(audit.Precision)
+		day := audit.ChainDate(activityLog.CreatedTime)
+
+		month := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+		if _, ok := s.partitions[ActivityLogPartitionName(month)]; !ok {
+			return fmt.Errorf("no partition of relation \"activity_log\" found for row created at %s", activityLog.CreatedTime.UTC())
+		}
+
+		head := s.chains[day]
+		activityLog.ChainSeq = head.LastSeq + 1
+		activityLog.PrevHash = head.LastHash
+		activityLog.RowHash = audit.Hash(head.LastHash, activityLog)
+		activityLog.ID = s.nextID("activity_log")
+
+		s.chains[day] = model.ActivityLogChainHead{
+			ChainDate: day,
+			LastSeq:   activityLog.ChainSeq,
+			LastHash:  activityLog.RowHash,
+		}
+		s.activityLogs = append(s.activityLogs, &activityLogRow{
+			ActivityLog: *copyActivityLog(activityLog),
+			chainDate:   day,
+		})
+		return nil
+	})
+}
+
+func (mdb *MemoryDB) InquiryActivityLog(ctx context.Context, filter model.ActivityLogFilter, list *listquery.List) ([]*model.ActivityLog, *model.Pagination, error) {
+	rows := make([]*model.ActivityLog, 0)
+	err := mdb.read(ctx, func(s *state) error {
+		for _, row := range s.activityLogs {
+			if matchesActivityLogFilter(&row.ActivityLog, filter) {
+				rows = append(rows, &row.ActivityLog)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, pagination := selectList(rows, list, activityLogColumn)
+
+	result := make([]*model.ActivityLog, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, copyActivityLog(row))
+	}
+
+	return result, pagination, nil
+}
+
+// matchesActivityLogFilter ignores zero values of filter, like postgresql's
+// Query.Equal
+func matchesActivityLogFilter(activityLog *model.ActivityLog, filter model.ActivityLogFilter) bool {
+	switch {
+	case filter.UserID != 0 && (activityLog.UserID == nil || *activityLog.UserID != filter.UserID):
+		return false
+	case filter.EmailAddress != "" && (activityLog.EmailAddress == nil || !strings.EqualFold(*activityLog.EmailAddress, filter.EmailAddress)):
+		return false
+	case filter.ServiceCode != "" && activityLog.ServiceCode != filter.ServiceCode:
+		return false
+	case filter.HTTPStatusCode != 0 && activityLog.HTTPStatusCode != filter.HTTPStatusCode:
+		return false
+	case filter.RequestNo != "" && activityLog.RequestNo != filter.RequestNo:
+		return false
+	case filter.TraceID != "" && activityLog.TraceID != filter.TraceID:
+		return false
+	case filter.StartTime != nil && activityLog.CreatedTime.Before(*filter.StartTime):
+		return false
+	case filter.EndTime != nil && !activityLog.CreatedTime.Before(*filter.EndTime):
+		return false
+	}
+	return true
+}
+
+// activityLogColumn is the columnFunc of activity_log
+func activityLogColumn(activityLog *model.ActivityLog, column string) any {
+	switch column {
+	case "id":
+		return activityLog.ID
+	case "request_no":
+		return activityLog.RequestNo
+	case "trace_id":
+		return activityLog.TraceID
+	case "span_id":
+		return activityLog.SpanID
+	case "service_code":
+		return activityLog.ServiceCode
+	case "user_id":
+		return int64Value(activityLog.UserID)
+	case "azure_user_id":
+		return stringValue(activityLog.AzureUserID)
+	case "email_address":
+		return stringValue(activityLog.EmailAddress)
+	case "user_roles":
+		return activityLog.UserRoles
+	case "http_method":
+		return activityLog.HTTPMethod
+	case "request_uri":
+		return activityLog.RequestURI
+	case "http_status_code":
+		return int64(activityLog.HTTPStatusCode)
+	case "response_code":
+		return int64Value(activityLog.ResponseCode)
+	case "duration_ms":
+		return activityLog.DurationMs
+	case "ip_address":
+		return activityLog.IPAddress
+	case "created_time":
+		return activityLog.CreatedTime
+	case "chain_seq":
+		return activityLog.ChainSeq
+	}
+	panic(fmt.Sprintf("memory: unknown activity_log column %q", column))
+}
+
+// copyActivityLog copies activityLog, including what its pointers point to,
+// so stored rows do not change with the caller's
+func copyActivityLog(activityLog *model.ActivityLog) *model.ActivityLog {
+	c := *activityLog
+	c.UserID = copyPointer(activityLog.UserID)
+	c.AzureUserID = copyPointer(activityLog.AzureUserID)
+	c.EmailAddress = copyPointer(activityLog.EmailAddress)
+	c.ResponseCode = copyPointer(activityLog.ResponseCode)
+	c.UserAgent = copyPointer(activityLog.UserAgent)
+	c.RequestBody = copyPointer(activityLog.RequestBody)
+	c.ResponseBody = copyPointer(activityLog.ResponseBody)
+	c.UserRoles = append([]string{}, activityLog.UserRoles...)
+	return &c
+}
+
+func copyPointer[T any](value *T) *T {
+	if value == nil {
+		return nil
+	}
+	c := *value
+	return &c
+}
+
+// ListActivityLogChainHeads returns the chain heads of the days in
+// [from, to], oldest first. Zero times leave the range open.
+func (mdb *MemoryDB) ListActivityLogChainHeads(ctx context.Context, from, to time.Time) ([]*model.ActivityLogChainHead, error) {
+	return mdb.chainHeads(ctx, func(s *state, head model.ActivityLogChainHead) bool {
+		return (from.IsZero() || !head.ChainDate.Before(audit.ChainDate(from))) &&
+			(to.IsZero() || !head.ChainDate.After(audit.ChainDate(to)))
+	})
+}
+
+// ListUncheckpointedActivityLogChainHeads returns the chain heads whose last
+// link has no checkpoint yet
+func (mdb *MemoryDB) ListUncheckpointedActivityLogChainHeads(ctx context.Context) ([]*model.ActivityLogChainHead, error) {
+	return mdb.chainHeads(ctx, func(s *state, head model.ActivityLogChainHead) bool {
+		for _, checkpoint := range s.checkpoints {
+			if checkpoint.ChainDate.Equal(head.ChainDate) && checkpoint.ChainSeq == head.LastSeq {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func (mdb *MemoryDB) chainHeads(ctx context.Context, include func(s *state, head model.ActivityLogChainHead) bool) ([]*model.ActivityLogChainHead, error) {
+	heads := make([]*model.ActivityLogChainHead, 0)
+	err := mdb.read(ctx, func(s *state) error {
+		for _, head := range s.chains {
+			if include(s, head) {
+				heads = append(heads, &head)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(heads, func(i, j int) bool {
+		return heads[i].ChainDate.Before(heads[j].ChainDate)
+	})
+	return heads, nil
+}
+
+// WalkActivityLogChain calls fn for every chained row of day in chain order
+func (mdb *MemoryDB) WalkActivityLogChain(ctx context.Context, day time.Time, fn func(activityLog *model.ActivityLog) error) error {
+	day = audit.ChainDate(day)
+
+	rows := make([]*model.ActivityLog, 0)
+	err := mdb.read(ctx, func(s *state) error {
+		for _, row := range s.activityLogs {
+			if row.chainDate.Equal(day) {
+				rows = append(rows, copyActivityLog(&row.ActivityLog))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].ChainSeq < rows[j].ChainSeq
+	})
+	for _, row := range rows {
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateActivityLogCheckpoint stores checkpoint; a second checkpoint of the
+// same link is ignored
+func (mdb *MemoryDB) CreateActivityLogCheckpoint(ctx context.Context, checkpoint *model.ActivityLogCheckpoint) error {
+	return mdb.write(ctx, func(s *state) error {
+		for _, existing := range s.checkpoints {
+			if existing.ChainDate.Equal(checkpoint.ChainDate) && existing.ChainSeq == checkpoint.ChainSeq {
+				return nil
+			}
+		}
+
+		c := *checkpoint
+		c.ChainDate = audit.ChainDate(checkpoint.ChainDate)
+		c.ID = s.nextID("activity_log_checkpoint")
+		c.CreatedTime = time.Now().Truncate(time.Microsecond)
+		s.checkpoints = append(s.checkpoints, c)
+		return nil
+	})
+}
+
+// ListActivityLogCheckpoints returns the checkpoints of day in chain order
+func (mdb *MemoryDB) ListActivityLogCheckpoints(ctx context.Context, day time.Time) ([]*model.ActivityLogCheckpoint, error) {
+	day = audit.ChainDate(day)
+
+	result := make([]*model.ActivityLogCheckpoint, 0)
+	err := mdb.read(ctx, func(s *state) error {
+		for _, checkpoint := range s.checkpoints {
+			if checkpoint.ChainDate.Equal(day) {
+				result = append(result, &checkpoint)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ChainSeq < result[j].ChainSeq
+	})
+	return result, nil
+}
+
+// ListActivityLogCheckpointDays returns the days in [from, to] that have
+// checkpoints, oldest first. Zero times leave the range open.
+func (mdb *MemoryDB) ListActivityLogCheckpointDays(ctx context.Context, from, to time.Time) ([]time.Time, error) {
+	days := make([]time.Time, 0)
+	err := mdb.read(ctx, func(s *state) error {
+		seen := make(map[time.Time]bool)
+		for _, checkpoint := range s.checkpoints {
+			day := checkpoint.ChainDate
+			if seen[day] ||
+				(!from.IsZero() && day.Before(audit.ChainDate(from))) ||
+				(!to.IsZero() && day.After(audit.ChainDate(to))) {
+				continue
+			}
+			seen[day] = true
+			days = append(days, day)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Before(days[j])
+	})
+	return days, nil
+}
@@ -0,0 +1,181 @@
+package memory
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"go-template/src/core/model"
+)
+
+// ActivityLogPartitionName returns the name of the partition holding month
+func ActivityLogPartitionName(month time.Time) string {
+	return "activity_log_p" + month.Format("200601")
+}
+
+// activityLogArchiveRow is the JSON of a row in an archive, the fields of
+// Postgres's row_to_json so archives restore on every backend
+type activityLogArchiveRow struct {
+	*model.ActivityLog
+	ChainDate string `json:"chain_date"`
+}
+
+func ensurePartitions(s *state, from time.Time, months int) []string {
+	names := make([]string, 0, months+1)
+	month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i <= months; i++ {
+		current := month.AddDate(0, i, 0)
+		name := ActivityLogPartitionName(current)
+		s.partitions[name] = current
+		names = append(names, name)
+	}
+	return names
+}
+
+// EnsureActivityLogPartitions creates the partitions for the month of from and
+// the following months, skipping the ones that exist
+func (mdb *MemoryDB) EnsureActivityLogPartitions(ctx context.Context, from time.Time, months int) ([]string, error) {
+	var names []string
+	err := mdb.write(ctx, func(s *state) error {
+		names = ensurePartitions(s, from, months)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+// ListActivityLogPartitions returns the monthly partitions, oldest first
+func (mdb *MemoryDB) ListActivityLogPartitions(ctx context.Context) ([]*model.ActivityLogPartition, error) {
+	result := make([]*model.ActivityLogPartition, 0)
+	err := mdb.read(ctx, func(s *state) error {
+		for _, name := range sortedKeys(s.partitions) {
+			result = append(result, &model.ActivityLogPartition{
+				Name:  name,
+				Month: s.partitions[name],
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ExportActivityLogPartition writes every row of the partition of month to w
+// as JSON Lines, in insertion order, and returns the number of rows
+func (mdb *MemoryDB) ExportActivityLogPartition(ctx context.Context, month time.Time, w io.Writer) (int64, error) {
+	name := ActivityLogPartitionName(month)
+
+	rows := make([]*activityLogRow, 0)
+	err := mdb.read(ctx, func(s *state) error {
+		if _, ok := s.partitions[name]; !ok {
+			return fmt.Errorf("relation %q does not exist", name)
+		}
+		for _, row := range s.activityLogs {
+			if ActivityLogPartitionName(row.CreatedTime.UTC()) == name {
+				rows = append(rows, row)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, errors.Wrapf(err, "Can not export activity log partition %s", name)
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if !rows[i].CreatedTime.Equal(rows[j].CreatedTime) {
+			return rows[i].CreatedTime.Before(rows[j].CreatedTime)
+		}
+		return rows[i].ID < rows[j].ID
+	})
+
+	var count int64
+	for _, row := range rows {
+		line, err := json.Marshal(activityLogArchiveRow{
+			ActivityLog: copyActivityLog(&row.ActivityLog),
+			ChainDate:   row.chainDate.Format(time.DateOnly),
+		})
+		if err != nil {
+			return count, err
+		}
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// DropActivityLogPartition drops the partition of month with its rows. The
+// chain heads stay, as in Postgres.
+func (mdb *MemoryDB) DropActivityLogPartition(ctx context.Context, month time.Time) error {
+	name := ActivityLogPartitionName(month)
+
+	err := mdb.write(ctx, func(s *state) error {
+		if _, ok := s.partitions[name]; !ok {
+			return fmt.Errorf("relation %q does not exist", name)
+		}
+		delete(s.partitions, name)
+
+		kept := make([]*activityLogRow, 0, len(s.activityLogs))
+		for _, row := range s.activityLogs {
+			if ActivityLogPartitionName(row.CreatedTime.UTC()) != name {
+				kept = append(kept, row)
+			}
+		}
+		s.activityLogs = kept
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Can not drop activity log partition %s", name)
+	}
+
+	return nil
+}
+
+// RestoreActivityLogArchive loads JSON Lines produced by
+// ExportActivityLogPartition into a new table named tableName, kept apart
+// from activity_log, and returns the number of rows loaded
+func (mdb *MemoryDB) RestoreActivityLogArchive(ctx context.Context, tableName string, r io.Reader) (int64, error) {
+	rows := make([]*model.ActivityLog, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		row := activityLogArchiveRow{ActivityLog: &model.ActivityLog{}}
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			return 0, errors.Wrap(err, "Can not read activity log archive")
+		}
+		rows = append(rows, row.ActivityLog)
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, errors.Wrap(err, "Can not read activity log archive")
+	}
+
+	err := mdb.write(ctx, func(s *state) error {
+		if _, ok := s.restored[tableName]; ok || tableName == "activity_log" {
+			return fmt.Errorf("relation %q already exists", tableName)
+		}
+		s.restored[tableName] = rows
+		return nil
+	})
+	if err != nil {
+		return 0, errors.Wrapf(err, "Can not create restore table %s", tableName)
+	}
+
+	return int64(len(rows)), nil
+}
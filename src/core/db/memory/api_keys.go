@@ -0,0 +1,80 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go-template/src/core/model"
+)
+
+func (mdb *MemoryDB) InsertApiKeys(ctx context.Context, apiKeysList []model.ApiKey, isRoot bool) error {
+	return mdb.write(ctx, func(s *state) error {
+		for _, apiKey := range apiKeysList {
+			if _, ok := s.apiKeys[apiKey.Key]; ok {
+				return fmt.Errorf("duplicate key value violates unique constraint \"api_keys_pkey\"")
+			}
+
+			apiKey.ExpireTime = apiKey.ExpireTime.Truncate(time.Microsecond)
+			apiKey.CreatedTime = time.Now().Truncate(time.Microsecond)
+			s.apiKeys[apiKey.Key] = apiKey
+		}
+
+		return nil
+	})
+}
+
+// VerifyApiKey extends key to newExpireTime when it has not expired, and
+// returns it whether it has or not
+func (mdb *MemoryDB) VerifyApiKey(ctx context.Context, key string, newExpireTime time.Time) ([]*model.ApiKey, error) {
+	result := make([]*model.ApiKey, 0)
+
+	err := mdb.write(ctx, func(s *state) error {
+		apiKey, ok := s.apiKeys[key]
+		if !ok {
+			return nil
+		}
+
+		if !apiKey.ExpireTime.Before(time.Now()) {
+			apiKey.ExpireTime = newExpireTime.Truncate(time.Microsecond)
+			s.apiKeys[key] = apiKey
+		}
+		result = append(result, &apiKey)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (mdb *MemoryDB) DeleteApiKey(ctx context.Context, key string) error {
+	return mdb.write(ctx, func(s *state) error {
+		delete(s.apiKeys, key)
+		return nil
+	})
+}
+
+func (mdb *MemoryDB) DeleteExpireApiKey(ctx context.Context) error {
+	var deleted int
+	err := mdb.write(ctx, func(s *state) error {
+		now := time.Now()
+		for key, apiKey := range s.apiKeys {
+			if apiKey.ExpireTime.Before(now) {
+				delete(s.apiKeys, key)
+				deleted++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if deleted > 0 {
+		mdb.logger.Infof("Deleted %v api key expire", deleted)
+	}
+
+	return nil
+}
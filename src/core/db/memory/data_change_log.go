@@ -0,0 +1,23 @@
+package memory
+
+import (
+	"context"
+
+	"go-template/src/core/model"
+)
+
+// InquiryDataChangeLog returns the history of one record. The Postgres
+// trigger only records tables enabled by a migration, and none of the tables
+// of this backend is, so every history is empty.
+func (mdb *MemoryDB) InquiryDataChangeLog(ctx context.Context, tableName, recordID string, page, limit int64) ([]*model.DataChangeLog, *model.Pagination, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	pagination := &model.Pagination{
+		Limit: limit,
+		Page:  page,
+	}
+
+	return make([]*model.DataChangeLog, 0), pagination, nil
+}
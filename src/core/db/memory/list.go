@@ -0,0 +1,217 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go-template/src/core/listquery"
+	"go-template/src/core/model"
+)
+
+// columnFunc returns the value of a column of row: nil for NULL, otherwise
+// an int64, string, bool, time.Time or []string
+type columnFunc[T any] func(row T, column string) any
+
+// selectList applies the filters, sort, cursor and page of list to rows, as
+// postgresql's selectList does in SQL
+func selectList[T any](rows []T, list *listquery.List, column columnFunc[T]) ([]T, *model.Pagination) {
+	matching := make([]T, 0)
+	for _, row := range rows {
+		if matchesFilters(row, list.Filters, column) {
+			matching = append(matching, row)
+		}
+	}
+
+	sort.SliceStable(matching, func(i, j int) bool {
+		for _, key := range list.Sort {
+			c := compareSortValues(column(matching[i], key.Column), column(matching[j], key.Column))
+			if key.Desc {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+
+	pagination := &model.Pagination{
+		Limit: list.Limit,
+	}
+
+	if list.Keyset() {
+		after := make([]T, 0, len(matching))
+		for _, row := range matching {
+			// c > 0 when row comes after the cursor in the order of the sort
+			c := 0
+			for i, key := range list.Sort {
+				if c = compareSortValues(column(row, key.Column), list.After[i]); c != 0 {
+					if key.Desc {
+						c = -c
+					}
+					break
+				}
+			}
+			if c > 0 {
+				after = append(after, row)
+			}
+		}
+		matching = after
+	} else {
+		pagination.Page = list.Page
+		pagination.Total = int64(len(matching))
+	}
+
+	offset := list.Offset()
+	if offset > int64(len(matching)) {
+		offset = int64(len(matching))
+	}
+	matching = matching[offset:]
+
+	if int64(len(matching)) > list.Limit {
+		matching = matching[:list.Limit]
+		pagination.HasMore = true
+
+		last := matching[len(matching)-1]
+		values := make([]json.RawMessage, 0, len(list.Sort))
+		for _, key := range list.Sort {
+			value, _ := json.Marshal(column(last, key.Column))
+			values = append(values, value)
+		}
+		pagination.NextCursor = list.NextCursor(values)
+	}
+
+	return matching, pagination
+}
+
+func matchesFilters[T any](row T, filters []listquery.Filter, column columnFunc[T]) bool {
+	for _, filter := range filters {
+		value := column(row, filter.Column)
+		// comparisons with NULL are never true
+		if value == nil {
+			return false
+		}
+
+		var ok bool
+		switch filter.Op {
+		case listquery.OpEq:
+			ok = compare(value, filter.Value) == 0
+		case listquery.OpNe:
+			ok = compare(value, filter.Value) != 0
+		case listquery.OpLt:
+			ok = compare(value, filter.Value) < 0
+		case listquery.OpLte:
+			ok = compare(value, filter.Value) <= 0
+		case listquery.OpGt:
+			ok = compare(value, filter.Value) > 0
+		case listquery.OpGte:
+			ok = compare(value, filter.Value) >= 0
+		case listquery.OpIn:
+			ok = inList(value, filter.Value)
+		case listquery.OpNotIn:
+			ok = !inList(value, filter.Value)
+		case listquery.OpContains:
+			ok = strings.Contains(strings.ToLower(value.(string)), strings.ToLower(filter.Value.(string)))
+		case listquery.OpPrefix:
+			ok = strings.HasPrefix(strings.ToLower(value.(string)), strings.ToLower(filter.Value.(string)))
+		case listquery.OpHas:
+			ok = inList(filter.Value, value)
+		default:
+			panic(fmt.Sprintf("memory: unknown list operator %q", filter.Op))
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func inList(value any, list any) bool {
+	switch list := list.(type) {
+	case []int64:
+		for _, item := range list {
+			if compare(value, item) == 0 {
+				return true
+			}
+		}
+	case []string:
+		for _, item := range list {
+			if compare(value, item) == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// compareSortValues orders NULL after every value, as Postgres does in
+// ascending order
+func compareSortValues(a, b any) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	return compare(a, b)
+}
+
+// compare orders two values of the same column type
+func compare(a, b any) int {
+	switch a := a.(type) {
+	case int64:
+		b := toInt64(b)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(a, b.(string))
+	case bool:
+		b := b.(bool)
+		switch {
+		case a == b:
+			return 0
+		case !a:
+			return -1
+		}
+		return 1
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	}
+	panic(fmt.Sprintf("memory: can not compare %T", a))
+}
+
+func toInt64(value any) int64 {
+	switch value := value.(type) {
+	case int64:
+		return value
+	case int:
+		return int64(value)
+	}
+	panic(fmt.Sprintf("memory: %T is not an integer", value))
+}
+
+// stringValue is the column value of a nullable text column
+func stringValue(value *string) any {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
+
+// int64Value is the column value of a nullable integer column
+func int64Value[T int | int64](value *T) any {
+	if value == nil {
+		return nil
+	}
+	return int64(*value)
+}
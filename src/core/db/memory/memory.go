@@ -0,0 +1,174 @@
+// Package memory keeps the data of db.DB in the process, for tests and
+// local demos. It follows the Postgres backend: keys expire, activity logs
+// are hash-chained into monthly partitions and transactions roll back. Data
+// is lost when the process exits.
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go-template/src/core/log"
+	"go-template/src/core/model"
+)
+
+// partitionMonthsAhead matches the partitions the Postgres migrations create
+const partitionMonthsAhead = 3
+
+type MemoryDB struct {
+	logger log.Logger
+
+	store *store
+	// tx is the state of the transaction, nil outside one
+	tx       *state
+	readOnly bool
+}
+
+// store holds the committed state. Transactions take writeMu for their whole
+// run, so they are serializable; mu guards swapping committed.
+type store struct {
+	writeMu   sync.Mutex
+	mu        sync.RWMutex
+	committed *state
+}
+
+// state is every table. Rows are never changed in place, a change replaces
+// them, so clone only copies the maps and slices.
+type state struct {
+	apiKeys map[string]model.ApiKey
+
+	activityLogs []*activityLogRow
+	partitions   map[string]time.Time
+	chains       map[time.Time]model.ActivityLogChainHead
+	checkpoints  []model.ActivityLogCheckpoint
+	// restored holds the tables of RestoreActivityLogArchive
+	restored map[string][]*model.ActivityLog
+
+	// sequences holds the last id of each table
+	sequences map[string]int64
+}
+
+type activityLogRow struct {
+	model.ActivityLog
+	chainDate time.Time
+}
+
+func newState() *state {
+	return &state{
+		apiKeys:    make(map[string]model.ApiKey),
+		partitions: make(map[string]time.Time),
+		chains:     make(map[time.Time]model.ActivityLogChainHead),
+		restored:   make(map[string][]*model.ActivityLog),
+		sequences:  make(map[string]int64),
+	}
+}
+
+func (s *state) clone() *state {
+	c := &state{
+		apiKeys:      make(map[string]model.ApiKey, len(s.apiKeys)),
+		activityLogs: append([]*activityLogRow(nil), s.activityLogs...),
+		partitions:   make(map[string]time.Time, len(s.partitions)),
+		chains:       make(map[time.Time]model.ActivityLogChainHead, len(s.chains)),
+		checkpoints:  append([]model.ActivityLogCheckpoint(nil), s.checkpoints...),
+		restored:     make(map[string][]*model.ActivityLog, len(s.restored)),
+		sequences:    make(map[string]int64, len(s.sequences)),
+	}
+	for k, v := range s.apiKeys {
+		c.apiKeys[k] = v
+	}
+	for k, v := range s.partitions {
+		c.partitions[k] = v
+	}
+	for k, v := range s.chains {
+		c.chains[k] = v
+	}
+	for k, v := range s.restored {
+		c.restored[k] = v
+	}
+	for k, v := range s.sequences {
+		c.sequences[k] = v
+	}
+	return c
+}
+
+// nextID is the next value of the id sequence of table
+func (s *state) nextID(table string) int64 {
+	s.sequences[table]++
+	return s.sequences[table]
+}
+
+// New returns an empty database with the activity log partitions of this
+// month and the next three, as after migrate-db
+func New(logger log.Logger) (*MemoryDB, error) {
+	mdb := &MemoryDB{
+		logger: logger.WithFields(log.Fields{
+			"module": "db/memory",
+		}),
+		store: &store{
+			committed: newState(),
+		},
+	}
+
+	ensurePartitions(mdb.store.committed, time.Now(), partitionMonthsAhead)
+	mdb.logger.Infof("Using the in-memory database, data is lost when the process exits")
+
+	return mdb, nil
+}
+
+func (mdb *MemoryDB) Close() error {
+	return nil
+}
+
+var errReadOnly = errors.New("cannot execute a write in a read-only transaction")
+
+// read runs fn on the state seen by mdb
+func (mdb *MemoryDB) read(ctx context.Context, fn func(s *state) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if mdb.tx != nil {
+		return fn(mdb.tx)
+	}
+
+	mdb.store.mu.RLock()
+	defer mdb.store.mu.RUnlock()
+	return fn(mdb.store.committed)
+}
+
+// write runs fn on the state of the transaction of mdb, or as a transaction
+// of its own outside one, so a failing fn changes nothing
+func (mdb *MemoryDB) write(ctx context.Context, fn func(s *state) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if mdb.readOnly {
+		return errReadOnly
+	}
+
+	return mdb.begin(ctx, func(tx *MemoryDB) error {
+		return fn(tx.tx)
+	})
+}
+
+// begin runs fn in a savepoint of the transaction of mdb, or in a new
+// transaction outside one
+func (mdb *MemoryDB) begin(ctx context.Context, fn func(tx *MemoryDB) error) error {
+	if mdb.tx != nil {
+		return mdb.runSavepoint(fn)
+	}
+	return mdb.runTx(ctx, false, fn)
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,352 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go-template/src/core/model"
+	"go-template/src/core/seed"
+)
+
+var errSeedDryRun = errors.New("seed dry run")
+
+// timestampFormat is the text form of a timestamptz in Postgres
+const timestampFormat = "2006-01-02 15:04:05.999999-07"
+
+// seedColumn converts a column of a row to and from the text form used in
+// seed diffs, as the ::text cast of Postgres does
+type seedColumn struct {
+	get     func(apiKey *model.ApiKey) *string
+	set     func(apiKey *model.ApiKey, value *string) error
+	notNull bool
+}
+
+// apiKeyColumns are the columns of api_keys; api_keys is the only table of
+// this backend that can be seeded
+var apiKeyColumns = map[string]seedColumn{
+	"key": {
+		get:     func(apiKey *model.ApiKey) *string { return textOf(apiKey.Key) },
+		set:     func(apiKey *model.ApiKey, value *string) error { apiKey.Key = valueOf(value); return nil },
+		notNull: true,
+	},
+	"azure_user_id": {
+		get:     func(apiKey *model.ApiKey) *string { return textOf(apiKey.AzureUserID) },
+		set:     func(apiKey *model.ApiKey, value *string) error { apiKey.AzureUserID = valueOf(value); return nil },
+		notNull: true,
+	},
+	"user_id": {
+		get: func(apiKey *model.ApiKey) *string {
+			if apiKey.UserID == 0 {
+				return nil
+			}
+			return textOf(strconv.FormatInt(apiKey.UserID, 10))
+		},
+		set: func(apiKey *model.ApiKey, value *string) (err error) {
+			apiKey.UserID = 0
+			if value != nil {
+				apiKey.UserID, err = strconv.ParseInt(*value, 10, 64)
+			}
+			return err
+		},
+	},
+	"email_address": {
+		get: func(apiKey *model.ApiKey) *string { return textOf(apiKey.EmailAddress) },
+		set: func(apiKey *model.ApiKey, value *string) error { apiKey.EmailAddress = valueOf(value); return nil },
+	},
+	"user_role_name": {
+		get: func(apiKey *model.ApiKey) *string { return textOf(apiKey.UserRoleName) },
+		set: func(apiKey *model.ApiKey, value *string) error { apiKey.UserRoleName = valueOf(value); return nil },
+	},
+	"expire_time": {
+		get:     func(apiKey *model.ApiKey) *string { return timestampText(apiKey.ExpireTime) },
+		set:     func(apiKey *model.ApiKey, value *string) error { return parseTimestamp(&apiKey.ExpireTime, value) },
+		notNull: true,
+	},
+	"created_time": {
+		get: func(apiKey *model.ApiKey) *string { return timestampText(apiKey.CreatedTime) },
+		set: func(apiKey *model.ApiKey, value *string) error { return parseTimestamp(&apiKey.CreatedTime, value) },
+	},
+	"user_profile_pic": {
+		get: func(apiKey *model.ApiKey) *string { return textOf(apiKey.UserProfilePic) },
+		set: func(apiKey *model.ApiKey, value *string) error { apiKey.UserProfilePic = valueOf(value); return nil },
+	},
+}
+
+// Seed upserts the rows of tables, in order, in one transaction. Only
+// api_keys exists here; other tables are skipped when they have if_exists.
+// On a dry run the transaction is rolled back and the changes it would have
+// made are returned.
+func (mdb *MemoryDB) Seed(ctx context.Context, tables []*seed.Table, dryRun bool) ([]*seed.Change, error) {
+	var changes []*seed.Change
+
+	err := mdb.WithTx(ctx, func(tx *MemoryDB) error {
+		if tx.readOnly {
+			return errReadOnly
+		}
+		changes = make([]*seed.Change, 0)
+
+		for _, table := range tables {
+			tableChanges, err := seedTable(tx.tx, table)
+			if err != nil {
+				return err
+			}
+			changes = append(changes, tableChanges...)
+		}
+
+		if dryRun {
+			return errSeedDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errSeedDryRun) {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+func seedTable(s *state, table *seed.Table) ([]*seed.Change, error) {
+	if table.Table != "api_keys" {
+		if table.IfExists {
+			return []*seed.Change{{Table: table.Table, Action: seed.ActionSkipped}}, nil
+		}
+		return nil, fmt.Errorf("seed table %s does not exist", table.Table)
+	}
+
+	changes := make([]*seed.Change, 0, len(table.Rows))
+	for i, row := range table.Rows {
+		change, err := seedRow(s, table, row)
+		if err != nil {
+			return nil, errors.Wrapf(err, "row %d of %s", i+1, table.Table)
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+func seedRow(s *state, table *seed.Table, row map[string]any) (*seed.Change, error) {
+	values, err := textValues(s, row)
+	if err != nil {
+		return nil, err
+	}
+	columns := sortedKeys(values)
+
+	keyParts := make([]string, 0, len(table.Key))
+	for _, column := range table.Key {
+		if _, ok := apiKeyColumns[column]; !ok {
+			return nil, fmt.Errorf("key column %s does not exist", column)
+		}
+		if values[column] == nil {
+			return nil, fmt.Errorf("key column %s is null", column)
+		}
+		keyParts = append(keyParts, column+"="+*values[column])
+	}
+
+	change := &seed.Change{
+		Table: table.Table,
+		Key:   strings.Join(keyParts, ","),
+	}
+
+	matches := matchingApiKeys(s, table.Key, values)
+	switch len(matches) {
+	case 0:
+		apiKey := model.ApiKey{CreatedTime: time.Now().Truncate(time.Microsecond)}
+		for _, column := range columns {
+			if err := apiKeyColumns[column].set(&apiKey, values[column]); err != nil {
+				return nil, err
+			}
+			change.Columns = append(change.Columns, &seed.ColumnChange{Column: column, New: values[column]})
+		}
+		for _, column := range sortedKeys(apiKeyColumns) {
+			if apiKeyColumns[column].notNull && apiKeyColumns[column].get(&apiKey) == nil {
+				return nil, fmt.Errorf("null value in column %q violates not-null constraint", column)
+			}
+		}
+		if _, ok := s.apiKeys[apiKey.Key]; ok {
+			return nil, fmt.Errorf("duplicate key value violates unique constraint \"api_keys_pkey\"")
+		}
+
+		change.Action = seed.ActionInsert
+		s.apiKeys[apiKey.Key] = apiKey
+		return change, nil
+
+	case 1:
+		apiKey := matches[0]
+		oldKey := apiKey.Key
+		for _, column := range columns {
+			old := apiKeyColumns[column].get(&apiKey)
+			if equalText(old, values[column]) {
+				continue
+			}
+			if values[column] == nil && apiKeyColumns[column].notNull {
+				return nil, fmt.Errorf("null value in column %q violates not-null constraint", column)
+			}
+			if err := apiKeyColumns[column].set(&apiKey, values[column]); err != nil {
+				return nil, err
+			}
+			change.Columns = append(change.Columns, &seed.ColumnChange{Column: column, Old: old, New: values[column]})
+		}
+		if len(change.Columns) == 0 {
+			change.Action = seed.ActionUnchanged
+			return change, nil
+		}
+		if _, ok := s.apiKeys[apiKey.Key]; ok && apiKey.Key != oldKey {
+			return nil, fmt.Errorf("duplicate key value violates unique constraint \"api_keys_pkey\"")
+		}
+
+		change.Action = seed.ActionUpdate
+		delete(s.apiKeys, oldKey)
+		s.apiKeys[apiKey.Key] = apiKey
+		return change, nil
+	}
+
+	return nil, fmt.Errorf("key %s matches more than one row", change.Key)
+}
+
+// textValues converts the values of row to their text form, resolving
+// references
+func textValues(s *state, row map[string]any) (map[string]*string, error) {
+	values := make(map[string]*string, len(row))
+	for column, value := range row {
+		seedColumn, ok := apiKeyColumns[column]
+		if !ok {
+			return nil, fmt.Errorf("column %s does not exist", column)
+		}
+
+		text, err := textValue(s, value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "column %s", column)
+		}
+
+		// the text form of the column type, e.g. of a timestamp
+		apiKey := model.ApiKey{}
+		if err := seedColumn.set(&apiKey, text); err != nil {
+			return nil, errors.Wrapf(err, "column %s", column)
+		}
+		values[column] = seedColumn.get(&apiKey)
+	}
+	return values, nil
+}
+
+func textValue(s *state, value any) (*string, error) {
+	ref, isRef, err := seed.AsRef(value)
+	if err != nil {
+		return nil, err
+	}
+	if isRef {
+		return resolve(s, ref)
+	}
+
+	switch value := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return &value, nil
+	case time.Time:
+		return timestampText(value), nil
+	case []any, map[string]any:
+		b, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		return textOf(string(b)), nil
+	}
+	return textOf(fmt.Sprint(value)), nil
+}
+
+// resolve returns the text form of ref.Column of the one row matching ref
+func resolve(s *state, ref *seed.Ref) (*string, error) {
+	if ref.Table != "api_keys" {
+		return nil, fmt.Errorf("referenced table %s does not exist", ref.Table)
+	}
+	if _, ok := apiKeyColumns[ref.Column]; !ok {
+		return nil, fmt.Errorf("referenced column %s.%s does not exist", ref.Table, ref.Column)
+	}
+
+	values, err := textValues(s, ref.Where)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := matchingApiKeys(s, sortedKeys(values), values)
+	if len(matches) != 1 {
+		return nil, fmt.Errorf("reference to %s %v matches %d rows, expected 1", ref.Table, ref.Where, len(matches))
+	}
+	return apiKeyColumns[ref.Column].get(&matches[0]), nil
+}
+
+// matchingApiKeys returns the api keys whose columns equal values, in key
+// order
+func matchingApiKeys(s *state, columns []string, values map[string]*string) []model.ApiKey {
+	matches := make([]model.ApiKey, 0)
+	for _, apiKey := range s.apiKeys {
+		matched := true
+		for _, column := range columns {
+			text := apiKeyColumns[column].get(&apiKey)
+			// NULL equals nothing
+			if text == nil || values[column] == nil || *text != *values[column] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			matches = append(matches, apiKey)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Key < matches[j].Key
+	})
+	return matches
+}
+
+func equalText(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// textOf is the text of a string column, NULL when empty
+func textOf(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func valueOf(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func timestampText(t time.Time) *string {
+	if t.IsZero() {
+		return nil
+	}
+	return textOf(t.UTC().Format(timestampFormat))
+}
+
+// parseTimestamp accepts the input formats of timestamptz used in fixtures
+func parseTimestamp(t *time.Time, value *string) error {
+	*t = time.Time{}
+	if value == nil {
+		return nil
+	}
+
+	for _, layout := range []string{time.RFC3339Nano, timestampFormat, time.DateTime, time.DateOnly} {
+		if parsed, err := time.Parse(layout, *value); err == nil {
+			*t = parsed.Truncate(time.Microsecond)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid input syntax for type timestamp with time zone: %q", *value)
+}
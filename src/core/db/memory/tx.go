@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+)
+
+// TxOptions apply to the outermost transaction only, a nested WithTx is a
+// savepoint of it. Transactions run one at a time, so every isolation level
+// is serializable and none is ever retried.
+type TxOptions struct {
+	ReadOnly bool
+}
+
+type TxOption func(options *TxOptions)
+
+func WithReadOnly() TxOption {
+	return func(options *TxOptions) {
+		options.ReadOnly = true
+	}
+}
+
+// WithTx runs fn in a transaction, committing when it returns nil and rolling
+// back when it returns an error or panics. The tx passed to fn has every
+// repository method of mdb and works on a copy of the data, which replaces
+// the data on commit; calling WithTx on it opens a savepoint.
+func (mdb *MemoryDB) WithTx(ctx context.Context, fn func(tx *MemoryDB) error, opts ...TxOption) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	options := TxOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if mdb.tx != nil {
+		return mdb.runSavepoint(fn)
+	}
+	return mdb.runTx(ctx, options.ReadOnly, fn)
+}
+
+func (mdb *MemoryDB) runTx(ctx context.Context, readOnly bool, fn func(tx *MemoryDB) error) error {
+	mdb.store.writeMu.Lock()
+	defer mdb.store.writeMu.Unlock()
+
+	mdb.store.mu.RLock()
+	tx := &MemoryDB{
+		logger:   mdb.logger,
+		store:    mdb.store,
+		tx:       mdb.store.committed.clone(),
+		readOnly: readOnly,
+	}
+	mdb.store.mu.RUnlock()
+
+	// a panic leaves committed as it was
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if !readOnly {
+		mdb.store.mu.Lock()
+		mdb.store.committed = tx.tx
+		mdb.store.mu.Unlock()
+	}
+	return nil
+}
+
+func (mdb *MemoryDB) runSavepoint(fn func(tx *MemoryDB) error) error {
+	savepoint := &MemoryDB{
+		logger:   mdb.logger,
+		store:    mdb.store,
+		tx:       mdb.tx.clone(),
+		readOnly: mdb.readOnly,
+	}
+
+	if err := fn(savepoint); err != nil {
+		return err
+	}
+
+	*mdb.tx = *savepoint.tx
+	return nil
+}
@@ -1,15 +1,133 @@
 package db
 
 import (
+	"context"
 	"errors"
 
+	"go-template/src/core/db/mysql"
+	mysqlmigrations "go-template/src/core/db/mysql/migrations"
+	"go-template/src/core/db/postgresql"
 	"go-template/src/core/db/postgresql/migrations"
+	"go-template/src/core/log"
 )
 
-func Migrate(config *Config, dryRun bool, number int, forceMigrate bool) error {
+type MigrateOptions = migrations.Options
+type MigrationStatus = migrations.MigrationStatus
+
+const (
+	MigrationApplied = migrations.StateApplied
+	MigrationPending = migrations.StatePending
+	MigrationChanged = migrations.StateChanged
+	MigrationUnknown = migrations.StateUnknown
+)
+
+// Migrate connects with the same configuration as New and migrates the
+// database
+func Migrate(ctx context.Context, config *Config, logger log.Logger, options MigrateOptions) error {
 	switch config.DBType {
 	case "postgres":
-		return migrations.Migrate(dryRun, number, forceMigrate)
+		dbConfig, err := postgresql.InitConfig()
+		if err != nil {
+			return err
+		}
+
+		// migrations may run longer than any statement of a request
+		dbConfig.StatementTimeout = 0
+
+		pool, err := postgresql.Connect(ctx, dbConfig, logger)
+		if err != nil {
+			return err
+		}
+		defer pool.Close()
+
+		return migrations.Migrate(ctx, pool, dbConfig.Schema, logger, options)
+
+	case "mysql", "tidb":
+		dbConfig, err := mysql.InitConfig()
+		if err != nil {
+			return err
+		}
+
+		sqlDB, err := mysql.NewSQLDB(ctx, dbConfig)
+		if err != nil {
+			return err
+		}
+		defer sqlDB.Close()
+
+		return mysqlmigrations.Migrate(ctx, sqlDB, logger, mysqlmigrations.Options(options))
+
+	case "memory":
+		// every memory database starts with the latest schema
+		logger.Infof("no migrations to apply to the memory database")
+		return nil
 	}
 	return errors.New("unsupported database type")
 }
+
+// MigrationsStatus lists the applied and pending migrations
+func MigrationsStatus(ctx context.Context, config *Config, logger log.Logger) ([]*MigrationStatus, error) {
+	switch config.DBType {
+	case "postgres":
+		dbConfig, err := postgresql.InitConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		pool, err := postgresql.NewPool(ctx, dbConfig, logger)
+		if err != nil {
+			return nil, err
+		}
+		defer pool.Close()
+
+		return migrations.Status(ctx, pool, dbConfig.Schema)
+
+	case "mysql", "tidb":
+		dbConfig, err := mysql.InitConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		sqlDB, err := mysql.NewSQLDB(ctx, dbConfig)
+		if err != nil {
+			return nil, err
+		}
+		defer sqlDB.Close()
+
+		statuses, err := mysqlmigrations.Status(ctx, sqlDB)
+		if err != nil {
+			return nil, err
+		}
+
+		result := make([]*MigrationStatus, 0, len(statuses))
+		for _, status := range statuses {
+			result = append(result, (*MigrationStatus)(status))
+		}
+		return result, nil
+
+	case "memory":
+		return make([]*MigrationStatus, 0), nil
+	}
+	return nil, errors.New("unsupported database type")
+}
+
+// NewMigration scaffolds the up and down SQL files of the next migration in
+// dir, the SQLDir of the migrations of the database type when empty
+func NewMigration(config *Config, dir string, name string) (up string, down string, err error) {
+	switch config.DBType {
+	case "postgres":
+		if dir == "" {
+			dir = migrations.SQLDir
+		}
+		return migrations.NewSQLMigration(dir, name)
+
+	case "mysql", "tidb":
+		if dir == "" {
+			dir = mysqlmigrations.SQLDir
+		}
+		return mysqlmigrations.NewSQLMigration(dir, name)
+
+	case "memory":
+		return "", "", errors.New("the memory database has no migrations, set Database.Type to the database the migration is for")
+	}
+	return "", "", errors.New("unsupported database type")
+}
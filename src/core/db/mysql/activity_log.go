@@ -0,0 +1,455 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go-template/src/core/audit"
+	"go-template/src/core/listquery"
+	"go-template/src/core/model"
+)
+
+// activityLogInsertBatch keeps a multi-row insert well under the 65535
+// placeholders of a statement
+const activityLogInsertBatch = 500
+
+var activityLogColumns = []string{
+	"request_no",
+	"trace_id",
+	"span_id",
+	"service_code",
+	"user_id",
+	"azure_user_id",
+	"email_address",
+	"user_roles",
+	"http_method",
+	"request_uri",
+	"http_status_code",
+	"response_code",
+	"duration_ms",
+	"ip_address",
+	"user_agent",
+	"request_body",
+	"response_body",
+	"created_time",
+	"chain_date",
+	"chain_seq",
+	"prev_hash",
+	"row_hash",
+}
+
+// activityLogSelectColumns is read by scanActivityLog
+const activityLogSelectColumns = `
+	id, COALESCE(chain_seq, 0), COALESCE(prev_hash, ''), COALESCE(row_hash, ''),
+	COALESCE(request_no, ''), COALESCE(trace_id, ''), COALESCE(span_id, ''), service_code,
+	user_id, azure_user_id, email_address, user_roles, http_method, request_uri,
+	http_status_code, response_code, duration_ms, COALESCE(ip_address, ''), user_agent,
+	request_body, response_body, created_time
+`
+
+// CreateActivityLog appends activityLog to the hash chain. The MySQL backend
+// always writes on the request path, Database.ActivityLog.Async only
+// applies to Postgres.
+func (mydb *MySQLDB) CreateActivityLog(ctx context.Context, activityLog *model.ActivityLog) error {
+	if activityLog.CreatedTime.IsZero() {
+		activityLog.CreatedTime = time.Now()
+	}
+
+	return mydb.begin(ctx, func(tx *MySQLDB) error {
+		return appendActivityLogs(ctx, tx.DB, []*model.ActivityLog{activityLog})
+	})
+}
+
+// appendActivityLogs links batch to the hash chain of each row's day and
+// inserts it, in the transaction of db. The chain row of every day is
+// locked with SELECT ... FOR UPDATE, so several replicas can append
+// concurrently.
+func appendActivityLogs(ctx context.Context, db Querier, batch []*model.ActivityLog) error {
+	days := make(map[time.Time][]*model.ActivityLog)
+	for _, activityLog := range batch {
+		activityLog.CreatedTime = activityLog.CreatedTime.UTC().Truncate(audit.Precision)
+		day := audit.ChainDate(activityLog.CreatedTime)
+		days[day] = append(days[day], activityLog)
+	}
+
+	// lock in a fixed order so concurrent batches spanning midnight cannot deadlock
+	chainDates := make([]time.Time, 0, len(days))
+	for day := range days {
+		chainDates = append(chainDates, day)
+	}
+	sort.Slice(chainDates, func(i, j int) bool {
+		return chainDates[i].Before(chainDates[j])
+	})
+
+	for _, day := range chainDates {
+		if err := linkActivityLogs(ctx, db, day, days[day]); err != nil {
+			return err
+		}
+	}
+
+	for start := 0; start < len(batch); start += activityLogInsertBatch {
+		end := min(start+activityLogInsertBatch, len(batch))
+		if err := insertActivityLogs(ctx, db, "activity_log", batch[start:end], false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func linkActivityLogs(ctx context.Context, db Querier, day time.Time, activityLogs []*model.ActivityLog) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT IGNORE INTO activity_log_chain (chain_date, last_seq, last_hash, updated_time)
+		VALUES (?, 0, '', UTC_TIMESTAMP(6))
+	`, day)
+	if err != nil {
+		return errors.Wrap(err, "Can not lock activity log chain")
+	}
+
+	var lastSeq int64
+	var lastHash string
+	err = db.QueryRowContext(ctx, `
+		SELECT last_seq, last_hash FROM activity_log_chain WHERE chain_date = ? FOR UPDATE
+	`, day).Scan(&lastSeq, &lastHash)
+	if err != nil {
+		return errors.Wrap(err, "Can not read activity log chain")
+	}
+
+	// rows of a day are chained in creation order
+	sort.SliceStable(activityLogs, func(i, j int) bool {
+		return activityLogs[i].CreatedTime.Before(activityLogs[j].CreatedTime)
+	})
+
+	for _, activityLog := range activityLogs {
+		lastSeq++
+		activityLog.ChainSeq = lastSeq
+		activityLog.PrevHash = lastHash
+		activityLog.RowHash = audit.Hash(lastHash, activityLog)
+		lastHash = activityLog.RowHash
+	}
+
+	_, err = db.ExecContext(ctx, `
+		UPDATE activity_log_chain
+		SET last_seq = ?, last_hash = ?, updated_time = UTC_TIMESTAMP(6)
+		WHERE chain_date = ?
+	`, lastSeq, lastHash, day)
+	if err != nil {
+		return errors.Wrap(err, "Can not update activity log chain")
+	}
+
+	return nil
+}
+
+// insertActivityLogs inserts activityLogs into table, activity_log or a
+// table shaped like it, in one statement. keepID inserts their ID instead of
+// a new one, for restored rows.
+func insertActivityLogs(ctx context.Context, db Querier, table string, activityLogs []*model.ActivityLog, keepID bool) error {
+	columns := activityLogColumns
+	if keepID {
+		columns = append([]string{"id"}, columns...)
+	}
+
+	rowPlaceholder := "(" + placeholders(len(columns)) + ")"
+	rows := make([]string, 0, len(activityLogs))
+	args := make([]any, 0, len(activityLogs)*len(columns))
+	for _, activityLog := range activityLogs {
+		row, err := activityLogRow(activityLog)
+		if err != nil {
+			return err
+		}
+		if keepID {
+			row = append([]any{activityLog.ID}, row...)
+		}
+		rows = append(rows, rowPlaceholder)
+		args = append(args, row...)
+	}
+
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO `+quoteIdentifier(table)+` (`+strings.Join(columns, ", ")+`) VALUES `+strings.Join(rows, ", "),
+		args...,
+	)
+	return errors.Wrap(err, "Can not insert activity log")
+}
+
+func activityLogRow(activityLog *model.ActivityLog) ([]any, error) {
+	userRoles := activityLog.UserRoles
+	if userRoles == nil {
+		userRoles = []string{}
+	}
+	userRolesJSON, err := json.Marshal(userRoles)
+	if err != nil {
+		return nil, err
+	}
+
+	return []any{
+		activityLog.RequestNo,
+		activityLog.TraceID,
+		activityLog.SpanID,
+		activityLog.ServiceCode,
+		activityLog.UserID,
+		activityLog.AzureUserID,
+		activityLog.EmailAddress,
+		string(userRolesJSON),
+		activityLog.HTTPMethod,
+		activityLog.RequestURI,
+		activityLog.HTTPStatusCode,
+		activityLog.ResponseCode,
+		activityLog.DurationMs,
+		activityLog.IPAddress,
+		activityLog.UserAgent,
+		activityLog.RequestBody,
+		activityLog.ResponseBody,
+		activityLog.CreatedTime.UTC(),
+		audit.ChainDate(activityLog.CreatedTime),
+		activityLog.ChainSeq,
+		activityLog.PrevHash,
+		activityLog.RowHash,
+	}, nil
+}
+
+// scanner is *sql.Rows or *sql.Row
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanActivityLog reads the activityLogSelectColumns of row
+func scanActivityLog(row scanner) (*model.ActivityLog, error) {
+	activityLog := &model.ActivityLog{}
+	var userRoles []byte
+	err := row.Scan(
+		&activityLog.ID,
+		&activityLog.ChainSeq,
+		&activityLog.PrevHash,
+		&activityLog.RowHash,
+		&activityLog.RequestNo,
+		&activityLog.TraceID,
+		&activityLog.SpanID,
+		&activityLog.ServiceCode,
+		&activityLog.UserID,
+		&activityLog.AzureUserID,
+		&activityLog.EmailAddress,
+		&userRoles,
+		&activityLog.HTTPMethod,
+		&activityLog.RequestURI,
+		&activityLog.HTTPStatusCode,
+		&activityLog.ResponseCode,
+		&activityLog.DurationMs,
+		&activityLog.IPAddress,
+		&activityLog.UserAgent,
+		&activityLog.RequestBody,
+		&activityLog.ResponseBody,
+		&activityLog.CreatedTime,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	activityLog.UserRoles = []string{}
+	if len(userRoles) > 0 {
+		if err := json.Unmarshal(userRoles, &activityLog.UserRoles); err != nil {
+			return nil, errors.Wrap(err, "invalid user_roles")
+		}
+	}
+
+	return activityLog, nil
+}
+
+func (mydb *MySQLDB) InquiryActivityLog(ctx context.Context, filter model.ActivityLogFilter, list *listquery.List) ([]*model.ActivityLog, *model.Pagination, error) {
+	query := NewQuery().
+		Equal("user_id", filter.UserID).
+		EqualFold("email_address", filter.EmailAddress).
+		Equal("service_code", filter.ServiceCode).
+		Equal("http_status_code", filter.HTTPStatusCode).
+		Equal("request_no", filter.RequestNo).
+		Equal("trace_id", filter.TraceID).
+		TimeRange("created_time", filter.StartTime, filter.EndTime)
+
+	result, pagination, err := selectList(ctx, mydb.DB, "activity_log", activityLogSelectColumns, query, list,
+		func(rows *sql.Rows) (*model.ActivityLog, error) {
+			return scanActivityLog(rows)
+		},
+	)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Can not select activity log list from database")
+	}
+
+	return result, pagination, nil
+}
+
+// ListActivityLogChainHeads returns the chain heads of the days in
+// [from, to], oldest first. Zero times leave the range open.
+func (mydb *MySQLDB) ListActivityLogChainHeads(ctx context.Context, from, to time.Time) ([]*model.ActivityLogChainHead, error) {
+	heads, err := mydb.queryChainHeads(ctx, `
+		SELECT chain_date, last_seq, last_hash
+		FROM activity_log_chain
+		WHERE last_seq > 0 AND (? IS NULL OR chain_date >= ?) AND (? IS NULL OR chain_date <= ?)
+		ORDER BY chain_date
+	`, nullableDate(from), nullableDate(from), nullableDate(to), nullableDate(to))
+	if err != nil {
+		return nil, errors.Wrap(err, "Can not list activity log chains")
+	}
+
+	return heads, nil
+}
+
+// ListUncheckpointedActivityLogChainHeads returns the chain heads that moved
+// since their last checkpoint
+func (mydb *MySQLDB) ListUncheckpointedActivityLogChainHeads(ctx context.Context) ([]*model.ActivityLogChainHead, error) {
+	heads, err := mydb.queryChainHeads(ctx, `
+		SELECT h.chain_date, h.last_seq, h.last_hash
+		FROM activity_log_chain h
+		WHERE h.last_seq > 0 AND NOT EXISTS (
+			SELECT 1 FROM activity_log_checkpoint c
+			WHERE c.chain_date = h.chain_date AND c.chain_seq = h.last_seq
+		)
+		ORDER BY h.chain_date
+	`)
+	if err != nil {
+		return nil, errors.Wrap(err, "Can not list activity log chains")
+	}
+
+	return heads, nil
+}
+
+func (mydb *MySQLDB) queryChainHeads(ctx context.Context, query string, args ...any) ([]*model.ActivityLogChainHead, error) {
+	rows, err := mydb.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	heads := make([]*model.ActivityLogChainHead, 0)
+	for rows.Next() {
+		head := &model.ActivityLogChainHead{}
+		if err := rows.Scan(&head.ChainDate, &head.LastSeq, &head.LastHash); err != nil {
+			return nil, err
+		}
+		heads = append(heads, head)
+	}
+	return heads, rows.Err()
+}
+
+// WalkActivityLogChain calls fn for every chained row of day in chain order
+func (mydb *MySQLDB) WalkActivityLogChain(ctx context.Context, day time.Time, fn func(activityLog *model.ActivityLog) error) error {
+	rows, err := mydb.DB.QueryContext(ctx, `
+		SELECT `+activityLogSelectColumns+`
+		FROM activity_log
+		WHERE chain_date = ? AND chain_seq IS NOT NULL
+		ORDER BY chain_seq
+	`, day)
+	if err != nil {
+		return errors.Wrap(err, "Can not read activity log chain")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		activityLog, err := scanActivityLog(rows)
+		if err != nil {
+			return errors.Wrap(err, "Can not read activity log chain")
+		}
+
+		if err := fn(activityLog); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// CreateActivityLogCheckpoint stores a signed checkpoint, ignoring one that
+// already exists for the same link
+func (mydb *MySQLDB) CreateActivityLogCheckpoint(ctx context.Context, checkpoint *model.ActivityLogCheckpoint) error {
+	_, err := mydb.DB.ExecContext(ctx, `
+		INSERT IGNORE INTO activity_log_checkpoint (chain_date, chain_seq, row_hash, key_id, signature)
+		VALUES (?, ?, ?, ?, ?)
+	`,
+		checkpoint.ChainDate,
+		checkpoint.ChainSeq,
+		checkpoint.RowHash,
+		checkpoint.KeyID,
+		checkpoint.Signature,
+	)
+	if err != nil {
+		return errors.Wrap(err, "Can not create activity log checkpoint")
+	}
+
+	return nil
+}
+
+// ListActivityLogCheckpoints returns the checkpoints of day
+func (mydb *MySQLDB) ListActivityLogCheckpoints(ctx context.Context, day time.Time) ([]*model.ActivityLogCheckpoint, error) {
+	rows, err := mydb.DB.QueryContext(ctx, `
+		SELECT id, chain_date, chain_seq, row_hash, key_id, signature, created_time
+		FROM activity_log_checkpoint
+		WHERE chain_date = ?
+		ORDER BY chain_seq
+	`, day)
+	if err != nil {
+		return nil, errors.Wrap(err, "Can not list activity log checkpoints")
+	}
+	defer rows.Close()
+
+	checkpoints := make([]*model.ActivityLogCheckpoint, 0)
+	for rows.Next() {
+		checkpoint := &model.ActivityLogCheckpoint{}
+		err := rows.Scan(
+			&checkpoint.ID,
+			&checkpoint.ChainDate,
+			&checkpoint.ChainSeq,
+			&checkpoint.RowHash,
+			&checkpoint.KeyID,
+			&checkpoint.Signature,
+			&checkpoint.CreatedTime,
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "Can not list activity log checkpoints")
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "Can not list activity log checkpoints")
+	}
+
+	return checkpoints, nil
+}
+
+// ListActivityLogCheckpointDays returns the days in [from, to] that have
+// checkpoints, oldest first. Zero times leave the range open.
+func (mydb *MySQLDB) ListActivityLogCheckpointDays(ctx context.Context, from, to time.Time) ([]time.Time, error) {
+	rows, err := mydb.DB.QueryContext(ctx, `
+		SELECT DISTINCT chain_date
+		FROM activity_log_checkpoint
+		WHERE (? IS NULL OR chain_date >= ?) AND (? IS NULL OR chain_date <= ?)
+		ORDER BY chain_date
+	`, nullableDate(from), nullableDate(from), nullableDate(to), nullableDate(to))
+	if err != nil {
+		return nil, errors.Wrap(err, "Can not list activity log checkpoint days")
+	}
+	defer rows.Close()
+
+	days := make([]time.Time, 0)
+	for rows.Next() {
+		var day time.Time
+		if err := rows.Scan(&day); err != nil {
+			return nil, errors.Wrap(err, "Can not list activity log checkpoint days")
+		}
+		days = append(days, day)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "Can not list activity log checkpoint days")
+	}
+
+	return days, nil
+}
+
+func nullableDate(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,253 @@
+package mysql
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go-template/src/core/model"
+)
+
+const activityLogPartitionPrefix = "activity_log_p"
+
+// ActivityLogPartitionName returns the name of the partition holding month
+func ActivityLogPartitionName(month time.Time) string {
+	return activityLogPartitionPrefix + month.Format("200601")
+}
+
+// ActivityLogPartitionSQL is the partition of month, for CREATE TABLE and
+// ALTER TABLE ADD PARTITION
+func ActivityLogPartitionSQL(month time.Time) string {
+	month = time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return fmt.Sprintf("PARTITION %s VALUES LESS THAN ('%s')",
+		quoteIdentifier(ActivityLogPartitionName(month)), month.AddDate(0, 1, 0).Format(time.DateOnly))
+}
+
+// activityLogArchiveRow is the JSON of a row in an archive, the fields of
+// Postgres's row_to_json so archives restore on either backend
+type activityLogArchiveRow struct {
+	*model.ActivityLog
+	ChainDate string `json:"chain_date"`
+}
+
+// EnsureActivityLogPartitions adds the partitions for the month of from and
+// the following months, skipping the ones that exist. activity_log is
+// partitioned by RANGE COLUMNS, so only months after the last partition can
+// be added; earlier months already fall in the first partition.
+func (mydb *MySQLDB) EnsureActivityLogPartitions(ctx context.Context, from time.Time, months int) ([]string, error) {
+	partitions, err := mydb.ListActivityLogPartitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var last time.Time
+	existing := make(map[string]bool)
+	for _, partition := range partitions {
+		existing[partition.Name] = true
+		if partition.Month.After(last) {
+			last = partition.Month
+		}
+	}
+	if last.IsZero() {
+		return nil, errors.New("activity_log has no monthly partitions, run migrate-db")
+	}
+
+	names := make([]string, 0, months+1)
+	month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i <= months; i++ {
+		current := month.AddDate(0, i, 0)
+		name := ActivityLogPartitionName(current)
+
+		if !existing[name] {
+			if !current.After(last) {
+				continue
+			}
+
+			// months skipped since the last partition are added in order
+			for next := last.AddDate(0, 1, 0); !next.After(current); next = next.AddDate(0, 1, 0) {
+				_, err := mydb.DB.ExecContext(ctx, `ALTER TABLE activity_log ADD PARTITION (`+ActivityLogPartitionSQL(next)+`)`)
+				if err != nil {
+					return nil, errors.Wrapf(err, "Can not create activity log partition for %s", next.Format("2006-01"))
+				}
+			}
+			last = current
+		}
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// ListActivityLogPartitions returns the monthly partitions, oldest first
+func (mydb *MySQLDB) ListActivityLogPartitions(ctx context.Context) ([]*model.ActivityLogPartition, error) {
+	rows, err := mydb.DB.QueryContext(ctx, `
+		SELECT
+			PARTITION_NAME
+		FROM
+			information_schema.PARTITIONS
+		WHERE
+			TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'activity_log' AND PARTITION_NAME IS NOT NULL
+		ORDER BY PARTITION_ORDINAL_POSITION
+	`)
+	if err != nil {
+		return nil, errors.Wrap(err, "Can not list activity log partitions")
+	}
+	defer rows.Close()
+
+	result := make([]*model.ActivityLogPartition, 0)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, errors.Wrap(err, "Can not list activity log partitions")
+		}
+
+		month, err := time.Parse("200601", strings.TrimPrefix(name, activityLogPartitionPrefix))
+		if err != nil || !strings.HasPrefix(name, activityLogPartitionPrefix) {
+			// not created by ActivityLogPartitionSQL, leave it alone
+			continue
+		}
+		result = append(result, &model.ActivityLogPartition{
+			Name:  name,
+			Month: month,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "Can not list activity log partitions")
+	}
+
+	return result, nil
+}
+
+// ExportActivityLogPartition writes every row of the partition of month to w
+// as JSON Lines, in insertion order, and returns the number of rows
+func (mydb *MySQLDB) ExportActivityLogPartition(ctx context.Context, month time.Time, w io.Writer) (int64, error) {
+	partition := quoteIdentifier(ActivityLogPartitionName(month))
+
+	rows, err := mydb.DB.QueryContext(ctx,
+		`SELECT `+activityLogSelectColumns+`, chain_date FROM activity_log PARTITION (`+partition+`) ORDER BY created_time, id`,
+	)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Can not export activity log partition %s", partition)
+	}
+	defer rows.Close()
+
+	var count int64
+	for rows.Next() {
+		var chainDate *time.Time
+		activityLog, err := scanActivityLog(scanFunc(func(dest ...any) error {
+			return rows.Scan(append(dest, &chainDate)...)
+		}))
+		if err != nil {
+			return count, errors.Wrapf(err, "Can not export activity log partition %s", partition)
+		}
+
+		row := activityLogArchiveRow{ActivityLog: activityLog}
+		if chainDate != nil {
+			row.ChainDate = chainDate.Format(time.DateOnly)
+		}
+		line, err := json.Marshal(row)
+		if err != nil {
+			return count, err
+		}
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, errors.Wrapf(err, "Can not export activity log partition %s", partition)
+	}
+
+	return count, nil
+}
+
+// scanFunc adapts a function to scanner
+type scanFunc func(dest ...any) error
+
+func (f scanFunc) Scan(dest ...any) error {
+	return f(dest...)
+}
+
+// DropActivityLogPartition drops the partition of month
+func (mydb *MySQLDB) DropActivityLogPartition(ctx context.Context, month time.Time) error {
+	partition := quoteIdentifier(ActivityLogPartitionName(month))
+
+	_, err := mydb.DB.ExecContext(ctx, `ALTER TABLE activity_log DROP PARTITION `+partition)
+	if err != nil {
+		return errors.Wrapf(err, "Can not drop activity log partition %s", partition)
+	}
+
+	return nil
+}
+
+// RestoreActivityLogArchive loads JSON Lines produced by
+// ExportActivityLogPartition into a new table shaped like activity_log and
+// returns the number of rows loaded. r is streamed. DDL is not transactional
+// here, so the table is dropped again when loading or reading r fails.
+func (mydb *MySQLDB) RestoreActivityLogArchive(ctx context.Context, tableName string, r io.Reader) (count int64, err error) {
+	table := quoteIdentifier(tableName)
+
+	_, err = mydb.DB.ExecContext(ctx, `CREATE TABLE `+table+` LIKE activity_log`)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Can not create restore table %s", table)
+	}
+	defer func() {
+		if err != nil {
+			_, _ = mydb.DB.ExecContext(context.WithoutCancel(ctx), `DROP TABLE IF EXISTS `+table)
+		}
+	}()
+
+	_, err = mydb.DB.ExecContext(ctx, `ALTER TABLE `+table+` REMOVE PARTITIONING`)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Can not create restore table %s", table)
+	}
+
+	// rows are inserted in batches as they are read
+	err = mydb.begin(ctx, func(tx *MySQLDB) error {
+		insert := func(rows []*model.ActivityLog) error {
+			if err := insertActivityLogs(ctx, tx.DB, tableName, rows, true); err != nil {
+				return errors.Wrapf(err, "Can not restore activity log archive into %s", table)
+			}
+			count += int64(len(rows))
+			return nil
+		}
+
+		rows := make([]*model.ActivityLog, 0, activityLogInsertBatch)
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+		for scanner.Scan() {
+			if len(scanner.Bytes()) == 0 {
+				continue
+			}
+			row := activityLogArchiveRow{ActivityLog: &model.ActivityLog{}}
+			if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+				return errors.Wrap(err, "Can not read activity log archive")
+			}
+			rows = append(rows, row.ActivityLog)
+			if len(rows) == activityLogInsertBatch {
+				if err := insert(rows); err != nil {
+					return err
+				}
+				rows = rows[:0]
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return errors.Wrap(err, "Can not read activity log archive")
+		}
+		if len(rows) > 0 {
+			return insert(rows)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
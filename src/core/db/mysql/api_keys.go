@@ -0,0 +1,139 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+	"go-template/src/core/model"
+)
+
+// keyColumn is the quoted key column, KEY is a reserved word
+const keyColumn = "`key`"
+
+func (mydb *MySQLDB) InsertApiKeys(ctx context.Context, apiKeysList []model.ApiKey, isRoot bool) error {
+	return mydb.WithTx(ctx, func(tx *MySQLDB) error {
+		for _, apiKey := range apiKeysList {
+			_, err := tx.DB.ExecContext(ctx, `
+					INSERT INTO api_keys(
+						`+keyColumn+`,
+						azure_user_id,
+						user_id,
+						email_address,
+						user_role_name,
+						expire_time,
+						user_profile_pic
+					)
+					VALUES (?, ?, ?, ?, ?, ?, ?)
+				`,
+				apiKey.Key,
+				apiKey.AzureUserID,
+				apiKey.UserID,
+				apiKey.EmailAddress,
+				apiKey.UserRoleName,
+				apiKey.ExpireTime,
+				apiKey.UserProfilePic,
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (mydb *MySQLDB) VerifyApiKey(ctx context.Context, key string, newExpireTime time.Time) ([]*model.ApiKey, error) {
+	_, err := mydb.DB.ExecContext(ctx, `
+		UPDATE api_keys
+		SET expire_time = ?
+		WHERE `+keyColumn+` = ? AND expire_time >= UTC_TIMESTAMP(6)
+	`,
+		newExpireTime,
+		key,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := mydb.DB.QueryContext(ctx, `
+		SELECT
+			`+keyColumn+`,
+			azure_user_id,
+			COALESCE(user_id, 0),
+			COALESCE(email_address, ''),
+			COALESCE(user_role_name, ''),
+			expire_time,
+			created_time,
+			COALESCE(user_profile_pic, '')
+		FROM
+			api_keys
+		WHERE
+			`+keyColumn+` = ?
+	`,
+		key,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "Can not select api key list from database")
+	}
+	defer rows.Close()
+
+	result := make([]*model.ApiKey, 0)
+	for rows.Next() {
+		apiKey, err := scanApiKey(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "Can not select api key list from database")
+		}
+		result = append(result, apiKey)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "Can not select api key list from database")
+	}
+
+	return result, nil
+}
+
+func scanApiKey(rows *sql.Rows) (*model.ApiKey, error) {
+	apiKey := &model.ApiKey{}
+	err := rows.Scan(
+		&apiKey.Key,
+		&apiKey.AzureUserID,
+		&apiKey.UserID,
+		&apiKey.EmailAddress,
+		&apiKey.UserRoleName,
+		&apiKey.ExpireTime,
+		&apiKey.CreatedTime,
+		&apiKey.UserProfilePic,
+	)
+	return apiKey, err
+}
+
+func (mydb *MySQLDB) DeleteApiKey(ctx context.Context, key string) error {
+	_, err := mydb.DB.ExecContext(ctx, `
+		DELETE FROM api_keys WHERE `+keyColumn+` = ?
+	`,
+		key,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (mydb *MySQLDB) DeleteExpireApiKey(ctx context.Context) error {
+	result, err := mydb.DB.ExecContext(ctx, `
+		DELETE FROM api_keys WHERE expire_time IS NOT NULL AND expire_time < UTC_TIMESTAMP(6)
+	`,
+	)
+	if err != nil {
+		return err
+	}
+
+	if deleted, err := result.RowsAffected(); err == nil && deleted > 0 {
+		mydb.logger.Infof("Deleted %v api key expire", deleted)
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package mysql
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+)
+
+type Config struct {
+	Host         string
+	Port         string
+	Username     string
+	Password     string
+	DatabaseName string
+	MaxOpenConns int
+	// TLS is the tls parameter of the driver: false, true, skip-verify or
+	// preferred. TiDB Cloud needs true.
+	TLS string
+
+	Transaction *TransactionConfig
+}
+
+// TransactionConfig is the default of WithTx, overridden per call with
+// TxOption. An empty IsolationLevel uses the server default.
+type TransactionConfig struct {
+	IsolationLevel IsolationLevel
+	MaxRetries     int
+}
+
+func InitConfig() (config *Config, err error) {
+	dbHost := viper.GetString("MYSQL_HOST")
+	if dbHost == "" {
+		dbHost = viper.GetString("Database.MySQL.Host")
+	}
+
+	dbPort := viper.GetString("MYSQL_PORT")
+	if dbPort == "" {
+		dbPort = viper.GetString("Database.MySQL.Port")
+	}
+
+	dbUsername := viper.GetString("MYSQL_USERNAME")
+	if dbUsername == "" {
+		dbUsername = viper.GetString("Database.MySQL.Username")
+	}
+
+	dbPassword := viper.GetString("MYSQL_PASSWORD")
+	if dbPassword == "" {
+		dbPassword = viper.GetString("Database.MySQL.Password")
+	}
+
+	dbDBName := viper.GetString("MYSQL_DB_NAME")
+	if dbDBName == "" {
+		dbDBName = viper.GetString("Database.MySQL.DBName")
+	}
+
+	config = &Config{
+		Host:         dbHost,
+		Port:         dbPort,
+		Username:     dbUsername,
+		Password:     dbPassword,
+		DatabaseName: dbDBName,
+		MaxOpenConns: viper.GetInt("Database.MySQL.MaxOpenConns"),
+		TLS:          viper.GetString("Database.MySQL.TLS"),
+	}
+
+	config.Transaction, err = initTransactionConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Host == "" {
+		config.Host = "localhost"
+	}
+	if config.Port == "" {
+		config.Port = "3306"
+	}
+	if config.Username == "" {
+		config.Username = "root"
+	}
+	if config.DatabaseName == "" {
+		config.DatabaseName = "evaluation"
+	}
+	if config.TLS == "" {
+		config.TLS = "false"
+	}
+
+	return config, nil
+}
+
+func initTransactionConfig() (*TransactionConfig, error) {
+	isolationLevel, err := parseIsolationLevel(viper.GetString("Database.Transaction.IsolationLevel"))
+	if err != nil {
+		return nil, err
+	}
+
+	maxRetries := 3
+	if viper.IsSet("Database.Transaction.MaxRetries") {
+		maxRetries = viper.GetInt("Database.Transaction.MaxRetries")
+	}
+
+	return &TransactionConfig{
+		IsolationLevel: isolationLevel,
+		MaxRetries:     maxRetries,
+	}, nil
+}
+
+func parseIsolationLevel(level string) (IsolationLevel, error) {
+	switch IsolationLevel(level) {
+	case "":
+		return "", nil
+	case ReadCommitted, RepeatableRead, Serializable:
+		return IsolationLevel(level), nil
+	default:
+		return "", fmt.Errorf("invalid Database.Transaction.IsolationLevel %q, expected %q, %q or %q",
+			level, ReadCommitted, RepeatableRead, Serializable)
+	}
+}
@@ -0,0 +1,77 @@
+package mysql
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go-template/src/core/model"
+)
+
+// InquiryDataChangeLog returns the history of one record, newest first.
+// TiDB has no triggers, so on this backend data_change_log only holds rows
+// written by the application.
+func (mydb *MySQLDB) InquiryDataChangeLog(ctx context.Context, tableName, recordID string, page, limit int64) ([]*model.DataChangeLog, *model.Pagination, error) {
+	query := NewQuery().
+		Where("table_name = ?", tableName).
+		Where("record_id = ?", recordID)
+
+	var total int64
+	err := mydb.DB.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM data_change_log `+query.WhereSQL(),
+		query.Args()...,
+	).Scan(&total)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Can not count data change log from database")
+	}
+
+	query.Page(page, limit)
+
+	rows, err := mydb.DB.QueryContext(ctx, `
+		SELECT
+			id, table_name, record_id, operation, changes, acting_user_id, request_no, db_user, created_time
+		FROM
+			data_change_log
+		`+query.WhereSQL()+`
+		ORDER BY created_time DESC, id DESC
+		`+query.LimitSQL(),
+		query.Args()...,
+	)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Can not select data change log from database")
+	}
+	defer rows.Close()
+
+	result := make([]*model.DataChangeLog, 0)
+	for rows.Next() {
+		change := &model.DataChangeLog{}
+		var changes []byte
+		err := rows.Scan(
+			&change.ID,
+			&change.TableName,
+			&change.RecordID,
+			&change.Operation,
+			&changes,
+			&change.ActingUserID,
+			&change.RequestNo,
+			&change.DBUser,
+			&change.CreatedTime,
+		)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "Can not select data change log from database")
+		}
+		change.Changes = changes
+		result = append(result, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, errors.Wrap(err, "Can not select data change log from database")
+	}
+
+	pagination := &model.Pagination{
+		Total:   total,
+		Limit:   limit,
+		Page:    page,
+		HasMore: page*limit < total,
+	}
+
+	return result, pagination, nil
+}
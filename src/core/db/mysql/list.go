@@ -0,0 +1,147 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"go-template/src/core/listquery"
+	"go-template/src/core/model"
+)
+
+// List adds the filters, sort and cursor condition of list
+func (q *Query) List(list *listquery.List) *Query {
+	for _, filter := range list.Filters {
+		switch filter.Op {
+		case listquery.OpEq:
+			q.Where(quoteColumn(filter.Column)+" = ?", filter.Value)
+		case listquery.OpNe:
+			q.Where(quoteColumn(filter.Column)+" <> ?", filter.Value)
+		case listquery.OpLt:
+			q.Where(quoteColumn(filter.Column)+" < ?", filter.Value)
+		case listquery.OpLte:
+			q.Where(quoteColumn(filter.Column)+" <= ?", filter.Value)
+		case listquery.OpGt:
+			q.Where(quoteColumn(filter.Column)+" > ?", filter.Value)
+		case listquery.OpGte:
+			q.Where(quoteColumn(filter.Column)+" >= ?", filter.Value)
+		case listquery.OpIn:
+			q.In(filter.Column, filter.Value)
+		case listquery.OpNotIn:
+			q.NotIn(filter.Column, filter.Value)
+		case listquery.OpContains:
+			q.Contains(filter.Column, filter.Value.(string))
+		case listquery.OpPrefix:
+			q.HasPrefix(filter.Column, filter.Value.(string))
+		case listquery.OpHas:
+			q.ArrayHas(filter.Column, filter.Value)
+		default:
+			panic(fmt.Sprintf("mysql: unknown list operator %q", filter.Op))
+		}
+	}
+
+	q.orderBy = make([]string, 0, len(list.Sort))
+	columns := make([]string, 0, len(list.Sort))
+	desc := make([]bool, 0, len(list.Sort))
+	for _, key := range list.Sort {
+		direction := "ASC"
+		if key.Desc {
+			direction = "DESC"
+		}
+		q.orderBy = append(q.orderBy, quoteColumn(key.Column)+" "+direction)
+		columns = append(columns, key.Column)
+		desc = append(desc, key.Desc)
+	}
+
+	switch {
+	case !list.Keyset():
+	case list.MixedDirections():
+		q.AfterMixed(columns, list.After, desc)
+	default:
+		q.After(columns, list.After, list.Sort[0].Desc)
+	}
+
+	return q
+}
+
+// selectList selects columns from table with the list query, after the
+// conditions already in q, scanning each row with scan. It returns the
+// pagination with the total on page requests and the next cursor when
+// there are more rows; the cursor takes the sort values from the JSON of
+// the last row, so the JSON names of T must be the column names.
+func selectList[T any](ctx context.Context, db Querier, table string, columns string, q *Query, list *listquery.List, scan func(rows *sql.Rows) (T, error)) ([]T, *model.Pagination, error) {
+	q.List(list)
+
+	pagination := &model.Pagination{
+		Limit: list.Limit,
+	}
+
+	if !list.Keyset() {
+		pagination.Page = list.Page
+		err := db.QueryRowContext(ctx,
+			`SELECT COUNT(*) FROM `+table+` `+q.WhereSQL(),
+			q.Args()...,
+		).Scan(&pagination.Total)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "Can not count list from database")
+		}
+	}
+
+	// one more row tells whether there is a next page
+	q.limit = list.Limit + 1
+	q.offset = list.Offset()
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT `+columns+` FROM `+table+` `+q.WhereSQL()+` `+q.OrderBySQL()+` `+q.LimitSQL(),
+		q.Args()...,
+	)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Can not select list from database")
+	}
+	defer rows.Close()
+
+	result := make([]T, 0)
+	for rows.Next() {
+		row, err := scan(rows)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "Can not read list from database")
+		}
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, errors.Wrap(err, "Can not select list from database")
+	}
+
+	if int64(len(result)) > list.Limit {
+		result = result[:list.Limit]
+		pagination.HasMore = true
+
+		values, err := sortValues(result[len(result)-1], list)
+		if err != nil {
+			return nil, nil, err
+		}
+		pagination.NextCursor = list.NextCursor(values)
+	}
+
+	return result, pagination, nil
+}
+
+func sortValues(row any, list *listquery.List) ([]json.RawMessage, error) {
+	b, err := json.Marshal(row)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+
+	values := make([]json.RawMessage, 0, len(list.Sort))
+	for _, key := range list.Sort {
+		values = append(values, fields[key.Column])
+	}
+	return values, nil
+}
@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+// key is a reserved word in MySQL
+const createApiKeysTableSQL = `
+	CREATE TABLE IF NOT EXISTS api_keys (
+		` + "`key`" + ` varchar(255) NOT NULL PRIMARY KEY,
+		azure_user_id varchar(255) NOT NULL,
+		user_id bigint,
+		email_address varchar(255),
+		user_role_name varchar(255),
+		expire_time datetime(6) NOT NULL,
+		created_time datetime(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+		user_profile_pic text,
+		KEY ak_user_id_idx (user_id),
+		KEY ak_expire_time_idx (expire_time)
+	);
+`
+
+var createApiKeysTableMigration = &Migration{
+	Number: 1,
+	Name:   "Create api_keys table",
+	Source: createApiKeysTableSQL,
+	Forwards: func(ctx context.Context, tx *sql.Tx) error {
+		return errors.Wrap(execStatements(ctx, tx, createApiKeysTableSQL), "unable to create api_keys table")
+	},
+	Backwards: func(ctx context.Context, tx *sql.Tx) error {
+		const sql = `DROP TABLE IF EXISTS api_keys;`
+
+		_, err := tx.ExecContext(ctx, sql)
+		return errors.Wrap(err, "unable to drop api_keys table")
+	},
+}
+
+func init() {
+	Migrations = append(Migrations, createApiKeysTableMigration)
+}
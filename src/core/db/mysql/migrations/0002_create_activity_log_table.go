@@ -0,0 +1,85 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// createActivityLogTableSQL is the Postgres activity_log as of its hash
+// chain migration, in one table. %s is the list of initial partitions: the
+// first one also holds every earlier month, later months are added by
+// EnsureActivityLogPartitions.
+const createActivityLogTableSQL = `
+	CREATE TABLE IF NOT EXISTS activity_log (
+		id bigint NOT NULL AUTO_INCREMENT,
+		request_no varchar(255),
+		trace_id varchar(64),
+		span_id varchar(64),
+		service_code varchar(255) NOT NULL,
+		user_id bigint,
+		azure_user_id varchar(255),
+		email_address varchar(255),
+		user_roles json NOT NULL,
+		http_method varchar(16) NOT NULL,
+		request_uri text NOT NULL,
+		http_status_code int NOT NULL,
+		response_code int,
+		duration_ms bigint NOT NULL DEFAULT 0,
+		ip_address varchar(64),
+		user_agent text,
+		request_body longtext,
+		response_body longtext,
+		created_time datetime(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+		chain_date date,
+		chain_seq bigint,
+		prev_hash varchar(64),
+		row_hash varchar(64),
+		PRIMARY KEY (id, created_time),
+		KEY activity_log_request_no_idx (request_no),
+		KEY activity_log_trace_id_idx (trace_id),
+		KEY activity_log_service_code_idx (service_code, created_time),
+		KEY activity_log_user_id_idx (user_id, created_time),
+		KEY activity_log_email_address_idx (email_address, created_time),
+		KEY activity_log_http_status_code_idx (http_status_code, created_time),
+		KEY activity_log_created_time_idx (created_time),
+		KEY activity_log_chain_idx (chain_date, chain_seq)
+	)
+	PARTITION BY RANGE COLUMNS (created_time) (
+		%s
+	);
+`
+
+var createActivityLogTableMigration = &Migration{
+	Number: 2,
+	Name:   "Create activity_log table",
+	Source: createActivityLogTableSQL,
+	Forwards: func(ctx context.Context, tx *sql.Tx) error {
+		// partitions for this month and the next three
+		now := time.Now().UTC()
+		month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		partitions := make([]string, 0, 4)
+		for i := 0; i <= 3; i++ {
+			current := month.AddDate(0, i, 0)
+			partitions = append(partitions, fmt.Sprintf("PARTITION activity_log_p%s VALUES LESS THAN ('%s')",
+				current.Format("200601"), current.AddDate(0, 1, 0).Format(time.DateOnly)))
+		}
+
+		query := fmt.Sprintf(createActivityLogTableSQL, strings.Join(partitions, ",\n\t\t"))
+		return errors.Wrap(execStatements(ctx, tx, query), "unable to create activity_log table")
+	},
+	Backwards: func(ctx context.Context, tx *sql.Tx) error {
+		const sql = `DROP TABLE IF EXISTS activity_log;`
+
+		_, err := tx.ExecContext(ctx, sql)
+		return errors.Wrap(err, "unable to drop activity_log table")
+	},
+}
+
+func init() {
+	Migrations = append(Migrations, createActivityLogTableMigration)
+}
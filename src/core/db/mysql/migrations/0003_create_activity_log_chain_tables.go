@@ -0,0 +1,50 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+const createActivityLogChainTablesSQL = `
+	-- last link of each daily chain, updated in the same transaction as the insert
+	CREATE TABLE IF NOT EXISTS activity_log_chain (
+		chain_date date PRIMARY KEY,
+		last_seq bigint NOT NULL,
+		last_hash varchar(64) NOT NULL,
+		updated_time datetime(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6)
+	);
+
+	CREATE TABLE IF NOT EXISTS activity_log_checkpoint (
+		id bigint NOT NULL AUTO_INCREMENT PRIMARY KEY,
+		chain_date date NOT NULL,
+		chain_seq bigint NOT NULL,
+		row_hash varchar(64) NOT NULL,
+		key_id varchar(255) NOT NULL,
+		signature text NOT NULL,
+		created_time datetime(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+		UNIQUE KEY activity_log_checkpoint_chain_idx (chain_date, chain_seq)
+	);
+`
+
+var createActivityLogChainTablesMigration = &Migration{
+	Number: 3,
+	Name:   "Create activity_log hash chain and checkpoint tables",
+	Source: createActivityLogChainTablesSQL,
+	Forwards: func(ctx context.Context, tx *sql.Tx) error {
+		return errors.Wrap(execStatements(ctx, tx, createActivityLogChainTablesSQL), "unable to create activity_log chain tables")
+	},
+	Backwards: func(ctx context.Context, tx *sql.Tx) error {
+		const sql = `
+			DROP TABLE IF EXISTS activity_log_checkpoint;
+			DROP TABLE IF EXISTS activity_log_chain;
+		`
+
+		return errors.Wrap(execStatements(ctx, tx, sql), "unable to drop activity_log chain tables")
+	},
+}
+
+func init() {
+	Migrations = append(Migrations, createActivityLogChainTablesMigration)
+}
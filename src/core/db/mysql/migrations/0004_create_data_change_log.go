@@ -0,0 +1,45 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+// createDataChangeLogSQL creates the table only. TiDB has no triggers, so
+// unlike on Postgres changes are not recorded by the database.
+const createDataChangeLogSQL = `
+	CREATE TABLE IF NOT EXISTS data_change_log (
+		id bigint NOT NULL AUTO_INCREMENT PRIMARY KEY,
+		table_name varchar(255) NOT NULL,
+		record_id varchar(255) NOT NULL,
+		operation varchar(16) NOT NULL,
+		changes json NOT NULL,
+		acting_user_id varchar(255),
+		request_no varchar(255),
+		db_user varchar(255) NOT NULL DEFAULT '',
+		created_time datetime(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+		KEY data_change_log_record_idx (table_name, record_id, created_time),
+		KEY data_change_log_acting_user_id_idx (acting_user_id, created_time)
+	);
+`
+
+var createDataChangeLogMigration = &Migration{
+	Number: 4,
+	Name:   "Create data_change_log table",
+	Source: createDataChangeLogSQL,
+	Forwards: func(ctx context.Context, tx *sql.Tx) error {
+		return errors.Wrap(execStatements(ctx, tx, createDataChangeLogSQL), "unable to create data_change_log table")
+	},
+	Backwards: func(ctx context.Context, tx *sql.Tx) error {
+		const sql = `DROP TABLE IF EXISTS data_change_log;`
+
+		_, err := tx.ExecContext(ctx, sql)
+		return errors.Wrap(err, "unable to drop data_change_log table")
+	},
+}
+
+func init() {
+	Migrations = append(Migrations, createDataChangeLogMigration)
+}
@@ -0,0 +1,541 @@
+package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go-template/src/core/log"
+)
+
+// lockTimeout is how long GET_LOCK waits for another replica to finish
+// migrating, in seconds
+const lockTimeout = 3600
+
+// Migration is a migration of the MySQL/TiDB schema. MySQL commits DDL
+// implicitly, so a migration that fails part way is not rolled back; keep
+// each one to statements that are safe to rerun, e.g. IF NOT EXISTS.
+type Migration struct {
+	Number uint
+	Name   string
+
+	// Checksum, AppliedAt and DurationMs are kept in the migrations table
+	Checksum   string
+	AppliedAt  *time.Time
+	DurationMs int64
+
+	// Source is what Checksum covers, normally the forwards SQL. Migrations
+	// without it are not checked for changes.
+	Source   string
+	Forwards func(ctx context.Context, tx *sql.Tx) error
+	// Backwards undoes Forwards. Migrations without it can not be rolled
+	// back.
+	Backwards func(ctx context.Context, tx *sql.Tx) error
+}
+
+// Migrations holds the Go migrations, registered from init, and, once
+// loaded, the embedded SQL ones
+var Migrations []*Migration
+
+var loadSQLOnce sync.Once
+var loadSQLErr error
+
+// Options are the options of postgresql's migrations.Options, with the same
+// meaning; Reset always rolls back every applied migration.
+type Options struct {
+	DryRun      bool
+	To          int
+	Steps       int
+	Reset       bool
+	IgnoreDrift bool
+}
+
+// MigrationStatus is one migration as reported by Status, see
+// postgresql's migrations.MigrationStatus
+type MigrationStatus struct {
+	Number     uint       `json:"number"`
+	Name       string     `json:"name"`
+	State      string     `json:"state"`
+	Reversible bool       `json:"reversible"`
+	AppliedAt  *time.Time `json:"applied_at,omitempty"`
+	DurationMs int64      `json:"duration_ms,omitempty"`
+	Checksum   string     `json:"checksum,omitempty"`
+}
+
+const (
+	StateApplied = "applied"
+	StatePending = "pending"
+	StateChanged = "changed"
+	StateUnknown = "unknown"
+)
+
+// Migrate migrates the database of db. Every statement runs on one
+// connection holding the migration lock.
+func Migrate(ctx context.Context, db *sql.DB, logger log.Logger, options Options) error {
+	logger = logger.WithFields(log.Fields{
+		"module": "db/mysql/migrations",
+	})
+
+	if options.DryRun {
+		logger.Infof("=== DRY RUN ===")
+	}
+
+	if options.Steps != 0 && options.To != -1 {
+		return errors.New("--to and --steps can not be used together")
+	}
+
+	if err := loadMigrations(); err != nil {
+		logger.Errorf("Unable to apply migrations, err: %+v", err)
+		return err
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to connect to mysql")
+	}
+	defer conn.Close()
+
+	unlock, err := lock(ctx, conn, logger)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := ensureTable(ctx, conn); err != nil {
+		return err
+	}
+
+	if options.Reset {
+		logger.Infof("=== RESET ===")
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := rollback(ctx, conn, logger, applied, 0, options.DryRun); err != nil {
+			logger.Errorf("unable to reset the database. err: %+v", err)
+			return err
+		}
+	}
+
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return err
+	}
+	// a dry run reset has not rolled anything back
+	if options.Reset && options.DryRun {
+		applied = nil
+	}
+
+	if err := checkDrift(ctx, conn, logger, applied, options); err != nil {
+		return err
+	}
+
+	var latest uint
+	if len(applied) > 0 {
+		latest = applied[len(applied)-1].Number
+	}
+
+	target, err := targetNumber(options, applied, latest)
+	if err != nil {
+		return err
+	}
+
+	if target < latest {
+		return rollback(ctx, conn, logger, applied, target, options.DryRun)
+	}
+
+	// applying past the latest would leave them behind for good
+	if skipped := skippedMigrations(applied, latest); len(skipped) > 0 {
+		migration := skipped[0]
+		err := fmt.Errorf("migration %d %q is pending but numbered below the latest applied migration %d, renumber it above %d or roll back to %d first",
+			migration.Number, migration.Name, latest, latest, migration.Number-1)
+		logger.Errorf("Unable to apply migrations, err: %+v", err)
+		return err
+	}
+
+	if len(Migrations) == 0 || latest >= Migrations[len(Migrations)-1].Number || target == latest {
+		logger.Infof("no migrations to apply")
+		return nil
+	}
+
+	for _, migration := range Migrations {
+		if migration.Number > target {
+			break
+		}
+
+		if migration.Number <= latest {
+			continue
+		}
+
+		if latest > 0 {
+			logger.Infof("continuing migration starting from %d", migration.Number)
+		}
+
+		logger := logger.WithFields(log.Fields{
+			"migration_number": migration.Number,
+		})
+		logger.Infof("applying migration %q", migration.Name)
+
+		if options.DryRun {
+			continue
+		}
+
+		if err := apply(ctx, conn, migration); err != nil {
+			logger.Errorf("unable to apply migration. err: %+v", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Status lists every migration of this build and every applied one, by
+// number
+func Status(ctx context.Context, db *sql.DB) ([]*MigrationStatus, error) {
+	if err := loadMigrations(); err != nil {
+		return nil, err
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to connect to mysql")
+	}
+	defer conn.Close()
+
+	if err := ensureTable(ctx, conn); err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make(map[uint]*Migration)
+	for _, record := range applied {
+		records[record.Number] = record
+	}
+
+	result := make([]*MigrationStatus, 0, len(Migrations))
+	for _, migration := range Migrations {
+		status := &MigrationStatus{
+			Number:     migration.Number,
+			Name:       migration.Name,
+			State:      StatePending,
+			Reversible: migration.Backwards != nil,
+		}
+
+		if record, ok := records[migration.Number]; ok {
+			status.State = StateApplied
+			status.AppliedAt = record.AppliedAt
+			status.DurationMs = record.DurationMs
+			status.Checksum = record.Checksum
+			if changed(migration, record) {
+				status.State = StateChanged
+			}
+			delete(records, migration.Number)
+		}
+
+		result = append(result, status)
+	}
+
+	for _, record := range records {
+		result = append(result, &MigrationStatus{
+			Number:     record.Number,
+			Name:       record.Name,
+			State:      StateUnknown,
+			AppliedAt:  record.AppliedAt,
+			DurationMs: record.DurationMs,
+			Checksum:   record.Checksum,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Number < result[j].Number
+	})
+
+	return result, nil
+}
+
+// loadMigrations adds the embedded SQL migrations to Migrations, orders them
+// by Number and rejects duplicates
+func loadMigrations() error {
+	loadSQLOnce.Do(func() {
+		var sqlMigrations []*Migration
+		sqlMigrations, loadSQLErr = loadSQLMigrations(sqlFiles, "sql")
+		Migrations = append(Migrations, sqlMigrations...)
+	})
+	if loadSQLErr != nil {
+		return loadSQLErr
+	}
+
+	migrationIDs := make(map[uint]struct{})
+	for _, migration := range Migrations {
+		if _, ok := migrationIDs[migration.Number]; ok {
+			return fmt.Errorf("Duplicate migration Number found: %d", migration.Number)
+		}
+
+		migrationIDs[migration.Number] = struct{}{}
+	}
+
+	sort.Slice(Migrations, func(i, j int) bool {
+		return Migrations[i].Number < Migrations[j].Number
+	})
+
+	return nil
+}
+
+func ensureTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS migrations (
+			number bigint PRIMARY KEY,
+			name varchar(255) NOT NULL DEFAULT '',
+			checksum varchar(64),
+			applied_at datetime(6),
+			duration_ms bigint
+		)
+	`)
+	return errors.Wrap(err, "unable to create migrations table")
+}
+
+// lock takes the named lock of the database so replicas starting together
+// migrate one at a time; the others wait and then find nothing to apply.
+// The lock belongs to conn until unlock.
+func lock(ctx context.Context, conn *sql.Conn, logger log.Logger) (unlock func(), err error) {
+	logger.Debugf("waiting for the migration lock")
+
+	var locked *int
+	err = conn.QueryRowContext(ctx, `SELECT GET_LOCK(CONCAT('migrations:', DATABASE()), ?)`, lockTimeout).Scan(&locked)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to take the migration lock")
+	}
+	if locked == nil || *locked != 1 {
+		return nil, fmt.Errorf("unable to take the migration lock within %ds", lockTimeout)
+	}
+
+	return func() {
+		// the lock must be released even when ctx is done
+		if _, err := conn.ExecContext(context.WithoutCancel(ctx), `SELECT RELEASE_LOCK(CONCAT('migrations:', DATABASE()))`); err != nil {
+			logger.Warnf("unable to release the migration lock. err: %+v", err)
+		}
+	}, nil
+}
+
+// apply runs Forwards and records the migration in one transaction
+func apply(ctx context.Context, conn *sql.Conn, migration *Migration) error {
+	start := time.Now()
+
+	return withTx(ctx, conn, func(tx *sql.Tx) error {
+		if err := migration.Forwards(ctx, tx); err != nil {
+			return errors.Wrapf(err, "unable to apply migration %d", migration.Number)
+		}
+
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO migrations (number, name, checksum, applied_at, duration_ms) VALUES (?, ?, ?, ?, ?)`,
+			int64(migration.Number),
+			migration.Name,
+			checksum(migration),
+			start.UTC(),
+			time.Since(start).Milliseconds(),
+		)
+		return errors.Wrapf(err, "unable to create migration record %d", migration.Number)
+	})
+}
+
+func withTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "unable to make a transaction")
+	}
+
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return errors.Wrap(tx.Commit(), "unable to commit a transaction")
+}
+
+// checkDrift compares the applied migrations with their Source. Records
+// without a checksum get the current one.
+func checkDrift(ctx context.Context, conn *sql.Conn, logger log.Logger, applied []*Migration, options Options) error {
+	drifted := make([]string, 0)
+	for _, record := range applied {
+		migration := findMigration(record.Number)
+		if migration == nil || migration.Source == "" {
+			continue
+		}
+
+		if record.Checksum == "" {
+			if options.DryRun {
+				continue
+			}
+			record.Checksum = checksum(migration)
+			if _, err := conn.ExecContext(ctx, `UPDATE migrations SET checksum = ? WHERE number = ?`, record.Checksum, int64(record.Number)); err != nil {
+				return errors.Wrapf(err, "unable to store the checksum of migration %d", record.Number)
+			}
+			continue
+		}
+
+		if changed(migration, record) {
+			logger.Warnf("migration %d %q changed after it was applied", record.Number, migration.Name)
+			drifted = append(drifted, fmt.Sprint(record.Number))
+		}
+	}
+
+	if len(drifted) > 0 && !options.IgnoreDrift {
+		return fmt.Errorf("applied migrations changed: %s; revert the change or add a new migration, or pass --ignore-drift", strings.Join(drifted, ", "))
+	}
+
+	return nil
+}
+
+func changed(migration *Migration, record *Migration) bool {
+	return migration.Source != "" && record.Checksum != "" && record.Checksum != checksum(migration)
+}
+
+// checksum is the SHA-256 of Source with whitespace collapsed, so
+// reformatting a migration is not a change
+func checksum(migration *Migration) string {
+	if migration.Source == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(strings.Join(strings.Fields(migration.Source), " ")))
+	return hex.EncodeToString(sum[:])
+}
+
+// skippedMigrations returns the migrations of this build that are not applied
+// although a higher numbered one is, e.g. merged from a branch after it
+func skippedMigrations(applied []*Migration, latest uint) []*Migration {
+	numbers := make(map[uint]bool, len(applied))
+	for _, record := range applied {
+		numbers[record.Number] = true
+	}
+
+	skipped := make([]*Migration, 0)
+	for _, migration := range Migrations {
+		if migration.Number < latest && !numbers[migration.Number] {
+			skipped = append(skipped, migration)
+		}
+	}
+	return skipped
+}
+
+// targetNumber resolves To or Steps to the number of the last migration that
+// should be applied, 0 for none
+func targetNumber(options Options, applied []*Migration, latest uint) (uint, error) {
+	switch {
+	case options.Steps > 0:
+		target := latest
+		for _, migration := range Migrations {
+			if migration.Number > latest && options.Steps > 0 {
+				target = migration.Number
+				options.Steps--
+			}
+		}
+		return target, nil
+
+	case options.Steps < 0:
+		index := len(applied) + options.Steps - 1
+		if index < 0 {
+			return 0, nil
+		}
+		return applied[index].Number, nil
+
+	case options.To == -1:
+		if len(Migrations) == 0 {
+			return latest, nil
+		}
+		return Migrations[len(Migrations)-1].Number, nil
+
+	case options.To < 0:
+		return 0, fmt.Errorf("invalid migration number %d", options.To)
+	}
+
+	return uint(options.To), nil
+}
+
+// rollback runs Backwards of every applied migration above target, newest
+// first, each with the removal of its record. It refuses before changing
+// anything when one of them has no Backwards.
+func rollback(ctx context.Context, conn *sql.Conn, logger log.Logger, applied []*Migration, target uint, dryRun bool) error {
+	steps := make([]*Migration, 0)
+	for i := len(applied) - 1; i >= 0; i-- {
+		if applied[i].Number <= target {
+			break
+		}
+
+		migration := findMigration(applied[i].Number)
+		if migration == nil {
+			return fmt.Errorf("migration %d %q is applied but unknown to this build, can not roll it back", applied[i].Number, applied[i].Name)
+		}
+		if migration.Backwards == nil {
+			return fmt.Errorf("migration %d %q has no backwards step, can not roll back to %d", migration.Number, migration.Name, target)
+		}
+		steps = append(steps, migration)
+	}
+
+	for _, migration := range steps {
+		logger := logger.WithFields(log.Fields{
+			"migration_number": migration.Number,
+		})
+		logger.Infof("rolling back migration %q", migration.Name)
+
+		if dryRun {
+			continue
+		}
+
+		err := withTx(ctx, conn, func(tx *sql.Tx) error {
+			if err := migration.Backwards(ctx, tx); err != nil {
+				return errors.Wrapf(err, "unable to roll back migration %d", migration.Number)
+			}
+			_, err := tx.ExecContext(ctx, `DELETE FROM migrations WHERE number = ?`, int64(migration.Number))
+			return errors.Wrapf(err, "unable to delete migration record %d", migration.Number)
+		})
+		if err != nil {
+			logger.Errorf("unable to roll back migration. err: %+v", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func appliedMigrations(ctx context.Context, conn *sql.Conn) ([]*Migration, error) {
+	rows, err := conn.QueryContext(ctx, `
+		SELECT number, name, COALESCE(checksum, ''), applied_at, COALESCE(duration_ms, 0)
+		FROM migrations
+		ORDER BY number
+	`)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list applied migrations")
+	}
+	defer rows.Close()
+
+	applied := make([]*Migration, 0)
+	for rows.Next() {
+		var number int64
+		record := &Migration{}
+		if err := rows.Scan(&number, &record.Name, &record.Checksum, &record.AppliedAt, &record.DurationMs); err != nil {
+			return nil, errors.Wrap(err, "unable to list applied migrations")
+		}
+		record.Number = uint(number)
+		applied = append(applied, record)
+	}
+	return applied, errors.Wrap(rows.Err(), "unable to list applied migrations")
+}
+
+func findMigration(number uint) *Migration {
+	for _, migration := range Migrations {
+		if migration.Number == number {
+			return migration
+		}
+	}
+	return nil
+}
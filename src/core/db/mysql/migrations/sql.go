@@ -0,0 +1,234 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SQLDir is where migrate-db new writes files for the mysql and tidb
+// database types, relative to the repository root. The files are embedded at
+// build time.
+const SQLDir = "src/core/db/mysql/migrations/sql"
+
+//go:embed sql
+var sqlFiles embed.FS
+
+var sqlFilePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// loadSQLMigrations reads the NNNN_name.up.sql and NNNN_name.down.sql pairs
+// of fsys into migrations. A down file that is missing or holds only
+// comments leaves the migration without Backwards.
+func loadSQLMigrations(fsys fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read SQL migrations")
+	}
+
+	byNumber := make(map[uint]*Migration)
+	downs := make(map[uint]string)
+	result := make([]*Migration, 0)
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+
+		match := sqlFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid SQL migration file name %q, expected NNNN_name.up.sql or NNNN_name.down.sql", entry.Name())
+		}
+
+		number, err := strconv.ParseUint(match[1], 10, 32)
+		if err != nil || number == 0 {
+			return nil, fmt.Errorf("invalid SQL migration number in %q", entry.Name())
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read %s", entry.Name())
+		}
+		sql := string(content)
+
+		if match[3] == "down" {
+			downs[uint(number)] = sql
+			continue
+		}
+		if isBlankSQL(sql) {
+			return nil, fmt.Errorf("SQL migration %s is empty", entry.Name())
+		}
+
+		if _, ok := byNumber[uint(number)]; ok {
+			return nil, fmt.Errorf("Duplicate migration Number found: %d", number)
+		}
+
+		migration := &Migration{
+			Number:   uint(number),
+			Name:     strings.ReplaceAll(match[2], "_", " "),
+			Source:   sql,
+			Forwards: execSQL(sql, entry.Name()),
+		}
+		byNumber[migration.Number] = migration
+		result = append(result, migration)
+	}
+
+	for number, sql := range downs {
+		migration, ok := byNumber[number]
+		if !ok {
+			return nil, fmt.Errorf("SQL migration %d has a down file but no up file", number)
+		}
+		if isBlankSQL(sql) {
+			continue
+		}
+
+		migration.Backwards = execSQL(sql, fmt.Sprintf("migration %d down", number))
+	}
+
+	return result, nil
+}
+
+// execSQL runs the statements of query one at a time in the migration
+// transaction; the driver does not accept several in one query
+func execSQL(query string, name string) func(ctx context.Context, tx *sql.Tx) error {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		return errors.Wrapf(execStatements(ctx, tx, query), "unable to run %s", name)
+	}
+}
+
+func execStatements(ctx context.Context, tx *sql.Tx, query string) error {
+	for _, statement := range splitStatements(query) {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isBlankSQL reports whether sql holds nothing but whitespace and line
+// comments
+func isBlankSQL(sql string) bool {
+	for _, line := range strings.Split(sql, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") && !strings.HasPrefix(line, "#") {
+			return false
+		}
+	}
+	return true
+}
+
+// splitStatements splits sql on semicolons outside quotes, backquoted
+// identifiers and comments, dropping empty statements
+func splitStatements(sql string) []string {
+	statements := make([]string, 0)
+	start := 0
+
+	add := func(statement string) {
+		if !isBlankSQL(statement) {
+			statements = append(statements, strings.TrimSpace(statement))
+		}
+	}
+
+	for i := 0; i < len(sql); i++ {
+		switch {
+		case strings.HasPrefix(sql[i:], "-- "), sql[i] == '#':
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end
+			}
+
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end + 3
+			}
+
+		case sql[i] == '\'' || sql[i] == '"' || sql[i] == '`':
+			quote := sql[i]
+			for i++; i < len(sql) && sql[i] != quote; i++ {
+				if sql[i] == '\\' && quote != '`' {
+					i++
+				}
+			}
+
+		case sql[i] == ';':
+			add(sql[start:i])
+			start = i + 1
+		}
+	}
+	add(sql[start:])
+
+	return statements
+}
+
+// NewSQLMigration writes the up and down files of a new migration named name
+// into dir, numbered after the latest migration, and returns their paths
+func NewSQLMigration(dir string, name string) (up string, down string, err error) {
+	if err := loadMigrations(); err != nil {
+		return "", "", err
+	}
+
+	slug := strings.Trim(regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if slug == "" {
+		return "", "", fmt.Errorf("invalid migration name %q", name)
+	}
+
+	var latest uint
+	if len(Migrations) > 0 {
+		latest = Migrations[len(Migrations)-1].Number
+	}
+
+	// files created since the build are not embedded yet
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", "", errors.Wrap(err, "unable to read the migrations directory")
+	}
+	for _, entry := range entries {
+		if match := sqlFilePattern.FindStringSubmatch(entry.Name()); match != nil {
+			if number, err := strconv.ParseUint(match[1], 10, 32); err == nil && uint(number) > latest {
+				latest = uint(number)
+			}
+		}
+	}
+	number := latest + 1
+
+	prefix := fmt.Sprintf("%04d_%s", number, slug)
+	up = filepath.Join(dir, prefix+".up.sql")
+	down = filepath.Join(dir, prefix+".down.sql")
+
+	upContent := fmt.Sprintf("-- %s\n-- MySQL commits DDL statements implicitly, keep them safe to rerun.\n\n", name)
+	downContent := fmt.Sprintf("-- Undoes %s.up.sql. Leave only comments when it can not be undone.\n\n", prefix)
+
+	if err := writeNewFile(up, upContent); err != nil {
+		return "", "", err
+	}
+	if err := writeNewFile(down, downContent); err != nil {
+		return "", "", err
+	}
+
+	return up, down, nil
+}
+
+func writeNewFile(name string, content string) error {
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return errors.Wrap(err, "unable to create migration file")
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(content)
+	return errors.Wrap(err, "unable to write migration file")
+}
@@ -0,0 +1,58 @@
+package migrations
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []string
+	}{
+		{
+			name: "statements",
+			sql:  "CREATE TABLE a (id int);\nCREATE TABLE b (id int);\n",
+			want: []string{"CREATE TABLE a (id int)", "CREATE TABLE b (id int)"},
+		},
+		{
+			name: "semicolons in strings and identifiers",
+			sql:  "INSERT INTO `a;b` VALUES ('x;y', \"u;v\"); SELECT 1",
+			want: []string{"INSERT INTO `a;b` VALUES ('x;y', \"u;v\")", "SELECT 1"},
+		},
+		{
+			name: "backslash escaped quotes",
+			sql:  `INSERT INTO a VALUES ('it\'s;', "say \";\""); SELECT 1`,
+			want: []string{`INSERT INTO a VALUES ('it\'s;', "say \";\"")`, "SELECT 1"},
+		},
+		{
+			name: "backslashes do not escape backquotes",
+			sql:  "SELECT `a\\`; SELECT 1",
+			want: []string{"SELECT `a\\`", "SELECT 1"},
+		},
+		{
+			name: "semicolons in comments",
+			sql:  "-- first; statement\n# second; comment\nSELECT 1; /* block;\ncomment; */ SELECT 2",
+			want: []string{"-- first; statement\n# second; comment\nSELECT 1", "/* block;\ncomment; */ SELECT 2"},
+		},
+		{
+			name: "double dash without a space is not a comment",
+			sql:  "SELECT 1--1; SELECT 2",
+			want: []string{"SELECT 1--1", "SELECT 2"},
+		},
+		{
+			name: "blank and comment-only statements dropped",
+			sql:  ";\n  ;\n# nothing to run\n;SELECT 1;\n-- trailing comment\n",
+			want: []string{"SELECT 1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitStatements(tt.sql); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitStatements() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
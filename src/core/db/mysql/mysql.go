@@ -0,0 +1,90 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
+	"go-template/src/core/log"
+)
+
+// MySQLDB implements the repositories on MySQL 8 and TiDB. Times are stored
+// in UTC, the session time zone is set to match.
+type MySQLDB struct {
+	logger log.Logger
+	Config *Config
+
+	// DB is the pool, or the transaction inside WithTx
+	DB Querier
+
+	sqlDB *sql.DB
+	tx    *sql.Tx
+	// savepoints is the nesting depth of WithTx, naming the savepoints
+	savepoints int
+}
+
+func New(config *Config, logger log.Logger) (mydb *MySQLDB, err error) {
+	mydb = &MySQLDB{
+		Config: config,
+	}
+
+	mydb.logger = logger.WithFields(log.Fields{
+		"module": "db/mysql",
+	})
+
+	mydb.sqlDB, err = NewSQLDB(context.Background(), config)
+	if err != nil {
+		mydb.logger.Errorf("Error connecting to mysql: %+v", err)
+		return nil, err
+	}
+	mydb.DB = mydb.sqlDB
+
+	return mydb, nil
+}
+
+// NewSQLDB opens a pool to the database of config and checks that it is
+// reachable. The service and the migrations connect through it.
+func NewSQLDB(ctx context.Context, config *Config) (*sql.DB, error) {
+	driverConfig := mysql.NewConfig()
+	driverConfig.User = config.Username
+	driverConfig.Passwd = config.Password
+	driverConfig.Net = "tcp"
+	driverConfig.Addr = net.JoinHostPort(config.Host, config.Port)
+	driverConfig.DBName = config.DatabaseName
+	driverConfig.TLSConfig = config.TLS
+	driverConfig.ParseTime = true
+	driverConfig.Loc = time.UTC
+	driverConfig.Params = map[string]string{
+		"time_zone": "'+00:00'",
+	}
+
+	connector, err := mysql.NewConnector(driverConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid mysql configuration")
+	}
+
+	sqlDB := sql.OpenDB(connector)
+	if config.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(config.MaxOpenConns)
+	}
+	sqlDB.SetConnMaxIdleTime(5 * time.Second)
+	sqlDB.SetConnMaxLifetime(300 * time.Second)
+
+	if err := sqlDB.PingContext(ctx); err != nil {
+		sqlDB.Close()
+		return nil, errors.Wrap(err, "unable to connect to mysql")
+	}
+
+	return sqlDB, nil
+}
+
+// Close closes the pool. It does nothing on the tx passed to WithTx.
+func (mydb *MySQLDB) Close() error {
+	if mydb.tx != nil {
+		return nil
+	}
+	return mydb.sqlDB.Close()
+}
@@ -0,0 +1,295 @@
+package mysql
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Query builds the WHERE, ORDER BY and LIMIT clauses of a list query, like
+// postgresql.Query but with ? placeholders. Values are always sent as
+// arguments; column names come from code and are quoted.
+//
+// Conditions with an empty value (zero, "", nil or an empty slice) are
+// skipped, so optional filters need no if around them.
+type Query struct {
+	conditions []string
+	args       []any
+	orderBy    []string
+	limit      int64
+	offset     int64
+}
+
+func NewQuery() *Query {
+	return &Query{}
+}
+
+// Args returns the arguments of the placeholders, in order
+func (q *Query) Args() []any {
+	return q.args
+}
+
+// Where adds a raw condition with a ? placeholder per arg. condition must be
+// a constant.
+func (q *Query) Where(condition string, args ...any) *Query {
+	if count := strings.Count(condition, "?"); count != len(args) {
+		panic(fmt.Sprintf("mysql: %d args for %d placeholders in %q", len(args), count, condition))
+	}
+
+	q.conditions = append(q.conditions, condition)
+	q.args = append(q.args, args...)
+	return q
+}
+
+// Equal adds column = value
+func (q *Query) Equal(column string, value any) *Query {
+	if isEmpty(value) {
+		return q
+	}
+	return q.Where(quoteColumn(column)+" = ?", value)
+}
+
+// NotEqual adds column <> value
+func (q *Query) NotEqual(column string, value any) *Query {
+	if isEmpty(value) {
+		return q
+	}
+	return q.Where(quoteColumn(column)+" <> ?", value)
+}
+
+// EqualFold adds a case-insensitive column = value
+func (q *Query) EqualFold(column string, value string) *Query {
+	if value == "" {
+		return q
+	}
+	return q.Where("LOWER("+quoteColumn(column)+") = LOWER(?)", value)
+}
+
+// Compare adds column op value, op is one of = <> < <= > >=
+func (q *Query) Compare(column string, op string, value any) *Query {
+	if isEmpty(value) {
+		return q
+	}
+	switch op {
+	case "=", "<>", "<", "<=", ">", ">=":
+	default:
+		panic(fmt.Sprintf("mysql: unknown comparison %q", op))
+	}
+	return q.Where(quoteColumn(column)+" "+op+" ?", value)
+}
+
+// Contains adds a case-insensitive substring match. % and _ in value match
+// themselves.
+func (q *Query) Contains(column string, value string) *Query {
+	if value == "" {
+		return q
+	}
+	return q.Where("LOWER("+quoteColumn(column)+") LIKE LOWER(?)", "%"+EscapeLike(value)+"%")
+}
+
+// HasPrefix adds a case-insensitive prefix match
+func (q *Query) HasPrefix(column string, value string) *Query {
+	if value == "" {
+		return q
+	}
+	return q.Where("LOWER("+quoteColumn(column)+") LIKE LOWER(?)", EscapeLike(value)+"%")
+}
+
+// In adds column IN (values...), values is a slice
+func (q *Query) In(column string, values any) *Query {
+	if isEmpty(values) {
+		return q
+	}
+	items := sliceItems(values)
+	return q.Where(quoteColumn(column)+" IN ("+placeholders(len(items))+")", items...)
+}
+
+// NotIn adds column NOT IN (values...), values is a slice
+func (q *Query) NotIn(column string, values any) *Query {
+	if isEmpty(values) {
+		return q
+	}
+	items := sliceItems(values)
+	return q.Where(quoteColumn(column)+" NOT IN ("+placeholders(len(items))+")", items...)
+}
+
+// ArrayHas adds value is an element of the JSON array column
+func (q *Query) ArrayHas(column string, value any) *Query {
+	if isEmpty(value) {
+		return q
+	}
+	return q.Where("JSON_CONTAINS("+quoteColumn(column)+", JSON_QUOTE(?))", value)
+}
+
+// TimeRange adds from <= column < to, either bound may be nil
+func (q *Query) TimeRange(column string, from, to *time.Time) *Query {
+	if from != nil {
+		q.Where(quoteColumn(column)+" >= ?", from.UTC())
+	}
+	if to != nil {
+		q.Where(quoteColumn(column)+" < ?", to.UTC())
+	}
+	return q
+}
+
+// Page sets LIMIT limit OFFSET (page-1)*limit, page starts at 1
+func (q *Query) Page(page, limit int64) *Query {
+	if page < 1 {
+		page = 1
+	}
+	q.limit = limit
+	q.offset = (page - 1) * limit
+	return q
+}
+
+// After adds the keyset condition continuing after the row whose values of
+// columns are values. The ORDER BY must use the same columns all in the
+// direction of desc.
+func (q *Query) After(columns []string, values []any, desc bool) *Query {
+	if len(columns) == 0 || len(columns) != len(values) {
+		panic("mysql: After needs one value per column")
+	}
+
+	quoted := make([]string, 0, len(columns))
+	for _, column := range columns {
+		quoted = append(quoted, quoteColumn(column))
+	}
+
+	op := ">"
+	if desc {
+		op = "<"
+	}
+
+	return q.Where("("+strings.Join(quoted, ", ")+") "+op+" ("+placeholders(len(values))+")", values...)
+}
+
+// AfterMixed is After for an ORDER BY mixing directions, desc[i] being the
+// direction of columns[i]. It expands to one branch per column:
+// (a > ?) OR (a = ? AND b < ?) OR (a = ? AND b = ? AND c > ?).
+func (q *Query) AfterMixed(columns []string, values []any, desc []bool) *Query {
+	if len(columns) == 0 || len(columns) != len(values) || len(columns) != len(desc) {
+		panic("mysql: AfterMixed needs one value and direction per column")
+	}
+
+	branches := make([]string, 0, len(columns))
+	args := make([]any, 0)
+	for i := range columns {
+		terms := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			terms = append(terms, quoteColumn(columns[j])+" = ?")
+			args = append(args, values[j])
+		}
+		op := ">"
+		if desc[i] {
+			op = "<"
+		}
+		terms = append(terms, quoteColumn(columns[i])+" "+op+" ?")
+		args = append(args, values[i])
+		branches = append(branches, "("+strings.Join(terms, " AND ")+")")
+	}
+
+	return q.Where("("+strings.Join(branches, " OR ")+")", args...)
+}
+
+// WhereSQL returns "WHERE ..." or "" without conditions
+func (q *Query) WhereSQL() string {
+	if len(q.conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(q.conditions, " AND ")
+}
+
+// OrderBySQL returns "ORDER BY ..." or "" when not set
+func (q *Query) OrderBySQL() string {
+	if len(q.orderBy) == 0 {
+		return ""
+	}
+	return "ORDER BY " + strings.Join(q.orderBy, ", ")
+}
+
+// LimitSQL returns "LIMIT n OFFSET m" or "" when not set. The numbers are
+// inlined, they are always int64.
+func (q *Query) LimitSQL() string {
+	if q.limit <= 0 {
+		return ""
+	}
+	sql := fmt.Sprintf("LIMIT %d", q.limit)
+	if q.offset > 0 {
+		sql += fmt.Sprintf(" OFFSET %d", q.offset)
+	}
+	return sql
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// EscapeLike escapes the LIKE wildcards in value, for the default \ escape
+func EscapeLike(value string) string {
+	return likeEscaper.Replace(value)
+}
+
+var columnPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// quoteColumn quotes column, optionally qualified by a table. A column that
+// is not a plain identifier is a programming error.
+func quoteColumn(column string) string {
+	if !columnPattern.MatchString(column) {
+		panic(fmt.Sprintf("mysql: invalid column name %q", column))
+	}
+	return "`" + strings.ReplaceAll(column, ".", "`.`") + "`"
+}
+
+// quoteIdentifier quotes any name, doubling backticks in it
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func sliceItems(values any) []any {
+	v := reflect.ValueOf(values)
+	if v.Kind() != reflect.Slice {
+		panic(fmt.Sprintf("mysql: %T is not a slice", values))
+	}
+	items := make([]any, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		items = append(items, v.Index(i).Interface())
+	}
+	return items
+}
+
+// isEmpty reports the zero values that skip an optional condition
+func isEmpty(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case int:
+		return v == 0
+	case int32:
+		return v == 0
+	case int64:
+		return v == 0
+	case float64:
+		return v == 0
+	case *bool:
+		return v == nil
+	case *string:
+		return v == nil
+	case *int64:
+		return v == nil
+	case *time.Time:
+		return v == nil
+	case []string:
+		return len(v) == 0
+	case []int:
+		return len(v) == 0
+	case []int64:
+		return len(v) == 0
+	}
+	return false
+}
@@ -0,0 +1,382 @@
+package mysql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go-template/src/core/seed"
+)
+
+var errSeedDryRun = errors.New("seed dry run")
+
+// Seed upserts the rows of tables, in order, in one transaction. Values are
+// sent as text and converted by the server, JSON columns are cast. On a dry
+// run the transaction is rolled back and the changes it would have made are
+// returned.
+func (mydb *MySQLDB) Seed(ctx context.Context, tables []*seed.Table, dryRun bool) ([]*seed.Change, error) {
+	var changes []*seed.Change
+
+	err := mydb.WithTx(ctx, func(tx *MySQLDB) error {
+		s := &seeder{
+			db:    tx.DB,
+			types: make(map[string]map[string]string),
+		}
+		changes = make([]*seed.Change, 0)
+
+		for _, table := range tables {
+			tableChanges, err := s.seedTable(ctx, table)
+			if err != nil {
+				return err
+			}
+			changes = append(changes, tableChanges...)
+		}
+
+		if dryRun {
+			return errSeedDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errSeedDryRun) {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+type seeder struct {
+	db Querier
+	// column data types by table, nil for a missing table
+	types map[string]map[string]string
+}
+
+func (s *seeder) seedTable(ctx context.Context, table *seed.Table) ([]*seed.Change, error) {
+	types, err := s.columnTypes(ctx, table.Table)
+	if err != nil {
+		return nil, err
+	}
+	if types == nil {
+		if table.IfExists {
+			return []*seed.Change{{Table: table.Table, Action: seed.ActionSkipped}}, nil
+		}
+		return nil, fmt.Errorf("seed table %s does not exist", table.Table)
+	}
+
+	changes := make([]*seed.Change, 0, len(table.Rows))
+	for i, row := range table.Rows {
+		change, err := s.seedRow(ctx, table, types, row)
+		if err != nil {
+			return nil, errors.Wrapf(err, "row %d of %s", i+1, table.Table)
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+func (s *seeder) seedRow(ctx context.Context, table *seed.Table, types map[string]string, row map[string]any) (*seed.Change, error) {
+	columns := make([]string, 0, len(row))
+	for column := range row {
+		if _, ok := types[column]; !ok {
+			return nil, fmt.Errorf("column %s does not exist", column)
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	values := make(map[string]*string, len(row))
+	for _, column := range columns {
+		value, err := s.textValue(ctx, row[column], types[column])
+		if err != nil {
+			return nil, errors.Wrapf(err, "column %s", column)
+		}
+		values[column] = value
+	}
+
+	keyParts := make([]string, 0, len(table.Key))
+	for _, column := range table.Key {
+		if values[column] == nil {
+			return nil, fmt.Errorf("key column %s is null", column)
+		}
+		keyParts = append(keyParts, column+"="+*values[column])
+	}
+
+	change := &seed.Change{
+		Table: table.Table,
+		Key:   strings.Join(keyParts, ","),
+	}
+
+	selects := make([]string, 0, len(columns)*2)
+	args := make([]any, 0, len(columns)+len(table.Key))
+	for _, column := range columns {
+		selects = append(selects,
+			"CAST("+quoteIdentifier(column)+" AS CHAR)",
+			"NOT ("+quoteIdentifier(column)+" <=> "+valueSQL(types[column])+")",
+		)
+		args = append(args, values[column])
+	}
+	where, whereArgs := equalsSQL(table.Key, types, values)
+	args = append(args, whereArgs...)
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(
+		`SELECT %s FROM %s WHERE %s LIMIT 2`,
+		strings.Join(selects, ", "),
+		quoteTable(table.Table),
+		where,
+	), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	old := make([]*string, len(columns))
+	changed := make([]bool, len(columns))
+	dest := make([]any, 0, len(columns)*2)
+	for i := range columns {
+		dest = append(dest, &old[i], &changed[i])
+	}
+
+	found := 0
+	for rows.Next() {
+		found++
+		if err := rows.Scan(dest...); err != nil {
+			rows.Close()
+			return nil, err
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	switch found {
+	case 0:
+		change.Action = seed.ActionInsert
+		for _, column := range columns {
+			change.Columns = append(change.Columns, &seed.ColumnChange{Column: column, New: values[column]})
+		}
+		return change, s.insert(ctx, table.Table, types, columns, values)
+
+	case 1:
+		for i, column := range columns {
+			if changed[i] {
+				change.Columns = append(change.Columns, &seed.ColumnChange{Column: column, Old: old[i], New: values[column]})
+			}
+		}
+		if len(change.Columns) == 0 {
+			change.Action = seed.ActionUnchanged
+			return change, nil
+		}
+		change.Action = seed.ActionUpdate
+		return change, s.update(ctx, table, types, change.Columns, values)
+	}
+
+	return nil, fmt.Errorf("key %s matches more than one row", change.Key)
+}
+
+func (s *seeder) insert(ctx context.Context, table string, types map[string]string, columns []string, values map[string]*string) error {
+	names := make([]string, 0, len(columns))
+	valueSQLs := make([]string, 0, len(columns))
+	args := make([]any, 0, len(columns))
+	for _, column := range columns {
+		names = append(names, quoteIdentifier(column))
+		valueSQLs = append(valueSQLs, valueSQL(types[column]))
+		args = append(args, values[column])
+	}
+
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf(
+		`INSERT INTO %s (%s) VALUES (%s)`,
+		quoteTable(table),
+		strings.Join(names, ", "),
+		strings.Join(valueSQLs, ", "),
+	), args...)
+	return err
+}
+
+func (s *seeder) update(ctx context.Context, table *seed.Table, types map[string]string, changed []*seed.ColumnChange, values map[string]*string) error {
+	sets := make([]string, 0, len(changed))
+	args := make([]any, 0)
+	for _, column := range changed {
+		sets = append(sets, quoteIdentifier(column.Column)+" = "+valueSQL(types[column.Column]))
+		args = append(args, values[column.Column])
+	}
+
+	where, whereArgs := equalsSQL(table.Key, types, values)
+	args = append(args, whereArgs...)
+
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf(
+		`UPDATE %s SET %s WHERE %s`,
+		quoteTable(table.Table),
+		strings.Join(sets, ", "),
+		where,
+	), args...)
+	return err
+}
+
+// textValue converts a fixture value to the text form of a column of
+// dataType, resolving references. Lists and objects become JSON, MySQL has
+// no arrays.
+func (s *seeder) textValue(ctx context.Context, value any, dataType string) (*string, error) {
+	ref, isRef, err := seed.AsRef(value)
+	if err != nil {
+		return nil, err
+	}
+	if isRef {
+		return s.resolve(ctx, ref)
+	}
+
+	var text string
+	switch value := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		text = value
+	case bool:
+		text = "0"
+		if value {
+			text = "1"
+		}
+	case time.Time:
+		text = value.UTC().Format("2006-01-02 15:04:05.999999")
+	case []any, map[string]any:
+		b, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		text = string(b)
+	default:
+		text = fmt.Sprint(value)
+	}
+	return &text, nil
+}
+
+// resolve returns the text form of ref.Column of the one row matching ref
+func (s *seeder) resolve(ctx context.Context, ref *seed.Ref) (*string, error) {
+	types, err := s.columnTypes(ctx, ref.Table)
+	if err != nil {
+		return nil, err
+	}
+	if types == nil {
+		return nil, fmt.Errorf("referenced table %s does not exist", ref.Table)
+	}
+	if _, ok := types[ref.Column]; !ok {
+		return nil, fmt.Errorf("referenced column %s.%s does not exist", ref.Table, ref.Column)
+	}
+
+	columns := make([]string, 0, len(ref.Where))
+	values := make(map[string]*string, len(ref.Where))
+	for column, value := range ref.Where {
+		if _, ok := types[column]; !ok {
+			return nil, fmt.Errorf("referenced column %s.%s does not exist", ref.Table, column)
+		}
+		text, err := s.textValue(ctx, value, types[column])
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+		values[column] = text
+	}
+	sort.Strings(columns)
+
+	where, args := equalsSQL(columns, types, values)
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(
+		`SELECT CAST(%s AS CHAR) FROM %s WHERE %s LIMIT 2`,
+		quoteIdentifier(ref.Column),
+		quoteTable(ref.Table),
+		where,
+	), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matches := make([]*string, 0)
+	for rows.Next() {
+		var match *string
+		if err := rows.Scan(&match); err != nil {
+			return nil, err
+		}
+		matches = append(matches, match)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(matches) != 1 {
+		return nil, fmt.Errorf("reference to %s %v matches %d rows, expected 1", ref.Table, ref.Where, len(matches))
+	}
+	return matches[0], nil
+}
+
+// columnTypes returns the data type of every column of table, nil when the
+// table does not exist
+func (s *seeder) columnTypes(ctx context.Context, table string) (map[string]string, error) {
+	if types, ok := s.types[table]; ok {
+		return types, nil
+	}
+
+	var schema *string
+	name := table
+	if i := strings.IndexByte(table, '.'); i >= 0 {
+		schemaName := table[:i]
+		schema = &schemaName
+		name = table[i+1:]
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT COLUMN_NAME, DATA_TYPE
+		FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = COALESCE(?, DATABASE()) AND TABLE_NAME = ?
+	`, schema, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	types := make(map[string]string)
+	for rows.Next() {
+		var column, dataType string
+		if err := rows.Scan(&column, &dataType); err != nil {
+			return nil, err
+		}
+		types[column] = strings.ToLower(dataType)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(types) == 0 {
+		types = nil
+	}
+	s.types[table] = types
+	return types, nil
+}
+
+// equalsSQL matches every column to its value
+func equalsSQL(columns []string, types map[string]string, values map[string]*string) (string, []any) {
+	conditions := make([]string, 0, len(columns))
+	args := make([]any, 0, len(columns))
+	for _, column := range columns {
+		conditions = append(conditions, quoteIdentifier(column)+" = "+valueSQL(types[column]))
+		args = append(args, values[column])
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// valueSQL is the placeholder of a text value for a column of dataType
+func valueSQL(dataType string) string {
+	if dataType == "json" {
+		return "CAST(? AS JSON)"
+	}
+	return "?"
+}
+
+// quoteTable quotes table, which may be schema qualified
+func quoteTable(table string) string {
+	parts := strings.Split(table, ".")
+	for i, part := range parts {
+		parts[i] = quoteIdentifier(part)
+	}
+	return strings.Join(parts, ".")
+}
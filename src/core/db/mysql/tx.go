@@ -0,0 +1,185 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
+)
+
+// Querier is implemented by both *sql.DB and *sql.Tx, so repository methods
+// run unchanged inside WithTx
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type IsolationLevel string
+
+const (
+	ReadCommitted  IsolationLevel = "read committed"
+	RepeatableRead IsolationLevel = "repeatable read"
+	Serializable   IsolationLevel = "serializable"
+)
+
+// TxOptions apply to the outermost transaction only, a nested WithTx is a
+// savepoint of it
+type TxOptions struct {
+	IsolationLevel IsolationLevel
+	ReadOnly       bool
+	// MaxRetries reruns the whole transaction after a deadlock or a TiDB
+	// write conflict, so fn must be safe to run more than once
+	MaxRetries int
+}
+
+type TxOption func(options *TxOptions)
+
+func WithIsolationLevel(level IsolationLevel) TxOption {
+	return func(options *TxOptions) {
+		options.IsolationLevel = level
+	}
+}
+
+func WithReadOnly() TxOption {
+	return func(options *TxOptions) {
+		options.ReadOnly = true
+	}
+}
+
+func WithMaxRetries(maxRetries int) TxOption {
+	return func(options *TxOptions) {
+		options.MaxRetries = maxRetries
+	}
+}
+
+const (
+	errorCodeDeadlock          = 1213
+	errorCodeTiDBWriteConflict = 9007
+
+	txRetryBaseDelay = 10 * time.Millisecond
+)
+
+var isolationLevels = map[IsolationLevel]sql.IsolationLevel{
+	"":             sql.LevelDefault,
+	ReadCommitted:  sql.LevelReadCommitted,
+	RepeatableRead: sql.LevelRepeatableRead,
+	Serializable:   sql.LevelSerializable,
+}
+
+// WithTx runs fn in a transaction, committing when it returns nil and rolling
+// back when it returns an error or panics. The tx passed to fn has every
+// repository method of mydb; calling WithTx on it opens a savepoint. DDL
+// statements commit implicitly in MySQL and TiDB, keep them out of fn.
+func (mydb *MySQLDB) WithTx(ctx context.Context, fn func(tx *MySQLDB) error, opts ...TxOption) error {
+	options := TxOptions{
+		IsolationLevel: mydb.Config.Transaction.IsolationLevel,
+		MaxRetries:     mydb.Config.Transaction.MaxRetries,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if mydb.tx != nil {
+		return mydb.runSavepoint(ctx, fn)
+	}
+
+	txOptions := &sql.TxOptions{
+		Isolation: isolationLevels[options.IsolationLevel],
+		ReadOnly:  options.ReadOnly,
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := mydb.runTx(ctx, txOptions, fn)
+		if err == nil || attempt >= options.MaxRetries || !isRetryableTxError(err) {
+			return err
+		}
+
+		delay := txRetryBaseDelay<<attempt + time.Duration(rand.Int63n(int64(txRetryBaseDelay)))
+		mydb.logger.Warnf("Transaction failed, retrying in %s (%d/%d): %v", delay, attempt+1, options.MaxRetries, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (mydb *MySQLDB) runTx(ctx context.Context, txOptions *sql.TxOptions, fn func(tx *MySQLDB) error) (err error) {
+	tx, err := mydb.sqlDB.BeginTx(ctx, txOptions)
+	if err != nil {
+		return errors.Wrap(err, "Unable to make a transaction")
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		if err = tx.Commit(); err != nil {
+			err = errors.Wrap(err, "Unable to commit a transaction")
+		}
+	}()
+
+	return fn(mydb.withTx(tx))
+}
+
+func (mydb *MySQLDB) runSavepoint(ctx context.Context, fn func(tx *MySQLDB) error) (err error) {
+	txdb := mydb.withTx(mydb.tx)
+	txdb.savepoints++
+	savepoint := fmt.Sprintf("sp_%d", txdb.savepoints)
+
+	if _, err := mydb.tx.ExecContext(ctx, "SAVEPOINT "+savepoint); err != nil {
+		return errors.Wrap(err, "Unable to make a savepoint")
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_, _ = mydb.tx.ExecContext(context.WithoutCancel(ctx), "ROLLBACK TO SAVEPOINT "+savepoint)
+			panic(p)
+		}
+		if err != nil {
+			_, _ = mydb.tx.ExecContext(context.WithoutCancel(ctx), "ROLLBACK TO SAVEPOINT "+savepoint)
+			return
+		}
+		if _, err = mydb.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+savepoint); err != nil {
+			err = errors.Wrap(err, "Unable to release a savepoint")
+		}
+	}()
+
+	return fn(txdb)
+}
+
+// withTx returns a copy of mydb whose queries run in tx
+func (mydb *MySQLDB) withTx(tx *sql.Tx) *MySQLDB {
+	txdb := *mydb
+	txdb.DB = tx
+	txdb.tx = tx
+	return &txdb
+}
+
+// begin starts a transaction, or a savepoint when mydb is one, for methods
+// that need several statements to succeed together
+func (mydb *MySQLDB) begin(ctx context.Context, fn func(tx *MySQLDB) error) error {
+	if mydb.tx != nil {
+		return mydb.runSavepoint(ctx, fn)
+	}
+	return mydb.runTx(ctx, &sql.TxOptions{}, fn)
+}
+
+func isRetryableTxError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return false
+	}
+	return mysqlErr.Number == errorCodeDeadlock || mysqlErr.Number == errorCodeTiDBWriteConflict
+}
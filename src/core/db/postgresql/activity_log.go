@@ -2,23 +2,44 @@ package postgresql
 
 import (
 	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"go-template/src/core/listquery"
+	"go-template/src/core/model"
 )
 
-func (pgdb *PostgresqlDB) CreateActivityLog(serviceCode, requestNo string, requestBody, responseBody []byte) error {
-	_, err := pgdb.DB.Exec(
-		context.Background(),
-		`
-		INSERT INTO activity_log(request_no, service_code, request_body, response_body) 
-		VALUES ($1, $2, $3, $4)
-		`,
-		requestNo,
-		serviceCode,
-		requestBody,
-		responseBody,
-	)
+// CreateActivityLog queues activityLog when the asynchronous writer is
+// enabled, otherwise, and always inside WithTx, appends it to the hash chain
+// right away
+func (pgdb *PostgresqlDB) CreateActivityLog(ctx context.Context, activityLog *model.ActivityLog) error {
+	if activityLog.CreatedTime.IsZero() {
+		activityLog.CreatedTime = time.Now()
+	}
+
+	if pgdb.activityLogWriter != nil {
+		return pgdb.activityLogWriter.Enqueue(activityLog)
+	}
+
+	return appendActivityLogs(ctx, pgdb.DB, []*model.ActivityLog{activityLog})
+}
+
+// InquiryActivityLog reads from a replica when there is a healthy one
+func (pgdb *PostgresqlDB) InquiryActivityLog(ctx context.Context, filter model.ActivityLogFilter, list *listquery.List) ([]*model.ActivityLog, *model.Pagination, error) {
+	query := NewQuery().
+		Equal("user_id", filter.UserID).
+		EqualFold("email_address", filter.EmailAddress).
+		Equal("service_code", filter.ServiceCode).
+		Equal("http_status_code", filter.HTTPStatusCode).
+		Equal("request_no", filter.RequestNo).
+		Equal("trace_id", filter.TraceID).
+		TimeRange("created_time", filter.StartTime, filter.EndTime)
+
+	result := make([]*model.ActivityLog, 0)
+	pagination, err := pgdb.selectList(ctx, pgdb.reader(ctx), "activity_log", query, list, &result)
 	if err != nil {
-		return err
+		return nil, nil, errors.Wrap(err, "Can not select activity log list from database")
 	}
 
-	return nil
+	return result, pagination, nil
 }
@@ -0,0 +1,302 @@
+package postgresql
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+	"go-template/src/core/audit"
+	"go-template/src/core/model"
+)
+
+// activityLogChainLockKey namespaces the advisory locks of the daily chains,
+// the second key is the chain day
+const activityLogChainLockKey int32 = 0x61756474
+
+// appendActivityLogs links batch to the hash chain of each row's day and
+// copies it into activity_log. Every chain day is locked with a transaction
+// advisory lock, so several replicas can append concurrently. Inside WithTx
+// the rows are appended in a savepoint and the locks held until the outer
+// transaction ends.
+func appendActivityLogs(ctx context.Context, db Querier, batch []*model.ActivityLog) (err error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return errors.Wrap(err, "Unable to make a transaction")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		} else {
+			err = tx.Commit(ctx)
+			if err != nil {
+				err = errors.Wrap(err, "Unable to commit a transaction")
+			}
+		}
+	}()
+
+	days := make(map[time.Time][]*model.ActivityLog)
+	for _, activityLog := range batch {
+		activityLog.CreatedTime = activityLog.CreatedTime.Truncate(audit.Precision)
+		day := audit.ChainDate(activityLog.CreatedTime)
+		days[day] = append(days[day], activityLog)
+	}
+
+	// lock in a fixed order so concurrent batches spanning midnight cannot deadlock
+	chainDates := make([]time.Time, 0, len(days))
+	for day := range days {
+		chainDates = append(chainDates, day)
+	}
+	sort.Slice(chainDates, func(i, j int) bool {
+		return chainDates[i].Before(chainDates[j])
+	})
+
+	for _, day := range chainDates {
+		if err = linkActivityLogs(ctx, tx, day, days[day]); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.CopyFrom(
+		ctx,
+		pgx.Identifier{"activity_log"},
+		activityLogColumns,
+		pgx.CopyFromSlice(len(batch), func(i int) ([]interface{}, error) {
+			return activityLogRow(batch[i]), nil
+		}),
+	)
+	return err
+}
+
+func linkActivityLogs(ctx context.Context, tx pgx.Tx, day time.Time, activityLogs []*model.ActivityLog) error {
+	_, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1, $2)`,
+		activityLogChainLockKey, int32(day.Unix()/86400))
+	if err != nil {
+		return errors.Wrap(err, "Can not lock activity log chain")
+	}
+
+	var lastSeq int64
+	var lastHash string
+	err = tx.QueryRow(ctx, `
+		SELECT last_seq, last_hash FROM activity_log_chain WHERE chain_date = $1
+	`, day).Scan(&lastSeq, &lastHash)
+	if err != nil && err != pgx.ErrNoRows {
+		return errors.Wrap(err, "Can not read activity log chain")
+	}
+
+	// rows of a day are chained in creation order
+	sort.SliceStable(activityLogs, func(i, j int) bool {
+		return activityLogs[i].CreatedTime.Before(activityLogs[j].CreatedTime)
+	})
+
+	for _, activityLog := range activityLogs {
+		lastSeq++
+		activityLog.ChainSeq = lastSeq
+		activityLog.PrevHash = lastHash
+		activityLog.RowHash = audit.Hash(lastHash, activityLog)
+		lastHash = activityLog.RowHash
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO activity_log_chain (chain_date, last_seq, last_hash, updated_time)
+		VALUES ($1, $2, $3, now())
+		ON CONFLICT (chain_date) DO UPDATE
+		SET last_seq = EXCLUDED.last_seq, last_hash = EXCLUDED.last_hash, updated_time = now()
+	`, day, lastSeq, lastHash)
+	if err != nil {
+		return errors.Wrap(err, "Can not update activity log chain")
+	}
+
+	return nil
+}
+
+// ListActivityLogChainHeads returns the chain heads of the days in
+// [from, to], oldest first. Zero times leave the range open.
+func (pgdb *PostgresqlDB) ListActivityLogChainHeads(ctx context.Context, from, to time.Time) ([]*model.ActivityLogChainHead, error) {
+	rows, err := pgdb.DB.Query(ctx, `
+		SELECT chain_date, last_seq, last_hash
+		FROM activity_log_chain
+		WHERE ($1::date IS NULL OR chain_date >= $1) AND ($2::date IS NULL OR chain_date <= $2)
+		ORDER BY chain_date
+	`, nullableDate(from), nullableDate(to))
+	if err != nil {
+		return nil, errors.Wrap(err, "Can not list activity log chains")
+	}
+
+	heads, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*model.ActivityLogChainHead, error) {
+		head := &model.ActivityLogChainHead{}
+		err := row.Scan(&head.ChainDate, &head.LastSeq, &head.LastHash)
+		return head, err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Can not list activity log chains")
+	}
+
+	return heads, nil
+}
+
+// WalkActivityLogChain calls fn for every chained row of day in chain order
+func (pgdb *PostgresqlDB) WalkActivityLogChain(ctx context.Context, day time.Time, fn func(activityLog *model.ActivityLog) error) error {
+	rows, err := pgdb.DB.Query(ctx, `
+		SELECT
+			id, chain_seq, COALESCE(prev_hash, ''), COALESCE(row_hash, ''),
+			COALESCE(request_no, ''), COALESCE(trace_id, ''), COALESCE(span_id, ''), service_code,
+			user_id, azure_user_id, email_address, user_roles, http_method, request_uri,
+			http_status_code, response_code, duration_ms, COALESCE(ip_address, ''), user_agent,
+			request_body, response_body, created_time
+		FROM activity_log
+		WHERE chain_date = $1 AND chain_seq IS NOT NULL
+		ORDER BY chain_seq
+	`, day)
+	if err != nil {
+		return errors.Wrap(err, "Can not read activity log chain")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		activityLog := &model.ActivityLog{}
+		err := rows.Scan(
+			&activityLog.ID,
+			&activityLog.ChainSeq,
+			&activityLog.PrevHash,
+			&activityLog.RowHash,
+			&activityLog.RequestNo,
+			&activityLog.TraceID,
+			&activityLog.SpanID,
+			&activityLog.ServiceCode,
+			&activityLog.UserID,
+			&activityLog.AzureUserID,
+			&activityLog.EmailAddress,
+			&activityLog.UserRoles,
+			&activityLog.HTTPMethod,
+			&activityLog.RequestURI,
+			&activityLog.HTTPStatusCode,
+			&activityLog.ResponseCode,
+			&activityLog.DurationMs,
+			&activityLog.IPAddress,
+			&activityLog.UserAgent,
+			&activityLog.RequestBody,
+			&activityLog.ResponseBody,
+			&activityLog.CreatedTime,
+		)
+		if err != nil {
+			return errors.Wrap(err, "Can not read activity log chain")
+		}
+
+		if err := fn(activityLog); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// CreateActivityLogCheckpoint stores a signed checkpoint, ignoring one that
+// already exists for the same link
+func (pgdb *PostgresqlDB) CreateActivityLogCheckpoint(ctx context.Context, checkpoint *model.ActivityLogCheckpoint) error {
+	_, err := pgdb.DB.Exec(ctx, `
+		INSERT INTO activity_log_checkpoint (chain_date, chain_seq, row_hash, key_id, signature)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (chain_date, chain_seq) DO NOTHING
+	`,
+		checkpoint.ChainDate,
+		checkpoint.ChainSeq,
+		checkpoint.RowHash,
+		checkpoint.KeyID,
+		checkpoint.Signature,
+	)
+	if err != nil {
+		return errors.Wrap(err, "Can not create activity log checkpoint")
+	}
+
+	return nil
+}
+
+// ListUncheckpointedActivityLogChainHeads returns the chain heads that moved
+// since their last checkpoint
+func (pgdb *PostgresqlDB) ListUncheckpointedActivityLogChainHeads(ctx context.Context) ([]*model.ActivityLogChainHead, error) {
+	rows, err := pgdb.DB.Query(ctx, `
+		SELECT h.chain_date, h.last_seq, h.last_hash
+		FROM activity_log_chain h
+		WHERE NOT EXISTS (
+			SELECT 1 FROM activity_log_checkpoint c
+			WHERE c.chain_date = h.chain_date AND c.chain_seq = h.last_seq
+		)
+		ORDER BY h.chain_date
+	`)
+	if err != nil {
+		return nil, errors.Wrap(err, "Can not list activity log chains")
+	}
+
+	heads, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*model.ActivityLogChainHead, error) {
+		head := &model.ActivityLogChainHead{}
+		err := row.Scan(&head.ChainDate, &head.LastSeq, &head.LastHash)
+		return head, err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Can not list activity log chains")
+	}
+
+	return heads, nil
+}
+
+// ListActivityLogCheckpoints returns the checkpoints of day
+func (pgdb *PostgresqlDB) ListActivityLogCheckpoints(ctx context.Context, day time.Time) ([]*model.ActivityLogCheckpoint, error) {
+	rows, err := pgdb.DB.Query(ctx, `
+		SELECT id, chain_date, chain_seq, row_hash, key_id, signature, created_time
+		FROM activity_log_checkpoint
+		WHERE chain_date = $1
+		ORDER BY chain_seq
+	`, day)
+	if err != nil {
+		return nil, errors.Wrap(err, "Can not list activity log checkpoints")
+	}
+
+	checkpoints, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*model.ActivityLogCheckpoint, error) {
+		checkpoint := &model.ActivityLogCheckpoint{}
+		err := row.Scan(
+			&checkpoint.ID,
+			&checkpoint.ChainDate,
+			&checkpoint.ChainSeq,
+			&checkpoint.RowHash,
+			&checkpoint.KeyID,
+			&checkpoint.Signature,
+			&checkpoint.CreatedTime,
+		)
+		return checkpoint, err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Can not list activity log checkpoints")
+	}
+
+	return checkpoints, nil
+}
+
+// ListActivityLogCheckpointDays returns the days in [from, to] that have
+// checkpoints, oldest first. Zero times leave the range open.
+func (pgdb *PostgresqlDB) ListActivityLogCheckpointDays(ctx context.Context, from, to time.Time) ([]time.Time, error) {
+	rows, err := pgdb.DB.Query(ctx, `
+		SELECT DISTINCT chain_date
+		FROM activity_log_checkpoint
+		WHERE ($1::date IS NULL OR chain_date >= $1) AND ($2::date IS NULL OR chain_date <= $2)
+		ORDER BY chain_date
+	`, nullableDate(from), nullableDate(to))
+	if err != nil {
+		return nil, errors.Wrap(err, "Can not list activity log checkpoint days")
+	}
+
+	days, err := pgx.CollectRows(rows, pgx.RowTo[time.Time])
+	if err != nil {
+		return nil, errors.Wrap(err, "Can not list activity log checkpoint days")
+	}
+
+	return days, nil
+}
+
+func nullableDate(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
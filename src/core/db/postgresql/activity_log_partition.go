@@ -0,0 +1,199 @@
+package postgresql
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+	"go-template/src/core/model"
+)
+
+const activityLogPartitionPrefix = "activity_log_p"
+
+// ActivityLogPartitionName returns the name of the partition holding month
+func ActivityLogPartitionName(month time.Time) string {
+	return activityLogPartitionPrefix + month.Format("200601")
+}
+
+// EnsureActivityLogPartitions creates the partitions for the month of from and
+// the following months, skipping the ones that exist. Months are in UTC, a
+// partition holds created_time from 00:00 UTC of its first day.
+func (pgdb *PostgresqlDB) EnsureActivityLogPartitions(ctx context.Context, from time.Time, months int) ([]string, error) {
+	names := make([]string, 0, months+1)
+	from = from.UTC()
+	month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i <= months; i++ {
+		var name string
+		err := pgdb.DB.QueryRow(ctx,
+			`SELECT activity_log_create_partition($1::date)`,
+			month.AddDate(0, i, 0).Format(time.DateOnly),
+		).Scan(&name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Can not create activity log partition for %s", month.AddDate(0, i, 0).Format("2006-01"))
+		}
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// ListActivityLogPartitions returns the monthly partitions, oldest first
+func (pgdb *PostgresqlDB) ListActivityLogPartitions(ctx context.Context) ([]*model.ActivityLogPartition, error) {
+	rows, err := pgdb.DB.Query(ctx, `
+		SELECT
+			child.relname
+		FROM
+			pg_inherits
+			JOIN pg_class parent ON parent.oid = pg_inherits.inhparent
+			JOIN pg_class child ON child.oid = pg_inherits.inhrelid
+		WHERE
+			parent.relname = 'activity_log'
+		ORDER BY child.relname
+	`)
+	if err != nil {
+		return nil, errors.Wrap(err, "Can not list activity log partitions")
+	}
+
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, errors.Wrap(err, "Can not list activity log partitions")
+	}
+
+	result := make([]*model.ActivityLogPartition, 0, len(names))
+	for _, name := range names {
+		month, err := time.Parse("200601", strings.TrimPrefix(name, activityLogPartitionPrefix))
+		if err != nil || !strings.HasPrefix(name, activityLogPartitionPrefix) {
+			// not created by activity_log_create_partition, leave it alone
+			continue
+		}
+		result = append(result, &model.ActivityLogPartition{
+			Name:  name,
+			Month: month,
+		})
+	}
+
+	return result, nil
+}
+
+// ExportActivityLogPartition writes every row of the partition of month to w
+// as JSON Lines, in insertion order, and returns the number of rows
+func (pgdb *PostgresqlDB) ExportActivityLogPartition(ctx context.Context, month time.Time, w io.Writer) (int64, error) {
+	table := pgx.Identifier{ActivityLogPartitionName(month)}.Sanitize()
+
+	rows, err := pgdb.DB.Query(ctx,
+		`SELECT row_to_json(t)::text FROM `+table+` t ORDER BY created_time, id`,
+	)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Can not export activity log partition %s", table)
+	}
+	defer rows.Close()
+
+	var count int64
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return count, errors.Wrapf(err, "Can not export activity log partition %s", table)
+		}
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, errors.Wrapf(err, "Can not export activity log partition %s", table)
+	}
+
+	return count, nil
+}
+
+// DropActivityLogPartition detaches and drops the partition of month
+func (pgdb *PostgresqlDB) DropActivityLogPartition(ctx context.Context, month time.Time) error {
+	table := pgx.Identifier{ActivityLogPartitionName(month)}.Sanitize()
+
+	_, err := pgdb.DB.Exec(ctx, `
+		ALTER TABLE activity_log DETACH PARTITION `+table+`;
+		DROP TABLE `+table+`;
+	`)
+	if err != nil {
+		return errors.Wrapf(err, "Can not drop activity log partition %s", table)
+	}
+
+	return nil
+}
+
+// archiveLines is a pgx.CopyFromSource of the non-empty lines of an archive
+type archiveLines struct {
+	scanner *bufio.Scanner
+}
+
+func (l *archiveLines) Next() bool {
+	for l.scanner.Scan() {
+		if len(l.scanner.Bytes()) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *archiveLines) Values() ([]interface{}, error) {
+	return []interface{}{l.scanner.Text()}, nil
+}
+
+func (l *archiveLines) Err() error {
+	return l.scanner.Err()
+}
+
+// RestoreActivityLogArchive loads JSON Lines produced by
+// ExportActivityLogPartition into a new unlogged table shaped like
+// activity_log and returns the number of rows loaded. r is streamed, when
+// reading it fails the table is not created.
+func (pgdb *PostgresqlDB) RestoreActivityLogArchive(ctx context.Context, tableName string, r io.Reader) (count int64, err error) {
+	table := pgx.Identifier{tableName}.Sanitize()
+
+	tx, err := pgdb.DB.Begin(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "Unable to make a transaction")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		} else {
+			err = tx.Commit(ctx)
+			if err != nil {
+				err = errors.Wrap(err, "Unable to commit a transaction")
+			}
+		}
+	}()
+
+	_, err = tx.Exec(ctx, `
+		CREATE UNLOGGED TABLE `+table+` (LIKE activity_log INCLUDING DEFAULTS);
+		CREATE TEMP TABLE activity_log_restore_lines (line jsonb) ON COMMIT DROP;
+	`)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Can not create restore table %s", table)
+	}
+
+	// rows are copied as they are read, a read error aborts the COPY and
+	// rolls back the restore table
+	lines := &archiveLines{scanner: bufio.NewScanner(r)}
+	lines.scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"activity_log_restore_lines"}, []string{"line"}, lines)
+	if err != nil {
+		return 0, errors.Wrap(err, "Can not load activity log archive")
+	}
+
+	result, err := tx.Exec(ctx, `
+		INSERT INTO `+table+`
+		SELECT r.* FROM activity_log_restore_lines l, jsonb_populate_record(NULL::`+table+`, l.line) r
+	`)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Can not restore activity log archive into %s", table)
+	}
+
+	return result.RowsAffected(), nil
+}
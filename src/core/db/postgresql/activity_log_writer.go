@@ -0,0 +1,446 @@
+package postgresql
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pkg/errors"
+	"go-template/src/core/audit"
+	"go-template/src/core/log"
+	"go-template/src/core/model"
+)
+
+const (
+	OverflowPolicyBlock = "block"
+	OverflowPolicyDrop  = "drop"
+
+	spillFilePattern = "activity_log-*.jsonl"
+	// deadLetterFile collects spilled entries Postgres rejects, e.g. of a
+	// month whose partition was archived and dropped since
+	deadLetterFile = "activity_log.dead-letter.jsonl"
+	flushTimeout   = 30 * time.Second
+)
+
+var ErrActivityLogWriterClosed = errors.New("activity log writer is closed")
+
+var activityLogColumns = []string{
+	"request_no",
+	"trace_id",
+	"span_id",
+	"service_code",
+	"user_id",
+	"azure_user_id",
+	"email_address",
+	"user_roles",
+	"http_method",
+	"request_uri",
+	"http_status_code",
+	"response_code",
+	"duration_ms",
+	"ip_address",
+	"user_agent",
+	"request_body",
+	"response_body",
+	"created_time",
+	"chain_date",
+	"chain_seq",
+	"prev_hash",
+	"row_hash",
+}
+
+// ActivityLogWriterStats counts entries since the writer started
+type ActivityLogWriterStats struct {
+	Queued  int   `json:"queued"`
+	Written int64 `json:"written"`
+	Dropped int64 `json:"dropped"`
+	Failed  int64 `json:"failed"`
+	Spilled int64 `json:"spilled"`
+	// DeadLettered counts spilled entries moved to the dead-letter file
+	DeadLettered int64 `json:"dead_lettered"`
+}
+
+// activityLogWriter queues activity log entries in memory and appends them
+// to the hash chain with COPY, in batches of BatchSize or every FlushInterval. When the queue
+// is full it waits up to BlockTimeout ("block") or drops the entry ("drop").
+// Batches that cannot be written are appended to a file in SpillDir, if set,
+// and copied to Postgres once it is reachable again.
+type activityLogWriter struct {
+	config *ActivityLogConfig
+	logger log.Logger
+	pool   *pgxpool.Pool
+
+	queue chan *model.ActivityLog
+	done  chan struct{}
+	wg    sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+	// senders counts Enqueue calls in progress, which may be waiting for
+	// room in the queue without holding mu
+	senders sync.WaitGroup
+
+	written atomic.Int64
+	dropped atomic.Int64
+	failed  atomic.Int64
+	spilled atomic.Int64
+	dead    atomic.Int64
+
+	hasSpill bool
+}
+
+func newActivityLogWriter(config *ActivityLogConfig, pool *pgxpool.Pool, logger log.Logger) (*activityLogWriter, error) {
+	w := &activityLogWriter{
+		config: config,
+		logger: logger,
+		pool:   pool,
+		queue:  make(chan *model.ActivityLog, config.QueueSize),
+		done:   make(chan struct{}),
+	}
+
+	if config.SpillDir != "" {
+		if err := os.MkdirAll(config.SpillDir, 0o750); err != nil {
+			return nil, errors.Wrap(err, "unable to create activity log spill directory")
+		}
+
+		files, err := w.spillFiles()
+		if err != nil {
+			return nil, err
+		}
+		w.hasSpill = len(files) > 0
+	}
+
+	w.wg.Add(1)
+	go w.run()
+
+	return w, nil
+}
+
+// Enqueue hands activityLog to the writer without waiting for the database
+func (w *activityLogWriter) Enqueue(activityLog *model.ActivityLog) error {
+	w.mu.RLock()
+	if w.closed {
+		w.mu.RUnlock()
+		return ErrActivityLogWriterClosed
+	}
+	w.senders.Add(1)
+	w.mu.RUnlock()
+	defer w.senders.Done()
+
+	select {
+	case w.queue <- activityLog:
+		return nil
+	default:
+	}
+
+	if w.config.OverflowPolicy == OverflowPolicyDrop {
+		w.dropped.Add(1)
+		return nil
+	}
+
+	if w.config.BlockTimeout <= 0 {
+		w.queue <- activityLog
+		return nil
+	}
+
+	timer := time.NewTimer(w.config.BlockTimeout)
+	defer timer.Stop()
+
+	select {
+	case w.queue <- activityLog:
+	case <-timer.C:
+		w.dropped.Add(1)
+	}
+	return nil
+}
+
+func (w *activityLogWriter) Stats() ActivityLogWriterStats {
+	return ActivityLogWriterStats{
+		Queued:       len(w.queue),
+		Written:      w.written.Load(),
+		Dropped:      w.dropped.Load(),
+		Failed:       w.failed.Load(),
+		Spilled:      w.spilled.Load(),
+		DeadLettered: w.dead.Load(),
+	}
+}
+
+// Close stops accepting entries and flushes everything still queued
+func (w *activityLogWriter) Close() {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.closed = true
+	w.mu.Unlock()
+
+	// senders waiting on a full queue get in while run still drains it
+	w.senders.Wait()
+	close(w.done)
+	w.wg.Wait()
+
+	stats := w.Stats()
+	w.logger.Infof("Activity log writer stopped, written: %d, dropped: %d, failed: %d, spilled: %d, dead-lettered: %d",
+		stats.Written, stats.Dropped, stats.Failed, stats.Spilled, stats.DeadLettered)
+}
+
+func (w *activityLogWriter) run() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*model.ActivityLog, 0, w.config.BatchSize)
+	var reportedDropped int64
+
+	for {
+		select {
+		case activityLog := <-w.queue:
+			batch = append(batch, activityLog)
+			if len(batch) >= w.config.BatchSize {
+				batch = w.flush(batch)
+			}
+
+		case <-ticker.C:
+			batch = w.flush(batch)
+
+			if dropped := w.dropped.Load(); dropped != reportedDropped {
+				w.logger.Warnf("Activity log queue full, %d entries dropped so far", dropped)
+				reportedDropped = dropped
+			}
+
+		case <-w.done:
+			// Enqueue can no longer be called, drain what is left
+			for {
+				select {
+				case activityLog := <-w.queue:
+					batch = append(batch, activityLog)
+					if len(batch) >= w.config.BatchSize {
+						batch = w.flush(batch)
+					}
+				default:
+					w.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush writes batch and returns it emptied for reuse
+func (w *activityLogWriter) flush(batch []*model.ActivityLog) []*model.ActivityLog {
+	if len(batch) == 0 {
+		if w.hasSpill {
+			w.replaySpill()
+		}
+		return batch
+	}
+
+	if err := w.copy(batch); err != nil {
+		w.logger.Errorf("Unable to write %d activity log entries: %+v", len(batch), err)
+		w.failed.Add(int64(len(batch)))
+
+		if w.config.SpillDir != "" {
+			if err := w.spill(batch); err != nil {
+				w.logger.Errorf("Unable to spill activity log entries to disk, %d entries lost: %+v", len(batch), err)
+			}
+		}
+		return batch[:0]
+	}
+
+	w.written.Add(int64(len(batch)))
+	if w.hasSpill {
+		w.replaySpill()
+	}
+	return batch[:0]
+}
+
+func (w *activityLogWriter) copy(batch []*model.ActivityLog) error {
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+
+	return appendActivityLogs(ctx, w.pool, batch)
+}
+
+func activityLogRow(activityLog *model.ActivityLog) []interface{} {
+	userRoles := activityLog.UserRoles
+	if userRoles == nil {
+		userRoles = []string{}
+	}
+
+	return []interface{}{
+		activityLog.RequestNo,
+		activityLog.TraceID,
+		activityLog.SpanID,
+		activityLog.ServiceCode,
+		activityLog.UserID,
+		activityLog.AzureUserID,
+		activityLog.EmailAddress,
+		userRoles,
+		activityLog.HTTPMethod,
+		activityLog.RequestURI,
+		activityLog.HTTPStatusCode,
+		activityLog.ResponseCode,
+		activityLog.DurationMs,
+		activityLog.IPAddress,
+		activityLog.UserAgent,
+		activityLog.RequestBody,
+		activityLog.ResponseBody,
+		activityLog.CreatedTime,
+		audit.ChainDate(activityLog.CreatedTime),
+		activityLog.ChainSeq,
+		activityLog.PrevHash,
+		activityLog.RowHash,
+	}
+}
+
+// spill appends batch as JSON lines to a new file in SpillDir
+func (w *activityLogWriter) spill(batch []*model.ActivityLog) error {
+	name := filepath.Join(w.config.SpillDir, fmt.Sprintf("activity_log-%d.jsonl", time.Now().UnixNano()))
+
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o640)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, activityLog := range batch {
+		if err := encoder.Encode(activityLog); err != nil {
+			_ = file.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	w.spilled.Add(int64(len(batch)))
+	w.hasSpill = true
+	return nil
+}
+
+func (w *activityLogWriter) spillFiles() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(w.config.SpillDir, spillFilePattern))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// replaySpill copies spilled files back to Postgres, oldest first, and stops
+// at the first failure
+func (w *activityLogWriter) replaySpill() {
+	files, err := w.spillFiles()
+	if err != nil {
+		w.logger.Errorf("Unable to list activity log spill files: %+v", err)
+		return
+	}
+
+	for _, name := range files {
+		batch, err := readSpillFile(name)
+		if err != nil {
+			// keep the file for inspection but stop retrying it
+			w.logger.Errorf("Unable to read activity log spill file %s: %+v", name, err)
+			if err := os.Rename(name, name+".bad"); err != nil {
+				return
+			}
+			continue
+		}
+
+		if err := w.copy(batch); err != nil {
+			if !isRejectedError(err) {
+				// Postgres is unreachable, try again on the next flush
+				return
+			}
+
+			// retrying would fail the same way and hold back the files after it
+			w.logger.Errorf("Postgres rejected activity log spill file %s, moving its %d entries to %s: %+v",
+				name, len(batch), deadLetterFile, err)
+			if err := w.deadLetter(name); err != nil {
+				w.logger.Errorf("Unable to move activity log spill file %s to the dead-letter file: %+v", name, err)
+				return
+			}
+			w.dead.Add(int64(len(batch)))
+			continue
+		}
+
+		if err := os.Remove(name); err != nil {
+			w.logger.Errorf("Unable to remove activity log spill file %s: %+v", name, err)
+			return
+		}
+
+		w.written.Add(int64(len(batch)))
+		w.logger.Infof("Restored %d activity log entries from %s", len(batch), name)
+	}
+
+	w.hasSpill = false
+}
+
+// deadLetter appends the entries of the spill file name to the dead-letter
+// file, as they were spilled, and removes it
+func (w *activityLogWriter) deadLetter(name string) error {
+	content, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(filepath.Join(w.config.SpillDir, deadLetterFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(content); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(name)
+}
+
+// isRejectedError tells whether Postgres refused the data itself (classes 22
+// and 23, e.g. no partition for the row), rather than being unreachable
+func isRejectedError(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return strings.HasPrefix(pgErr.Code, "22") || strings.HasPrefix(pgErr.Code, "23")
+}
+
+func readSpillFile(name string) ([]*model.ActivityLog, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	batch := make([]*model.ActivityLog, 0)
+	decoder := json.NewDecoder(file)
+	for decoder.More() {
+		activityLog := &model.ActivityLog{}
+		if err := decoder.Decode(activityLog); err != nil {
+			return nil, err
+		}
+		batch = append(batch, activityLog)
+	}
+	return batch, nil
+}
@@ -0,0 +1,29 @@
+package postgresql
+
+import (
+	"context"
+)
+
+type contextKey string
+
+const contextKeyActor contextKey = "Actor"
+
+// Actor identifies who makes a change, recorded by the data_change_log trigger
+type Actor struct {
+	UserID    string
+	RequestNo string
+}
+
+// ContextWithActor returns a copy of ctx carrying actor. Transactions begun
+// with it, see WithTx, pass the actor to Postgres as the transaction-local settings
+// app.user_id and app.request_no. Writes outside a transaction record no
+// actor, so repository methods writing a table with the data_change_log
+// trigger run in WithTx.
+func ContextWithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, contextKeyActor, actor)
+}
+
+func actorFromContext(ctx context.Context) (Actor, bool) {
+	actor, ok := ctx.Value(contextKeyActor).(Actor)
+	return actor, ok
+}
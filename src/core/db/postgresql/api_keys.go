@@ -5,69 +5,56 @@ import (
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/pkg/errors"
 	"go-template/src/core/model"
 )
 
-func (pgdb *PostgresqlDB) InsertApiKeys(apiKeysList []model.ApiKey, isRoot bool) error {
-
-	ctx := context.Background()
-
-	tx, err := pgdb.DB.Begin(ctx)
-	if err != nil {
-		return errors.Wrap(err, "Unable to make a transaction")
-	}
-	defer func() {
-		if err != nil {
-			_ = tx.Rollback(ctx)
-		} else {
-			err = tx.Commit(ctx)
+func (pgdb *PostgresqlDB) InsertApiKeys(ctx context.Context, apiKeysList []model.ApiKey, isRoot bool) error {
+	return pgdb.WithTx(ctx, func(tx *PostgresqlDB) error {
+		for _, apiKey := range apiKeysList {
+			_, err := tx.DB.Exec(ctx, `
+					INSERT INTO api_keys(
+						key,
+						azure_user_id,
+						user_id,
+						email_address,
+						user_role_name,
+						expire_time,
+						user_profile_pic
+					)
+					VALUES ($1, $2, $3, $4, $5, $6, $7)
+				`,
+				apiKey.Key,
+				apiKey.AzureUserID,
+				apiKey.UserID,
+				apiKey.EmailAddress,
+				apiKey.UserRoleName,
+				apiKey.ExpireTime,
+				apiKey.UserProfilePic,
+			)
 			if err != nil {
-				err = errors.Wrap(err, "Unable to commit a transaction")
+				return err
 			}
 		}
-	}()
-
-	for _, apiKey := range apiKeysList {
-		_, err = tx.Exec(ctx, `
-				INSERT INTO api_keys(
-					key,
-					azure_user_id,
-					user_id,
-					email_address,
-					user_role_name,
-					expire_time,
-					user_profile_pic
-				)
-				VALUES ($1, $2, $3, $4, $5, $6, $7)
-			`,
-			apiKey.Key,
-			apiKey.AzureUserID,
-			apiKey.UserID,
-			apiKey.EmailAddress,
-			apiKey.UserRoleName,
-			apiKey.ExpireTime,
-			apiKey.UserProfilePic,
-		)
-		if err != nil {
-			return err
-		}
-
-	}
 
-	return nil
+		return nil
+	})
 }
 
-func (pgdb *PostgresqlDB) VerifyApiKey(key string, newExpireTime time.Time) ([]*model.ApiKey, error) {
+func (pgdb *PostgresqlDB) VerifyApiKey(ctx context.Context, key string, newExpireTime time.Time) ([]*model.ApiKey, error) {
 	result := make([]*model.ApiKey, 0)
-	_, err := pgdb.DB.Exec(context.Background(), `
-		UPDATE api_keys
-		SET expire_time = $1
-		WHERE key = $2 AND expire_time >= NOW()
-	`,
-		newExpireTime,
-		key,
-	)
+	err := pgdb.WithTx(ctx, func(tx *PostgresqlDB) error {
+		_, err := tx.DB.Exec(ctx, `
+			UPDATE api_keys
+			SET expire_time = $1
+			WHERE key = $2 AND expire_time >= NOW()
+		`,
+			newExpireTime,
+			key,
+		)
+		return err
+	})
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, nil
@@ -75,7 +62,7 @@ func (pgdb *PostgresqlDB) VerifyApiKey(key string, newExpireTime time.Time) ([]*
 		return nil, err
 	}
 
-	err = pgdb.DB.QueryRow(context.Background(),
+	err = pgdb.DB.QueryRow(ctx,
 		`
 			WITH cte AS (
 				SELECT 
@@ -109,24 +96,26 @@ func (pgdb *PostgresqlDB) VerifyApiKey(key string, newExpireTime time.Time) ([]*
 	return result, nil
 }
 
-func (pgdb *PostgresqlDB) DeleteApiKey(key string) error {
-	_, err := pgdb.DB.Exec(context.Background(), `
-		DELETE FROM api_keys WHERE key = $1
-	`,
-		key,
-	)
-	if err != nil {
+func (pgdb *PostgresqlDB) DeleteApiKey(ctx context.Context, key string) error {
+	return pgdb.WithTx(ctx, func(tx *PostgresqlDB) error {
+		_, err := tx.DB.Exec(ctx, `
+			DELETE FROM api_keys WHERE key = $1
+		`,
+			key,
+		)
 		return err
-	}
-
-	return nil
+	})
 }
 
-func (pgdb *PostgresqlDB) DeleteExpireApiKey() error {
-	result, err := pgdb.DB.Exec(context.Background(), `
-		DELETE FROM api_keys WHERE expire_time IS NOT NULL AND expire_time < NOW()
-	`,
-	)
+func (pgdb *PostgresqlDB) DeleteExpireApiKey(ctx context.Context) error {
+	var result pgconn.CommandTag
+	err := pgdb.WithTx(ctx, func(tx *PostgresqlDB) (err error) {
+		result, err = tx.DB.Exec(ctx, `
+			DELETE FROM api_keys WHERE expire_time IS NOT NULL AND expire_time < NOW()
+		`,
+		)
+		return err
+	})
 	if err != nil {
 		return err
 	}
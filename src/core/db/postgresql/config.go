@@ -2,8 +2,11 @@ package postgresql
 
 import (
 	"fmt"
+	"os"
 	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5/tracelog"
 	"github.com/spf13/viper"
 )
 
@@ -17,9 +20,77 @@ type Config struct {
 	DatabaseName string
 	MaxOpenConns int32
 	SSLMode      string
+	// SSLRootCert, SSLCert and SSLKey are file paths, empty uses none
+	SSLRootCert string
+	SSLCert     string
+	SSLKey      string
+	// Schema is set as the search_path, empty uses the server default
+	Schema string
+	// TimeZone is the session time zone, TZ of the environment by default
+	TimeZone        string
+	ApplicationName string
+	// StatementTimeout cancels statements running longer, 0 never does
+	StatementTimeout time.Duration
+
+	Pool         *PoolConfig
+	ConnectRetry *ConnectRetryConfig
+
+	ActivityLog *ActivityLogConfig
+	Transaction *TransactionConfig
+	// Replicas is nil when no replica host is configured
+	Replicas *ReplicaConfig
+}
+
+// ReplicaConfig lists read-only standbys of the primary. They share its
+// credentials, database name and schema, and each has a pool of its own.
+type ReplicaConfig struct {
+	// Hosts are host or host:port, the port of the primary by default
+	Hosts        []string
+	MaxOpenConns int32
+	// MaxLag is the replay lag above which a replica is skipped
+	MaxLag        time.Duration
+	CheckInterval time.Duration
 }
 
-func InitConfig() (*Config, error) {
+// PoolConfig tunes the connections of a pool, see pgxpool.Config
+type PoolConfig struct {
+	MinConns              int32
+	MaxConnIdleTime       time.Duration
+	MaxConnLifetime       time.Duration
+	MaxConnLifetimeJitter time.Duration
+	HealthCheckPeriod     time.Duration
+	ConnectTimeout        time.Duration
+}
+
+// ConnectRetryConfig bounds how long New waits for the database at startup.
+// Attempts back off exponentially from InitialInterval up to MaxInterval;
+// a MaxWait of 0 fails on the first error.
+type ConnectRetryConfig struct {
+	MaxWait         time.Duration
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+}
+
+// TransactionConfig is the default of WithTx, overridden per call with
+// TxOption. An empty IsolationLevel uses the server default.
+type TransactionConfig struct {
+	IsolationLevel IsolationLevel
+	MaxRetries     int
+}
+
+// ActivityLogConfig controls the asynchronous activity log writer. When
+// Async is false every entry is inserted on the request path.
+type ActivityLogConfig struct {
+	Async          bool
+	QueueSize      int
+	BatchSize      int
+	FlushInterval  time.Duration
+	OverflowPolicy string
+	BlockTimeout   time.Duration
+	SpillDir       string
+}
+
+func InitConfig() (config *Config, err error) {
 	dbHost := viper.GetString("PG_HOST")
 	if dbHost == "" {
 		dbHost = viper.GetString("Database.PostgreSQL.Host")
@@ -45,7 +116,20 @@ func InitConfig() (*Config, error) {
 		dbDBName = viper.GetString("Database.PostgreSQL.DBName")
 	}
 
-	config := &Config{
+	dbSchema := viper.GetString("PG_SCHEMA")
+	if dbSchema == "" {
+		dbSchema = viper.GetString("Database.PostgreSQL.Schema")
+	}
+
+	dbTimeZone := viper.GetString("PG_TIMEZONE")
+	if dbTimeZone == "" {
+		dbTimeZone = viper.GetString("Database.PostgreSQL.TimeZone")
+	}
+	if dbTimeZone == "" {
+		dbTimeZone = os.Getenv("TZ")
+	}
+
+	config = &Config{
 		LogLevel: viper.GetString("Database.Log.Level"),
 
 		Host:         dbHost,
@@ -55,12 +139,39 @@ func InitConfig() (*Config, error) {
 		DatabaseName: dbDBName,
 		MaxOpenConns: viper.GetInt32("Database.PostgreSQL.MaxOpenConns"),
 		SSLMode:      viper.GetString("Database.PostgreSQL.SSLMode"),
+		SSLRootCert:  viper.GetString("Database.PostgreSQL.SSLRootCert"),
+		SSLCert:      viper.GetString("Database.PostgreSQL.SSLCert"),
+		SSLKey:       viper.GetString("Database.PostgreSQL.SSLKey"),
+		Schema:       dbSchema,
+
+		TimeZone:         dbTimeZone,
+		ApplicationName:  viper.GetString("Database.PostgreSQL.ApplicationName"),
+		StatementTimeout: viper.GetDuration("Database.PostgreSQL.StatementTimeout"),
+
+		Pool:         initPoolConfig(),
+		ConnectRetry: initConnectRetryConfig(),
 	}
 
+	// Log.Level is not inherited, at info pgx logs every query
 	if config.LogLevel == "" {
-		config.LogLevel = viper.GetString("Log.Level")
+		config.LogLevel = "warn"
+	}
+	if _, err := tracelog.LogLevelFromString(config.LogLevel); err != nil {
+		return nil, fmt.Errorf("invalid Database.Log.Level %q, expected trace, debug, info, warn, error or none", config.LogLevel)
+	}
+
+	config.ActivityLog, err = initActivityLogConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	config.Transaction, err = initTransactionConfig()
+	if err != nil {
+		return nil, err
 	}
 
+	config.Replicas = initReplicaConfig(config.MaxOpenConns)
+
 	if config.Host == "" {
 		config.Host = "localhost"
 	}
@@ -83,6 +194,139 @@ func InitConfig() (*Config, error) {
 	return config, nil
 }
 
+func initPoolConfig() *PoolConfig {
+	config := &PoolConfig{
+		MinConns:              viper.GetInt32("Database.PostgreSQL.Pool.MinConns"),
+		MaxConnIdleTime:       viper.GetDuration("Database.PostgreSQL.Pool.MaxConnIdleTime"),
+		MaxConnLifetime:       viper.GetDuration("Database.PostgreSQL.Pool.MaxConnLifetime"),
+		MaxConnLifetimeJitter: viper.GetDuration("Database.PostgreSQL.Pool.MaxConnLifetimeJitter"),
+		HealthCheckPeriod:     viper.GetDuration("Database.PostgreSQL.Pool.HealthCheckPeriod"),
+		ConnectTimeout:        viper.GetDuration("Database.PostgreSQL.Pool.ConnectTimeout"),
+	}
+
+	if config.MaxConnIdleTime <= 0 {
+		config.MaxConnIdleTime = 5 * time.Second
+	}
+	if config.MaxConnLifetime <= 0 {
+		config.MaxConnLifetime = 300 * time.Second
+	}
+	if config.HealthCheckPeriod <= 0 {
+		config.HealthCheckPeriod = 15 * time.Second
+	}
+	if config.ConnectTimeout <= 0 {
+		config.ConnectTimeout = 5 * time.Second
+	}
+
+	return config
+}
+
+func initConnectRetryConfig() *ConnectRetryConfig {
+	config := &ConnectRetryConfig{
+		MaxWait:         30 * time.Second,
+		InitialInterval: viper.GetDuration("Database.PostgreSQL.ConnectRetry.InitialInterval"),
+		MaxInterval:     viper.GetDuration("Database.PostgreSQL.ConnectRetry.MaxInterval"),
+	}
+	if viper.IsSet("Database.PostgreSQL.ConnectRetry.MaxWait") {
+		config.MaxWait = viper.GetDuration("Database.PostgreSQL.ConnectRetry.MaxWait")
+	}
+
+	if config.InitialInterval <= 0 {
+		config.InitialInterval = 500 * time.Millisecond
+	}
+	if config.MaxInterval <= 0 {
+		config.MaxInterval = 5 * time.Second
+	}
+	if config.MaxInterval < config.InitialInterval {
+		config.MaxInterval = config.InitialInterval
+	}
+
+	return config
+}
+
+func initActivityLogConfig() (*ActivityLogConfig, error) {
+	config := &ActivityLogConfig{
+		Async:          viper.GetBool("Database.ActivityLog.Async"),
+		QueueSize:      viper.GetInt("Database.ActivityLog.QueueSize"),
+		BatchSize:      viper.GetInt("Database.ActivityLog.BatchSize"),
+		FlushInterval:  viper.GetDuration("Database.ActivityLog.FlushInterval"),
+		OverflowPolicy: viper.GetString("Database.ActivityLog.OverflowPolicy"),
+		BlockTimeout:   viper.GetDuration("Database.ActivityLog.BlockTimeout"),
+		SpillDir:       viper.GetString("Database.ActivityLog.SpillDir"),
+	}
+
+	if config.QueueSize <= 0 {
+		config.QueueSize = 10000
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 500
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+	if config.OverflowPolicy == "" {
+		config.OverflowPolicy = OverflowPolicyBlock
+	}
+
+	switch config.OverflowPolicy {
+	case OverflowPolicyBlock, OverflowPolicyDrop:
+	default:
+		return nil, fmt.Errorf("invalid Database.ActivityLog.OverflowPolicy %q, expected %q or %q",
+			config.OverflowPolicy, OverflowPolicyBlock, OverflowPolicyDrop)
+	}
+
+	return config, nil
+}
+
+func initTransactionConfig() (*TransactionConfig, error) {
+	isolationLevel, err := parseIsolationLevel(viper.GetString("Database.Transaction.IsolationLevel"))
+	if err != nil {
+		return nil, err
+	}
+
+	maxRetries := 3
+	if viper.IsSet("Database.Transaction.MaxRetries") {
+		maxRetries = viper.GetInt("Database.Transaction.MaxRetries")
+	}
+
+	return &TransactionConfig{
+		IsolationLevel: isolationLevel,
+		MaxRetries:     maxRetries,
+	}, nil
+}
+
+func initReplicaConfig(maxOpenConns int32) *ReplicaConfig {
+	hosts := viper.GetStringSlice("Database.PostgreSQL.Replicas.Hosts")
+	if env := viper.GetString("PG_REPLICA_HOSTS"); env != "" {
+		hosts = strings.Split(env, ",")
+	}
+
+	config := &ReplicaConfig{
+		MaxOpenConns:  viper.GetInt32("Database.PostgreSQL.Replicas.MaxOpenConns"),
+		MaxLag:        viper.GetDuration("Database.PostgreSQL.Replicas.MaxLag"),
+		CheckInterval: viper.GetDuration("Database.PostgreSQL.Replicas.CheckInterval"),
+	}
+	for _, host := range hosts {
+		if host = strings.TrimSpace(host); host != "" {
+			config.Hosts = append(config.Hosts, host)
+		}
+	}
+	if len(config.Hosts) == 0 {
+		return nil
+	}
+
+	if config.MaxOpenConns <= 0 {
+		config.MaxOpenConns = maxOpenConns
+	}
+	if config.MaxLag <= 0 {
+		config.MaxLag = 10 * time.Second
+	}
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = 5 * time.Second
+	}
+
+	return config
+}
+
 func bulkParamsString(paramPerInsert int, values []interface{}) string {
 
 	sqlParams := ""
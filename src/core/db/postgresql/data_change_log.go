@@ -0,0 +1,60 @@
+package postgresql
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go-template/src/core/model"
+)
+
+// InquiryDataChangeLog returns the history of one record, newest first. It
+// reads from a replica when there is a healthy one.
+func (pgdb *PostgresqlDB) InquiryDataChangeLog(ctx context.Context, tableName, recordID string, page, limit int64) ([]*model.DataChangeLog, *model.Pagination, error) {
+	db := pgdb.reader(ctx)
+	query := NewQuery().
+		Where("table_name = ?", tableName).
+		Where("record_id = ?", recordID)
+
+	var total int64
+	err := db.QueryRow(ctx,
+		`SELECT COUNT(*) FROM data_change_log `+query.WhereSQL(),
+		query.Args()...,
+	).Scan(&total)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Can not count data change log from database")
+	}
+
+	query.Page(page, limit)
+
+	result := make([]*model.DataChangeLog, 0)
+	err = db.QueryRow(ctx, `
+		SELECT
+			COALESCE(jsonb_agg(d.* ORDER BY d.created_time DESC, d.id DESC), '[]')
+		FROM
+			(
+				SELECT
+					*
+				FROM
+					data_change_log
+				`+query.WhereSQL()+`
+				ORDER BY created_time DESC, id DESC
+				`+query.LimitSQL()+`
+			) as d
+	`,
+		query.Args()...,
+	).Scan(
+		&result,
+	)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Can not select data change log from database")
+	}
+
+	pagination := &model.Pagination{
+		Total:   total,
+		Limit:   limit,
+		Page:    page,
+		HasMore: page*limit < total,
+	}
+
+	return result, pagination, nil
+}
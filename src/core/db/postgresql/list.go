@@ -0,0 +1,161 @@
+package postgresql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go-template/src/core/listquery"
+	"go-template/src/core/model"
+)
+
+// List adds the filters, sort and cursor condition of list
+func (q *Query) List(list *listquery.List) *Query {
+	for _, filter := range list.Filters {
+		switch filter.Op {
+		case listquery.OpEq:
+			q.Where(quoteColumn(filter.Column)+" = ?", filter.Value)
+		case listquery.OpNe:
+			q.Where(quoteColumn(filter.Column)+" <> ?", filter.Value)
+		case listquery.OpLt:
+			q.Where(quoteColumn(filter.Column)+" < ?", filter.Value)
+		case listquery.OpLte:
+			q.Where(quoteColumn(filter.Column)+" <= ?", filter.Value)
+		case listquery.OpGt:
+			q.Where(quoteColumn(filter.Column)+" > ?", filter.Value)
+		case listquery.OpGte:
+			q.Where(quoteColumn(filter.Column)+" >= ?", filter.Value)
+		case listquery.OpIn:
+			q.Where(quoteColumn(filter.Column)+" = ANY(?)", filter.Value)
+		case listquery.OpNotIn:
+			q.Where(quoteColumn(filter.Column)+" <> ALL(?)", filter.Value)
+		case listquery.OpContains:
+			q.Where(quoteColumn(filter.Column)+" ILIKE ?", "%"+EscapeLike(filter.Value.(string))+"%")
+		case listquery.OpPrefix:
+			q.Where(quoteColumn(filter.Column)+" ILIKE ?", EscapeLike(filter.Value.(string))+"%")
+		case listquery.OpHas:
+			q.Where("? = ANY("+quoteColumn(filter.Column)+")", filter.Value)
+		default:
+			panic(fmt.Sprintf("postgresql: unknown list operator %q", filter.Op))
+		}
+	}
+
+	q.orderBy = make([]string, 0, len(list.Sort))
+	columns := make([]string, 0, len(list.Sort))
+	desc := make([]bool, 0, len(list.Sort))
+	for _, key := range list.Sort {
+		direction := "ASC"
+		if key.Desc {
+			direction = "DESC"
+		}
+		q.orderBy = append(q.orderBy, quoteColumn(key.Column)+" "+direction)
+		columns = append(columns, key.Column)
+		desc = append(desc, key.Desc)
+	}
+
+	switch {
+	case !list.Keyset():
+	case list.MixedDirections():
+		q.AfterMixed(columns, list.After, desc)
+	default:
+		q.After(columns, list.After, list.Sort[0].Desc)
+	}
+
+	return q
+}
+
+// selectList runs the list query on db over from, a table or a parenthesized
+// subquery, after the conditions already in q. It unmarshals the rows into
+// dest, a pointer to a slice, and returns the pagination with the total on
+// page requests and the next cursor when there are more rows.
+func (pgdb *PostgresqlDB) selectList(ctx context.Context, db Querier, from string, q *Query, list *listquery.List, dest any) (*model.Pagination, error) {
+	q.List(list)
+
+	pagination := &model.Pagination{
+		Limit: list.Limit,
+	}
+
+	if !list.Keyset() {
+		pagination.Page = list.Page
+		err := db.QueryRow(ctx,
+			`SELECT COUNT(*) FROM `+from+` AS t `+q.WhereSQL(),
+			q.Args()...,
+		).Scan(&pagination.Total)
+		if err != nil {
+			return nil, errors.Wrap(err, "Can not count list from database")
+		}
+	}
+
+	// one more row tells whether there is a next page
+	q.limit = list.Limit + 1
+	q.offset = list.Offset()
+
+	// the outer aggregate orders by the same columns as the inner query
+	outerOrderBy := make([]string, 0, len(list.Sort))
+	sortValues := make([]string, 0, len(list.Sort))
+	for _, key := range list.Sort {
+		column := quoteColumn("d." + key.Column)
+		if key.Desc {
+			outerOrderBy = append(outerOrderBy, column+" DESC")
+		} else {
+			outerOrderBy = append(outerOrderBy, column+" ASC")
+		}
+		sortValues = append(sortValues, column)
+	}
+
+	var rowsJSON, cursorsJSON []byte
+	err := db.QueryRow(ctx, fmt.Sprintf(`
+		SELECT
+			COALESCE(jsonb_agg(to_jsonb(d.*) ORDER BY %[1]s), '[]'),
+			COALESCE(jsonb_agg(jsonb_build_array(%[2]s) ORDER BY %[1]s), '[]')
+		FROM
+			(
+				SELECT
+					t.*
+				FROM
+					%[3]s AS t
+				%[4]s
+				%[5]s
+				%[6]s
+			) as d
+	`,
+		strings.Join(outerOrderBy, ", "),
+		strings.Join(sortValues, ", "),
+		from,
+		q.WhereSQL(),
+		q.OrderBySQL(),
+		q.LimitSQL(),
+	),
+		q.Args()...,
+	).Scan(&rowsJSON, &cursorsJSON)
+	if err != nil {
+		return nil, errors.Wrap(err, "Can not select list from database")
+	}
+
+	rows := make([]json.RawMessage, 0)
+	cursors := make([][]json.RawMessage, 0)
+	if err := json.Unmarshal(rowsJSON, &rows); err != nil {
+		return nil, errors.Wrap(err, "Can not read list from database")
+	}
+	if err := json.Unmarshal(cursorsJSON, &cursors); err != nil {
+		return nil, errors.Wrap(err, "Can not read list from database")
+	}
+
+	if int64(len(rows)) > list.Limit {
+		rows = rows[:list.Limit]
+		pagination.HasMore = true
+		pagination.NextCursor = list.NextCursor(cursors[list.Limit-1])
+	}
+
+	page, err := json.Marshal(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(page, dest); err != nil {
+		return nil, errors.Wrap(err, "Can not read list from database")
+	}
+
+	return pagination, nil
+}
@@ -18,7 +18,7 @@ func NewDatabaseLogger(logger *log.Logger) *PostgresLogger {
 func (pglog *PostgresLogger) Log(ctx context.Context, level tracelog.LogLevel, msg string, data map[string]interface{}) {
 
 	// idea from https://github.com/jackc/pgx-logrus/blob/master/adapter.go
-	var logger = pglog.Logger
+	var logger = pglog.Logger.WithContext(ctx)
 	if data != nil {
 		logger = logger.WithFields(data)
 	}
@@ -1,32 +1,42 @@
 package migrations
 
 import (
-	"github.com/jinzhu/gorm"
+	"context"
+
+	"github.com/jackc/pgx/v5"
 	"github.com/pkg/errors"
 )
 
+const createApiKeysTableSQL = `
+	CREATE TABLE api_keys(
+	    key TEXT NOT NULL PRIMARY KEY,
+	    azure_user_id TEXT NOT NULL,
+		user_id BIGINT,
+		email_address TEXT,
+		user_role_name TEXT,
+		expire_time timestamptz NOT NULL,
+		created_time TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		user_profile_pic TEXT
+	);
+	
+	create index if not exists ak_key_idx on api_keys (key);
+	create index if not exists ak_user_id_idx on api_keys (user_id);
+	create index if not exists ak_expire_time_idx on api_keys (expire_time);
+`
+
 var createApiKeysTableMigration = &Migration{
 	Number: 1,
 	Name:   "Create api_keys table",
-	Forwards: func(db *gorm.DB) error {
-		const sql = `
-			CREATE TABLE api_keys(
-			    key TEXT NOT NULL PRIMARY KEY,
-			    azure_user_id TEXT NOT NULL,
-				user_id BIGINT,
-				email_address TEXT,
-				user_role_name TEXT,
-				expire_time timestamptz NOT NULL,
-				created_time TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-				user_profile_pic TEXT
-			);
-			
-			create index if not exists ak_key_idx on api_keys (key);
-			create index if not exists ak_user_id_idx on api_keys (user_id);
-			create index if not exists ak_expire_time_idx on api_keys (expire_time);
-		`
+	Source: createApiKeysTableSQL,
+	Forwards: func(ctx context.Context, tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, createApiKeysTableSQL)
+		return errors.Wrap(err, "unable to create api_keys table")
+	},
+	Backwards: func(ctx context.Context, tx pgx.Tx) error {
+		const sql = `DROP TABLE IF EXISTS api_keys;`
 
-		return errors.Wrap(db.Exec(sql).Error, "unable to create api_keys table")
+		_, err := tx.Exec(ctx, sql)
+		return errors.Wrap(err, "unable to drop api_keys table")
 	},
 }
 
@@ -0,0 +1,106 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+)
+
+// reworkActivityLogTableSQL turns the table of migration 2 into one row per
+// request with the caller's identity. Existing rows keep their request_no,
+// endpoint, bodies and time; the columns nothing writes any more are dropped.
+const reworkActivityLogTableSQL = `
+	ALTER TABLE activity_log DROP CONSTRAINT activity_log_pkey;
+	DROP INDEX IF EXISTS service_code_idx;
+
+	ALTER TABLE activity_log ADD COLUMN id bigserial PRIMARY KEY;
+	ALTER TABLE activity_log ALTER COLUMN request_no TYPE text USING request_no::text;
+	ALTER TABLE activity_log ALTER COLUMN request_no DROP NOT NULL;
+	ALTER TABLE activity_log ALTER COLUMN response_code DROP NOT NULL;
+	ALTER TABLE activity_log RENAME COLUMN service_endpoint TO request_uri;
+	ALTER TABLE activity_log RENAME COLUMN create_date TO created_time;
+
+	ALTER TABLE activity_log
+		ADD COLUMN user_id bigint,
+		ADD COLUMN azure_user_id text,
+		ADD COLUMN email_address text,
+		ADD COLUMN user_roles text[] NOT NULL DEFAULT '{}',
+		ADD COLUMN duration_ms bigint NOT NULL DEFAULT 0,
+		ADD COLUMN ip_address text,
+		ADD COLUMN user_agent text,
+		DROP COLUMN service_name,
+		DROP COLUMN request_header,
+		DROP COLUMN response_message,
+		DROP COLUMN active_status,
+		DROP COLUMN create_by,
+		DROP COLUMN update_date,
+		DROP COLUMN update_by;
+
+	CREATE INDEX activity_log_request_no_idx ON activity_log (request_no);
+	CREATE INDEX activity_log_service_code_idx ON activity_log (service_code, created_time);
+	CREATE INDEX activity_log_user_id_idx ON activity_log (user_id, created_time);
+	CREATE INDEX activity_log_email_address_idx ON activity_log (email_address, created_time);
+	CREATE INDEX activity_log_http_status_code_idx ON activity_log (http_status_code, created_time);
+	CREATE INDEX activity_log_created_time_idx ON activity_log (created_time);
+`
+
+var reworkActivityLogTableMigration = &Migration{
+	Number: 3,
+	Name:   "Rework activity_log for request logging",
+	Source: reworkActivityLogTableSQL,
+	Forwards: func(ctx context.Context, tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, reworkActivityLogTableSQL)
+		return errors.Wrap(err, "unable to rework activity_log table")
+	},
+	Backwards: func(ctx context.Context, tx pgx.Tx) error {
+		// the dropped columns come back empty; rows without a request_no, or
+		// sharing one, can not be kept under the old primary key
+		const sql = `
+			DROP INDEX IF EXISTS activity_log_request_no_idx;
+			DROP INDEX IF EXISTS activity_log_service_code_idx;
+			DROP INDEX IF EXISTS activity_log_user_id_idx;
+			DROP INDEX IF EXISTS activity_log_email_address_idx;
+			DROP INDEX IF EXISTS activity_log_http_status_code_idx;
+			DROP INDEX IF EXISTS activity_log_created_time_idx;
+
+			DELETE FROM activity_log a
+			WHERE request_no IS NULL
+				OR request_no !~* '^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$'
+				OR EXISTS (SELECT 1 FROM activity_log b WHERE b.request_no = a.request_no AND b.id < a.id);
+
+			ALTER TABLE activity_log
+				DROP COLUMN id,
+				DROP COLUMN user_id,
+				DROP COLUMN azure_user_id,
+				DROP COLUMN email_address,
+				DROP COLUMN user_roles,
+				DROP COLUMN duration_ms,
+				DROP COLUMN ip_address,
+				DROP COLUMN user_agent,
+				ADD COLUMN service_name text NOT NULL DEFAULT '',
+				ADD COLUMN request_header text,
+				ADD COLUMN response_message text,
+				ADD COLUMN active_status varchar(1) NOT NULL DEFAULT 'Y',
+				ADD COLUMN create_by varchar(255) NOT NULL DEFAULT '',
+				ADD COLUMN update_date timestamptz NOT NULL DEFAULT now(),
+				ADD COLUMN update_by varchar(255) NOT NULL DEFAULT '';
+
+			ALTER TABLE activity_log RENAME COLUMN created_time TO create_date;
+			ALTER TABLE activity_log RENAME COLUMN request_uri TO service_endpoint;
+			UPDATE activity_log SET response_code = 0 WHERE response_code IS NULL;
+			ALTER TABLE activity_log ALTER COLUMN response_code SET NOT NULL;
+			ALTER TABLE activity_log ALTER COLUMN request_no TYPE uuid USING request_no::uuid;
+			ALTER TABLE activity_log ADD PRIMARY KEY (request_no);
+
+			CREATE INDEX service_code_idx ON activity_log (service_code);
+		`
+
+		_, err := tx.Exec(ctx, sql)
+		return errors.Wrap(err, "unable to restore the original activity_log table")
+	},
+}
+
+func init() {
+	Migrations = append(Migrations, reworkActivityLogTableMigration)
+}
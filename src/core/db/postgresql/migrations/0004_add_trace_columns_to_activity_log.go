@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+)
+
+const addTraceColumnsToActivityLogSQL = `
+	ALTER TABLE activity_log
+		ADD COLUMN IF NOT EXISTS trace_id text,
+		ADD COLUMN IF NOT EXISTS span_id text;
+
+	CREATE INDEX IF NOT EXISTS activity_log_trace_id_idx ON activity_log (trace_id);
+`
+
+var addTraceColumnsToActivityLogMigration = &Migration{
+	Number: 4,
+	Name:   "Add trace_id and span_id to activity_log",
+	Source: addTraceColumnsToActivityLogSQL,
+	Forwards: func(ctx context.Context, tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, addTraceColumnsToActivityLogSQL)
+		return errors.Wrap(err, "unable to add trace columns to activity_log table")
+	},
+	Backwards: func(ctx context.Context, tx pgx.Tx) error {
+		const sql = `
+			DROP INDEX IF EXISTS activity_log_trace_id_idx;
+
+			ALTER TABLE activity_log
+				DROP COLUMN IF EXISTS trace_id,
+				DROP COLUMN IF EXISTS span_id;
+		`
+
+		_, err := tx.Exec(ctx, sql)
+		return errors.Wrap(err, "unable to drop trace columns from activity_log table")
+	},
+}
+
+func init() {
+	Migrations = append(Migrations, addTraceColumnsToActivityLogMigration)
+}
@@ -0,0 +1,160 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+)
+
+const partitionActivityLogByMonthSQL = `
+	-- creates the partition holding the month of p_month, named
+	-- activity_log_pYYYYMM, and returns its name. Months are in UTC whatever
+	-- the session TimeZone, the bounds are written with an explicit offset.
+	CREATE OR REPLACE FUNCTION activity_log_create_partition(p_month date) RETURNS text AS $$
+	DECLARE
+		v_month timestamp := date_trunc('month', p_month::timestamp);
+		v_from text := to_char(v_month, 'YYYY-MM-DD') || ' 00:00:00+00';
+		v_to text := to_char(v_month + interval '1 month', 'YYYY-MM-DD') || ' 00:00:00+00';
+		v_name text := 'activity_log_p' || to_char(v_month, 'YYYYMM');
+	BEGIN
+		EXECUTE format(
+			'CREATE TABLE IF NOT EXISTS %I PARTITION OF activity_log FOR VALUES FROM (%L) TO (%L)',
+			v_name, v_from, v_to
+		);
+		RETURN v_name;
+	END;
+	$$ LANGUAGE plpgsql;
+
+	ALTER TABLE activity_log RENAME TO activity_log_legacy;
+	ALTER TABLE activity_log_legacy RENAME CONSTRAINT activity_log_pkey TO activity_log_legacy_pkey;
+	ALTER SEQUENCE activity_log_id_seq RENAME TO activity_log_legacy_id_seq;
+
+	CREATE TABLE activity_log (
+		id bigserial NOT NULL,
+		request_no text,
+		trace_id text,
+		span_id text,
+		service_code text NOT NULL,
+		user_id bigint,
+		azure_user_id text,
+		email_address text,
+		user_roles text[] NOT NULL DEFAULT '{}',
+		http_method text NOT NULL,
+		request_uri text NOT NULL,
+		http_status_code int NOT NULL,
+		response_code int,
+		duration_ms bigint NOT NULL DEFAULT 0,
+		ip_address text,
+		user_agent text,
+		request_body text,
+		response_body text,
+		created_time timestamptz NOT NULL DEFAULT now(),
+		PRIMARY KEY (id, created_time)
+	) PARTITION BY RANGE (created_time);
+
+	-- partitions for the existing rows and the next three months, in UTC
+	SELECT activity_log_create_partition(month::date)
+	FROM generate_series(
+		date_trunc('month', LEAST(COALESCE((SELECT MIN(created_time) FROM activity_log_legacy), now()), now()) AT TIME ZONE 'UTC'),
+		date_trunc('month', now() AT TIME ZONE 'UTC') + interval '3 months',
+		interval '1 month'
+	) AS month;
+
+	INSERT INTO activity_log (
+		id, request_no, trace_id, span_id, service_code, user_id, azure_user_id, email_address,
+		user_roles, http_method, request_uri, http_status_code, response_code, duration_ms,
+		ip_address, user_agent, request_body, response_body, created_time
+	)
+	SELECT
+		id, request_no, trace_id, span_id, service_code, user_id, azure_user_id, email_address,
+		user_roles, http_method, request_uri, http_status_code, response_code, duration_ms,
+		ip_address, user_agent, request_body, response_body, created_time
+	FROM activity_log_legacy;
+
+	SELECT setval(pg_get_serial_sequence('activity_log', 'id'), COALESCE(MAX(id), 0) + 1, false)
+	FROM activity_log;
+
+	DROP TABLE activity_log_legacy;
+
+	CREATE INDEX activity_log_request_no_idx ON activity_log (request_no);
+	CREATE INDEX activity_log_trace_id_idx ON activity_log (trace_id);
+	CREATE INDEX activity_log_service_code_idx ON activity_log (service_code, created_time);
+	CREATE INDEX activity_log_user_id_idx ON activity_log (user_id, created_time);
+	CREATE INDEX activity_log_email_address_idx ON activity_log (email_address, created_time);
+	CREATE INDEX activity_log_http_status_code_idx ON activity_log (http_status_code, created_time);
+	CREATE INDEX activity_log_created_time_idx ON activity_log (created_time);
+`
+
+var partitionActivityLogByMonthMigration = &Migration{
+	Number: 5,
+	Name:   "Partition activity_log by month of created_time",
+	Source: partitionActivityLogByMonthSQL,
+	Forwards: func(ctx context.Context, tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, partitionActivityLogByMonthSQL)
+		return errors.Wrap(err, "unable to partition activity_log table")
+	},
+	Backwards: func(ctx context.Context, tx pgx.Tx) error {
+		// partitions already archived and dropped are not restored
+		const sql = `
+			ALTER TABLE activity_log RENAME TO activity_log_partitioned;
+			ALTER TABLE activity_log_partitioned RENAME CONSTRAINT activity_log_pkey TO activity_log_partitioned_pkey;
+			ALTER SEQUENCE activity_log_id_seq RENAME TO activity_log_partitioned_id_seq;
+
+			CREATE TABLE activity_log (
+				id bigserial PRIMARY KEY,
+				request_no text,
+				service_code text NOT NULL,
+				user_id bigint,
+				azure_user_id text,
+				email_address text,
+				user_roles text[] NOT NULL DEFAULT '{}',
+				http_method text NOT NULL,
+				request_uri text NOT NULL,
+				http_status_code int NOT NULL,
+				response_code int,
+				duration_ms bigint NOT NULL DEFAULT 0,
+				ip_address text,
+				user_agent text,
+				request_body text,
+				response_body text,
+				created_time timestamptz NOT NULL DEFAULT now(),
+				trace_id text,
+				span_id text
+			);
+
+			INSERT INTO activity_log (
+				id, request_no, trace_id, span_id, service_code, user_id, azure_user_id, email_address,
+				user_roles, http_method, request_uri, http_status_code, response_code, duration_ms,
+				ip_address, user_agent, request_body, response_body, created_time
+			)
+			SELECT
+				id, request_no, trace_id, span_id, service_code, user_id, azure_user_id, email_address,
+				user_roles, http_method, request_uri, http_status_code, response_code, duration_ms,
+				ip_address, user_agent, request_body, response_body, created_time
+			FROM activity_log_partitioned;
+
+			SELECT setval(pg_get_serial_sequence('activity_log', 'id'), COALESCE(MAX(id), 0) + 1, false)
+			FROM activity_log;
+
+			-- drops the partitions too
+			DROP TABLE activity_log_partitioned;
+			DROP FUNCTION IF EXISTS activity_log_create_partition(date);
+
+			CREATE INDEX activity_log_request_no_idx ON activity_log (request_no);
+			CREATE INDEX activity_log_trace_id_idx ON activity_log (trace_id);
+			CREATE INDEX activity_log_service_code_idx ON activity_log (service_code, created_time);
+			CREATE INDEX activity_log_user_id_idx ON activity_log (user_id, created_time);
+			CREATE INDEX activity_log_email_address_idx ON activity_log (email_address, created_time);
+			CREATE INDEX activity_log_http_status_code_idx ON activity_log (http_status_code, created_time);
+			CREATE INDEX activity_log_created_time_idx ON activity_log (created_time);
+		`
+
+		_, err := tx.Exec(ctx, sql)
+		return errors.Wrap(err, "unable to unpartition activity_log table")
+	},
+}
+
+func init() {
+	Migrations = append(Migrations, partitionActivityLogByMonthMigration)
+}
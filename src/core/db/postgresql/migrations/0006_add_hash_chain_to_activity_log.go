@@ -0,0 +1,68 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+)
+
+const addHashChainToActivityLogSQL = `
+	ALTER TABLE activity_log
+		ADD COLUMN IF NOT EXISTS chain_date date,
+		ADD COLUMN IF NOT EXISTS chain_seq bigint,
+		ADD COLUMN IF NOT EXISTS prev_hash text,
+		ADD COLUMN IF NOT EXISTS row_hash text;
+
+	CREATE INDEX IF NOT EXISTS activity_log_chain_idx ON activity_log (chain_date, chain_seq);
+
+	-- last link of each daily chain, updated in the same transaction as the insert
+	CREATE TABLE IF NOT EXISTS activity_log_chain (
+		chain_date date PRIMARY KEY,
+		last_seq bigint NOT NULL,
+		last_hash text NOT NULL,
+		updated_time timestamptz NOT NULL DEFAULT now()
+	);
+
+	CREATE TABLE IF NOT EXISTS activity_log_checkpoint (
+		id bigserial PRIMARY KEY,
+		chain_date date NOT NULL,
+		chain_seq bigint NOT NULL,
+		row_hash text NOT NULL,
+		key_id text NOT NULL,
+		signature text NOT NULL,
+		created_time timestamptz NOT NULL DEFAULT now(),
+		UNIQUE (chain_date, chain_seq)
+	);
+`
+
+var addHashChainToActivityLogMigration = &Migration{
+	Number: 6,
+	Name:   "Add hash chain and signed checkpoints to activity_log",
+	Source: addHashChainToActivityLogSQL,
+	Forwards: func(ctx context.Context, tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, addHashChainToActivityLogSQL)
+		return errors.Wrap(err, "unable to add hash chain to activity_log table")
+	},
+	Backwards: func(ctx context.Context, tx pgx.Tx) error {
+		const sql = `
+			DROP TABLE IF EXISTS activity_log_checkpoint;
+			DROP TABLE IF EXISTS activity_log_chain;
+
+			DROP INDEX IF EXISTS activity_log_chain_idx;
+
+			ALTER TABLE activity_log
+				DROP COLUMN IF EXISTS chain_date,
+				DROP COLUMN IF EXISTS chain_seq,
+				DROP COLUMN IF EXISTS prev_hash,
+				DROP COLUMN IF EXISTS row_hash;
+		`
+
+		_, err := tx.Exec(ctx, sql)
+		return errors.Wrap(err, "unable to drop hash chain from activity_log table")
+	},
+}
+
+func init() {
+	Migrations = append(Migrations, addHashChainToActivityLogMigration)
+}
@@ -0,0 +1,151 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+)
+
+const createDataChangeLogSQL = `
+	CREATE TABLE IF NOT EXISTS data_change_log (
+		id bigserial PRIMARY KEY,
+		table_name text NOT NULL,
+		record_id text NOT NULL,
+		operation text NOT NULL,
+		changes jsonb NOT NULL,
+		acting_user_id text,
+		request_no text,
+		db_user text NOT NULL DEFAULT current_user,
+		created_time timestamptz NOT NULL DEFAULT clock_timestamp()
+	);
+
+	CREATE INDEX IF NOT EXISTS data_change_log_record_idx ON data_change_log (table_name, record_id, created_time);
+	CREATE INDEX IF NOT EXISTS data_change_log_acting_user_id_idx ON data_change_log (acting_user_id, created_time);
+
+	-- TG_ARGV[0]: primary key columns, TG_ARGV[1]: columns left out of
+	-- the diff, both comma separated. An excluded primary key column is
+	-- recorded in record_id as its SHA-256. The acting user and request
+	-- number come from the transaction-local settings app.user_id and
+	-- app.request_no.
+	CREATE OR REPLACE FUNCTION data_change_log_trigger() RETURNS trigger AS $$
+	DECLARE
+		v_pk_columns text[] := string_to_array(TG_ARGV[0], ',');
+		v_excluded text[] := COALESCE(string_to_array(NULLIF(TG_ARGV[1], ''), ','), '{}');
+		v_old jsonb;
+		v_new jsonb;
+		v_changes jsonb := '{}';
+		v_record_id text;
+		v_key text;
+	BEGIN
+		IF TG_OP IN ('UPDATE', 'DELETE') THEN
+			v_old := to_jsonb(OLD);
+		END IF;
+		IF TG_OP IN ('INSERT', 'UPDATE') THEN
+			v_new := to_jsonb(NEW);
+		END IF;
+
+		SELECT string_agg(
+			CASE
+				WHEN c = ANY(v_excluded) THEN encode(sha256(convert_to(COALESCE(v_new, v_old) ->> c, 'UTF8')), 'hex')
+				ELSE COALESCE(v_new, v_old) ->> c
+			END,
+			',' ORDER BY ord
+		)
+		INTO v_record_id
+		FROM unnest(v_pk_columns) WITH ORDINALITY AS pk(c, ord);
+
+		v_old := v_old - v_excluded;
+		v_new := v_new - v_excluded;
+
+		FOR v_key IN SELECT jsonb_object_keys(COALESCE(v_new, v_old)) LOOP
+			IF v_old IS NULL OR v_new IS NULL OR (v_old -> v_key) IS DISTINCT FROM (v_new -> v_key) THEN
+				v_changes := v_changes || jsonb_build_object(
+					v_key, jsonb_build_object('old', v_old -> v_key, 'new', v_new -> v_key)
+				);
+			END IF;
+		END LOOP;
+
+		IF TG_OP = 'UPDATE' AND v_changes = '{}' THEN
+			RETURN NULL;
+		END IF;
+
+		INSERT INTO data_change_log (table_name, record_id, operation, changes, acting_user_id, request_no)
+		VALUES (
+			TG_TABLE_NAME,
+			v_record_id,
+			TG_OP,
+			v_changes,
+			NULLIF(current_setting('app.user_id', true), ''),
+			NULLIF(current_setting('app.request_no', true), '')
+		);
+
+		RETURN NULL;
+	END;
+	$$ LANGUAGE plpgsql;
+
+	-- attaches the trigger to p_table; call it from the migration creating
+	-- a business table, listing secrets in p_excluded. Its writes must run in
+	-- a transaction begun by WithTx to record the acting user.
+	CREATE OR REPLACE FUNCTION data_change_log_enable(p_table regclass, p_excluded text[] DEFAULT '{}') RETURNS void AS $$
+	DECLARE
+		v_pk_columns text;
+	BEGIN
+		SELECT string_agg(a.attname, ',' ORDER BY array_position(i.indkey::int2[], a.attnum))
+		INTO v_pk_columns
+		FROM pg_index i
+		JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+		WHERE i.indrelid = p_table AND i.indisprimary;
+
+		IF v_pk_columns IS NULL THEN
+			RAISE EXCEPTION 'table % has no primary key', p_table;
+		END IF;
+
+		EXECUTE format('DROP TRIGGER IF EXISTS data_change_log ON %s', p_table);
+		EXECUTE format(
+			'CREATE TRIGGER data_change_log AFTER INSERT OR UPDATE OR DELETE ON %s '
+			'FOR EACH ROW EXECUTE FUNCTION data_change_log_trigger(%L, %L)',
+			p_table, v_pk_columns, array_to_string(p_excluded, ',')
+		);
+	END;
+	$$ LANGUAGE plpgsql;
+
+	-- the key is a secret; expire_time is extended on every request
+	SELECT data_change_log_enable('api_keys', ARRAY['key', 'expire_time']);
+`
+
+var createDataChangeLogMigration = &Migration{
+	Number: 7,
+	Name:   "Create data_change_log table and trigger",
+	Source: createDataChangeLogSQL,
+	Forwards: func(ctx context.Context, tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, createDataChangeLogSQL)
+		return errors.Wrap(err, "unable to create data_change_log table")
+	},
+	Backwards: func(ctx context.Context, tx pgx.Tx) error {
+		const sql = `
+			DO $$
+			DECLARE
+				v_table regclass;
+			BEGIN
+				FOR v_table IN
+					SELECT tgrelid::regclass FROM pg_trigger WHERE tgfoid = 'data_change_log_trigger'::regproc
+				LOOP
+					EXECUTE format('DROP TRIGGER data_change_log ON %s', v_table);
+				END LOOP;
+			END;
+			$$;
+
+			DROP FUNCTION IF EXISTS data_change_log_enable(regclass, text[]);
+			DROP FUNCTION IF EXISTS data_change_log_trigger();
+			DROP TABLE IF EXISTS data_change_log;
+		`
+
+		_, err := tx.Exec(ctx, sql)
+		return errors.Wrap(err, "unable to drop data_change_log table")
+	},
+}
+
+func init() {
+	Migrations = append(Migrations, createDataChangeLogMigration)
+}
@@ -1,64 +1,297 @@
 package migrations
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"sort"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/jinzhu/gorm"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/pkg/errors"
-	"github.com/spf13/viper"
 	"go-template/src/core/log"
 )
 
+// migrationLockKey is the first key of the advisory lock held while
+// migrating; the second is the hash of the schema name
+const migrationLockKey = 726_100_043
+
 type Migration struct {
-	Number uint `gorm:"primary_key"`
+	Number uint
 	Name   string
 
-	Forwards func(db *gorm.DB) error `gorm:"-"`
+	// Checksum, AppliedAt and DurationMs are kept in the migrations table
+	Checksum   string
+	AppliedAt  *time.Time
+	DurationMs int64
+
+	// Source is what Checksum covers, normally the forwards SQL. Migrations
+	// without it are not checked for changes.
+	Source   string
+	Forwards func(ctx context.Context, tx pgx.Tx) error
+	// Backwards undoes Forwards. Migrations without it can not be rolled
+	// back.
+	Backwards func(ctx context.Context, tx pgx.Tx) error
+
+	// statements run one by one outside a transaction instead of Forwards or
+	// Backwards, from SQL files marked no-transaction
+	forwardsNoTx  []string
+	backwardsNoTx []string
+}
+
+func (migration *Migration) reversible() bool {
+	return migration.Backwards != nil || migration.backwardsNoTx != nil
 }
 
+// Migrations holds the Go migrations, registered from init, and, once
+// loaded, the embedded SQL ones
 var Migrations []*Migration
 
-func Migrate(dryRun bool, number int, forceMigrate bool) error {
-	dbHost := viper.GetString("Database.PostgreSQL.Host")
-	dbPort := viper.GetString("Database.PostgreSQL.Port")
-	dbUser := viper.GetString("Database.PostgreSQL.Username")
-	sslMode := viper.GetString("Database.PostgreSQL.SSLMode")
+var loadSQLOnce sync.Once
+var loadSQLErr error
+
+// Options selects the migrations to run. To is the migration number to end
+// at, -1 for the latest; a number below the latest applied one rolls back.
+// Steps, when not 0, applies that many migrations instead, or rolls back
+// that many when negative. Reset drops the schema when it is a dedicated
+// one, otherwise rolls back every applied migration, before migrating to
+// To. IgnoreDrift only warns about applied migrations whose Source changed
+// instead of failing.
+type Options struct {
+	DryRun      bool
+	To          int
+	Steps       int
+	Reset       bool
+	IgnoreDrift bool
+}
 
-	if dbUser == "" {
-		dbUser = "postgres"
+// MigrationStatus is one migration as reported by Status. State is
+// "applied", "pending", "changed" (applied, but its Source changed since) or
+// "unknown" (applied, but not in this build).
+type MigrationStatus struct {
+	Number     uint       `json:"number"`
+	Name       string     `json:"name"`
+	State      string     `json:"state"`
+	Reversible bool       `json:"reversible"`
+	AppliedAt  *time.Time `json:"applied_at,omitempty"`
+	DurationMs int64      `json:"duration_ms,omitempty"`
+	Checksum   string     `json:"checksum,omitempty"`
+}
+
+const (
+	StateApplied = "applied"
+	StatePending = "pending"
+	StateChanged = "changed"
+	StateUnknown = "unknown"
+)
+
+// Migrate migrates the database of pool, whose search_path is schema (empty
+// for the server default). Every statement runs on one connection holding
+// the migration lock.
+func Migrate(ctx context.Context, pool *pgxpool.Pool, schema string, logger log.Logger, options Options) error {
+	logger = logger.WithFields(log.Fields{
+		"module": "db/postgresql/migrations",
+	})
+
+	if options.DryRun {
+		logger.Infof("=== DRY RUN ===")
 	}
-	dbPassword := viper.GetString("Database.PostgreSQL.Password")
-	if dbPassword == "" {
-		dbPassword = "postgres"
+
+	if options.Steps != 0 && options.To != -1 {
+		return errors.New("--to and --steps can not be used together")
 	}
-	dbName := viper.GetString("Database.PostgreSQL.DBName")
 
-	if sslMode == "" {
-		sslMode = "disable"
+	if err := loadMigrations(); err != nil {
+		logger.Errorf("Unable to apply migrations, err: %+v", err)
+		return err
 	}
 
-	configLogger, err := log.InitConfig()
+	poolConn, err := pool.Acquire(ctx)
 	if err != nil {
+		return errors.Wrap(err, "unable to connect to postgres")
+	}
+	defer poolConn.Release()
+	conn := poolConn.Conn()
+
+	unlock, err := lock(ctx, conn, logger, schema)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := ensureTable(ctx, conn, schema); err != nil {
 		return err
 	}
 
-	logger, err := log.NewLoggerWithModuleName(configLogger, "db/postgresql/migrations")
+	if options.Reset {
+		logger.Infof("=== RESET ===")
+		if err := reset(ctx, conn, logger, schema, options.DryRun); err != nil {
+			logger.Errorf("unable to reset the database. err: %+v", err)
+			return err
+		}
+	}
+
+	applied, err := appliedMigrations(ctx, conn)
 	if err != nil {
 		return err
 	}
+	// a dry run reset has not rolled anything back
+	if options.Reset && options.DryRun {
+		applied = nil
+	}
 
-	if dryRun {
-		logger.Infof("=== DRY RUN ===")
+	if err := checkDrift(ctx, conn, logger, applied, options); err != nil {
+		return err
+	}
+
+	var latest uint
+	if len(applied) > 0 {
+		latest = applied[len(applied)-1].Number
+	}
+
+	target, err := targetNumber(options, applied, latest)
+	if err != nil {
+		return err
+	}
+
+	if target < latest {
+		return rollback(ctx, conn, logger, applied, target, options.DryRun)
+	}
+
+	// applying past the latest would leave them behind for good
+	if skipped := skippedMigrations(applied, latest); len(skipped) > 0 {
+		migration := skipped[0]
+		err := fmt.Errorf("migration %d %q is pending but numbered below the latest applied migration %d, renumber it above %d or roll back to %d first",
+			migration.Number, migration.Name, latest, latest, migration.Number-1)
+		logger.Errorf("Unable to apply migrations, err: %+v", err)
+		return err
+	}
+
+	if len(Migrations) == 0 || latest >= Migrations[len(Migrations)-1].Number || target == latest {
+		logger.Infof("no migrations to apply")
+		return nil
+	}
+
+	for _, migration := range Migrations {
+		if migration.Number > target {
+			break
+		}
+
+		if migration.Number <= latest {
+			continue
+		}
+
+		if latest > 0 {
+			logger.Infof("continuing migration starting from %d", migration.Number)
+		}
+
+		logger := logger.WithFields(log.Fields{
+			"migration_number": migration.Number,
+		})
+		logger.Infof("applying migration %q", migration.Name)
+
+		if options.DryRun {
+			continue
+		}
+
+		if err := apply(ctx, conn, migration); err != nil {
+			logger.Errorf("unable to apply migration. err: %+v", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Status lists every migration of this build and every applied one, by
+// number
+func Status(ctx context.Context, pool *pgxpool.Pool, schema string) ([]*MigrationStatus, error) {
+	if err := loadMigrations(); err != nil {
+		return nil, err
+	}
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to connect to postgres")
+	}
+	defer conn.Release()
+
+	if err := ensureTable(ctx, conn.Conn(), schema); err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedMigrations(ctx, conn.Conn())
+	if err != nil {
+		return nil, err
+	}
+
+	records := make(map[uint]*Migration)
+	for _, record := range applied {
+		records[record.Number] = record
+	}
+
+	result := make([]*MigrationStatus, 0, len(Migrations))
+	for _, migration := range Migrations {
+		status := &MigrationStatus{
+			Number:     migration.Number,
+			Name:       migration.Name,
+			State:      StatePending,
+			Reversible: migration.reversible(),
+		}
+
+		if record, ok := records[migration.Number]; ok {
+			status.State = StateApplied
+			status.AppliedAt = record.AppliedAt
+			status.DurationMs = record.DurationMs
+			status.Checksum = record.Checksum
+			if changed(migration, record) {
+				status.State = StateChanged
+			}
+			delete(records, migration.Number)
+		}
+
+		result = append(result, status)
+	}
+
+	for _, record := range records {
+		result = append(result, &MigrationStatus{
+			Number:     record.Number,
+			Name:       record.Name,
+			State:      StateUnknown,
+			AppliedAt:  record.AppliedAt,
+			DurationMs: record.DurationMs,
+			Checksum:   record.Checksum,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Number < result[j].Number
+	})
+
+	return result, nil
+}
+
+// loadMigrations adds the embedded SQL migrations to Migrations, orders them
+// by Number and rejects duplicates
+func loadMigrations() error {
+	loadSQLOnce.Do(func() {
+		var sqlMigrations []*Migration
+		sqlMigrations, loadSQLErr = loadSQLMigrations(sqlFiles, "sql")
+		Migrations = append(Migrations, sqlMigrations...)
+	})
+	if loadSQLErr != nil {
+		return loadSQLErr
 	}
 
-	// check for duplicate migration Number
 	migrationIDs := make(map[uint]struct{})
 	for _, migration := range Migrations {
 		if _, ok := migrationIDs[migration.Number]; ok {
-			err := fmt.Errorf("Duplicate migration Number found: %d", migration.Number)
-			logger.Errorf("Unable to apply migrations, err: %+v", err)
-			return err
+			return fmt.Errorf("Duplicate migration Number found: %d", migration.Number)
 		}
 
 		migrationIDs[migration.Number] = struct{}{}
@@ -68,104 +301,304 @@ func Migrate(dryRun bool, number int, forceMigrate bool) error {
 		return Migrations[i].Number < Migrations[j].Number
 	})
 
-	connStr := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		dbHost,
-		dbPort,
-		dbUser,
-		dbPassword,
-		dbName,
-		sslMode,
-	)
-	db, err := gorm.Open("postgres", connStr)
-	if err != nil {
-		logger.Fatalf("%+v", err)
-	}
-	defer db.Close()
+	return nil
+}
 
-	// Force Migrate Zone
-	if forceMigrate {
-		logger.Infof("=== FORCE MIGRATE ===")
-		if err := db.DropTableIfExists(&Migration{}).Error; err != nil {
-			return errors.Wrap(err, "unable to drop migrations table")
+// ensureTable creates the configured schema and the migrations table when
+// missing. Tables created by the former gorm runner get the newer columns.
+func ensureTable(ctx context.Context, conn *pgx.Conn, schema string) error {
+	if schema != "" {
+		if _, err := conn.Exec(ctx, `CREATE SCHEMA IF NOT EXISTS `+pgx.Identifier{schema}.Sanitize()); err != nil {
+			return errors.Wrapf(err, "unable to create schema %q", schema)
 		}
 	}
 
-	// Make sure Migration table is there
-	logger.Debugf("ensuring migrations table is present")
-	if err := db.AutoMigrate(&Migration{}).Error; err != nil {
-		return errors.Wrap(err, "unable to automatically migrate migrations table")
+	const sql = `
+		CREATE TABLE IF NOT EXISTS migrations (
+			number bigint PRIMARY KEY,
+			name text NOT NULL DEFAULT ''
+		);
+
+		ALTER TABLE migrations
+			ADD COLUMN IF NOT EXISTS checksum text,
+			ADD COLUMN IF NOT EXISTS applied_at timestamptz,
+			ADD COLUMN IF NOT EXISTS duration_ms bigint;
+	`
+
+	_, err := conn.Exec(ctx, sql)
+	return errors.Wrap(err, "unable to create migrations table")
+}
+
+// lock takes the advisory lock of the schema so replicas starting together
+// migrate one at a time; the others wait and then find nothing to apply.
+// The lock belongs to conn until unlock.
+func lock(ctx context.Context, conn *pgx.Conn, logger log.Logger, schema string) (unlock func(), err error) {
+	if schema == "" {
+		schema = "public"
 	}
 
-	var latest Migration
-	if err := db.Order("number desc").First(&latest).Error; err != nil && !gorm.IsRecordNotFoundError(err) {
-		return errors.Wrap(err, "unable to find latest migration")
+	logger.Debugf("waiting for the migration lock")
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1, hashtext($2))`, migrationLockKey, schema); err != nil {
+		return nil, errors.Wrap(err, "unable to take the migration lock")
 	}
 
-	noMigrationsApplied := latest.Number == 0
+	return func() {
+		// the lock must be released even when ctx is done
+		if _, err := conn.Exec(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1, hashtext($2))`, migrationLockKey, schema); err != nil {
+			logger.Warnf("unable to release the migration lock. err: %+v", err)
+		}
+	}, nil
+}
+
+// apply runs Forwards and records the migration in one transaction
+func apply(ctx context.Context, conn *pgx.Conn, migration *Migration) error {
+	start := time.Now()
 
-	if noMigrationsApplied && len(Migrations) == 0 {
-		logger.Infof("no migrations to apply")
-		return nil
+	if migration.forwardsNoTx != nil {
+		if err := execStatements(ctx, conn, migration.forwardsNoTx); err != nil {
+			return errors.Wrapf(err, "unable to apply migration %d, it ran outside a transaction and may be partly applied", migration.Number)
+		}
+		return errors.Wrapf(insertRecord(ctx, conn, migration, start), "unable to create migration record %d", migration.Number)
 	}
 
-	if latest.Number >= Migrations[len(Migrations)-1].Number {
-		logger.Infof("no migrations to apply")
-		return nil
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		if err := migration.Forwards(ctx, tx); err != nil {
+			return errors.Wrapf(err, "unable to apply migration %d", migration.Number)
+		}
+		return errors.Wrapf(insertRecord(ctx, tx, migration, start), "unable to create migration record %d", migration.Number)
+	})
+}
+
+// execer is a pgx.Conn or a pgx.Tx
+type execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+func insertRecord(ctx context.Context, db execer, migration *Migration, start time.Time) error {
+	_, err := db.Exec(ctx,
+		`INSERT INTO migrations (number, name, checksum, applied_at, duration_ms) VALUES ($1, $2, $3, $4, $5)`,
+		int64(migration.Number),
+		migration.Name,
+		checksum(migration),
+		start,
+		time.Since(start).Milliseconds(),
+	)
+	return err
+}
+
+func execStatements(ctx context.Context, conn *pgx.Conn, statements []string) error {
+	for _, statement := range statements {
+		if _, err := conn.Exec(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkDrift compares the applied migrations with their Source. Records
+// from before checksums were kept get the current one.
+func checkDrift(ctx context.Context, conn *pgx.Conn, logger log.Logger, applied []*Migration, options Options) error {
+	drifted := make([]string, 0)
+	for _, record := range applied {
+		migration := findMigration(record.Number)
+		if migration == nil || migration.Source == "" {
+			continue
+		}
+
+		if record.Checksum == "" {
+			if options.DryRun {
+				continue
+			}
+			record.Checksum = checksum(migration)
+			if _, err := conn.Exec(ctx, `UPDATE migrations SET checksum = $1 WHERE number = $2`, record.Checksum, int64(record.Number)); err != nil {
+				return errors.Wrapf(err, "unable to store the checksum of migration %d", record.Number)
+			}
+			continue
+		}
+
+		if changed(migration, record) {
+			logger.Warnf("migration %d %q changed after it was applied", record.Number, migration.Name)
+			drifted = append(drifted, fmt.Sprint(record.Number))
+		}
 	}
 
-	if number == -1 {
-		number = int(Migrations[len(Migrations)-1].Number)
+	if len(drifted) > 0 && !options.IgnoreDrift {
+		return fmt.Errorf("applied migrations changed: %s; revert the change or add a new migration, or pass --ignore-drift", strings.Join(drifted, ", "))
 	}
 
-	if uint(number) <= latest.Number && latest.Number > 0 {
-		logger.Infof("no migrations to apply, specified number is less than or equal to latest migration; backwards migrations are not supported")
-		return nil
+	return nil
+}
+
+func changed(migration *Migration, record *Migration) bool {
+	return migration.Source != "" && record.Checksum != "" && record.Checksum != checksum(migration)
+}
+
+// checksum is the SHA-256 of Source with whitespace collapsed, so
+// reformatting a migration is not a change
+func checksum(migration *Migration) string {
+	if migration.Source == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(strings.Join(strings.Fields(migration.Source), " ")))
+	return hex.EncodeToString(sum[:])
+}
+
+// skippedMigrations returns the migrations of this build that are not applied
+// although a higher numbered one is, e.g. merged from a branch after it
+func skippedMigrations(applied []*Migration, latest uint) []*Migration {
+	numbers := make(map[uint]bool, len(applied))
+	for _, record := range applied {
+		numbers[record.Number] = true
 	}
 
+	skipped := make([]*Migration, 0)
 	for _, migration := range Migrations {
-		if migration.Number > uint(number) {
-			break
+		if migration.Number < latest && !numbers[migration.Number] {
+			skipped = append(skipped, migration)
 		}
+	}
+	return skipped
+}
 
-		if migration.Number <= latest.Number {
-			continue
+// targetNumber resolves To or Steps to the number of the last migration that
+// should be applied, 0 for none
+func targetNumber(options Options, applied []*Migration, latest uint) (uint, error) {
+	switch {
+	case options.Steps > 0:
+		target := latest
+		for _, migration := range Migrations {
+			if migration.Number > latest && options.Steps > 0 {
+				target = migration.Number
+				options.Steps--
+			}
 		}
+		return target, nil
 
-		if latest.Number > 0 {
-			logger.Infof("continuing migration starting from %d", migration.Number)
+	case options.Steps < 0:
+		index := len(applied) + options.Steps - 1
+		if index < 0 {
+			return 0, nil
+		}
+		return applied[index].Number, nil
+
+	case options.To == -1:
+		if len(Migrations) == 0 {
+			return latest, nil
+		}
+		return Migrations[len(Migrations)-1].Number, nil
+
+	case options.To < 0:
+		return 0, fmt.Errorf("invalid migration number %d", options.To)
+	}
+
+	return uint(options.To), nil
+}
+
+// rollback runs Backwards of every applied migration above target, newest
+// first, each in its own transaction with the removal of its record. It
+// refuses before changing anything when one of them has no Backwards.
+func rollback(ctx context.Context, conn *pgx.Conn, logger log.Logger, applied []*Migration, target uint, dryRun bool) error {
+	steps := make([]*Migration, 0)
+	for i := len(applied) - 1; i >= 0; i-- {
+		if applied[i].Number <= target {
+			break
+		}
+
+		migration := findMigration(applied[i].Number)
+		if migration == nil {
+			return fmt.Errorf("migration %d %q is applied but unknown to this build, can not roll it back", applied[i].Number, applied[i].Name)
+		}
+		if !migration.reversible() {
+			return fmt.Errorf("migration %d %q has no backwards step, can not roll back to %d", migration.Number, migration.Name, target)
 		}
+		steps = append(steps, migration)
+	}
 
+	for _, migration := range steps {
 		logger := logger.WithFields(log.Fields{
 			"migration_number": migration.Number,
 		})
-		logger.Infof("applying migration %q", migration.Name)
+		logger.Infof("rolling back migration %q", migration.Name)
 
 		if dryRun {
 			continue
 		}
 
-		tx := db.Begin()
+		if migration.backwardsNoTx != nil {
+			if err := execStatements(ctx, conn, migration.backwardsNoTx); err != nil {
+				logger.Errorf("unable to roll back migration, it ran outside a transaction and may be partly rolled back. err: %+v", err)
+				return errors.Wrapf(err, "unable to roll back migration %d", migration.Number)
+			}
+			if _, err := conn.Exec(ctx, `DELETE FROM migrations WHERE number = $1`, int64(migration.Number)); err != nil {
+				return errors.Wrapf(err, "unable to delete migration record %d", migration.Number)
+			}
+			continue
+		}
 
-		if err := migration.Forwards(tx); err != nil {
-			logger.Errorf("unable to apply migration, rolling back. err: %+v", err)
-			if err := tx.Rollback().Error; err != nil {
-				logger.Errorf("unable to rollback... err: %+v", err)
+		err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			if err := migration.Backwards(ctx, tx); err != nil {
+				return errors.Wrapf(err, "unable to roll back migration %d", migration.Number)
 			}
-			break
+			_, err := tx.Exec(ctx, `DELETE FROM migrations WHERE number = $1`, int64(migration.Number))
+			return errors.Wrapf(err, "unable to delete migration record %d", migration.Number)
+		})
+		if err != nil {
+			logger.Errorf("unable to roll back migration. err: %+v", err)
+			return err
 		}
+	}
 
-		if err := tx.Commit().Error; err != nil {
-			logger.Errorf("unable to commit transaction... err: %+v", err)
-			break
+	return nil
+}
+
+// reset empties the database for a fresh migration. A dedicated schema is
+// dropped with everything in it; the public schema is shared, so there every
+// applied migration is rolled back instead.
+func reset(ctx context.Context, conn *pgx.Conn, logger log.Logger, schema string, dryRun bool) error {
+	if schema != "" && schema != "public" {
+		logger.Infof("dropping schema %q", schema)
+		if dryRun {
+			return nil
 		}
 
-		// Create migration record
-		if err := db.Create(migration).Error; err != nil {
-			logger.Errorf("unable to create migration record. err: %+v", err)
-			break
+		if _, err := conn.Exec(ctx, `DROP SCHEMA `+pgx.Identifier{schema}.Sanitize()+` CASCADE`); err != nil {
+			return errors.Wrapf(err, "unable to drop schema %q", schema)
 		}
+		return ensureTable(ctx, conn, schema)
+	}
+
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return rollback(ctx, conn, logger, applied, 0, dryRun)
+}
+
+func appliedMigrations(ctx context.Context, conn *pgx.Conn) ([]*Migration, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT number, COALESCE(name, ''), COALESCE(checksum, ''), applied_at, COALESCE(duration_ms, 0)
+		FROM migrations
+		ORDER BY number
+	`)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list applied migrations")
 	}
 
+	applied, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*Migration, error) {
+		var number int64
+		record := &Migration{}
+		err := row.Scan(&number, &record.Name, &record.Checksum, &record.AppliedAt, &record.DurationMs)
+		record.Number = uint(number)
+		return record, err
+	})
+	return applied, errors.Wrap(err, "unable to list applied migrations")
+}
+
+func findMigration(number uint) *Migration {
+	for _, migration := range Migrations {
+		if migration.Number == number {
+			return migration
+		}
+	}
 	return nil
 }
@@ -0,0 +1,68 @@
+package migrations
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []string
+	}{
+		{
+			name: "statements",
+			sql:  "CREATE TABLE a (id int);\nCREATE TABLE b (id int);\n",
+			want: []string{"CREATE TABLE a (id int)", "CREATE TABLE b (id int)"},
+		},
+		{
+			name: "no trailing semicolon",
+			sql:  "SELECT 1; SELECT 2",
+			want: []string{"SELECT 1", "SELECT 2"},
+		},
+		{
+			name: "semicolons in strings and identifiers",
+			sql:  `INSERT INTO "a;b" VALUES ('x;y', 'it''s;'); SELECT 1`,
+			want: []string{`INSERT INTO "a;b" VALUES ('x;y', 'it''s;')`, "SELECT 1"},
+		},
+		{
+			name: "semicolons in comments",
+			sql:  "-- first; statement\nSELECT 1; /* block;\ncomment; */ SELECT 2",
+			want: []string{"-- first; statement\nSELECT 1", "/* block;\ncomment; */ SELECT 2"},
+		},
+		{
+			name: "dollar quoted function body",
+			sql:  "CREATE FUNCTION f() RETURNS int AS $$ BEGIN RETURN 1; END; $$ LANGUAGE plpgsql; SELECT f()",
+			want: []string{"CREATE FUNCTION f() RETURNS int AS $$ BEGIN RETURN 1; END; $$ LANGUAGE plpgsql", "SELECT f()"},
+		},
+		{
+			name: "tagged dollar quote containing $$",
+			sql:  "DO $body$ BEGIN EXECUTE $$SELECT 1;$$; END $body$; SELECT 2",
+			want: []string{"DO $body$ BEGIN EXECUTE $$SELECT 1;$$; END $body$", "SELECT 2"},
+		},
+		{
+			name: "positional parameters are not dollar quotes",
+			sql:  "PREPARE p AS SELECT $1; EXECUTE p(1)",
+			want: []string{"PREPARE p AS SELECT $1", "EXECUTE p(1)"},
+		},
+		{
+			name: "blank and comment-only statements dropped",
+			sql:  ";\n  ;\n-- nothing to run\n;SELECT 1;\n-- trailing comment\n",
+			want: []string{"SELECT 1"},
+		},
+		{
+			name: "empty",
+			sql:  "",
+			want: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitStatements(tt.sql); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitStatements() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package postgresql
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// PoolStats are the connections of a pool. Counts are since the pool opened.
+type PoolStats struct {
+	MaxConns          int32 `json:"max_conns"`
+	TotalConns        int32 `json:"total_conns"`
+	IdleConns         int32 `json:"idle_conns"`
+	AcquiredConns     int32 `json:"acquired_conns"`
+	ConstructingConns int32 `json:"constructing_conns"`
+
+	AcquireCount int64 `json:"acquire_count"`
+	// EmptyAcquireCount counts acquires that had to wait for a connection
+	EmptyAcquireCount       int64 `json:"empty_acquire_count"`
+	CanceledAcquireCount    int64 `json:"canceled_acquire_count"`
+	AcquireDurationMs       int64 `json:"acquire_duration_ms"`
+	NewConnsCount           int64 `json:"new_conns_count"`
+	MaxLifetimeDestroyCount int64 `json:"max_lifetime_destroy_count"`
+	MaxIdleDestroyCount     int64 `json:"max_idle_destroy_count"`
+}
+
+func newPoolStats(pool *pgxpool.Pool) *PoolStats {
+	stat := pool.Stat()
+	return &PoolStats{
+		MaxConns:                stat.MaxConns(),
+		TotalConns:              stat.TotalConns(),
+		IdleConns:               stat.IdleConns(),
+		AcquiredConns:           stat.AcquiredConns(),
+		ConstructingConns:       stat.ConstructingConns(),
+		AcquireCount:            stat.AcquireCount(),
+		EmptyAcquireCount:       stat.EmptyAcquireCount(),
+		CanceledAcquireCount:    stat.CanceledAcquireCount(),
+		AcquireDurationMs:       stat.AcquireDuration().Milliseconds(),
+		NewConnsCount:           stat.NewConnsCount(),
+		MaxLifetimeDestroyCount: stat.MaxLifetimeDestroyCount(),
+		MaxIdleDestroyCount:     stat.MaxIdleDestroyCount(),
+	}
+}
+
+// PoolStats returns the connections of the primary pool
+func (pgdb *PostgresqlDB) PoolStats() *PoolStats {
+	return newPoolStats(pgdb.pool)
+}
+
+// registerPoolMetrics reports the connections of the primary and replica
+// pools as OpenTelemetry gauges, read by the global MeterProvider
+func (pgdb *PostgresqlDB) registerPoolMetrics() (metric.Registration, error) {
+	meter := otel.Meter(tracerName)
+
+	connections, err := meter.Int64ObservableGauge("db.client.connection.count",
+		metric.WithDescription("The number of connections of the pool by state"),
+		metric.WithUnit("{connection}"))
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to create the connection count metric")
+	}
+	maxConnections, err := meter.Int64ObservableGauge("db.client.connection.max",
+		metric.WithDescription("The maximum number of connections of the pool"),
+		metric.WithUnit("{connection}"))
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to create the connection max metric")
+	}
+	waits, err := meter.Int64ObservableCounter("db.client.connection.waits",
+		metric.WithDescription("The number of acquires that waited for a connection"),
+		metric.WithUnit("{acquire}"))
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to create the connection waits metric")
+	}
+
+	pools := map[string]*pgxpool.Pool{"primary": pgdb.pool}
+	if pgdb.replicas != nil {
+		for _, r := range pgdb.replicas.replicas {
+			pools[r.host] = r.pool
+		}
+	}
+
+	return meter.RegisterCallback(func(ctx context.Context, observer metric.Observer) error {
+		for name, pool := range pools {
+			stats := newPoolStats(pool)
+			poolName := attribute.String("db.client.connection.pool.name", name)
+
+			observer.ObserveInt64(connections, int64(stats.IdleConns),
+				metric.WithAttributes(poolName, attribute.String("db.client.connection.state", "idle")))
+			observer.ObserveInt64(connections, int64(stats.AcquiredConns),
+				metric.WithAttributes(poolName, attribute.String("db.client.connection.state", "used")))
+			observer.ObserveInt64(maxConnections, int64(stats.MaxConns), metric.WithAttributes(poolName))
+			observer.ObserveInt64(waits, stats.EmptyAcquireCount, metric.WithAttributes(poolName))
+		}
+		return nil
+	}, connections, maxConnections, waits)
+}
@@ -2,22 +2,34 @@ package postgresql
 
 import (
 	"context"
-	"fmt"
-	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	pgxdecimal "github.com/jackc/pgx-shopspring-decimal"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/multitracer"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/pgx/v5/tracelog"
+	"github.com/pkg/errors"
 	"go-template/src/core/log"
+	"go.opentelemetry.io/otel/metric"
 )
 
 type PostgresqlDB struct {
 	logger log.Logger
 	Config *Config
 
-	DB *pgxpool.Pool
+	// DB is the pool, or the transaction inside WithTx
+	DB Querier
+
+	pool *pgxpool.Pool
+	tx   pgx.Tx
+	// replicas serve the reads of reader, nil without replicas
+	replicas *replicaSet
+
+	activityLogWriter *activityLogWriter
+	poolMetrics       metric.Registration
 }
 
 func New(config *Config, logger log.Logger) (pgdb *PostgresqlDB, err error) {
@@ -29,50 +41,190 @@ func New(config *Config, logger log.Logger) (pgdb *PostgresqlDB, err error) {
 		"module": "db/postgresql",
 	})
 
-	connStr := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		config.Host,
-		config.Port,
-		config.Username,
-		config.Password,
-		config.DatabaseName,
-		config.SSLMode,
-	)
+	pgdb.pool, err = Connect(context.Background(), config, pgdb.logger)
+	if err != nil {
+		pgdb.logger.Errorf("Error connecting to postgres: %+v", err)
+		return nil, err
+	}
+	pgdb.DB = pgdb.pool
 
-	// logger.Debugf("PG Connection : %s", connStr)
+	if config.Replicas != nil {
+		pgdb.replicas, err = newReplicaSet(config, pgdb.logger)
+		if err != nil {
+			pgdb.pool.Close()
+			return nil, err
+		}
+	}
 
-	var connectConf, _ = pgxpool.ParseConfig(connStr)
-	connectConf.MaxConns = config.MaxOpenConns
-	connectConf.MaxConnIdleTime = 5 * time.Second
-	connectConf.MaxConnLifetime = 300 * time.Second
-	connectConf.HealthCheckPeriod = 15 * time.Second
+	if config.ActivityLog != nil && config.ActivityLog.Async {
+		pgdb.activityLogWriter, err = newActivityLogWriter(config.ActivityLog, pgdb.pool, pgdb.logger)
+		if err != nil {
+			if pgdb.replicas != nil {
+				pgdb.replicas.Close()
+			}
+			pgdb.pool.Close()
+			return nil, err
+		}
+	}
 
-	connectConf.ConnConfig.Tracer = &tracelog.TraceLog{
-		Logger:   NewDatabaseLogger(&pgdb.logger),
-		LogLevel: tracelog.LogLevelTrace,
+	pgdb.poolMetrics, err = pgdb.registerPoolMetrics()
+	if err != nil {
+		pgdb.logger.Warnf("Pool metrics are not reported: %v", err)
 	}
 
+	return pgdb, nil
+}
+
+// NewPool opens a pool to the database of config, with query logging and
+// tracing, the decimal type and the session settings of config. The service
+// and the migrations connect through it. Connections are made lazily, see
+// Connect to wait for the database.
+func NewPool(ctx context.Context, config *Config, logger log.Logger) (*pgxpool.Pool, error) {
+	params := []string{
+		"host=" + connStringValue(config.Host),
+		"port=" + connStringValue(config.Port),
+		"user=" + connStringValue(config.Username),
+		"password=" + connStringValue(config.Password),
+		"dbname=" + connStringValue(config.DatabaseName),
+		"sslmode=" + connStringValue(config.SSLMode),
+	}
+	if config.SSLRootCert != "" {
+		params = append(params, "sslrootcert="+connStringValue(config.SSLRootCert))
+	}
+	if config.SSLCert != "" {
+		params = append(params, "sslcert="+connStringValue(config.SSLCert))
+	}
+	if config.SSLKey != "" {
+		params = append(params, "sslkey="+connStringValue(config.SSLKey))
+	}
+
+	connectConf, err := pgxpool.ParseConfig(strings.Join(params, " "))
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to parse the postgres connection config")
+	}
+
+	connectConf.MaxConns = config.MaxOpenConns
+	if pool := config.Pool; pool != nil {
+		connectConf.MinConns = pool.MinConns
+		connectConf.MaxConnIdleTime = pool.MaxConnIdleTime
+		connectConf.MaxConnLifetime = pool.MaxConnLifetime
+		connectConf.MaxConnLifetimeJitter = pool.MaxConnLifetimeJitter
+		connectConf.HealthCheckPeriod = pool.HealthCheckPeriod
+		connectConf.ConnConfig.ConnectTimeout = pool.ConnectTimeout
+	}
+
+	connectConf.ConnConfig.Tracer = multitracer.New(
+		&tracelog.TraceLog{
+			Logger:   NewDatabaseLogger(&logger),
+			LogLevel: traceLogLevel(config.LogLevel),
+		},
+		NewQueryTracer(config),
+	)
+
 	// Register Decimal Data Type to PGX Pool
 	connectConf.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
 		pgxdecimal.Register(conn.TypeMap())
 		return nil
 	}
 
-	// Set timezone to PGX runtime
-	if s := os.Getenv("TZ"); s != "" {
-		connectConf.ConnConfig.RuntimeParams["timezone"] = s
+	runtimeParams := connectConf.ConnConfig.RuntimeParams
+	if config.Schema != "" {
+		runtimeParams["search_path"] = config.Schema
+	}
+	if config.TimeZone != "" {
+		runtimeParams["timezone"] = config.TimeZone
 	}
+	if config.ApplicationName != "" {
+		runtimeParams["application_name"] = config.ApplicationName
+	}
+	if config.StatementTimeout > 0 {
+		runtimeParams["statement_timeout"] = strconv.FormatInt(config.StatementTimeout.Milliseconds(), 10)
+	}
+
+	return pgxpool.NewWithConfig(ctx, connectConf)
+}
 
-	pgdb.DB, err = pgxpool.NewWithConfig(context.Background(), connectConf)
+// Connect opens a pool with NewPool and waits for the database to accept a
+// connection, retrying with exponential backoff up to ConnectRetry.MaxWait
+func Connect(ctx context.Context, config *Config, logger log.Logger) (*pgxpool.Pool, error) {
+	pool, err := NewPool(ctx, config, logger)
 	if err != nil {
-		pgdb.logger.Errorf("Error connecting to postgres: %+v")
 		return nil, err
 	}
 
-	return pgdb, nil
+	retry := config.ConnectRetry
+	if retry == nil {
+		retry = &ConnectRetryConfig{}
+	}
+
+	deadline := time.Now().Add(retry.MaxWait)
+	interval := retry.InitialInterval
+	for attempt := 1; ; attempt++ {
+		err = pool.Ping(ctx)
+		if err == nil {
+			return pool, nil
+		}
+		if ctx.Err() != nil || time.Now().Add(interval).After(deadline) {
+			pool.Close()
+			return nil, errors.Wrapf(err, "Unable to connect to postgres at %s:%s after %d attempts", config.Host, config.Port, attempt)
+		}
+
+		logger.Warnf("Unable to connect to postgres at %s:%s, retrying in %s (attempt %d): %v", config.Host, config.Port, interval, attempt, err)
+
+		select {
+		case <-ctx.Done():
+			pool.Close()
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+
+		interval = min(interval*2, retry.MaxInterval)
+	}
 }
 
+// connStringValue quotes value for a keyword/value connection string, so
+// spaces and quotes in passwords and paths survive
+func connStringValue(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// traceLogLevel maps Database.Log.Level, checked by InitConfig, to the pgx
+// trace log level. Every query is logged from "info" down, so anything not
+// set is logged from "warn".
+func traceLogLevel(level string) tracelog.LogLevel {
+	logLevel, err := tracelog.LogLevelFromString(level)
+	if err != nil {
+		return tracelog.LogLevelWarn
+	}
+	return logLevel
+}
+
+// ActivityLogWriterStats returns the counters of the asynchronous activity
+// log writer, nil when it is disabled
+func (pgdb *PostgresqlDB) ActivityLogWriterStats() *ActivityLogWriterStats {
+	if pgdb.activityLogWriter == nil {
+		return nil
+	}
+	stats := pgdb.activityLogWriter.Stats()
+	return &stats
+}
+
+// Close closes the pools. It does nothing on the tx passed to WithTx.
 func (pgdb *PostgresqlDB) Close() error {
-	pgdb.DB.Close()
+	if pgdb.tx != nil {
+		return nil
+	}
+
+	// flush queued activity log entries while the pool is still open
+	if pgdb.activityLogWriter != nil {
+		pgdb.activityLogWriter.Close()
+	}
+	if pgdb.poolMetrics != nil {
+		_ = pgdb.poolMetrics.Unregister()
+	}
+	if pgdb.replicas != nil {
+		pgdb.replicas.Close()
+	}
+	pgdb.pool.Close()
 	return nil
 }
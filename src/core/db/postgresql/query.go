@@ -0,0 +1,405 @@
+package postgresql
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Query builds the WHERE, ORDER BY and LIMIT clauses of a list query. Values
+// are always sent as $n placeholders with Args; column names come from code
+// or from a SortColumns allowlist and are quoted, never from the request.
+//
+//	q := NewQuery().
+//		Equal("service_code", filter.ServiceCode).
+//		TimeRange("created_time", filter.StartTime, filter.EndTime)
+//	rows, err := pgdb.DB.Query(ctx, "SELECT * FROM activity_log "+q.WhereSQL(), q.Args()...)
+//
+// Conditions with an empty value (zero, "", nil or an empty slice) are
+// skipped, so optional filters need no if around them.
+type Query struct {
+	conditions []string
+	args       []any
+	orderBy    []string
+	limit      int64
+	offset     int64
+}
+
+func NewQuery() *Query {
+	return &Query{}
+}
+
+// Arg adds value to the arguments and returns its placeholder
+func (q *Query) Arg(value any) string {
+	q.args = append(q.args, value)
+	return "$" + strconv.Itoa(len(q.args))
+}
+
+// Args returns the arguments of the placeholders, in order
+func (q *Query) Args() []any {
+	return q.args
+}
+
+// Where adds a raw condition, replacing each ? in it with the placeholder of
+// the matching arg. Write ?? for a literal ?, e.g. the jsonb operators
+// "data ?? ?" and "data ??| ?". condition must be a constant.
+func (q *Query) Where(condition string, args ...any) *Query {
+	var b strings.Builder
+	placeholders := 0
+	for i := 0; i < len(condition); i++ {
+		if condition[i] != '?' {
+			b.WriteByte(condition[i])
+			continue
+		}
+		if i+1 < len(condition) && condition[i+1] == '?' {
+			b.WriteByte('?')
+			i++
+			continue
+		}
+		if placeholders < len(args) {
+			b.WriteString(q.Arg(args[placeholders]))
+		}
+		placeholders++
+	}
+	if placeholders != len(args) {
+		panic(fmt.Sprintf("postgresql: %d args for %d placeholders in %q", len(args), placeholders, condition))
+	}
+
+	q.conditions = append(q.conditions, b.String())
+	return q
+}
+
+// Equal adds column = value
+func (q *Query) Equal(column string, value any) *Query {
+	if isEmpty(value) {
+		return q
+	}
+	return q.Where(quoteColumn(column)+" = ?", value)
+}
+
+// NotEqual adds column <> value
+func (q *Query) NotEqual(column string, value any) *Query {
+	if isEmpty(value) {
+		return q
+	}
+	return q.Where(quoteColumn(column)+" <> ?", value)
+}
+
+// EqualFold adds a case-insensitive column = value
+func (q *Query) EqualFold(column string, value string) *Query {
+	if value == "" {
+		return q
+	}
+	return q.Where("LOWER("+quoteColumn(column)+") = LOWER(?)", value)
+}
+
+// Compare adds column op value, op is one of = <> < <= > >=
+func (q *Query) Compare(column string, op string, value any) *Query {
+	if isEmpty(value) {
+		return q
+	}
+	switch op {
+	case "=", "<>", "<", "<=", ">", ">=":
+	default:
+		panic(fmt.Sprintf("postgresql: unknown comparison %q", op))
+	}
+	return q.Where(quoteColumn(column)+" "+op+" ?", value)
+}
+
+// Contains adds a case-insensitive substring match. % and _ in value match
+// themselves.
+func (q *Query) Contains(column string, value string) *Query {
+	if value == "" {
+		return q
+	}
+	return q.Where(quoteColumn(column)+" ILIKE ?", "%"+EscapeLike(value)+"%")
+}
+
+// HasPrefix adds a case-insensitive prefix match
+func (q *Query) HasPrefix(column string, value string) *Query {
+	if value == "" {
+		return q
+	}
+	return q.Where(quoteColumn(column)+" ILIKE ?", EscapeLike(value)+"%")
+}
+
+// ContainsAny adds a case-insensitive substring match on any of columns
+func (q *Query) ContainsAny(columns []string, value string) *Query {
+	if value == "" || len(columns) == 0 {
+		return q
+	}
+
+	placeholder := q.Arg("%" + EscapeLike(value) + "%")
+	matches := make([]string, 0, len(columns))
+	for _, column := range columns {
+		matches = append(matches, quoteColumn(column)+" ILIKE "+placeholder)
+	}
+
+	q.conditions = append(q.conditions, "("+strings.Join(matches, " OR ")+")")
+	return q
+}
+
+// In adds column = ANY(values), values is a slice
+func (q *Query) In(column string, values any) *Query {
+	if isEmpty(values) {
+		return q
+	}
+	return q.Where(quoteColumn(column)+" = ANY(?)", values)
+}
+
+// NotIn adds column <> ALL(values), values is a slice
+func (q *Query) NotIn(column string, values any) *Query {
+	if isEmpty(values) {
+		return q
+	}
+	return q.Where(quoteColumn(column)+" <> ALL(?)", values)
+}
+
+// ArrayContains adds array column @> values, the row holds every value
+func (q *Query) ArrayContains(column string, values any) *Query {
+	if isEmpty(values) {
+		return q
+	}
+	return q.Where(quoteColumn(column)+" @> ?", values)
+}
+
+// ArrayHas adds value = ANY(array column)
+func (q *Query) ArrayHas(column string, value any) *Query {
+	if isEmpty(value) {
+		return q
+	}
+	return q.Where("? = ANY("+quoteColumn(column)+")", value)
+}
+
+// ArrayElementContains adds a case-insensitive substring match on any
+// element of array column
+func (q *Query) ArrayElementContains(column string, value string) *Query {
+	if value == "" {
+		return q
+	}
+	return q.Where("EXISTS (SELECT 1 FROM unnest("+quoteColumn(column)+") AS e WHERE e ILIKE ?)", "%"+EscapeLike(value)+"%")
+}
+
+// TimeRange adds from <= column < to, either bound may be nil
+func (q *Query) TimeRange(column string, from, to *time.Time) *Query {
+	if from != nil {
+		q.Where(quoteColumn(column)+" >= ?", *from)
+	}
+	if to != nil {
+		q.Where(quoteColumn(column)+" < ?", *to)
+	}
+	return q
+}
+
+// DateRange adds the days from and to, both included, in the location of
+// the times. Either bound may be nil.
+func (q *Query) DateRange(column string, from, to *time.Time) *Query {
+	var start, end *time.Time
+	if from != nil {
+		day := startOfDay(*from)
+		start = &day
+	}
+	if to != nil {
+		day := startOfDay(*to).AddDate(0, 0, 1)
+		end = &day
+	}
+	return q.TimeRange(column, start, end)
+}
+
+// SortColumns maps the sort keys accepted from a request to columns
+type SortColumns map[string]string
+
+// OrderBy sets the ORDER BY from sort, comma separated keys of columns with
+// a - prefix for descending, e.g. "-created_time,id". An empty sort uses
+// defaultSort. Keys missing from columns are rejected.
+func (q *Query) OrderBy(sort string, columns SortColumns, defaultSort string) error {
+	if sort == "" {
+		sort = defaultSort
+	}
+
+	orderBy := make([]string, 0)
+	for _, key := range strings.Split(sort, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+
+		direction := "ASC"
+		if strings.HasPrefix(key, "-") {
+			direction = "DESC"
+			key = key[1:]
+		}
+
+		column, ok := columns[key]
+		if !ok {
+			return fmt.Errorf("can not sort by %q", key)
+		}
+		orderBy = append(orderBy, quoteColumn(column)+" "+direction)
+	}
+
+	q.orderBy = orderBy
+	return nil
+}
+
+// Page sets LIMIT limit OFFSET (page-1)*limit, page starts at 1
+func (q *Query) Page(page, limit int64) *Query {
+	if page < 1 {
+		page = 1
+	}
+	q.limit = limit
+	q.offset = (page - 1) * limit
+	return q
+}
+
+// Limit sets LIMIT limit without an offset, for keyset pagination
+func (q *Query) Limit(limit int64) *Query {
+	q.limit = limit
+	q.offset = 0
+	return q
+}
+
+// After adds the keyset condition continuing after the row whose values of
+// columns are values, e.g. After([]string{"created_time", "id"}, ...). The
+// ORDER BY must use the same columns all in the direction of desc.
+func (q *Query) After(columns []string, values []any, desc bool) *Query {
+	if len(columns) == 0 || len(columns) != len(values) {
+		panic("postgresql: After needs one value per column")
+	}
+
+	quoted := make([]string, 0, len(columns))
+	placeholders := make([]string, 0, len(values))
+	for i, column := range columns {
+		quoted = append(quoted, quoteColumn(column))
+		placeholders = append(placeholders, q.Arg(values[i]))
+	}
+
+	op := ">"
+	if desc {
+		op = "<"
+	}
+
+	q.conditions = append(q.conditions,
+		"("+strings.Join(quoted, ", ")+") "+op+" ("+strings.Join(placeholders, ", ")+")")
+	return q
+}
+
+// AfterMixed is After for an ORDER BY mixing directions, desc[i] being the
+// direction of columns[i]. It expands to one branch per column:
+// (a > $1) OR (a = $1 AND b < $2) OR (a = $1 AND b = $2 AND c > $3).
+func (q *Query) AfterMixed(columns []string, values []any, desc []bool) *Query {
+	if len(columns) == 0 || len(columns) != len(values) || len(columns) != len(desc) {
+		panic("postgresql: AfterMixed needs one value and direction per column")
+	}
+
+	quoted := make([]string, 0, len(columns))
+	placeholders := make([]string, 0, len(values))
+	for i, column := range columns {
+		quoted = append(quoted, quoteColumn(column))
+		placeholders = append(placeholders, q.Arg(values[i]))
+	}
+
+	branches := make([]string, 0, len(columns))
+	for i := range columns {
+		terms := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			terms = append(terms, quoted[j]+" = "+placeholders[j])
+		}
+		op := ">"
+		if desc[i] {
+			op = "<"
+		}
+		terms = append(terms, quoted[i]+" "+op+" "+placeholders[i])
+		branches = append(branches, "("+strings.Join(terms, " AND ")+")")
+	}
+
+	q.conditions = append(q.conditions, "("+strings.Join(branches, " OR ")+")")
+	return q
+}
+
+// WhereSQL returns "WHERE ..." or "" without conditions
+func (q *Query) WhereSQL() string {
+	if len(q.conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(q.conditions, " AND ")
+}
+
+// OrderBySQL returns "ORDER BY ..." or "" when not set
+func (q *Query) OrderBySQL() string {
+	if len(q.orderBy) == 0 {
+		return ""
+	}
+	return "ORDER BY " + strings.Join(q.orderBy, ", ")
+}
+
+// LimitSQL returns "LIMIT $n OFFSET $m" or "" when not set. Call it after
+// the conditions are added, it adds arguments.
+func (q *Query) LimitSQL() string {
+	if q.limit <= 0 {
+		return ""
+	}
+	sql := "LIMIT " + q.Arg(q.limit)
+	if q.offset > 0 {
+		sql += " OFFSET " + q.Arg(q.offset)
+	}
+	return sql
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// EscapeLike escapes the LIKE wildcards in value, for the default \ escape
+func EscapeLike(value string) string {
+	return likeEscaper.Replace(value)
+}
+
+var columnPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// quoteColumn quotes column, optionally qualified by a table. A column that
+// is not a plain identifier is a programming error.
+func quoteColumn(column string) string {
+	if !columnPattern.MatchString(column) {
+		panic(fmt.Sprintf("postgresql: invalid column name %q", column))
+	}
+	return pgx.Identifier(strings.Split(column, ".")).Sanitize()
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// isEmpty reports the zero values that skip an optional condition
+func isEmpty(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case int:
+		return v == 0
+	case int32:
+		return v == 0
+	case int64:
+		return v == 0
+	case float64:
+		return v == 0
+	case *bool:
+		return v == nil
+	case *string:
+		return v == nil
+	case *int64:
+		return v == nil
+	case *time.Time:
+		return v == nil
+	case []string:
+		return len(v) == 0
+	case []int:
+		return len(v) == 0
+	case []int64:
+		return len(v) == 0
+	}
+	return false
+}
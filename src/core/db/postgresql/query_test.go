@@ -0,0 +1,186 @@
+package postgresql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWhere(t *testing.T) {
+	q := NewQuery().
+		Where(`"a" = ? AND "b" = ?`, 1, 2).
+		Where(`"c" > ?`, 3).
+		Where(`"data" ?? ? AND "tags" ??| ?`, "key", []string{"x", "y"})
+
+	wantSQL := `WHERE "a" = $1 AND "b" = $2 AND "c" > $3 AND "data" ? $4 AND "tags" ?| $5`
+	if got := q.WhereSQL(); got != wantSQL {
+		t.Errorf("WhereSQL() = %s, want %s", got, wantSQL)
+	}
+
+	wantArgs := []any{1, 2, 3, "key", []string{"x", "y"}}
+	if got := q.Args(); !reflect.DeepEqual(got, wantArgs) {
+		t.Errorf("Args() = %v, want %v", got, wantArgs)
+	}
+
+	if got := q.LimitSQL(); got != "" {
+		t.Errorf("LimitSQL() without a limit = %q, want empty", got)
+	}
+	if got := q.Page(3, 20).LimitSQL(); got != "LIMIT $6 OFFSET $7" {
+		t.Errorf("LimitSQL() = %s, want LIMIT $6 OFFSET $7", got)
+	}
+}
+
+func TestWherePanicsOnArgCountMismatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition string
+		args      []any
+	}{
+		{name: "missing arg", condition: `"a" = ? AND "b" = ?`, args: []any{1}},
+		{name: "extra arg", condition: `"a" = ?`, args: []any{1, 2}},
+		{name: "escaped placeholder", condition: `"data" ?? 'key'`, args: []any{1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Where(%q) with %d args did not panic", tt.condition, len(tt.args))
+				}
+			}()
+			NewQuery().Where(tt.condition, tt.args...)
+		})
+	}
+}
+
+func TestSkipsEmptyValues(t *testing.T) {
+	q := NewQuery().
+		Equal("a", "").
+		Equal("b", 0).
+		In("c", []string{}).
+		TimeRange("d", nil, nil).
+		Equal("e", "x")
+
+	if got := q.WhereSQL(); got != `WHERE "e" = $1` {
+		t.Errorf("WhereSQL() = %s", got)
+	}
+}
+
+func TestQuoteColumn(t *testing.T) {
+	valid := map[string]string{
+		"created_time":   `"created_time"`,
+		"a.created_time": `"a"."created_time"`,
+		"_Col9":          `"_Col9"`,
+	}
+	for column, want := range valid {
+		if got := quoteColumn(column); got != want {
+			t.Errorf("quoteColumn(%q) = %s, want %s", column, got, want)
+		}
+	}
+
+	crafted := []string{
+		"",
+		`id"; DROP TABLE api_keys; --`,
+		"id desc",
+		"id,key",
+		"a.b.c",
+		"9id",
+		"id)",
+		"lower(id)",
+		`"id"`,
+		"id--",
+	}
+	for _, column := range crafted {
+		t.Run(column, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("quoteColumn(%q) did not panic", column)
+				}
+			}()
+			quoteColumn(column)
+		})
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	tests := map[string]string{
+		"plain":    "plain",
+		"100%":     `100\%`,
+		"a_b":      `a\_b`,
+		`c:\temp`:  `c:\\temp`,
+		`\%_`:      `\\\%\_`,
+		"":         "",
+		"ไทย_100%": `ไทย\_100\%`,
+	}
+	for value, want := range tests {
+		if got := EscapeLike(value); got != want {
+			t.Errorf("EscapeLike(%q) = %s, want %s", value, got, want)
+		}
+	}
+
+	q := NewQuery().Contains("name", "50%_off")
+	if got := q.Args()[0]; got != `%50\%\_off%` {
+		t.Errorf("Contains arg = %v", got)
+	}
+}
+
+func TestOrderBy(t *testing.T) {
+	columns := SortColumns{
+		"created_time": "created_time",
+		"id":           "id",
+		"user":         "a.user_id",
+	}
+
+	tests := []struct {
+		name    string
+		sort    string
+		want    string
+		wantErr bool
+	}{
+		{name: "default", sort: "", want: `ORDER BY "created_time" DESC, "id" DESC`},
+		{name: "mixed", sort: "user, -id", want: `ORDER BY "a"."user_id" ASC, "id" DESC`},
+		{name: "skips empty keys", sort: ",id,", want: `ORDER BY "id" ASC`},
+		{name: "unknown column", sort: "password", wantErr: true},
+		{name: "column not key", sort: "a.user_id", wantErr: true},
+		{name: "injection", sort: `id; DROP TABLE api_keys`, wantErr: true},
+		{name: "unknown after known", sort: "id,-nope", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := NewQuery()
+			err := q.OrderBy(tt.sort, columns, "-created_time,-id")
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("OrderBy(%q) = %s, want an error", tt.sort, q.OrderBySQL())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("OrderBy(%q): %v", tt.sort, err)
+			}
+			if got := q.OrderBySQL(); got != tt.want {
+				t.Errorf("OrderBy(%q) = %s, want %s", tt.sort, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAfter(t *testing.T) {
+	q := NewQuery().Equal("service_code", "GT").
+		After([]string{"created_time", "id"}, []any{"t", 7}, true)
+	if got := q.WhereSQL(); got != `WHERE "service_code" = $1 AND ("created_time", "id") < ($2, $3)` {
+		t.Errorf("After() = %s", got)
+	}
+
+	q = NewQuery().Equal("service_code", "GT").
+		AfterMixed([]string{"service_code", "created_time", "id"}, []any{"GT", "t", 7}, []bool{false, true, true})
+	want := `WHERE "service_code" = $1 AND (("service_code" > $2)` +
+		` OR ("service_code" = $2 AND "created_time" < $3)` +
+		` OR ("service_code" = $2 AND "created_time" = $3 AND "id" < $4))`
+	if got := q.WhereSQL(); got != want {
+		t.Errorf("AfterMixed() = %s, want %s", got, want)
+	}
+	if got := len(q.Args()); got != 4 {
+		t.Errorf("AfterMixed() adds %d args, want 4", got)
+	}
+}
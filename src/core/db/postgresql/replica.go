@@ -0,0 +1,235 @@
+package postgresql
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pkg/errors"
+	"go-template/src/core/log"
+)
+
+const contextKeyPrimary contextKey = "Primary"
+
+const replicaCheckTimeout = 5 * time.Second
+
+// ContextWithPrimary returns a copy of ctx whose reads go to the primary,
+// e.g. to read back a row right after writing it
+func ContextWithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextKeyPrimary, true)
+}
+
+func primaryFromContext(ctx context.Context) bool {
+	primary, _ := ctx.Value(contextKeyPrimary).(bool)
+	return primary
+}
+
+// ReplicaStatus is the last check of a replica and its pool
+type ReplicaStatus struct {
+	Host      string     `json:"host"`
+	Healthy   bool       `json:"healthy"`
+	LagMs     int64      `json:"lag_ms"`
+	Error     string     `json:"error,omitempty"`
+	CheckedAt time.Time  `json:"checked_at"`
+	Pool      *PoolStats `json:"pool,omitempty"`
+}
+
+type replica struct {
+	host string
+	pool *pgxpool.Pool
+
+	mu     sync.RWMutex
+	status ReplicaStatus
+}
+
+// replicaSet picks the replica of a read in turn, skipping the ones whose
+// last check failed or lagged more than MaxLag
+type replicaSet struct {
+	config   *ReplicaConfig
+	logger   log.Logger
+	replicas []*replica
+	next     atomic.Uint64
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newReplicaSet(config *Config, logger log.Logger) (*replicaSet, error) {
+	rs := &replicaSet{
+		config: config.Replicas,
+		logger: logger,
+		done:   make(chan struct{}),
+	}
+
+	for _, host := range config.Replicas.Hosts {
+		replicaConfig := *config
+		replicaConfig.Host = host
+		if h, port, err := net.SplitHostPort(host); err == nil {
+			replicaConfig.Host = h
+			replicaConfig.Port = port
+		}
+		replicaConfig.MaxOpenConns = config.Replicas.MaxOpenConns
+
+		pool, err := NewPool(context.Background(), &replicaConfig, logger)
+		if err != nil {
+			rs.closePools()
+			return nil, errors.Wrapf(err, "Unable to connect to replica %s", host)
+		}
+		rs.replicas = append(rs.replicas, &replica{
+			host:   host,
+			pool:   pool,
+			status: ReplicaStatus{Host: host},
+		})
+	}
+
+	// route reads only once a replica is known to be healthy
+	rs.checkAll()
+
+	rs.wg.Add(1)
+	go rs.run()
+
+	return rs, nil
+}
+
+// pick returns the pool of the next healthy replica, nil when there is none
+func (rs *replicaSet) pick() *pgxpool.Pool {
+	start := rs.next.Add(1)
+	for i := range rs.replicas {
+		r := rs.replicas[(start+uint64(i))%uint64(len(rs.replicas))]
+		r.mu.RLock()
+		healthy := r.status.Healthy
+		r.mu.RUnlock()
+		if healthy {
+			return r.pool
+		}
+	}
+	return nil
+}
+
+func (rs *replicaSet) run() {
+	defer rs.wg.Done()
+
+	ticker := time.NewTicker(rs.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			rs.checkAll()
+		case <-rs.done:
+			return
+		}
+	}
+}
+
+func (rs *replicaSet) checkAll() {
+	for _, r := range rs.replicas {
+		rs.check(r)
+	}
+}
+
+// check measures the replay lag of r. A replica that has replayed all the
+// WAL it received has no lag, however old its last transaction is, as long
+// as its WAL receiver is streaming; one that stopped receiving would
+// otherwise look caught up forever.
+func (rs *replicaSet) check(r *replica) {
+	ctx, cancel := context.WithTimeout(context.Background(), replicaCheckTimeout)
+	defer cancel()
+
+	status := ReplicaStatus{
+		Host:      r.host,
+		CheckedAt: time.Now(),
+	}
+
+	// without pg_read_all_stats pg_stat_wal_receiver shows the pid only,
+	// a running receiver is then taken as streaming
+	var lag float64
+	var receiving bool
+	err := r.pool.QueryRow(ctx, `
+		SELECT
+			CASE
+				WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+				ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+			END::float8,
+			NOT pg_is_in_recovery() OR EXISTS (
+				SELECT 1 FROM pg_stat_wal_receiver WHERE COALESCE(status, 'streaming') = 'streaming'
+			)
+	`).Scan(&lag, &receiving)
+	switch {
+	case err != nil:
+		status.Error = err.Error()
+	case !receiving:
+		status.LagMs = int64(lag * 1000)
+		status.Error = "not receiving WAL from the primary"
+	default:
+		status.LagMs = int64(lag * 1000)
+		status.Healthy = time.Duration(status.LagMs)*time.Millisecond <= rs.config.MaxLag
+	}
+
+	r.mu.Lock()
+	wasHealthy := r.status.Healthy
+	// the first check reports a replica that starts unhealthy
+	firstCheck := r.status.CheckedAt.IsZero()
+	r.status = status
+	r.mu.Unlock()
+
+	switch {
+	case (wasHealthy || firstCheck) && !status.Healthy && err != nil:
+		rs.logger.Warnf("Replica %s is unreachable, reading from the primary: %v", r.host, err)
+	case (wasHealthy || firstCheck) && !status.Healthy && !receiving:
+		rs.logger.Warnf("Replica %s is not receiving WAL from the primary, reading from the primary", r.host)
+	case (wasHealthy || firstCheck) && !status.Healthy:
+		rs.logger.Warnf("Replica %s lags %dms, reading from the primary", r.host, status.LagMs)
+	case !wasHealthy && status.Healthy:
+		rs.logger.Infof("Replica %s is healthy, lag %dms", r.host, status.LagMs)
+	}
+}
+
+func (rs *replicaSet) Status() []*ReplicaStatus {
+	statuses := make([]*ReplicaStatus, 0, len(rs.replicas))
+	for _, r := range rs.replicas {
+		r.mu.RLock()
+		status := r.status
+		r.mu.RUnlock()
+		status.Pool = newPoolStats(r.pool)
+		statuses = append(statuses, &status)
+	}
+	return statuses
+}
+
+func (rs *replicaSet) Close() {
+	close(rs.done)
+	rs.wg.Wait()
+	rs.closePools()
+}
+
+func (rs *replicaSet) closePools() {
+	for _, r := range rs.replicas {
+		r.pool.Close()
+	}
+}
+
+// reader returns where a read-only query of pgdb runs: the transaction inside
+// WithTx, the primary when ctx asks for it or no replica is healthy, and
+// otherwise the next replica
+func (pgdb *PostgresqlDB) reader(ctx context.Context) Querier {
+	if pgdb.tx != nil || pgdb.replicas == nil || primaryFromContext(ctx) {
+		return pgdb.DB
+	}
+
+	if pool := pgdb.replicas.pick(); pool != nil {
+		return pool
+	}
+	return pgdb.DB
+}
+
+// ReplicaStatus returns the last check of each replica, nil without replicas
+func (pgdb *PostgresqlDB) ReplicaStatus() []*ReplicaStatus {
+	if pgdb.replicas == nil {
+		return nil
+	}
+	return pgdb.replicas.Status()
+}
@@ -0,0 +1,392 @@
+package postgresql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+	"go-template/src/core/seed"
+)
+
+var errSeedDryRun = errors.New("seed dry run")
+
+// Seed upserts the rows of tables, in order, in one transaction. Values are
+// sent as text and cast to the column type, so fixtures need no Go types.
+// On a dry run the transaction is rolled back and the changes it would have
+// made are returned.
+func (pgdb *PostgresqlDB) Seed(ctx context.Context, tables []*seed.Table, dryRun bool) ([]*seed.Change, error) {
+	var changes []*seed.Change
+
+	err := pgdb.WithTx(ctx, func(tx *PostgresqlDB) error {
+		s := &seeder{
+			db:    tx.DB,
+			types: make(map[string]map[string]string),
+		}
+		changes = make([]*seed.Change, 0)
+
+		for _, table := range tables {
+			tableChanges, err := s.seedTable(ctx, table)
+			if err != nil {
+				return err
+			}
+			changes = append(changes, tableChanges...)
+		}
+
+		if dryRun {
+			return errSeedDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errSeedDryRun) {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+type seeder struct {
+	db Querier
+	// column types by table, nil for a missing table
+	types map[string]map[string]string
+}
+
+func (s *seeder) seedTable(ctx context.Context, table *seed.Table) ([]*seed.Change, error) {
+	types, err := s.columnTypes(ctx, table.Table)
+	if err != nil {
+		return nil, err
+	}
+	if types == nil {
+		if table.IfExists {
+			return []*seed.Change{{Table: table.Table, Action: seed.ActionSkipped}}, nil
+		}
+		return nil, fmt.Errorf("seed table %s does not exist", table.Table)
+	}
+
+	changes := make([]*seed.Change, 0, len(table.Rows))
+	for i, row := range table.Rows {
+		change, err := s.seedRow(ctx, table, types, row)
+		if err != nil {
+			return nil, errors.Wrapf(err, "row %d of %s", i+1, table.Table)
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+func (s *seeder) seedRow(ctx context.Context, table *seed.Table, types map[string]string, row map[string]any) (*seed.Change, error) {
+	columns := make([]string, 0, len(row))
+	for column := range row {
+		if _, ok := types[column]; !ok {
+			return nil, fmt.Errorf("column %s does not exist", column)
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	values := make(map[string]*string, len(row))
+	for _, column := range columns {
+		value, err := s.textValue(ctx, row[column], types[column])
+		if err != nil {
+			return nil, errors.Wrapf(err, "column %s", column)
+		}
+		values[column] = value
+	}
+
+	keyParts := make([]string, 0, len(table.Key))
+	for _, column := range table.Key {
+		if values[column] == nil {
+			return nil, fmt.Errorf("key column %s is null", column)
+		}
+		keyParts = append(keyParts, column+"="+*values[column])
+	}
+
+	change := &seed.Change{
+		Table: table.Table,
+		Key:   strings.Join(keyParts, ","),
+	}
+
+	args := make([]any, 0)
+	where, whereArgs := equalsSQL(table.Key, types, values, len(args))
+	args = append(args, whereArgs...)
+
+	current := make([]string, 0, len(columns))
+	distinct := make([]string, 0, len(columns))
+	for _, column := range columns {
+		args = append(args, values[column])
+		current = append(current, quoteIdentifier(column)+"::text")
+		distinct = append(distinct, fmt.Sprintf("%s IS DISTINCT FROM $%d::text::%s", quoteIdentifier(column), len(args), types[column]))
+	}
+
+	rows, err := s.db.Query(ctx, fmt.Sprintf(
+		`SELECT ARRAY[%s], ARRAY[%s] FROM %s WHERE %s LIMIT 2`,
+		strings.Join(current, ", "),
+		strings.Join(distinct, ", "),
+		quoteTable(table.Table),
+		where,
+	), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var old []*string
+	var changed []bool
+	found := 0
+	for rows.Next() {
+		found++
+		if err := rows.Scan(&old, &changed); err != nil {
+			rows.Close()
+			return nil, err
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	switch found {
+	case 0:
+		change.Action = seed.ActionInsert
+		for _, column := range columns {
+			change.Columns = append(change.Columns, &seed.ColumnChange{Column: column, New: values[column]})
+		}
+		return change, s.insert(ctx, table.Table, types, columns, values)
+
+	case 1:
+		for i, column := range columns {
+			if changed[i] {
+				change.Columns = append(change.Columns, &seed.ColumnChange{Column: column, Old: old[i], New: values[column]})
+			}
+		}
+		if len(change.Columns) == 0 {
+			change.Action = seed.ActionUnchanged
+			return change, nil
+		}
+		change.Action = seed.ActionUpdate
+		return change, s.update(ctx, table, types, change.Columns, values)
+	}
+
+	return nil, fmt.Errorf("key %s matches more than one row", change.Key)
+}
+
+func (s *seeder) insert(ctx context.Context, table string, types map[string]string, columns []string, values map[string]*string) error {
+	names := make([]string, 0, len(columns))
+	placeholders := make([]string, 0, len(columns))
+	args := make([]any, 0, len(columns))
+	for _, column := range columns {
+		args = append(args, values[column])
+		names = append(names, quoteIdentifier(column))
+		placeholders = append(placeholders, fmt.Sprintf("$%d::text::%s", len(args), types[column]))
+	}
+
+	_, err := s.db.Exec(ctx, fmt.Sprintf(
+		`INSERT INTO %s (%s) VALUES (%s)`,
+		quoteTable(table),
+		strings.Join(names, ", "),
+		strings.Join(placeholders, ", "),
+	), args...)
+	return err
+}
+
+func (s *seeder) update(ctx context.Context, table *seed.Table, types map[string]string, changed []*seed.ColumnChange, values map[string]*string) error {
+	sets := make([]string, 0, len(changed))
+	args := make([]any, 0)
+	for _, column := range changed {
+		args = append(args, values[column.Column])
+		sets = append(sets, fmt.Sprintf("%s = $%d::text::%s", quoteIdentifier(column.Column), len(args), types[column.Column]))
+	}
+
+	where, whereArgs := equalsSQL(table.Key, types, values, len(args))
+	args = append(args, whereArgs...)
+
+	_, err := s.db.Exec(ctx, fmt.Sprintf(
+		`UPDATE %s SET %s WHERE %s`,
+		quoteTable(table.Table),
+		strings.Join(sets, ", "),
+		where,
+	), args...)
+	return err
+}
+
+// textValue converts a fixture value to the text form of a column of
+// columnType, resolving references
+func (s *seeder) textValue(ctx context.Context, value any, columnType string) (*string, error) {
+	ref, isRef, err := seed.AsRef(value)
+	if err != nil {
+		return nil, err
+	}
+	if isRef {
+		return s.resolve(ctx, ref)
+	}
+
+	var text string
+	switch value := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		text = value
+	case time.Time:
+		text = value.Format(time.RFC3339Nano)
+	case []any:
+		if isJSONType(columnType) {
+			b, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			text = string(b)
+		} else {
+			text, err = arrayLiteral(value)
+			if err != nil {
+				return nil, err
+			}
+		}
+	case map[string]any:
+		b, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		text = string(b)
+	default:
+		text = fmt.Sprint(value)
+	}
+	return &text, nil
+}
+
+// resolve returns the text form of ref.Column of the one row matching ref
+func (s *seeder) resolve(ctx context.Context, ref *seed.Ref) (*string, error) {
+	types, err := s.columnTypes(ctx, ref.Table)
+	if err != nil {
+		return nil, err
+	}
+	if types == nil {
+		return nil, fmt.Errorf("referenced table %s does not exist", ref.Table)
+	}
+	if _, ok := types[ref.Column]; !ok {
+		return nil, fmt.Errorf("referenced column %s.%s does not exist", ref.Table, ref.Column)
+	}
+
+	columns := make([]string, 0, len(ref.Where))
+	values := make(map[string]*string, len(ref.Where))
+	for column, value := range ref.Where {
+		if _, ok := types[column]; !ok {
+			return nil, fmt.Errorf("referenced column %s.%s does not exist", ref.Table, column)
+		}
+		text, err := s.textValue(ctx, value, types[column])
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+		values[column] = text
+	}
+	sort.Strings(columns)
+
+	where, args := equalsSQL(columns, types, values, 0)
+	rows, err := s.db.Query(ctx, fmt.Sprintf(
+		`SELECT %s::text FROM %s WHERE %s LIMIT 2`,
+		quoteIdentifier(ref.Column),
+		quoteTable(ref.Table),
+		where,
+	), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := pgx.CollectRows(rows, pgx.RowTo[*string])
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) != 1 {
+		return nil, fmt.Errorf("reference to %s %v matches %d rows, expected 1", ref.Table, ref.Where, len(matches))
+	}
+	return matches[0], nil
+}
+
+// columnTypes returns the type of every column of table, nil when the table
+// does not exist
+func (s *seeder) columnTypes(ctx context.Context, table string) (map[string]string, error) {
+	if types, ok := s.types[table]; ok {
+		return types, nil
+	}
+
+	var exists bool
+	if err := s.db.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, quoteTable(table)).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		s.types[table] = nil
+		return nil, nil
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT attname, format_type(atttypid, atttypmod)
+		FROM pg_attribute
+		WHERE attrelid = to_regclass($1) AND attnum > 0 AND NOT attisdropped
+	`, quoteTable(table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	types := make(map[string]string)
+	for rows.Next() {
+		var column, columnType string
+		if err := rows.Scan(&column, &columnType); err != nil {
+			return nil, err
+		}
+		types[column] = columnType
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	s.types[table] = types
+	return types, nil
+}
+
+// equalsSQL matches every column to its value, numbering placeholders after
+// offset
+func equalsSQL(columns []string, types map[string]string, values map[string]*string, offset int) (string, []any) {
+	conditions := make([]string, 0, len(columns))
+	args := make([]any, 0, len(columns))
+	for _, column := range columns {
+		args = append(args, values[column])
+		conditions = append(conditions, fmt.Sprintf("%s = $%d::text::%s", quoteIdentifier(column), offset+len(args), types[column]))
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+func quoteIdentifier(name string) string {
+	return pgx.Identifier{name}.Sanitize()
+}
+
+// quoteTable quotes table, which may be schema qualified
+func quoteTable(table string) string {
+	return pgx.Identifier(strings.Split(table, ".")).Sanitize()
+}
+
+func isJSONType(columnType string) bool {
+	return columnType == "json" || columnType == "jsonb"
+}
+
+// arrayLiteral formats values as a Postgres array literal, e.g. {"a","b"}
+func arrayLiteral(values []any) (string, error) {
+	elements := make([]string, 0, len(values))
+	for _, value := range values {
+		switch value := value.(type) {
+		case nil:
+			elements = append(elements, "NULL")
+		case []any, map[string]any:
+			return "", errors.New("nested arrays are not supported, use a JSON column")
+		default:
+			text := fmt.Sprint(value)
+			text = strings.ReplaceAll(text, `\`, `\\`)
+			text = strings.ReplaceAll(text, `"`, `\"`)
+			elements = append(elements, `"`+text+`"`)
+		}
+	}
+	return "{" + strings.Join(elements, ",") + "}", nil
+}
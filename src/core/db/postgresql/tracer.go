@@ -0,0 +1,137 @@
+package postgresql
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "go-template/src/core/db/postgresql"
+
+var (
+	sqlStringLiteral  = regexp.MustCompile(`'(?:[^']|'')*'`)
+	sqlNumericLiteral = regexp.MustCompile(`([^$\w.])\d+(?:\.\d+)?\b`)
+	sqlWhitespace     = regexp.MustCompile(`\s+`)
+)
+
+// QueryTracer emits an OpenTelemetry span for every query, batch and copy.
+// It is meant to be combined with tracelog.TraceLog through multitracer.
+type QueryTracer struct {
+	tracer       trace.Tracer
+	databaseName string
+	host         string
+}
+
+func NewQueryTracer(config *Config) *QueryTracer {
+	return &QueryTracer{
+		tracer:       otel.Tracer(tracerName),
+		databaseName: config.DatabaseName,
+		host:         config.Host,
+	}
+}
+
+// sanitizeSQL strips literal values from a statement so span attributes never
+// carry user data. Bind parameters ($1, $2, ...) are left untouched.
+func sanitizeSQL(sql string) string {
+	sql = sqlStringLiteral.ReplaceAllString(sql, "?")
+	sql = sqlNumericLiteral.ReplaceAllString(sql, "${1}?")
+	return strings.TrimSpace(sqlWhitespace.ReplaceAllString(sql, " "))
+}
+
+func sqlOperation(sql string) string {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToUpper(fields[0])
+}
+
+func (t *QueryTracer) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) context.Context {
+	attrs = append(attrs,
+		semconv.DBSystemPostgreSQL,
+		semconv.DBNameKey.String(t.databaseName),
+		semconv.NetPeerNameKey.String(t.host),
+	)
+
+	ctx, _ = t.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	return ctx
+}
+
+func endSpan(ctx context.Context, rowsAffected int64, err error) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.Int64("db.rows_affected", rowsAffected))
+	if err != nil && err != pgx.ErrNoRows {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (t *QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	statement := sanitizeSQL(data.SQL)
+	operation := sqlOperation(statement)
+
+	name := "postgresql.query"
+	if operation != "" {
+		name = "postgresql." + strings.ToLower(operation)
+	}
+
+	return t.startSpan(ctx, name,
+		semconv.DBStatementKey.String(statement),
+		semconv.DBOperationKey.String(operation),
+	)
+}
+
+func (t *QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	endSpan(ctx, data.CommandTag.RowsAffected(), data.Err)
+}
+
+func (t *QueryTracer) TraceBatchStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchStartData) context.Context {
+	size := 0
+	if data.Batch != nil {
+		size = data.Batch.Len()
+	}
+
+	return t.startSpan(ctx, "postgresql.batch",
+		semconv.DBOperationKey.String("BATCH"),
+		attribute.Int("db.batch_size", size),
+	)
+}
+
+func (t *QueryTracer) TraceBatchQuery(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchQueryData) {
+	span := trace.SpanFromContext(ctx)
+	attrs := []attribute.KeyValue{
+		semconv.DBStatementKey.String(sanitizeSQL(data.SQL)),
+		attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()),
+	}
+	if data.Err != nil {
+		span.RecordError(data.Err, trace.WithAttributes(attrs...))
+		return
+	}
+	span.AddEvent("batch.query", trace.WithAttributes(attrs...))
+}
+
+func (t *QueryTracer) TraceBatchEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchEndData) {
+	endSpan(ctx, 0, data.Err)
+}
+
+func (t *QueryTracer) TraceCopyFromStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	return t.startSpan(ctx, "postgresql.copy_from",
+		semconv.DBOperationKey.String("COPY"),
+		semconv.DBSQLTableKey.String(data.TableName.Sanitize()),
+	)
+}
+
+func (t *QueryTracer) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	endSpan(ctx, data.CommandTag.RowsAffected(), data.Err)
+}
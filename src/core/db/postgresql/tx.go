@@ -0,0 +1,188 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+)
+
+// Querier is implemented by both *pgxpool.Pool and pgx.Tx, so repository
+// methods run unchanged inside WithTx
+type Querier interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+type IsolationLevel string
+
+const (
+	ReadCommitted  IsolationLevel = "read committed"
+	RepeatableRead IsolationLevel = "repeatable read"
+	Serializable   IsolationLevel = "serializable"
+)
+
+// TxOptions apply to the outermost transaction only, a nested WithTx is a
+// savepoint of it
+type TxOptions struct {
+	IsolationLevel IsolationLevel
+	ReadOnly       bool
+	// MaxRetries reruns the whole transaction after a serialization failure
+	// or a deadlock, so fn must be safe to run more than once
+	MaxRetries int
+}
+
+type TxOption func(options *TxOptions)
+
+func WithIsolationLevel(level IsolationLevel) TxOption {
+	return func(options *TxOptions) {
+		options.IsolationLevel = level
+	}
+}
+
+func WithReadOnly() TxOption {
+	return func(options *TxOptions) {
+		options.ReadOnly = true
+	}
+}
+
+func WithMaxRetries(maxRetries int) TxOption {
+	return func(options *TxOptions) {
+		options.MaxRetries = maxRetries
+	}
+}
+
+const (
+	sqlStateSerializationFailure = "40001"
+	sqlStateDeadlockDetected     = "40P01"
+
+	txRetryBaseDelay = 10 * time.Millisecond
+)
+
+// WithTx runs fn in a transaction, committing when it returns nil and rolling
+// back when it returns an error or panics. The tx passed to fn has every
+// repository method of pgdb; calling WithTx on it opens a savepoint.
+func (pgdb *PostgresqlDB) WithTx(ctx context.Context, fn func(tx *PostgresqlDB) error, opts ...TxOption) error {
+	options := TxOptions{
+		IsolationLevel: pgdb.Config.Transaction.IsolationLevel,
+		MaxRetries:     pgdb.Config.Transaction.MaxRetries,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if pgdb.tx != nil {
+		return pgdb.runTx(ctx, pgx.TxOptions{}, fn)
+	}
+
+	txOptions := pgx.TxOptions{
+		IsoLevel: pgx.TxIsoLevel(options.IsolationLevel),
+	}
+	if options.ReadOnly {
+		txOptions.AccessMode = pgx.ReadOnly
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := pgdb.runTx(ctx, txOptions, fn)
+		if err == nil || attempt >= options.MaxRetries || !isRetryableTxError(err) {
+			return err
+		}
+
+		delay := txRetryBaseDelay<<attempt + time.Duration(rand.Int63n(int64(txRetryBaseDelay)))
+		pgdb.logger.Warnf("Transaction failed, retrying in %s (%d/%d): %v", delay, attempt+1, options.MaxRetries, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (pgdb *PostgresqlDB) runTx(ctx context.Context, txOptions pgx.TxOptions, fn func(tx *PostgresqlDB) error) (err error) {
+	tx, err := pgdb.beginTx(ctx, txOptions)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback(ctx)
+			panic(p)
+		}
+		if err != nil {
+			_ = tx.Rollback(ctx)
+			return
+		}
+		if err = tx.Commit(ctx); err != nil {
+			err = errors.Wrap(err, "Unable to commit a transaction")
+		}
+	}()
+
+	return fn(pgdb.withTx(tx))
+}
+
+// withTx returns a copy of pgdb whose queries run in tx. Activity logs are
+// written in tx too, so a rollback drops them and a retry does not queue
+// them twice.
+func (pgdb *PostgresqlDB) withTx(tx pgx.Tx) *PostgresqlDB {
+	txdb := *pgdb
+	txdb.DB = tx
+	txdb.tx = tx
+	txdb.activityLogWriter = nil
+	return &txdb
+}
+
+// beginTx starts a transaction, or a savepoint inside one, tagged with the
+// actor of ctx
+func (pgdb *PostgresqlDB) beginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	var tx pgx.Tx
+	var err error
+	if pgdb.tx != nil {
+		tx, err = pgdb.tx.Begin(ctx)
+	} else {
+		tx, err = pgdb.pool.BeginTx(ctx, txOptions)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to make a transaction")
+	}
+
+	if actor, ok := actorFromContext(ctx); ok {
+		_, err = tx.Exec(ctx, `SELECT set_config('app.user_id', $1, true), set_config('app.request_no', $2, true)`,
+			actor.UserID, actor.RequestNo)
+		if err != nil {
+			_ = tx.Rollback(ctx)
+			return nil, errors.Wrap(err, "Unable to set the acting user")
+		}
+	}
+
+	return tx, nil
+}
+
+func isRetryableTxError(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == sqlStateSerializationFailure || pgErr.Code == sqlStateDeadlockDetected
+}
+
+func parseIsolationLevel(level string) (IsolationLevel, error) {
+	switch IsolationLevel(level) {
+	case "":
+		return "", nil
+	case ReadCommitted, RepeatableRead, Serializable:
+		return IsolationLevel(level), nil
+	default:
+		return "", fmt.Errorf("invalid Database.Transaction.IsolationLevel %q, expected %q, %q or %q",
+			level, ReadCommitted, RepeatableRead, Serializable)
+	}
+}
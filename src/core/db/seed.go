@@ -0,0 +1,13 @@
+package db
+
+import (
+	"context"
+
+	"go-template/src/core/seed"
+)
+
+type DBSeedInterface interface {
+	// Seed upserts tables in one transaction and returns the change of
+	// every row; a dry run rolls the transaction back
+	Seed(ctx context.Context, tables []*seed.Table, dryRun bool) ([]*seed.Change, error)
+}
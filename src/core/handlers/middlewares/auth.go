@@ -5,6 +5,7 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"go-template/src/core/handlers/render"
+	"go-template/src/core/log"
 	"go-template/src/core/utils"
 	"go-template/src/service"
 )
@@ -25,7 +26,7 @@ func RequiredAuth(sv *service.Service) fiber.Handler {
 		var profilePic string
 
 		// FIXME: Change to config (Key expire time)
-		apiKeyData, err := ctx.DB.VerifyApiKey(bearerToken, time.Now().Add(time.Minute*time.Duration(10000)))
+		apiKeyData, err := ctx.DB.VerifyApiKey(ctx.DBContext(), bearerToken, time.Now().Add(time.Minute*time.Duration(10000)))
 		if err != nil {
 			return render.Error(c, fiber.ErrUnauthorized)
 		}
@@ -50,6 +51,19 @@ func RequiredAuth(sv *service.Service) fiber.Handler {
 			sv.EmailAddress = emailAddress
 			sv.ProfilePic = profilePic
 
+			c.Locals(service.UserKey, &service.AuthUser{
+				UserID:       userID,
+				AzureUserID:  azureUserID,
+				Role:         role,
+				EmailAddress: emailAddress,
+				ProfilePic:   profilePic,
+			})
+
+			c.SetUserContext(log.ContextWithFields(c.UserContext(), log.Fields{
+				"user_id":       userID,
+				"azure_user_id": azureUserID,
+			}))
+
 			return c.Next()
 		}
 
@@ -2,14 +2,20 @@ package middlewares
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"go-template/src/core/log"
+	"go-template/src/core/model"
+	"go-template/src/otel"
 	"go-template/src/service"
 )
 
@@ -17,14 +23,71 @@ type contextKey string
 
 const contextKeyTraceID contextKey = "TraceID"
 const contextKeySpanID contextKey = "SpanID"
+const contextKeyRequestNo contextKey = "RequestNo"
 
-func CorrelationMiddleware(sv *service.Service) fiber.Handler {
+const (
+	HeaderRequestNo   = "X-Request-No"
+	HeaderTraceID     = "X-Trace-Id"
+	HeaderTraceParent = "traceparent"
+)
+
+// RequestNoPropagationMiddleware lets callers that only send the legacy
+// x-request-no header join a trace: when no traceparent is present and the
+// request number is a UUID, it is used as the trace ID. The trace is not
+// flagged sampled, the local sampler decides. Must run before
+// otel.Middleware.
+func RequestNoPropagationMiddleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		traceID := c.Get("x-request-no")
+		if c.Get(HeaderTraceParent) != "" {
+			return c.Next()
+		}
+
+		requestNo, err := uuid.Parse(c.Get(HeaderRequestNo))
+		if err != nil {
+			return c.Next()
+		}
+
+		spanID := make([]byte, 8)
+		if _, err := rand.Read(spanID); err != nil {
+			return c.Next()
+		}
+
+		c.Request().Header.Set(HeaderTraceParent, fmt.Sprintf(
+			"00-%s-%s-00",
+			hex.EncodeToString(requestNo[:]),
+			hex.EncodeToString(spanID),
+		))
+		c.SetUserContext(otel.ContextWithAdoptedTrace(c.UserContext()))
+
+		return c.Next()
+	}
+}
+
+// CorrelationMiddleware takes the trace and span IDs from the active OTel
+// span and the request number from x-request-no, generating one from the
+// trace ID if absent. Both are echoed in the response headers.
+func CorrelationMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		traceID, spanID := log.TraceIDsFromContext(c.UserContext())
+
+		requestNo := c.Get(HeaderRequestNo)
+		if requestNo == "" {
+			requestNo = requestNoFromTraceID(traceID)
+		}
+
 		c.Locals(contextKeyTraceID, traceID)
-		c.Locals(contextKeySpanID, uuid.NewString())
-		sv.SpanID = GetSpanID(c)
-		sv.TraceID = GetTraceID(c)
+		c.Locals(contextKeySpanID, spanID)
+		c.Locals(contextKeyRequestNo, requestNo)
+		userCtx := log.ContextWithRequestNo(c.UserContext(), requestNo)
+		userCtx = log.ContextWithFields(userCtx, log.Fields{
+			"route": c.Method() + " " + c.Path(),
+		})
+		c.SetUserContext(userCtx)
+
+		c.Set(HeaderRequestNo, requestNo)
+		if traceID != "" {
+			c.Set(HeaderTraceID, traceID)
+		}
 
 		routeName := c.Route().Name
 		if routeName != "" {
@@ -35,6 +98,21 @@ func CorrelationMiddleware(sv *service.Service) fiber.Handler {
 	}
 }
 
+// requestNoFromTraceID formats a trace ID as a UUID so it fits the
+// activity_log request_no column, or generates a new one without a trace.
+func requestNoFromTraceID(traceID string) string {
+	b, err := hex.DecodeString(traceID)
+	if err != nil || len(b) != 16 {
+		return uuid.NewString()
+	}
+
+	id, err := uuid.FromBytes(b)
+	if err != nil {
+		return uuid.NewString()
+	}
+	return id.String()
+}
+
 func ServiceCodeMiddleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		// Proceed to the next handler in the chain
@@ -65,43 +143,63 @@ func GetTraceID(c *fiber.Ctx) string {
 	return ""
 }
 
+func GetRequestNo(c *fiber.Ctx) string {
+	requestNo := c.Locals(contextKeyRequestNo)
+	if ret, ok := requestNo.(string); ok {
+		return ret
+	}
+	return ""
+}
+
 func LoggingMiddleware(sv *service.Service) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		if c.OriginalURL() != "/api/health-check" {
 			startTime := time.Now()
-			appCtx := sv.NewContext(c)
-			logger := appCtx.Logger.WithFields(log.Fields{
+			logger := sv.Logger.WithContext(c.UserContext()).WithFields(log.Fields{
 				"package":   "http_api",
 				"remote_ip": c.Context().RemoteIP().String(),
 				"method":    c.Method(),
 				"path":      c.OriginalURL(),
-				"span_id":   GetSpanID(c),
-				"trace_id":  GetTraceID(c),
 			})
 
 			c.Next()
 
+			// created after the handlers so it carries the authenticated user
+			appCtx := sv.NewContext(c)
+
 			duration := time.Since(startTime)
 			statusCode := c.Response().StatusCode()
 			logger = logger.WithFields(log.Fields{
 				"duration":    duration.String(),
 				"status_code": statusCode,
 			})
-			reqBody := masking(CompactJSON(c.Request().Body()))
-			resBody := masking(c.Response().Body())
+			redactor := getRedactor(c, sv.Redactor)
+			reqBody := masking(redactor.RequestBody(textBody(CompactJSON(c.Request().Body()))))
+			resBody := masking(redactor.ResponseBody(c.Response().Body()))
 
-			logger.Debugf("JSON Request: %s", CompactJSON(c.Request().Body()))
-			logger.Debugf("JSON Response %s", c.Response().Body())
+			logger.Debug("Request headers", "headers", redactor.Headers(requestHeaders(c)))
+			logger.Debugf("JSON Request: %s", reqBody)
+			logger.Debugf("JSON Response %s", resBody)
 
-			err := appCtx.CreateActivityLog(GetTraceID(c), reqBody, resBody)
+			err := appCtx.CreateActivityLog(&model.ActivityLog{
+				HTTPMethod:     c.Method(),
+				RequestURI:     redactor.Text(c.OriginalURL()),
+				HTTPStatusCode: statusCode,
+				ResponseCode:   responseCode(c.Response().Body()),
+				DurationMs:     duration.Milliseconds(),
+				IPAddress:      c.IP(),
+				UserAgent:      optionalString([]byte(c.Get(fiber.HeaderUserAgent))),
+				RequestBody:    optionalString(reqBody),
+				ResponseBody:   optionalString(resBody),
+			})
 			if err != nil {
 				logger.Errorf("CreateActivityLog error : %v", err)
 			}
 
 			if statusCode != http.StatusOK && statusCode != http.StatusCreated && statusCode != http.StatusAccepted {
-				logger.Errorf("%s", c.Response().Body())
+				logger.Errorf("%s", resBody)
 			}
-			logger.Infof("%s %s", c.Method(), c.OriginalURL())
+			logger.Infof("%s %s", c.Method(), redactor.Text(c.OriginalURL()))
 		} else {
 			c.Next()
 		}
@@ -110,14 +208,53 @@ func LoggingMiddleware(sv *service.Service) fiber.Handler {
 	}
 }
 
+// responseCode returns the "code" of a JSON response, see result.Result and
+// the custom_error types
+func responseCode(body []byte) *int {
+	var response struct {
+		Code *int `json:"code"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil
+	}
+	return response.Code
+}
+
+func optionalString(b []byte) *string {
+	if len(b) == 0 {
+		return nil
+	}
+	s := string(b)
+	return &s
+}
+
+// CompactJSON compacts src when it is JSON and returns anything else, form
+// and text bodies, unchanged for the redactor to mask
 func CompactJSON(src []byte) []byte {
 	var dst bytes.Buffer
 	if err := json.Compact(&dst, src); err != nil {
-		return nil
+		return src
 	}
 	return dst.Bytes()
 }
 
+// textBody replaces a binary body, e.g. a multipart upload, with its length,
+// a text column can not hold it
+func textBody(b []byte) []byte {
+	if utf8.Valid(b) && bytes.IndexByte(b, 0) < 0 {
+		return b
+	}
+	return []byte(fmt.Sprintf("binary, %v bytes", len(b)))
+}
+
+func requestHeaders(c *fiber.Ctx) map[string]string {
+	headers := make(map[string]string)
+	for key, values := range c.GetReqHeaders() {
+		headers[key] = strings.Join(values, ", ")
+	}
+	return headers
+}
+
 func masking(b []byte) []byte {
 	if len(b) > 5000 {
 		return []byte(fmt.Sprintf("length is %v bytes", len(b)))
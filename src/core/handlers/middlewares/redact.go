@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"go-template/src/core/redact"
+)
+
+const contextKeyRedactRules contextKey = "RedactRules"
+
+// routeRedaction holds the rules of one route and the redactor compiled from
+// them, so patterns are compiled once per route rather than per request
+type routeRedaction struct {
+	rules redact.Rules
+
+	mu       sync.Mutex
+	base     *redact.Redactor
+	redactor *redact.Redactor
+}
+
+func (rr *routeRedaction) get(base *redact.Redactor) *redact.Redactor {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	if rr.redactor == nil || rr.base != base {
+		rr.base = base
+		rr.redactor = base.WithRules(rr.rules)
+	}
+	return rr.redactor
+}
+
+// Redact declares route-specific redaction rules, applied on top of the
+// configured ones when LoggingMiddleware logs and stores the request
+func Redact(rules redact.Rules) fiber.Handler {
+	rr := &routeRedaction{rules: rules}
+
+	return func(c *fiber.Ctx) error {
+		c.Locals(contextKeyRedactRules, rr)
+		return c.Next()
+	}
+}
+
+func GetRedactRules(c *fiber.Ctx) (redact.Rules, bool) {
+	rr, ok := c.Locals(contextKeyRedactRules).(*routeRedaction)
+	if !ok {
+		return redact.Rules{}, false
+	}
+	return rr.rules, true
+}
+
+func getRedactor(c *fiber.Ctx, base *redact.Redactor) *redact.Redactor {
+	if rr, ok := c.Locals(contextKeyRedactRules).(*routeRedaction); ok {
+		return rr.get(base)
+	}
+	return base
+}
@@ -0,0 +1,32 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequestTimeout cancels the request context after timeout, which aborts the
+// DB queries and outbound calls made with it. A handler failing because of
+// the deadline answers 503. A route can shorten the timeout by adding its
+// own RequestTimeout, never lengthen it.
+func RequestTimeout(timeout time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if timeout <= 0 {
+			return c.Next()
+		}
+
+		ctx, cancel := context.WithTimeout(c.UserContext(), timeout)
+		defer cancel()
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+		if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fiber.NewError(fiber.StatusServiceUnavailable, "Request timeout")
+		}
+
+		return err
+	}
+}
@@ -1,19 +1,30 @@
 package routes
 
-import "github.com/spf13/viper"
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
 
 type Config struct {
 	Port int
+	// RequestTimeout cancels the context of a request running longer, 0 disables it
+	RequestTimeout time.Duration
 }
 
 func InitConfig() (*Config, error) {
 	config := &Config{
-		Port: viper.GetInt("API.HTTPServerPort"),
+		Port:           viper.GetInt("API.HTTPServerPort"),
+		RequestTimeout: viper.GetDuration("API.RequestTimeout"),
 	}
 
 	if config.Port == 0 {
 		config.Port = 9092
 	}
 
+	if !viper.IsSet("API.RequestTimeout") {
+		config.RequestTimeout = 30 * time.Second
+	}
+
 	return config, nil
 }
@@ -0,0 +1,48 @@
+package endpoint
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go-template/src/core/handlers/render"
+	"go-template/src/core/listquery"
+	"go-template/src/core/model"
+	"go-template/src/custom_error"
+	"go-template/src/service"
+)
+
+type ActivityLogEndpoint interface {
+	InquiryActivityLog(c *fiber.Ctx) error
+}
+
+type activityLogEndpoint struct {
+	Service *service.Service
+}
+
+func NewActivityLogEndpoint(sv *service.Service) ActivityLogEndpoint {
+	return &activityLogEndpoint{
+		Service: sv,
+	}
+}
+
+func (ep *activityLogEndpoint) InquiryActivityLog(c *fiber.Ctx) error {
+	ctx := ep.Service.NewContext(c)
+
+	params := &model.InquiryActivityLogParams{}
+	if err := c.QueryParser(params); err != nil {
+		return &custom_error.ValidationError{
+			Code:    custom_error.InvalidParameter,
+			Message: "Invalid query parameter",
+		}
+	}
+
+	request, err := listquery.ParseQuery(c)
+	if err != nil {
+		return err
+	}
+
+	result, pagination, err := ctx.InquiryActivityLog(*params, request)
+	if err != nil {
+		return err
+	}
+
+	return render.JSON(c, result, pagination)
+}
@@ -0,0 +1,44 @@
+package endpoint
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go-template/src/core/handlers/render"
+	"go-template/src/core/model"
+	"go-template/src/custom_error"
+	"go-template/src/service"
+)
+
+type DataChangeLogEndpoint interface {
+	InquiryDataChangeLog(c *fiber.Ctx) error
+}
+
+type dataChangeLogEndpoint struct {
+	Service *service.Service
+}
+
+func NewDataChangeLogEndpoint(sv *service.Service) DataChangeLogEndpoint {
+	return &dataChangeLogEndpoint{
+		Service: sv,
+	}
+}
+
+func (ep *dataChangeLogEndpoint) InquiryDataChangeLog(c *fiber.Ctx) error {
+	ctx := ep.Service.NewContext(c)
+
+	params := &model.InquiryDataChangeLogParams{}
+	if err := c.QueryParser(params); err != nil {
+		return &custom_error.ValidationError{
+			Code:    custom_error.InvalidParameter,
+			Message: "Invalid query parameter",
+		}
+	}
+	params.TableName = c.Params("table")
+	params.RecordID = c.Params("id")
+
+	result, pagination, err := ctx.InquiryDataChangeLog(*params)
+	if err != nil {
+		return err
+	}
+
+	return render.JSON(c, result, pagination)
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"go-template/src/core/db"
 	"go-template/src/core/handlers/render"
 	"go-template/src/service"
 	"go-template/src/version"
@@ -11,6 +12,7 @@ import (
 
 type HealthCheckEndpoint interface {
 	HealthCheck(c *fiber.Ctx) error
+	HealthCheckDetail(c *fiber.Ctx) error
 }
 
 type healthCheckEndpoint struct {
@@ -37,13 +39,35 @@ type HealthCheckServiceDetail struct {
 
 const Version = "0.0.1"
 
+// HealthCheck is public, it reports nothing but the status
 func (ep *healthCheckEndpoint) HealthCheck(c *fiber.Ctx) error {
 	return render.JSON(c, HealthCheckServiceDetail{
 		ServiceName: "go-template",
 		Status:      "Online",
+	}, nil)
+}
+
+// HealthCheckDetail adds the build, uptime and database internals, replica
+// hosts and errors included, for admins
+func (ep *healthCheckEndpoint) HealthCheckDetail(c *fiber.Ctx) error {
+	detail := HealthCheckServiceDetail{
+		ServiceName: "go-template",
+		Status:      "Online",
 		StartTime:   ep.startTime.String(),
 		UpTime:      time.Since(ep.startTime).String(),
 		Version:     Version,
 		Commit:      version.GitCommit,
-	}, nil)
+	}
+	if ep.Service != nil && ep.Service.DB != nil {
+		if stats := db.GetStats(ep.Service.DB); stats != nil {
+			detail.Data = &HealthCheckData{Database: stats}
+		}
+	}
+
+	return render.JSON(c, detail, nil)
+}
+
+// HealthCheckData reports the connections of the database, when it has pools
+type HealthCheckData struct {
+	Database *db.Stats `json:"database,omitempty"`
 }
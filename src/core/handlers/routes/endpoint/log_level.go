@@ -0,0 +1,59 @@
+package endpoint
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go-template/src/core/handlers/render"
+	"go-template/src/custom_error"
+	"go-template/src/service"
+)
+
+type LogLevelEndpoint interface {
+	GetLogLevel(c *fiber.Ctx) error
+	SetLogLevel(c *fiber.Ctx) error
+	ResetLogLevel(c *fiber.Ctx) error
+}
+
+type logLevelEndpoint struct {
+	Service *service.Service
+}
+
+func NewLogLevelEndpoint(sv *service.Service) LogLevelEndpoint {
+	return &logLevelEndpoint{
+		Service: sv,
+	}
+}
+
+func (ep *logLevelEndpoint) GetLogLevel(c *fiber.Ctx) error {
+	ctx := ep.Service.NewContext(c)
+
+	return render.JSON(c, ctx.GetLogLevel(), nil)
+}
+
+func (ep *logLevelEndpoint) SetLogLevel(c *fiber.Ctx) error {
+	ctx := ep.Service.NewContext(c)
+
+	params := &service.SetLogLevelParams{}
+	if err := c.BodyParser(params); err != nil {
+		return &custom_error.ValidationError{
+			Code:    custom_error.InvalidJSONString,
+			Message: "Invalid JSON string",
+		}
+	}
+
+	result, err := ctx.SetLogLevel(*params)
+	if err != nil {
+		return err
+	}
+
+	return render.JSON(c, result, nil)
+}
+
+func (ep *logLevelEndpoint) ResetLogLevel(c *fiber.Ctx) error {
+	ctx := ep.Service.NewContext(c)
+
+	params := &service.ResetLogLevelParams{
+		Module: c.Query("module"),
+	}
+
+	return render.JSON(c, ctx.ResetLogLevel(*params), nil)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -12,6 +13,8 @@ import (
 	"go-template/src/core/handlers/middlewares"
 	"go-template/src/core/handlers/routes/endpoint"
 	"go-template/src/core/log"
+	"go-template/src/core/model"
+	"go-template/src/core/redact"
 	"go-template/src/otel"
 	"go-template/src/service"
 )
@@ -22,10 +25,12 @@ func NewRouter(config *Config, logger log.Logger, sv *service.Service) {
 
 	app.Use(
 		cors.New(),
+		middlewares.RequestNoPropagationMiddleware(),
 		otel.Middleware(),
-		middlewares.CorrelationMiddleware(sv),
+		middlewares.CorrelationMiddleware(),
 		middlewares.LoggingMiddleware(sv),
 		middlewares.WrapError(),
+		middlewares.RequestTimeout(config.RequestTimeout),
 		middlewares.ServiceCodeMiddleware(),
 	)
 
@@ -34,6 +39,7 @@ func NewRouter(config *Config, logger log.Logger, sv *service.Service) {
 
 	// Required Role
 	requiredOS := middlewares.RequiredRoles(sv, "aaa", "bbb")
+	requiredAdmin := middlewares.RequiredRoles(sv, string(model.ROLE_ADMIN_ROOT))
 	//requiredRoot := middlewares.RequiredRoles(sv, "aaa")
 
 	// Endpoint
@@ -41,6 +47,9 @@ func NewRouter(config *Config, logger log.Logger, sv *service.Service) {
 	//paramsEndpoint := endpoint.NewParameterEndpoint(sv)
 	userEndpoint := endpoint.NewUserEndpoint(sv)
 	loginEndpoint := endpoint.NewLoginEndpoint(sv)
+	logLevelEndpoint := endpoint.NewLogLevelEndpoint(sv)
+	activityLogEndpoint := endpoint.NewActivityLogEndpoint(sv)
+	dataChangeLogEndpoint := endpoint.NewDataChangeLogEndpoint(sv)
 
 	api := app.Group("/api")
 
@@ -48,7 +57,8 @@ func NewRouter(config *Config, logger log.Logger, sv *service.Service) {
 
 	api.Post("/root-login", loginEndpoint.LoginRoot)
 
-	api.Get("/me", requiredAuth, loginEndpoint.GetMe)
+	// the profile picture is a large base64 string, keep it out of the activity log
+	api.Get("/me", middlewares.Redact(redact.Rules{OmitResponseBody: true}), requiredAuth, loginEndpoint.GetMe)
 	api.Post("/logout", requiredAuth, loginEndpoint.Logout)
 
 	// Public api but req azure AD token
@@ -67,9 +77,22 @@ func NewRouter(config *Config, logger log.Logger, sv *service.Service) {
 		user.Post("/list", userEndpoint.InquiryUserList).Name("UM02004")
 	}
 
+	admin := api.Group("admin", requiredAuth, requiredAdmin)
+	{
+		admin.Get("/log-level", logLevelEndpoint.GetLogLevel).Name("AD01001")
+		admin.Put("/log-level", logLevelEndpoint.SetLogLevel).Name("AD01002")
+		admin.Delete("/log-level", logLevelEndpoint.ResetLogLevel).Name("AD01003")
+
+		// search results are already in the activity log, do not store them again
+		admin.Get("/activity-logs", middlewares.Redact(redact.Rules{OmitResponseBody: true}), activityLogEndpoint.InquiryActivityLog).Name("AD02001")
+		admin.Get("/data-changes/:table/:id", middlewares.Redact(redact.Rules{OmitResponseBody: true}), dataChangeLogEndpoint.InquiryDataChangeLog).Name("AD03001")
+
+		admin.Get("/health-check", middlewares.Redact(redact.Rules{OmitResponseBody: true}), healthCheckEndpoint.HealthCheckDetail).Name("AD04001")
+	}
+
 	// Waiting os signal
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-c
 		_, cancel := ctx.WithTimeout(ctx.Background(), 5*time.Second)
@@ -0,0 +1,70 @@
+package listquery
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// cursors are opaque to clients: base64url of the sort and the sort values
+// of the last row returned
+type cursor struct {
+	Sort   string            `json:"s"`
+	Values []json.RawMessage `json:"v"`
+}
+
+// NextCursor returns the cursor continuing after the row whose sort values,
+// as JSON in the order of list.Sort, are values
+func (list *List) NextCursor(values []json.RawMessage) string {
+	if !list.CursorSupported() || len(values) != len(list.Sort) {
+		return ""
+	}
+
+	b, err := json.Marshal(cursor{
+		Sort:   list.sortString(),
+		Values: values,
+	})
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (list *List) decodeCursor(encoded string) ([]any, error) {
+	if !list.CursorSupported() {
+		return nil, invalid("cursor needs a sort")
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, invalid("invalid cursor")
+	}
+
+	c := cursor{}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, invalid("invalid cursor")
+	}
+	if c.Sort != list.sortString() || len(c.Values) != len(list.Sort) {
+		return nil, invalid("cursor does not match the sort")
+	}
+
+	after := make([]any, 0, len(c.Values))
+	for i, raw := range c.Values {
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.UseNumber()
+
+		var value any
+		if err := decoder.Decode(&value); err != nil || value == nil {
+			return nil, invalid("invalid cursor")
+		}
+
+		typed, err := parseValue(list.Sort[i].Type, fmt.Sprint(value))
+		if err != nil {
+			return nil, invalid("invalid cursor")
+		}
+		after = append(after, typed)
+	}
+
+	return after, nil
+}
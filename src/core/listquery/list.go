@@ -0,0 +1,281 @@
+package listquery
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultLimit = 20
+
+// List is a Request validated against a Schema, with typed values
+type List struct {
+	Filters []Filter
+	Sort    []SortKey
+	Page    int64
+	Limit   int64
+	// After holds the sort values of the row the cursor points at, nil
+	// without a cursor
+	After []any
+
+	schema *Schema
+}
+
+type Filter struct {
+	Field  string
+	Column string
+	Type   FieldType
+	Op     Op
+	// Value is a single value of Type, or a slice of them for in and nin
+	Value any
+}
+
+type SortKey struct {
+	Field  string
+	Column string
+	Type   FieldType
+	Desc   bool
+}
+
+// Keyset reports whether the list continues from a cursor. Keyset lists
+// skip the total count.
+func (list *List) Keyset() bool {
+	return list.After != nil
+}
+
+// Offset is the number of rows skipped by page
+func (list *List) Offset() int64 {
+	if list.Keyset() {
+		return 0
+	}
+	return (list.Page - 1) * list.Limit
+}
+
+// CursorSupported reports whether next cursors can be built, which needs a
+// sort. Sort keys may mix directions.
+func (list *List) CursorSupported() bool {
+	return len(list.Sort) > 0
+}
+
+// MixedDirections reports whether the sort keys are not all ascending or all
+// descending, the keyset condition then needs one branch per key
+func (list *List) MixedDirections() bool {
+	for _, key := range list.Sort {
+		if key.Desc != list.Sort[0].Desc {
+			return true
+		}
+	}
+	return false
+}
+
+// Compile validates request. Unknown fields, operators not allowed for a
+// field, unparsable values, unsortable fields and limits above MaxLimit are
+// rejected with a ValidationError.
+func (schema *Schema) Compile(request *Request) (*List, error) {
+	list := &List{
+		Page:   request.Page,
+		Limit:  request.Limit,
+		schema: schema,
+	}
+
+	if list.Page == 0 {
+		list.Page = 1
+	}
+	if list.Limit == 0 {
+		list.Limit = schema.DefaultLimit
+	}
+	if list.Limit == 0 {
+		list.Limit = defaultLimit
+	}
+	if schema.MaxLimit > 0 && list.Limit > schema.MaxLimit {
+		return nil, invalid(fmt.Sprintf("limit must be %d or less", schema.MaxLimit))
+	}
+
+	// a stable order keeps error messages and placeholders deterministic
+	fieldNames := make([]string, 0, len(request.Filter))
+	for name := range request.Filter {
+		fieldNames = append(fieldNames, name)
+	}
+	sort.Strings(fieldNames)
+
+	for _, name := range fieldNames {
+		field, ok := schema.Fields[name]
+		if !ok {
+			return nil, invalid(fmt.Sprintf("can not filter by %q", name))
+		}
+
+		ops := make([]string, 0, len(request.Filter[name]))
+		for op := range request.Filter[name] {
+			ops = append(ops, string(op))
+		}
+		sort.Strings(ops)
+
+		for _, op := range ops {
+			filter, err := compileFilter(name, field, Op(op), request.Filter[name][Op(op)])
+			if err != nil {
+				return nil, err
+			}
+			list.Filters = append(list.Filters, filter)
+		}
+	}
+
+	if err := list.compileSort(request.Sort); err != nil {
+		return nil, err
+	}
+
+	if request.Cursor != "" {
+		after, err := list.decodeCursor(request.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		list.After = after
+		list.Page = 1
+	}
+
+	return list, nil
+}
+
+func compileFilter(name string, field Field, op Op, raw any) (Filter, error) {
+	if !field.allows(op) {
+		return Filter{}, invalid(fmt.Sprintf("can not filter %q with %q", name, op))
+	}
+
+	filter := Filter{
+		Field:  name,
+		Column: field.Column,
+		Type:   field.Type,
+		Op:     op,
+	}
+
+	if op == OpIn || op == OpNotIn {
+		items, ok := raw.([]string)
+		if !ok {
+			values, isSlice := raw.([]any)
+			if !isSlice {
+				return Filter{}, invalid(fmt.Sprintf("%q %s expects a list", name, op))
+			}
+			for _, value := range values {
+				items = append(items, fmt.Sprint(value))
+			}
+		}
+		if len(items) == 0 {
+			return Filter{}, invalid(fmt.Sprintf("%q %s expects a list", name, op))
+		}
+
+		switch field.Type {
+		case Int:
+			values := make([]int64, 0, len(items))
+			for _, item := range items {
+				value, err := parseValue(Int, item)
+				if err != nil {
+					return Filter{}, invalid(fmt.Sprintf("invalid %q value %q", name, item))
+				}
+				values = append(values, value.(int64))
+			}
+			filter.Value = values
+		default:
+			filter.Value = items
+		}
+		return filter, nil
+	}
+
+	text := fmt.Sprint(raw)
+	if _, isSlice := raw.([]any); isSlice {
+		return Filter{}, invalid(fmt.Sprintf("%q %s expects a single value", name, op))
+	}
+
+	// has compares one element of the array
+	valueType := field.Type
+	if valueType == StringArray {
+		valueType = String
+	}
+
+	value, err := parseValue(valueType, text)
+	if err != nil {
+		return Filter{}, invalid(fmt.Sprintf("invalid %q value %q", name, text))
+	}
+	filter.Value = value
+
+	return filter, nil
+}
+
+func (list *List) compileSort(sortParam string) error {
+	schema := list.schema
+	if sortParam == "" {
+		sortParam = schema.DefaultSort
+	}
+
+	seen := make(map[string]bool)
+	for _, key := range strings.Split(sortParam, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+
+		desc := strings.HasPrefix(key, "-")
+		name := strings.TrimPrefix(key, "-")
+
+		field, ok := schema.Fields[name]
+		if !ok || !field.Sortable {
+			return invalid(fmt.Sprintf("can not sort by %q", name))
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		list.Sort = append(list.Sort, SortKey{
+			Field:  name,
+			Column: field.Column,
+			Type:   field.Type,
+			Desc:   desc,
+		})
+	}
+
+	if schema.TieBreaker != "" && !seen[schema.TieBreaker] {
+		field := schema.Fields[schema.TieBreaker]
+		desc := false
+		if len(list.Sort) > 0 {
+			desc = list.Sort[len(list.Sort)-1].Desc
+		}
+		list.Sort = append(list.Sort, SortKey{
+			Field:  schema.TieBreaker,
+			Column: field.Column,
+			Type:   field.Type,
+			Desc:   desc,
+		})
+	}
+
+	return nil
+}
+
+// sortString is the canonical form of the sort, stored in cursors
+func (list *List) sortString() string {
+	keys := make([]string, 0, len(list.Sort))
+	for _, key := range list.Sort {
+		if key.Desc {
+			keys = append(keys, "-"+key.Field)
+		} else {
+			keys = append(keys, key.Field)
+		}
+	}
+	return strings.Join(keys, ",")
+}
+
+func parseValue(fieldType FieldType, text string) (any, error) {
+	switch fieldType {
+	case Int:
+		return strconv.ParseInt(text, 10, 64)
+	case Bool:
+		return strconv.ParseBool(text)
+	case Time:
+		if t, err := time.Parse(time.RFC3339Nano, text); err == nil {
+			return t, nil
+		}
+		return time.Parse(time.DateOnly, text)
+	default:
+		return text, nil
+	}
+}
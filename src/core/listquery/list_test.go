@@ -0,0 +1,237 @@
+package listquery
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testSchema = &Schema{
+	Fields: map[string]Field{
+		"id":           {Column: "id", Type: Int, Sortable: true},
+		"email":        {Column: "email_address", Type: String, Ops: []Op{OpEq, OpContains}},
+		"service_code": {Column: "service_code", Type: String, Sortable: true},
+		"status":       {Column: "http_status_code", Type: Int},
+		"active":       {Column: "active", Type: Bool},
+		"roles":        {Column: "user_roles", Type: StringArray},
+		"created_time": {Column: "created_time", Type: Time, Sortable: true},
+	},
+	DefaultSort:  "-created_time",
+	TieBreaker:   "id",
+	DefaultLimit: 20,
+	MaxLimit:     100,
+}
+
+func TestCompileFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  map[string]map[Op]any
+		want    []Filter
+		wantErr bool
+	}{
+		{
+			name:   "string eq",
+			filter: map[string]map[Op]any{"email": {OpEq: "a@example.com"}},
+			want:   []Filter{{Field: "email", Column: "email_address", Type: String, Op: OpEq, Value: "a@example.com"}},
+		},
+		{
+			name:   "int range, ops in order",
+			filter: map[string]map[Op]any{"status": {OpLt: "500", OpGte: "400"}},
+			want: []Filter{
+				{Field: "status", Column: "http_status_code", Type: Int, Op: OpGte, Value: int64(400)},
+				{Field: "status", Column: "http_status_code", Type: Int, Op: OpLt, Value: int64(500)},
+			},
+		},
+		{
+			name:   "int in from the query string",
+			filter: map[string]map[Op]any{"status": {OpIn: []string{"200", "204"}}},
+			want:   []Filter{{Field: "status", Column: "http_status_code", Type: Int, Op: OpIn, Value: []int64{200, 204}}},
+		},
+		{
+			name:   "string nin from JSON",
+			filter: map[string]map[Op]any{"service_code": {OpNotIn: []any{"a", "b"}}},
+			want:   []Filter{{Field: "service_code", Column: "service_code", Type: String, Op: OpNotIn, Value: []string{"a", "b"}}},
+		},
+		{
+			name:   "date only time",
+			filter: map[string]map[Op]any{"created_time": {OpGte: "2025-01-31"}},
+			want:   []Filter{{Field: "created_time", Column: "created_time", Type: Time, Op: OpGte, Value: time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)}},
+		},
+		{
+			name:   "array has",
+			filter: map[string]map[Op]any{"roles": {OpHas: "ADMIN"}},
+			want:   []Filter{{Field: "roles", Column: "user_roles", Type: StringArray, Op: OpHas, Value: "ADMIN"}},
+		},
+		{name: "unknown field", filter: map[string]map[Op]any{"password": {OpEq: "x"}}, wantErr: true},
+		{name: "op not in the field allowlist", filter: map[string]map[Op]any{"email": {OpPrefix: "a"}}, wantErr: true},
+		{name: "op not allowed for the type", filter: map[string]map[Op]any{"status": {OpContains: "4"}}, wantErr: true},
+		{name: "unknown op", filter: map[string]map[Op]any{"status": {"like": "4"}}, wantErr: true},
+		{name: "invalid int", filter: map[string]map[Op]any{"status": {OpEq: "4xx"}}, wantErr: true},
+		{name: "invalid int in list", filter: map[string]map[Op]any{"status": {OpIn: []string{"200", "x"}}}, wantErr: true},
+		{name: "invalid bool", filter: map[string]map[Op]any{"active": {OpEq: "maybe"}}, wantErr: true},
+		{name: "invalid time", filter: map[string]map[Op]any{"created_time": {OpGte: "yesterday"}}, wantErr: true},
+		{name: "in without a list", filter: map[string]map[Op]any{"status": {OpIn: "200"}}, wantErr: true},
+		{name: "in with an empty list", filter: map[string]map[Op]any{"status": {OpIn: []any{}}}, wantErr: true},
+		{name: "eq with a list", filter: map[string]map[Op]any{"email": {OpEq: []any{"a", "b"}}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := testSchema.Compile(&Request{Filter: tt.filter})
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Compile() = %+v, want an error", list.Filters)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Compile(): %v", err)
+			}
+			if !reflect.DeepEqual(list.Filters, tt.want) {
+				t.Errorf("Compile() filters = %+v, want %+v", list.Filters, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileSortAndLimit(t *testing.T) {
+	tests := []struct {
+		name      string
+		request   Request
+		wantSort  string
+		wantLimit int64
+		wantErr   bool
+	}{
+		{name: "defaults", wantSort: "-created_time,-id", wantLimit: 20},
+		{name: "tie breaker follows the last key", request: Request{Sort: "service_code", Limit: 5}, wantSort: "service_code,id", wantLimit: 5},
+		{name: "tie breaker already sorted", request: Request{Sort: "-id,created_time"}, wantSort: "-id,created_time", wantLimit: 20},
+		{name: "duplicate key", request: Request{Sort: "id,-id"}, wantSort: "id", wantLimit: 20},
+		{name: "unsortable field", request: Request{Sort: "email"}, wantErr: true},
+		{name: "unknown field", request: Request{Sort: "-password"}, wantErr: true},
+		{name: "limit above max", request: Request{Limit: 101}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := testSchema.Compile(&tt.request)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Compile() = %s, want an error", list.sortString())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Compile(): %v", err)
+			}
+			if got := list.sortString(); got != tt.wantSort {
+				t.Errorf("sort = %s, want %s", got, tt.wantSort)
+			}
+			if list.Limit != tt.wantLimit {
+				t.Errorf("limit = %d, want %d", list.Limit, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	createdTime := time.Date(2025, 3, 1, 10, 30, 0, 123000000, time.UTC)
+
+	tests := []struct {
+		name      string
+		sort      string
+		values    []any
+		wantAfter []any
+		wantMixed bool
+	}{
+		{
+			name:      "same direction",
+			sort:      "-created_time",
+			values:    []any{createdTime, 42},
+			wantAfter: []any{createdTime, int64(42)},
+		},
+		{
+			name:      "mixed directions",
+			sort:      "service_code,-created_time",
+			values:    []any{"GT-0001", createdTime, 7},
+			wantAfter: []any{"GT-0001", createdTime, int64(7)},
+			wantMixed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, err := testSchema.Compile(&Request{Sort: tt.sort})
+			if err != nil {
+				t.Fatalf("Compile(): %v", err)
+			}
+			if first.MixedDirections() != tt.wantMixed {
+				t.Errorf("MixedDirections() = %v, want %v", first.MixedDirections(), tt.wantMixed)
+			}
+
+			values := make([]json.RawMessage, 0, len(tt.values))
+			for _, value := range tt.values {
+				b, _ := json.Marshal(value)
+				values = append(values, b)
+			}
+			cursor := first.NextCursor(values)
+			if cursor == "" {
+				t.Fatal("NextCursor() is empty")
+			}
+
+			next, err := testSchema.Compile(&Request{Sort: tt.sort, Cursor: cursor, Page: 3})
+			if err != nil {
+				t.Fatalf("Compile() with cursor: %v", err)
+			}
+			if !next.Keyset() || next.Offset() != 0 {
+				t.Errorf("Keyset() = %v, Offset() = %d, want a keyset list", next.Keyset(), next.Offset())
+			}
+			if !reflect.DeepEqual(next.After, tt.wantAfter) {
+				t.Errorf("After = %#v, want %#v", next.After, tt.wantAfter)
+			}
+		})
+	}
+}
+
+func TestCursorRejected(t *testing.T) {
+	list, err := testSchema.Compile(&Request{Sort: "-created_time"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cursor := list.NextCursor([]json.RawMessage{json.RawMessage(`"2025-03-01T10:30:00Z"`), json.RawMessage(`42`)})
+
+	tests := []struct {
+		name    string
+		request Request
+	}{
+		{name: "other sort", request: Request{Sort: "created_time", Cursor: cursor}},
+		{name: "not base64", request: Request{Cursor: "not a cursor!"}},
+		{name: "not json", request: Request{Cursor: "bm90IGpzb24"}},
+		{name: "wrong value type", request: Request{Cursor: encodeTestCursor(t, "-created_time,-id", `"2025-03-01T10:30:00Z"`, `"x"`)}},
+		{name: "null value", request: Request{Cursor: encodeTestCursor(t, "-created_time,-id", `null`, `42`)}},
+		{name: "missing value", request: Request{Cursor: encodeTestCursor(t, "-created_time,-id", `"2025-03-01T10:30:00Z"`)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := testSchema.Compile(&tt.request); err == nil {
+				t.Errorf("Compile(%+v) accepted the cursor", tt.request)
+			}
+		})
+	}
+}
+
+func encodeTestCursor(t *testing.T, sort string, values ...string) string {
+	t.Helper()
+
+	c := cursor{Sort: sort}
+	for _, value := range values {
+		c.Values = append(c.Values, json.RawMessage(value))
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
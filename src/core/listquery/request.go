@@ -0,0 +1,119 @@
+package listquery
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go-template/src/custom_error"
+)
+
+// Request is a list request before validation, from the query string or the
+// JSON body:
+//
+//	?filter[email_address][contains]=som&filter[http_status_code][gte]=400&sort=-created_time&limit=50
+//	{"filter": {"email_address": {"contains": "som"}}, "sort": "-created_time", "limit": 50}
+//
+// Without an operator, filter[field]=value means eq. The values of in and
+// nin are comma separated in the query string and an array in JSON.
+type Request struct {
+	Filter map[string]map[Op]any `json:"filter"`
+	Sort   string                `json:"sort"`
+	Page   int64                 `json:"page"`
+	Limit  int64                 `json:"limit"`
+	Cursor string                `json:"cursor"`
+}
+
+var filterKeyPattern = regexp.MustCompile(`^filter\[([^\[\]]+)\](?:\[([^\[\]]+)\])?$`)
+
+// ParseQuery reads a Request from the query string of c
+func ParseQuery(c *fiber.Ctx) (*Request, error) {
+	values, err := url.ParseQuery(string(c.Request().URI().QueryString()))
+	if err != nil {
+		return nil, invalid("Invalid query parameter")
+	}
+	return ParseValues(values)
+}
+
+// ParseValues reads a Request from query string values. Parameters other
+// than filter, sort, page, limit and cursor are ignored.
+func ParseValues(values url.Values) (*Request, error) {
+	request := &Request{
+		Filter: make(map[string]map[Op]any),
+		Sort:   values.Get("sort"),
+		Cursor: values.Get("cursor"),
+	}
+
+	var err error
+	if request.Page, err = parseCount(values.Get("page"), "page"); err != nil {
+		return nil, err
+	}
+	if request.Limit, err = parseCount(values.Get("limit"), "limit"); err != nil {
+		return nil, err
+	}
+
+	for key, value := range values {
+		match := filterKeyPattern.FindStringSubmatch(key)
+		if match == nil {
+			continue
+		}
+
+		field, op := match[1], Op(match[2])
+		if op == "" {
+			op = OpEq
+		}
+		if request.Filter[field] == nil {
+			request.Filter[field] = make(map[Op]any)
+		}
+
+		raw := value[len(value)-1]
+		if op == OpIn || op == OpNotIn {
+			request.Filter[field][op] = strings.Split(raw, ",")
+		} else {
+			request.Filter[field][op] = raw
+		}
+	}
+
+	return request, nil
+}
+
+// ParseBody reads a Request from the JSON body of c
+func ParseBody(c *fiber.Ctx) (*Request, error) {
+	request := &Request{}
+	if len(c.Body()) == 0 {
+		return request, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(c.Body()))
+	decoder.UseNumber()
+	if err := decoder.Decode(request); err != nil {
+		return nil, &custom_error.ValidationError{
+			Code:    custom_error.InvalidJSONString,
+			Message: "Invalid JSON string",
+		}
+	}
+	return request, nil
+}
+
+func parseCount(value string, name string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, invalid(fmt.Sprintf("%s must be a positive integer", name))
+	}
+	return n, nil
+}
+
+func invalid(message string) *custom_error.ValidationError {
+	return &custom_error.ValidationError{
+		Code:    custom_error.InvalidParameter,
+		Message: message,
+	}
+}
@@ -0,0 +1,74 @@
+package listquery
+
+// FieldType decides how filter and cursor values are parsed
+type FieldType int
+
+const (
+	String FieldType = iota
+	Int
+	Time
+	Bool
+	// StringArray is a text[] column, filtered with has
+	StringArray
+)
+
+// Op is a filter operator, ?filter[field][op]=value
+type Op string
+
+const (
+	OpEq       Op = "eq"
+	OpNe       Op = "ne"
+	OpLt       Op = "lt"
+	OpLte      Op = "lte"
+	OpGt       Op = "gt"
+	OpGte      Op = "gte"
+	OpIn       Op = "in"
+	OpNotIn    Op = "nin"
+	OpContains Op = "contains"
+	OpPrefix   Op = "prefix"
+	OpHas      Op = "has"
+)
+
+var defaultOps = map[FieldType][]Op{
+	String:      {OpEq, OpNe, OpIn, OpNotIn, OpContains, OpPrefix},
+	Int:         {OpEq, OpNe, OpLt, OpLte, OpGt, OpGte, OpIn, OpNotIn},
+	Time:        {OpEq, OpLt, OpLte, OpGt, OpGte},
+	Bool:        {OpEq},
+	StringArray: {OpHas},
+}
+
+// Field is a field a list can be filtered or sorted by
+type Field struct {
+	// Column is the column of the listed table, a plain identifier
+	Column string
+	Type   FieldType
+	// Ops restricts the operators, nil allows every operator of Type
+	Ops []Op
+	// Sortable fields should be NOT NULL, cursors can not continue after a NULL
+	Sortable bool
+}
+
+func (f Field) allows(op Op) bool {
+	ops := f.Ops
+	if ops == nil {
+		ops = defaultOps[f.Type]
+	}
+	for _, allowed := range ops {
+		if allowed == op {
+			return true
+		}
+	}
+	return false
+}
+
+// Schema describes the fields of a resource accepted from list requests
+type Schema struct {
+	Fields map[string]Field
+	// DefaultSort is used without a sort parameter, e.g. "-created_time"
+	DefaultSort string
+	// TieBreaker is a unique sortable field appended to every sort so the
+	// order, and the cursors built from it, are stable
+	TieBreaker   string
+	DefaultLimit int64
+	MaxLimit     int64
+}
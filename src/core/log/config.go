@@ -1,12 +1,18 @@
 package log
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/spf13/viper"
+	lumberjack "gopkg.in/natefinch/lumberjack.v2"
 )
 
 // Configuration stores the config for the logger
 // For some loggers there can only be one level across writers, for such the level of Console is picked by default
 type Configuration struct {
+	// Instance selects the backend, InstanceZapLogger or InstanceLogrusLogger
+	Instance          int
 	EnableConsole     bool
 	ConsoleJSONFormat bool
 	ConsoleLevel      string
@@ -15,25 +21,108 @@ type Configuration struct {
 	FileLevel         string
 	FileLocation      string
 	Color             bool
+
+	// Rotation of the log file, see lumberjack.Logger
+	FileMaxSizeMB  int
+	FileMaxBackups int
+	FileMaxAgeDays int
+	FileCompress   bool
+
+	Sampling SamplingConfiguration
+
+	// RuntimeLevelTTL is how long a level changed at runtime (admin endpoint
+	// or SIGUSR1) stays in effect before reverting
+	RuntimeLevelTTL time.Duration
+	// SignalModules are the modules raised to debug by SIGUSR1; the global
+	// level is raised when empty
+	SignalModules []string
+}
+
+// SamplingConfiguration limits repeated messages: within every Tick, the
+// first Initial entries with the same level and message are logged, then
+// only every Thereafter-th one
+type SamplingConfiguration struct {
+	Enabled    bool
+	Initial    int
+	Thereafter int
+	Tick       time.Duration
 }
 
 func InitConfig() (*Configuration, error) {
+	viper.SetDefault("Log.Backend", "zap")
+	viper.SetDefault("Log.Console.Enabled", true)
+	viper.SetDefault("Log.File.Location", "log/app.log")
+	viper.SetDefault("Log.File.JSON", true)
+	viper.SetDefault("Log.File.MaxSizeMB", 100)
+	viper.SetDefault("Log.File.MaxAgeDays", 28)
+	viper.SetDefault("Log.File.Compress", true)
+	viper.SetDefault("Log.Sampling.Initial", 100)
+	viper.SetDefault("Log.Sampling.Thereafter", 100)
+	viper.SetDefault("Log.Sampling.Tick", time.Second)
+	viper.SetDefault("Log.Runtime.TTL", 15*time.Minute)
+
 	logLevel := viper.GetString("Log.Level")
 	logColor := viper.GetBool("Log.Color")
 	logJSON := viper.GetBool("Log.JSON")
 
 	logLevel = NormalizeLogLevel(logLevel)
 
+	fileLevel := viper.GetString("Log.File.Level")
+	if fileLevel == "" {
+		fileLevel = logLevel
+	}
+
+	var instance int
+	switch backend := viper.GetString("Log.Backend"); backend {
+	case "zap":
+		instance = InstanceZapLogger
+	case "logrus":
+		instance = InstanceLogrusLogger
+	default:
+		return nil, fmt.Errorf("unsupported Log.Backend %q, expected zap or logrus", backend)
+	}
+
 	config := &Configuration{
-		EnableConsole:     true,
+		Instance:          instance,
+		EnableConsole:     viper.GetBool("Log.Console.Enabled"),
 		ConsoleLevel:      logLevel,
 		Color:             logColor,
 		ConsoleJSONFormat: logJSON,
+		EnableFile:        viper.GetBool("Log.File.Enabled"),
+		FileJSONFormat:    viper.GetBool("Log.File.JSON"),
+		FileLevel:         NormalizeLogLevel(fileLevel),
+		FileLocation:      viper.GetString("Log.File.Location"),
+		FileMaxSizeMB:     viper.GetInt("Log.File.MaxSizeMB"),
+		FileMaxBackups:    viper.GetInt("Log.File.MaxBackups"),
+		FileMaxAgeDays:    viper.GetInt("Log.File.MaxAgeDays"),
+		FileCompress:      viper.GetBool("Log.File.Compress"),
+		Sampling: SamplingConfiguration{
+			Enabled:    viper.GetBool("Log.Sampling.Enabled"),
+			Initial:    viper.GetInt("Log.Sampling.Initial"),
+			Thereafter: viper.GetInt("Log.Sampling.Thereafter"),
+			Tick:       viper.GetDuration("Log.Sampling.Tick"),
+		},
+		RuntimeLevelTTL: viper.GetDuration("Log.Runtime.TTL"),
+		SignalModules:   viper.GetStringSlice("Log.Runtime.SignalModules"),
+	}
+
+	if config.EnableFile && config.FileLocation == "" {
+		return nil, fmt.Errorf("Log.File.Location must be set when Log.File.Enabled is true")
 	}
 
 	return config, nil
 }
 
+func (config Configuration) newFileWriter() *lumberjack.Logger {
+	return &lumberjack.Logger{
+		Filename:   config.FileLocation,
+		MaxSize:    config.FileMaxSizeMB,
+		MaxBackups: config.FileMaxBackups,
+		MaxAge:     config.FileMaxAgeDays,
+		Compress:   config.FileCompress,
+	}
+}
+
 func NormalizeLogLevel(logLevel string) string {
 	var normalizedLogLevel string
 	switch logLevel {
@@ -0,0 +1,84 @@
+package log
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type contextKey string
+
+const contextKeyRequestNo contextKey = "RequestNo"
+const contextKeyFields contextKey = "Fields"
+
+// ContextWithRequestNo stores the legacy request number (x-request-no) in ctx
+func ContextWithRequestNo(ctx context.Context, requestNo string) context.Context {
+	return context.WithValue(ctx, contextKeyRequestNo, requestNo)
+}
+
+// RequestNoFromContext returns the request number stored by ContextWithRequestNo
+func RequestNoFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if requestNo, ok := ctx.Value(contextKeyRequestNo).(string); ok {
+		return requestNo
+	}
+	return ""
+}
+
+// ContextWithFields returns a copy of ctx carrying fields, merged with the
+// fields already stored in ctx. Loggers obtained with WithContext add them to
+// every line, e.g. the user ID set by the auth middleware.
+func ContextWithFields(ctx context.Context, fields Fields) context.Context {
+	merged := Fields{}
+	for k, v := range fieldsFromContext(ctx) {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, contextKeyFields, merged)
+}
+
+func fieldsFromContext(ctx context.Context) Fields {
+	if ctx == nil {
+		return nil
+	}
+	if fields, ok := ctx.Value(contextKeyFields).(Fields); ok {
+		return fields
+	}
+	return nil
+}
+
+// TraceIDsFromContext returns the trace and span IDs of the active OpenTelemetry span
+func TraceIDsFromContext(ctx context.Context) (traceID string, spanID string) {
+	if ctx == nil {
+		return "", ""
+	}
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return "", ""
+	}
+	return sc.TraceID().String(), sc.SpanID().String()
+}
+
+// ContextFields returns the correlation fields found in ctx: trace and span
+// IDs, request number and anything stored with ContextWithFields
+func ContextFields(ctx context.Context) Fields {
+	fields := Fields{}
+	for k, v := range fieldsFromContext(ctx) {
+		fields[k] = v
+	}
+
+	traceID, spanID := TraceIDsFromContext(ctx)
+	if traceID != "" {
+		fields["trace_id"] = traceID
+		fields["span_id"] = spanID
+	}
+	if requestNo := RequestNoFromContext(ctx); requestNo != "" {
+		fields["request_no"] = requestNo
+	}
+
+	return fields
+}
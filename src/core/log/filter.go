@@ -0,0 +1,32 @@
+package log
+
+// FieldFilter rewrites structured fields before they are written, e.g. to
+// redact secrets. It must not modify fields in place.
+type FieldFilter func(fields Fields) Fields
+
+var fieldFilter FieldFilter
+
+// SetFieldFilter installs filter for every logger. Call it once at startup.
+func SetFieldFilter(filter FieldFilter) {
+	fieldFilter = filter
+}
+
+func filterFields(fields Fields) Fields {
+	if fieldFilter == nil || len(fields) == 0 {
+		return fields
+	}
+	return fieldFilter(fields)
+}
+
+func filterKeysAndValues(keysAndValues []interface{}) []interface{} {
+	if fieldFilter == nil || len(keysAndValues) == 0 {
+		return keysAndValues
+	}
+
+	fields := filterFields(keysAndValuesToFields(keysAndValues))
+	filtered := make([]interface{}, 0, len(fields)*2)
+	for k, v := range fields {
+		filtered = append(filtered, k, v)
+	}
+	return filtered
+}
@@ -0,0 +1,362 @@
+package log
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LevelController holds the runtime log level shared by every logger created
+// with NewLogger. The global level is a zap AtomicLevel; per-module levels
+// override it for loggers carrying a matching "module" field. Changes made
+// with a TTL revert to the configured level when it expires.
+type LevelController struct {
+	mu sync.RWMutex
+
+	base       zapcore.Level
+	defaultTTL time.Duration
+	global     zap.AtomicLevel
+	modules    map[string]zapcore.Level
+	// globalSet is true while SetLevel is in effect, so outputs with a
+	// configured level of their own follow it too
+	globalSet atomic.Bool
+
+	// generation guards against a timer firing after a newer change
+	generation       uint64
+	globalExpireTime time.Time
+	globalTimer      *time.Timer
+	moduleExpireTime map[string]time.Time
+	moduleTimers     map[string]*time.Timer
+	moduleGeneration map[string]uint64
+}
+
+// LevelStatus is a snapshot of the runtime levels
+type LevelStatus struct {
+	BaseLevel       string             `json:"base_level"`
+	Level           string             `json:"level"`
+	LevelExpireTime *time.Time         `json:"level_expire_time,omitempty"`
+	ModuleLevels    []ModuleLevelState `json:"module_levels"`
+	AvailableLevels []string           `json:"available_levels"`
+}
+
+type ModuleLevelState struct {
+	Module     string     `json:"module"`
+	Level      string     `json:"level"`
+	ExpireTime *time.Time `json:"expire_time,omitempty"`
+}
+
+const moduleFieldName = "module"
+
+var levelController = newLevelController(zapcore.InfoLevel)
+
+func newLevelController(base zapcore.Level) *LevelController {
+	return &LevelController{
+		base:             base,
+		global:           zap.NewAtomicLevelAt(base),
+		modules:          make(map[string]zapcore.Level),
+		moduleExpireTime: make(map[string]time.Time),
+		moduleTimers:     make(map[string]*time.Timer),
+		moduleGeneration: make(map[string]uint64),
+	}
+}
+
+// Levels returns the process-wide level controller
+func Levels() *LevelController {
+	return levelController
+}
+
+func parseLevel(level string) (zapcore.Level, error) {
+	switch level {
+	case Debug, Info, Warn, Error, Fatal:
+		return getZapLevel(level), nil
+	}
+	return zapcore.InfoLevel, fmt.Errorf("invalid log level %q", level)
+}
+
+// configure sets the configured level that changes revert to, and the TTL
+// used when a change does not specify one
+func (lc *LevelController) configure(level zapcore.Level, defaultTTL time.Duration) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	lc.base = level
+	lc.defaultTTL = defaultTTL
+	if lc.globalTimer == nil {
+		lc.global.SetLevel(level)
+	}
+}
+
+// Enabled reports whether an entry at level should be written by a logger
+// tagged with module
+func (lc *LevelController) Enabled(module string, level zapcore.Level) bool {
+	if module != "" {
+		lc.mu.RLock()
+		moduleLevel, ok := lc.modules[module]
+		lc.mu.RUnlock()
+		if ok {
+			return level >= moduleLevel
+		}
+	}
+	return lc.global.Enabled(level)
+}
+
+// enabledAbove is Enabled for an output configured at base rather than at
+// the configured level of the controller
+func (lc *LevelController) enabledAbove(module string, level zapcore.Level, base zapcore.Level) bool {
+	if module != "" {
+		lc.mu.RLock()
+		moduleLevel, ok := lc.modules[module]
+		lc.mu.RUnlock()
+		if ok {
+			return level >= moduleLevel
+		}
+	}
+	if lc.globalSet.Load() {
+		return lc.global.Enabled(level)
+	}
+	return level >= base
+}
+
+// SetLevel changes the global level until ttl elapses, then reverts it to the
+// configured level. A zero ttl uses Log.Runtime.TTL.
+func (lc *LevelController) SetLevel(level string, ttl time.Duration) error {
+	zapLevel, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	if ttl <= 0 {
+		ttl = lc.defaultTTL
+	}
+
+	if lc.globalTimer != nil {
+		lc.globalTimer.Stop()
+		lc.globalTimer = nil
+	}
+	lc.generation++
+	lc.globalExpireTime = time.Time{}
+	lc.global.SetLevel(zapLevel)
+	lc.globalSet.Store(true)
+
+	if ttl > 0 {
+		generation := lc.generation
+		lc.globalExpireTime = time.Now().Add(ttl)
+		lc.globalTimer = time.AfterFunc(ttl, func() {
+			lc.mu.Lock()
+			defer lc.mu.Unlock()
+
+			if lc.generation == generation {
+				lc.resetGlobalLocked()
+			}
+		})
+	}
+
+	return nil
+}
+
+// SetModuleLevel overrides the level for loggers whose "module" field equals
+// module until ttl elapses. A zero ttl uses Log.Runtime.TTL.
+func (lc *LevelController) SetModuleLevel(module, level string, ttl time.Duration) error {
+	if module == "" {
+		return lc.SetLevel(level, ttl)
+	}
+
+	zapLevel, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	if ttl <= 0 {
+		ttl = lc.defaultTTL
+	}
+
+	lc.clearModuleLocked(module)
+	lc.generation++
+	lc.modules[module] = zapLevel
+	lc.moduleGeneration[module] = lc.generation
+
+	if ttl > 0 {
+		generation := lc.generation
+		lc.moduleExpireTime[module] = time.Now().Add(ttl)
+		lc.moduleTimers[module] = time.AfterFunc(ttl, func() {
+			lc.mu.Lock()
+			defer lc.mu.Unlock()
+
+			if lc.moduleGeneration[module] == generation {
+				lc.clearModuleLocked(module)
+			}
+		})
+	}
+
+	return nil
+}
+
+// ResetModule removes the override for module
+func (lc *LevelController) ResetModule(module string) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	lc.clearModuleLocked(module)
+}
+
+// Reset restores the configured level and removes every module override
+func (lc *LevelController) Reset() {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	lc.resetGlobalLocked()
+	for module := range lc.modules {
+		lc.clearModuleLocked(module)
+	}
+}
+
+// IsRaised reports whether the global level differs from the configured one
+func (lc *LevelController) IsRaised() bool {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+
+	return lc.global.Level() != lc.base
+}
+
+func (lc *LevelController) resetGlobalLocked() {
+	if lc.globalTimer != nil {
+		lc.globalTimer.Stop()
+		lc.globalTimer = nil
+	}
+	lc.globalExpireTime = time.Time{}
+	lc.global.SetLevel(lc.base)
+	lc.globalSet.Store(false)
+}
+
+func (lc *LevelController) clearModuleLocked(module string) {
+	if timer, ok := lc.moduleTimers[module]; ok {
+		timer.Stop()
+	}
+	delete(lc.moduleTimers, module)
+	delete(lc.moduleExpireTime, module)
+	delete(lc.moduleGeneration, module)
+	delete(lc.modules, module)
+}
+
+// Status returns a snapshot of the current levels
+func (lc *LevelController) Status() LevelStatus {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+
+	status := LevelStatus{
+		BaseLevel:       lc.base.String(),
+		Level:           lc.global.Level().String(),
+		ModuleLevels:    make([]ModuleLevelState, 0, len(lc.modules)),
+		AvailableLevels: []string{Debug, Info, Warn, Error, Fatal},
+	}
+	if !lc.globalExpireTime.IsZero() {
+		expireTime := lc.globalExpireTime
+		status.LevelExpireTime = &expireTime
+	}
+
+	for module, level := range lc.modules {
+		state := ModuleLevelState{
+			Module: module,
+			Level:  level.String(),
+		}
+		if expireTime, ok := lc.moduleExpireTime[module]; ok {
+			state.ExpireTime = &expireTime
+		}
+		status.ModuleLevels = append(status.ModuleLevels, state)
+	}
+	sort.Slice(status.ModuleLevels, func(i, j int) bool {
+		return status.ModuleLevels[i].Module < status.ModuleLevels[j].Module
+	})
+
+	return status
+}
+
+// levelCore gates a zap core with the LevelController. It remembers the
+// "module" field added through With so per-module levels apply. Until a
+// level is set at runtime the core keeps its own configured base level.
+type levelCore struct {
+	zapcore.Core
+	module     string
+	base       zapcore.Level
+	controller *LevelController
+}
+
+func newLevelCore(core zapcore.Core, base zapcore.Level, controller *LevelController) zapcore.Core {
+	return &levelCore{
+		Core:       core,
+		base:       base,
+		controller: controller,
+	}
+}
+
+func (c *levelCore) Enabled(level zapcore.Level) bool {
+	return c.controller.enabledAbove(c.module, level, c.base)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	module := c.module
+	for _, field := range fields {
+		if field.Key == moduleFieldName && field.Type == zapcore.StringType {
+			module = field.String
+		}
+	}
+
+	return &levelCore{
+		Core:       c.Core.With(fields),
+		module:     module,
+		base:       c.base,
+		controller: c.controller,
+	}
+}
+
+func (c *levelCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+// toggleDebugLevel switches the configured signal modules, or the global level
+// when there are none, between debug and their configured level
+func toggleDebugLevel(config *Configuration, logger Logger) {
+	status := levelController.Status()
+
+	if len(config.SignalModules) == 0 {
+		if levelController.IsRaised() {
+			levelController.Reset()
+			logger.Infof("Log level reset to %s", status.BaseLevel)
+			return
+		}
+
+		_ = levelController.SetLevel(Debug, 0)
+		logger.Infof("Log level set to %s for %s", Debug, config.RuntimeLevelTTL)
+		return
+	}
+
+	raised := make(map[string]bool)
+	for _, moduleLevel := range status.ModuleLevels {
+		raised[moduleLevel.Module] = true
+	}
+
+	for _, module := range config.SignalModules {
+		if raised[module] {
+			levelController.ResetModule(module)
+			logger.Infof("Log level of module %s reset to %s", module, status.Level)
+			continue
+		}
+
+		_ = levelController.SetModuleLevel(module, Debug, 0)
+		logger.Infof("Log level of module %s set to %s for %s", module, Debug, config.RuntimeLevelTTL)
+	}
+}
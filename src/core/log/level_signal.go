@@ -0,0 +1,23 @@
+//go:build !windows
+
+package log
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// HandleLevelSignal toggles debug logging when the process receives SIGUSR1.
+// The first signal raises config.SignalModules (or the global level when
+// none are set) to debug for config.RuntimeLevelTTL; the next one reverts.
+func HandleLevelSignal(config *Configuration, logger Logger) {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGUSR1)
+
+	go func() {
+		for range sigChan {
+			toggleDebugLevel(config, logger)
+		}
+	}()
+}
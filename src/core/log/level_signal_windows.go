@@ -0,0 +1,7 @@
+//go:build windows
+
+package log
+
+// HandleLevelSignal is a no-op on Windows, which has no SIGUSR1. Use the
+// admin endpoint to change levels at runtime instead.
+func HandleLevelSignal(config *Configuration, logger Logger) {}
@@ -1,7 +1,9 @@
 package log
 
 import (
+	"context"
 	"errors"
+	"fmt"
 )
 
 // Fields Type to pass when we want to call WithFields for structured logging
@@ -43,7 +45,21 @@ type Logger interface {
 
 	Panicf(format string, args ...interface{})
 
+	// Debug, Info, Warn and Error log msg with alternating key-value pairs,
+	// e.g. logger.Info("user created", "user_id", id)
+	Debug(msg string, keysAndValues ...interface{})
+
+	Info(msg string, keysAndValues ...interface{})
+
+	Warn(msg string, keysAndValues ...interface{})
+
+	Error(msg string, keysAndValues ...interface{})
+
 	WithFields(keyValues Fields) Logger
+
+	// WithContext returns a logger carrying the trace and span IDs, request
+	// number, user ID and route found in the request context
+	WithContext(ctx context.Context) Logger
 }
 
 // NewLogger returns an instance of logger
@@ -82,7 +98,12 @@ func NewLogger(config *Configuration, loggerInstance int) (Logger, error) {
 
 // NewLoggerWithModuleName returns an instance of logger
 func NewLoggerWithModuleName(config *Configuration, moduleName string) (Logger, error) {
-	logger, err := NewLogger(config, InstanceZapLogger)
+	loggerInstance := InstanceZapLogger
+	if config != nil {
+		loggerInstance = config.Instance
+	}
+
+	logger, err := NewLogger(config, loggerInstance)
 	if err != nil {
 		return nil, err
 	}
@@ -93,3 +114,22 @@ func NewLoggerWithModuleName(config *Configuration, moduleName string) (Logger,
 
 	return logger, nil
 }
+
+// keysAndValuesToFields converts alternating key-value pairs to Fields. A
+// dangling value or non-string key is kept under a "!BADKEY" key.
+func keysAndValuesToFields(keysAndValues []interface{}) Fields {
+	fields := Fields{}
+	for i := 0; i < len(keysAndValues); i += 2 {
+		if i+1 == len(keysAndValues) {
+			fields["!BADKEY"] = keysAndValues[i]
+			break
+		}
+
+		key, ok := keysAndValues[i].(string)
+		if !ok {
+			key = fmt.Sprintf("!BADKEY(%v)", keysAndValues[i])
+		}
+		fields[key] = keysAndValues[i+1]
+	}
+	return fields
+}
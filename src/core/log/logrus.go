@@ -1,19 +1,23 @@
 package log
 
 import (
+	"context"
 	"io"
 	"os"
 
 	"github.com/sirupsen/logrus"
-	lumberjack "gopkg.in/natefinch/lumberjack.v2"
+	"go.uber.org/zap/zapcore"
 )
 
 type logrusLogEntry struct {
-	entry *logrus.Entry
+	entry   *logrus.Entry
+	module  string
+	sampler *sampler
 }
 
 type logrusLogger struct {
-	logger *logrus.Logger
+	logger  *logrus.Logger
+	sampler *sampler
 }
 
 func getFormatter(isJSON bool, color bool) logrus.Formatter {
@@ -27,29 +31,30 @@ func getFormatter(isJSON bool, color bool) logrus.Formatter {
 	}
 }
 
+// newLogrusLogger writes every entry once to all its outputs, so unlike zap
+// it has a single level: ConsoleLevel, or FileLevel when only the file is
+// enabled. Runtime levels change it for both outputs.
 func newLogrusLogger(config Configuration) (Logger, error) {
 	logLevel := config.ConsoleLevel
-	if logLevel == "" {
+	if logLevel == "" || (config.EnableFile && !config.EnableConsole) {
 		logLevel = config.FileLevel
 	}
 
-	level, err := logrus.ParseLevel(logLevel)
-	if err != nil {
+	if _, err := logrus.ParseLevel(logLevel); err != nil {
 		return nil, err
 	}
 
 	stdOutHandler := os.Stdout
-	fileHandler := &lumberjack.Logger{
-		Filename: config.FileLocation,
-		MaxSize:  100,
-		Compress: true,
-		MaxAge:   28,
-	}
+	fileHandler := config.newFileWriter()
+	// logrus itself accepts everything, the level is checked against
+	// Levels() so it can be changed at runtime and per module
+	levelController.configure(getZapLevel(logLevel), config.RuntimeLevelTTL)
+
 	lLogger := &logrus.Logger{
 		Out:       stdOutHandler,
 		Formatter: getFormatter(config.ConsoleJSONFormat, config.Color),
 		Hooks:     make(logrus.LevelHooks),
-		Level:     level,
+		Level:     logrus.DebugLevel,
 	}
 
 	if config.EnableConsole && config.EnableFile {
@@ -62,23 +67,36 @@ func newLogrusLogger(config Configuration) (Logger, error) {
 	}
 
 	return &logrusLogger{
-		logger: lLogger,
+		logger:  lLogger,
+		sampler: newSampler(config.Sampling),
 	}, nil
 }
 
 func (l *logrusLogger) Debugf(format string, args ...interface{}) {
+	if !levelController.Enabled("", zapcore.DebugLevel) || !l.sampler.allow(zapcore.DebugLevel, format) {
+		return
+	}
 	l.logger.Debugf(format, args...)
 }
 
 func (l *logrusLogger) Infof(format string, args ...interface{}) {
+	if !levelController.Enabled("", zapcore.InfoLevel) || !l.sampler.allow(zapcore.InfoLevel, format) {
+		return
+	}
 	l.logger.Infof(format, args...)
 }
 
 func (l *logrusLogger) Warnf(format string, args ...interface{}) {
+	if !levelController.Enabled("", zapcore.WarnLevel) || !l.sampler.allow(zapcore.WarnLevel, format) {
+		return
+	}
 	l.logger.Warnf(format, args...)
 }
 
 func (l *logrusLogger) Errorf(format string, args ...interface{}) {
+	if !levelController.Enabled("", zapcore.ErrorLevel) || !l.sampler.allow(zapcore.ErrorLevel, format) {
+		return
+	}
 	l.logger.Errorf(format, args...)
 }
 
@@ -90,25 +108,74 @@ func (l *logrusLogger) Panicf(format string, args ...interface{}) {
 	l.logger.Fatalf(format, args...)
 }
 
+func (l *logrusLogger) Debug(msg string, keysAndValues ...interface{}) {
+	if !levelController.Enabled("", zapcore.DebugLevel) || !l.sampler.allow(zapcore.DebugLevel, msg) {
+		return
+	}
+	l.logger.WithFields(convertToLogrusFields(keysAndValuesToFields(keysAndValues))).Debug(msg)
+}
+
+func (l *logrusLogger) Info(msg string, keysAndValues ...interface{}) {
+	if !levelController.Enabled("", zapcore.InfoLevel) || !l.sampler.allow(zapcore.InfoLevel, msg) {
+		return
+	}
+	l.logger.WithFields(convertToLogrusFields(keysAndValuesToFields(keysAndValues))).Info(msg)
+}
+
+func (l *logrusLogger) Warn(msg string, keysAndValues ...interface{}) {
+	if !levelController.Enabled("", zapcore.WarnLevel) || !l.sampler.allow(zapcore.WarnLevel, msg) {
+		return
+	}
+	l.logger.WithFields(convertToLogrusFields(keysAndValuesToFields(keysAndValues))).Warn(msg)
+}
+
+func (l *logrusLogger) Error(msg string, keysAndValues ...interface{}) {
+	if !levelController.Enabled("", zapcore.ErrorLevel) || !l.sampler.allow(zapcore.ErrorLevel, msg) {
+		return
+	}
+	l.logger.WithFields(convertToLogrusFields(keysAndValuesToFields(keysAndValues))).Error(msg)
+}
+
+func (l *logrusLogger) WithContext(ctx context.Context) Logger {
+	return &logrusLogEntry{
+		entry:   l.logger.WithContext(ctx).WithFields(convertToLogrusFields(ContextFields(ctx))),
+		sampler: l.sampler,
+	}
+}
+
 func (l *logrusLogger) WithFields(fields Fields) Logger {
 	return &logrusLogEntry{
-		entry: l.logger.WithFields(convertToLogrusFields(fields)),
+		entry:   l.logger.WithFields(convertToLogrusFields(fields)),
+		module:  moduleFromFields(fields, ""),
+		sampler: l.sampler,
 	}
 }
 
 func (l *logrusLogEntry) Debugf(format string, args ...interface{}) {
+	if !levelController.Enabled(l.module, zapcore.DebugLevel) || !l.sampler.allow(zapcore.DebugLevel, format) {
+		return
+	}
 	l.entry.Debugf(format, args...)
 }
 
 func (l *logrusLogEntry) Infof(format string, args ...interface{}) {
+	if !levelController.Enabled(l.module, zapcore.InfoLevel) || !l.sampler.allow(zapcore.InfoLevel, format) {
+		return
+	}
 	l.entry.Infof(format, args...)
 }
 
 func (l *logrusLogEntry) Warnf(format string, args ...interface{}) {
+	if !levelController.Enabled(l.module, zapcore.WarnLevel) || !l.sampler.allow(zapcore.WarnLevel, format) {
+		return
+	}
 	l.entry.Warnf(format, args...)
 }
 
 func (l *logrusLogEntry) Errorf(format string, args ...interface{}) {
+	if !levelController.Enabled(l.module, zapcore.ErrorLevel) || !l.sampler.allow(zapcore.ErrorLevel, format) {
+		return
+	}
 	l.entry.Errorf(format, args...)
 }
 
@@ -120,16 +187,61 @@ func (l *logrusLogEntry) Panicf(format string, args ...interface{}) {
 	l.entry.Fatalf(format, args...)
 }
 
+func (l *logrusLogEntry) Debug(msg string, keysAndValues ...interface{}) {
+	if !levelController.Enabled(l.module, zapcore.DebugLevel) || !l.sampler.allow(zapcore.DebugLevel, msg) {
+		return
+	}
+	l.entry.WithFields(convertToLogrusFields(keysAndValuesToFields(keysAndValues))).Debug(msg)
+}
+
+func (l *logrusLogEntry) Info(msg string, keysAndValues ...interface{}) {
+	if !levelController.Enabled(l.module, zapcore.InfoLevel) || !l.sampler.allow(zapcore.InfoLevel, msg) {
+		return
+	}
+	l.entry.WithFields(convertToLogrusFields(keysAndValuesToFields(keysAndValues))).Info(msg)
+}
+
+func (l *logrusLogEntry) Warn(msg string, keysAndValues ...interface{}) {
+	if !levelController.Enabled(l.module, zapcore.WarnLevel) || !l.sampler.allow(zapcore.WarnLevel, msg) {
+		return
+	}
+	l.entry.WithFields(convertToLogrusFields(keysAndValuesToFields(keysAndValues))).Warn(msg)
+}
+
+func (l *logrusLogEntry) Error(msg string, keysAndValues ...interface{}) {
+	if !levelController.Enabled(l.module, zapcore.ErrorLevel) || !l.sampler.allow(zapcore.ErrorLevel, msg) {
+		return
+	}
+	l.entry.WithFields(convertToLogrusFields(keysAndValuesToFields(keysAndValues))).Error(msg)
+}
+
+func (l *logrusLogEntry) WithContext(ctx context.Context) Logger {
+	return &logrusLogEntry{
+		entry:   l.entry.WithContext(ctx).WithFields(convertToLogrusFields(ContextFields(ctx))),
+		module:  l.module,
+		sampler: l.sampler,
+	}
+}
+
 func (l *logrusLogEntry) WithFields(fields Fields) Logger {
 	return &logrusLogEntry{
-		entry: l.entry.WithFields(convertToLogrusFields(fields)),
+		entry:   l.entry.WithFields(convertToLogrusFields(fields)),
+		module:  moduleFromFields(fields, l.module),
+		sampler: l.sampler,
 	}
 }
 
 func convertToLogrusFields(fields Fields) logrus.Fields {
 	logrusFields := logrus.Fields{}
-	for index, val := range fields {
+	for index, val := range filterFields(fields) {
 		logrusFields[index] = val
 	}
 	return logrusFields
 }
+
+func moduleFromFields(fields Fields, current string) string {
+	if module, ok := fields[moduleFieldName].(string); ok {
+		return module
+	}
+	return current
+}
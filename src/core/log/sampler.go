@@ -0,0 +1,67 @@
+package log
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// sampler is the logrus counterpart of zapcore.NewSamplerWithOptions: within
+// every tick it lets the first `initial` entries with the same level and
+// message through, then every `thereafter`-th one.
+type sampler struct {
+	mu         sync.Mutex
+	tick       time.Duration
+	initial    uint64
+	thereafter uint64
+	resetAt    time.Time
+	counts     map[sampleKey]uint64
+}
+
+type sampleKey struct {
+	level   zapcore.Level
+	message string
+}
+
+func newSampler(config SamplingConfiguration) *sampler {
+	if !config.Enabled {
+		return nil
+	}
+
+	return &sampler{
+		tick:       config.Tick,
+		initial:    uint64(config.Initial),
+		thereafter: uint64(config.Thereafter),
+		counts:     make(map[sampleKey]uint64),
+	}
+}
+
+// allow reports whether an entry should be written. A nil sampler allows
+// everything.
+func (s *sampler) allow(level zapcore.Level, message string) bool {
+	if s == nil {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.After(s.resetAt) {
+		s.counts = make(map[sampleKey]uint64)
+		s.resetAt = now.Add(s.tick)
+	}
+
+	key := sampleKey{level: level, message: message}
+	s.counts[key]++
+	n := s.counts[key]
+
+	if n <= s.initial {
+		return true
+	}
+	if s.thereafter == 0 {
+		return false
+	}
+	return (n-s.initial)%s.thereafter == 0
+}
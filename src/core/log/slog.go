@@ -0,0 +1,122 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// slogHandler exposes a Logger as a log/slog Handler so third-party libraries
+// that log through slog end up in the same zap or logrus backend.
+type slogHandler struct {
+	logger Logger
+	groups []string
+}
+
+// NewSlogHandler returns a slog.Handler writing to logger. Records below
+// the runtime level (see Levels) are dropped before reaching the backend.
+func NewSlogHandler(logger Logger) slog.Handler {
+	return &slogHandler{
+		logger: logger,
+	}
+}
+
+// NewSlogLogger returns a *slog.Logger writing to logger, suitable for
+// slog.SetDefault.
+func NewSlogLogger(logger Logger) *slog.Logger {
+	return slog.New(NewSlogHandler(logger))
+}
+
+func getZapLevelFromSlog(level slog.Level) zapcore.Level {
+	switch {
+	case level >= slog.LevelError:
+		return zapcore.ErrorLevel
+	case level >= slog.LevelWarn:
+		return zapcore.WarnLevel
+	case level >= slog.LevelInfo:
+		return zapcore.InfoLevel
+	default:
+		return zapcore.DebugLevel
+	}
+}
+
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return levelController.Enabled("", getZapLevelFromSlog(level))
+}
+
+func (h *slogHandler) Handle(ctx context.Context, record slog.Record) error {
+	keysAndValues := make([]interface{}, 0, record.NumAttrs()*2)
+	record.Attrs(func(attr slog.Attr) bool {
+		keysAndValues = h.appendAttr(keysAndValues, attr)
+		return true
+	})
+
+	logger := h.logger.WithContext(ctx)
+	switch {
+	case record.Level >= slog.LevelError:
+		logger.Error(record.Message, keysAndValues...)
+	case record.Level >= slog.LevelWarn:
+		logger.Warn(record.Message, keysAndValues...)
+	case record.Level >= slog.LevelInfo:
+		logger.Info(record.Message, keysAndValues...)
+	default:
+		logger.Debug(record.Message, keysAndValues...)
+	}
+
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	keysAndValues := make([]interface{}, 0, len(attrs)*2)
+	for _, attr := range attrs {
+		keysAndValues = h.appendAttr(keysAndValues, attr)
+	}
+
+	return &slogHandler{
+		logger: h.logger.WithFields(keysAndValuesToFields(keysAndValues)),
+		groups: h.groups,
+	}
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	groups := make([]string, 0, len(h.groups)+1)
+	groups = append(groups, h.groups...)
+	groups = append(groups, name)
+
+	return &slogHandler{
+		logger: h.logger,
+		groups: groups,
+	}
+}
+
+// appendAttr flattens attr into key-value pairs, prefixing keys with the
+// open groups as "group.key".
+func (h *slogHandler) appendAttr(keysAndValues []interface{}, attr slog.Attr) []interface{} {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return keysAndValues
+	}
+
+	if attr.Value.Kind() == slog.KindGroup {
+		inner := &slogHandler{logger: h.logger, groups: h.groups}
+		if attr.Key != "" {
+			inner = inner.WithGroup(attr.Key).(*slogHandler)
+		}
+		for _, groupAttr := range attr.Value.Group() {
+			keysAndValues = inner.appendAttr(keysAndValues, groupAttr)
+		}
+		return keysAndValues
+	}
+
+	key := attr.Key
+	for i := len(h.groups) - 1; i >= 0; i-- {
+		key = h.groups[i] + "." + key
+	}
+
+	return append(keysAndValues, key, attr.Value.Any())
+}
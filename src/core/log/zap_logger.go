@@ -1,11 +1,11 @@
 package log
 
 import (
+	"context"
 	"os"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
-	lumberjack "gopkg.in/natefinch/lumberjack.v2"
 )
 
 type zapLogger struct {
@@ -44,27 +44,34 @@ func getZapLevel(level string) zapcore.Level {
 func newZapLogger(config Configuration) (Logger, error) {
 	cores := []zapcore.Core{}
 
+	// The levels can be changed at runtime through Levels(), so the cores
+	// themselves accept everything and levelCore does the filtering. Each
+	// output starts at its own configured level and follows runtime changes.
+	levelController.configure(getZapLevel(config.ConsoleLevel), config.RuntimeLevelTTL)
+
 	if config.EnableConsole {
-		level := getZapLevel(config.ConsoleLevel)
 		writer := zapcore.Lock(os.Stdout)
-		core := zapcore.NewCore(getEncoder(config.ConsoleJSONFormat, config.Color), writer, level)
-		cores = append(cores, core)
+		core := zapcore.NewCore(getEncoder(config.ConsoleJSONFormat, config.Color), writer, zapcore.DebugLevel)
+		cores = append(cores, newLevelCore(core, getZapLevel(config.ConsoleLevel), levelController))
 	}
 
 	if config.EnableFile {
-		level := getZapLevel(config.FileLevel)
-		writer := zapcore.AddSync(&lumberjack.Logger{
-			Filename: config.FileLocation,
-			MaxSize:  100,
-			Compress: true,
-			MaxAge:   28,
-		})
-		core := zapcore.NewCore(getEncoder(config.FileJSONFormat, config.Color), writer, level)
-		cores = append(cores, core)
+		writer := zapcore.AddSync(config.newFileWriter())
+		core := zapcore.NewCore(getEncoder(config.FileJSONFormat, false), writer, zapcore.DebugLevel)
+		cores = append(cores, newLevelCore(core, getZapLevel(config.FileLevel), levelController))
 	}
 
 	combinedCore := zapcore.NewTee(cores...)
 
+	if config.Sampling.Enabled {
+		combinedCore = zapcore.NewSamplerWithOptions(
+			combinedCore,
+			config.Sampling.Tick,
+			config.Sampling.Initial,
+			config.Sampling.Thereafter,
+		)
+	}
+
 	// AddCallerSkip skips 2 number of callers, this is important else the file that gets
 	// logged will always be the wrapped file. In our case zap.go
 	logger := zap.New(combinedCore,
@@ -101,9 +108,33 @@ func (l *zapLogger) Panicf(format string, args ...interface{}) {
 	l.sugaredLogger.Fatalf(format, args...)
 }
 
+func (l *zapLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.sugaredLogger.Debugw(msg, filterKeysAndValues(keysAndValues)...)
+}
+
+func (l *zapLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.sugaredLogger.Infow(msg, filterKeysAndValues(keysAndValues)...)
+}
+
+func (l *zapLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.sugaredLogger.Warnw(msg, filterKeysAndValues(keysAndValues)...)
+}
+
+func (l *zapLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.sugaredLogger.Errorw(msg, filterKeysAndValues(keysAndValues)...)
+}
+
+func (l *zapLogger) WithContext(ctx context.Context) Logger {
+	fields := ContextFields(ctx)
+	if len(fields) == 0 {
+		return l
+	}
+	return l.WithFields(fields)
+}
+
 func (l *zapLogger) WithFields(fields Fields) Logger {
 	var f = make([]interface{}, 0)
-	for k, v := range fields {
+	for k, v := range filterFields(fields) {
 		f = append(f, k)
 		f = append(f, v)
 	}
@@ -5,15 +5,15 @@ import (
 	"github.com/minio/minio-go/v7"
 )
 
-func (m *minIO) CreateDefaultBucket() error {
+func (m *minIO) CreateDefaultBucket(ctx context.Context) error {
 	err := m.client.MakeBucket(
-		context.Background(),
+		ctx,
 		m.defaultBucket(),
 		minio.MakeBucketOptions{Region: "thailand"},
 	)
 	if err != nil {
 		// Check to see if we already own this bucket (which happens if you run this twice)
-		exists, errBucketExists := m.client.BucketExists(context.Background(), m.defaultBucket())
+		exists, errBucketExists := m.client.BucketExists(ctx, m.defaultBucket())
 		if errBucketExists == nil && exists {
 			m.log.Infof("Bucket: %s already exists", m.defaultBucket())
 			return nil
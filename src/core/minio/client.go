@@ -25,6 +25,25 @@ func (m *minIO) CreateObject(ctx context.Context, objectName string, body []byte
 	return nil
 }
 
+// putObjectPartSize bounds the memory of an upload of unknown size, which
+// minio-go buffers a part at a time, and allows objects up to 160 GiB
+const putObjectPartSize = 16 << 20
+
+func (m *minIO) PutObject(ctx context.Context, objectName string, body io.Reader, contentType string) error {
+
+	_, err := m.client.PutObject(ctx, m.defaultBucket(), objectName, body, -1, minio.PutObjectOptions{
+		ContentType: contentType,
+		PartSize:    putObjectPartSize,
+	})
+	if err != nil {
+		m.log.Errorf("Failed to upload file to bucket '%s': %+v", m.defaultBucket(), err)
+		return err
+	}
+
+	m.log.Debugf("Object '%s' created successfully in bucket '%s'", objectName, m.defaultBucket())
+	return nil
+}
+
 // DownloadFile downloads a file from the specified bucket.
 func (m *minIO) DownloadFile(ctx context.Context, objectName string) ([]byte, error) {
 
@@ -44,3 +63,22 @@ func (m *minIO) DownloadFile(ctx context.Context, objectName string) ([]byte, er
 	m.log.Debugf("File downloaded successfully: %+v", objectName)
 	return b, nil
 }
+
+// OpenObject returns a reader of the object, fetched as it is read.
+func (m *minIO) OpenObject(ctx context.Context, objectName string) (io.ReadCloser, error) {
+
+	obj, err := m.client.GetObject(ctx, m.defaultBucket(), objectName, minio.GetObjectOptions{})
+	if err != nil {
+		m.log.Infof("Failed to open object: %+v", err)
+		return nil, err
+	}
+
+	// GetObject is lazy, fail here when the object does not exist
+	if _, err := obj.Stat(); err != nil {
+		_ = obj.Close()
+		m.log.Infof("Failed to open object: %+v", err)
+		return nil, err
+	}
+
+	return obj, nil
+}
@@ -2,6 +2,8 @@ package minio
 
 import (
 	"context"
+	"io"
+
 	"go-template/src/core/log"
 
 	"github.com/minio/minio-go/v7"
@@ -10,8 +12,12 @@ import (
 
 type MinIO interface {
 	CreateObject(ctx context.Context, objectName string, body []byte) error
+	// PutObject uploads body as it is read, without knowing its size
+	PutObject(ctx context.Context, objectName string, body io.Reader, contentType string) error
 	DownloadFile(ctx context.Context, objectName string) ([]byte, error)
-	CreateDefaultBucket() error
+	// OpenObject streams an object, the caller closes it
+	OpenObject(ctx context.Context, objectName string) (io.ReadCloser, error)
+	CreateDefaultBucket(ctx context.Context) error
 }
 
 type minIO struct {
@@ -20,6 +26,12 @@ type minIO struct {
 	log    log.Logger
 }
 
+// IsNotFound reports whether err is the error of an object that does not
+// exist
+func IsNotFound(err error) bool {
+	return err != nil && minio.ToErrorResponse(err).Code == "NoSuchKey"
+}
+
 // New creates a new minIO client using the provided configuration.
 func New(logger log.Logger) (MinIO, error) {
 	conf, err := InitConfig()
@@ -35,11 +47,11 @@ func New(logger log.Logger) (MinIO, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &minIO{
+	return newTracedMinIO(&minIO{
 		conf:   conf,
 		client: minioClient,
 		log:    logger,
-	}, nil
+	}, conf.Bucket), nil
 }
 
 func (m *minIO) defaultBucket() string {
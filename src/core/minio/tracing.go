@@ -0,0 +1,152 @@
+package minio
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "go-template/src/core/minio"
+
+// tracedMinIO wraps a MinIO implementation and records a client span for
+// every call, parented to the span carried by the caller's context.
+type tracedMinIO struct {
+	next   MinIO
+	bucket string
+	tracer trace.Tracer
+}
+
+func newTracedMinIO(next MinIO, bucket string) MinIO {
+	return &tracedMinIO{
+		next:   next,
+		bucket: bucket,
+		tracer: otel.Tracer(tracerName),
+	}
+}
+
+func (t *tracedMinIO) start(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs,
+		attribute.String("minio.operation", operation),
+		attribute.String("minio.bucket", t.bucket),
+	)
+
+	return t.tracer.Start(ctx, "minio."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+func finish(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (t *tracedMinIO) CreateObject(ctx context.Context, objectName string, body []byte) (err error) {
+	ctx, span := t.start(ctx, "CreateObject",
+		attribute.String("minio.object", objectName),
+		attribute.Int("minio.object_size", len(body)),
+	)
+	defer func() { finish(span, err) }()
+
+	return t.next.CreateObject(ctx, objectName, body)
+}
+
+func (t *tracedMinIO) PutObject(ctx context.Context, objectName string, body io.Reader, contentType string) (err error) {
+	ctx, span := t.start(ctx, "PutObject",
+		attribute.String("minio.object", objectName),
+	)
+	counted := &countingReader{Reader: body}
+	defer func() {
+		span.SetAttributes(attribute.Int64("minio.object_size", counted.size))
+		finish(span, err)
+	}()
+
+	return t.next.PutObject(ctx, objectName, counted, contentType)
+}
+
+func (t *tracedMinIO) DownloadFile(ctx context.Context, objectName string) (b []byte, err error) {
+	ctx, span := t.start(ctx, "DownloadFile",
+		attribute.String("minio.object", objectName),
+	)
+	defer func() {
+		span.SetAttributes(attribute.Int("minio.object_size", len(b)))
+		finish(span, err)
+	}()
+
+	return t.next.DownloadFile(ctx, objectName)
+}
+
+// OpenObject ends its span when the object has been read or is closed
+func (t *tracedMinIO) OpenObject(ctx context.Context, objectName string) (io.ReadCloser, error) {
+	ctx, span := t.start(ctx, "OpenObject",
+		attribute.String("minio.object", objectName),
+	)
+
+	obj, err := t.next.OpenObject(ctx, objectName)
+	if err != nil {
+		finish(span, err)
+		return nil, err
+	}
+
+	return &spanReader{ReadCloser: obj, span: span}, nil
+}
+
+func (t *tracedMinIO) CreateDefaultBucket(ctx context.Context) (err error) {
+	ctx, span := t.start(ctx, "CreateDefaultBucket")
+	defer func() { finish(span, err) }()
+
+	return t.next.CreateDefaultBucket(ctx)
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	io.Reader
+	size int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// spanReader ends span at the end of the object, on a read error or on
+// Close, whichever comes first
+type spanReader struct {
+	io.ReadCloser
+	span trace.Span
+	size int64
+	once sync.Once
+}
+
+func (r *spanReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.size += int64(n)
+	if err == io.EOF {
+		r.end(nil)
+	} else if err != nil {
+		r.end(err)
+	}
+	return n, err
+}
+
+func (r *spanReader) Close() error {
+	err := r.ReadCloser.Close()
+	r.end(nil)
+	return err
+}
+
+func (r *spanReader) end(err error) {
+	r.once.Do(func() {
+		r.span.SetAttributes(attribute.Int64("minio.object_size", r.size))
+		finish(r.span, err)
+	})
+}
@@ -4,17 +4,109 @@ import "time"
 
 // ActivityLog represents the activity_log table
 type ActivityLog struct {
-	ID            int64     `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
-	UserID        *int64    `json:"user_id" gorm:"column:user_id"`
-	EmailAddress  *string   `json:"email_address" gorm:"column:email_address"`
-	UserRoleName  *string   `json:"user_role_name" gorm:"column:user_role_name"`
-	HTTPMethod    string    `json:"http_method" gorm:"column:http_method"`
-	RequestURI    string    `json:"request_uri" gorm:"column:request_uri"`
-	RequestBody   *string   `json:"request_body" gorm:"column:request_body"`
-	ResponseCode  int       `json:"response_code" gorm:"column:response_code"`
-	ResponseBody  *string   `json:"response_body" gorm:"column:response_body"`
-	IPAddress     string    `json:"ip_address" gorm:"column:ip_address"`
-	UserAgent     *string   `json:"user_agent" gorm:"column:user_agent"`
-	ExecutionTime int64     `json:"execution_time" gorm:"column:execution_time"`
-	CreatedTime   time.Time `json:"created_time" gorm:"column:created_time;default:now()"`
+	ID             int64     `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	RequestNo      string    `json:"request_no" gorm:"column:request_no"`
+	TraceID        string    `json:"trace_id" gorm:"column:trace_id"`
+	SpanID         string    `json:"span_id" gorm:"column:span_id"`
+	ServiceCode    string    `json:"service_code" gorm:"column:service_code"`
+	UserID         *int64    `json:"user_id" gorm:"column:user_id"`
+	AzureUserID    *string   `json:"azure_user_id" gorm:"column:azure_user_id"`
+	EmailAddress   *string   `json:"email_address" gorm:"column:email_address"`
+	UserRoles      []string  `json:"user_roles" gorm:"column:user_roles"`
+	HTTPMethod     string    `json:"http_method" gorm:"column:http_method"`
+	RequestURI     string    `json:"request_uri" gorm:"column:request_uri"`
+	HTTPStatusCode int       `json:"http_status_code" gorm:"column:http_status_code"`
+	ResponseCode   *int      `json:"response_code" gorm:"column:response_code"`
+	DurationMs     int64     `json:"duration_ms" gorm:"column:duration_ms"`
+	IPAddress      string    `json:"ip_address" gorm:"column:ip_address"`
+	UserAgent      *string   `json:"user_agent" gorm:"column:user_agent"`
+	RequestBody    *string   `json:"request_body" gorm:"column:request_body"`
+	ResponseBody   *string   `json:"response_body" gorm:"column:response_body"`
+	CreatedTime    time.Time `json:"created_time" gorm:"column:created_time;default:now()"`
+	ChainSeq       int64     `json:"chain_seq" gorm:"column:chain_seq"`
+	PrevHash       string    `json:"prev_hash" gorm:"column:prev_hash"`
+	RowHash        string    `json:"row_hash" gorm:"column:row_hash"`
+}
+
+// InquiryActivityLogParams filters the activity log. Times are RFC 3339, the
+// range is [start_time, end_time). Paging, sorting and filter[...]
+// parameters are read by the list query.
+type InquiryActivityLogParams struct {
+	UserID         int64  `json:"user_id" query:"user_id" validate:"gte=0"`
+	EmailAddress   string `json:"email_address" query:"email_address"`
+	ServiceCode    string `json:"service_code" query:"service_code"`
+	HTTPStatusCode int    `json:"http_status_code" query:"http_status_code" validate:"omitempty,gte=100,lte=599"`
+	RequestNo      string `json:"request_no" query:"request_no"`
+	TraceID        string `json:"trace_id" query:"trace_id"`
+	StartTime      string `json:"start_time" query:"start_time" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	EndTime        string `json:"end_time" query:"end_time" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+}
+
+// ActivityLogFilter is the parsed form of InquiryActivityLogParams, zero
+// values are ignored
+type ActivityLogFilter struct {
+	UserID         int64
+	EmailAddress   string
+	ServiceCode    string
+	HTTPStatusCode int
+	RequestNo      string
+	TraceID        string
+	StartTime      *time.Time
+	EndTime        *time.Time
+}
+
+// ActivityLogPartition is one monthly partition of activity_log
+type ActivityLogPartition struct {
+	Name  string    `json:"name"`
+	Month time.Time `json:"month"`
+}
+
+// ActivityLogArchiveManifest is stored next to an archived partition and
+// describes its uncompressed content
+type ActivityLogArchiveManifest struct {
+	Partition    string    `json:"partition"`
+	Month        string    `json:"month"`
+	ObjectName   string    `json:"object_name"`
+	Rows         int64     `json:"rows"`
+	SHA256       string    `json:"sha256"`
+	Bytes        int64     `json:"bytes"`
+	ArchivedTime time.Time `json:"archived_time"`
+}
+
+// ActivityLogChainHead is the last link of the hash chain of one day
+type ActivityLogChainHead struct {
+	ChainDate time.Time `json:"chain_date"`
+	LastSeq   int64     `json:"last_seq"`
+	LastHash  string    `json:"last_hash"`
+}
+
+// ActivityLogCheckpoint is a signed copy of a chain link
+type ActivityLogCheckpoint struct {
+	ID          int64     `json:"id"`
+	ChainDate   time.Time `json:"chain_date"`
+	ChainSeq    int64     `json:"chain_seq"`
+	RowHash     string    `json:"row_hash"`
+	KeyID       string    `json:"key_id"`
+	Signature   string    `json:"signature"`
+	CreatedTime time.Time `json:"created_time"`
+}
+
+// AuditVerifyResult is the outcome of walking the activity log hash chains
+type AuditVerifyResult struct {
+	Days         int   `json:"days"`
+	ArchivedDays int   `json:"archived_days"`
+	Rows         int64 `json:"rows"`
+	Checkpoints  int   `json:"checkpoints"`
+	// UnverifiedCheckpoints matched their rows, but their signatures were
+	// not checked for lack of a public key
+	UnverifiedCheckpoints int              `json:"unverified_checkpoints"`
+	BrokenLink            *AuditBrokenLink `json:"broken_link,omitempty"`
+}
+
+// AuditBrokenLink locates the first row that does not match its chain
+type AuditBrokenLink struct {
+	ChainDate     time.Time `json:"chain_date"`
+	ChainSeq      int64     `json:"chain_seq"`
+	ActivityLogID int64     `json:"activity_log_id,omitempty"`
+	Reason        string    `json:"reason"`
 }
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// DataChangeLog represents the data_change_log table, written by the
+// data_change_log trigger. Changes maps each changed column to its old and
+// new value.
+type DataChangeLog struct {
+	ID           int64           `json:"id"`
+	TableName    string          `json:"table_name"`
+	RecordID     string          `json:"record_id"`
+	Operation    string          `json:"operation"`
+	Changes      json.RawMessage `json:"changes"`
+	ActingUserID *string         `json:"acting_user_id"`
+	RequestNo    *string         `json:"request_no"`
+	DBUser       string          `json:"db_user"`
+	CreatedTime  time.Time       `json:"created_time"`
+}
+
+// InquiryDataChangeLogParams selects the history of one record. RecordID is
+// the primary key, comma separated for composite keys.
+type InquiryDataChangeLogParams struct {
+	TableName string `json:"table_name" validate:"required"`
+	RecordID  string `json:"record_id" validate:"required"`
+	Page      int64  `json:"page" query:"page" validate:"gte=0"`
+	Limit     int64  `json:"limit" query:"limit" validate:"gte=0,lte=500"`
+}
@@ -5,4 +5,6 @@ type Pagination struct {
 	Limit   int64 `json:"limit,omitempty"`
 	Page    int64 `json:"page,omitempty"`
 	HasMore bool  `json:"has_more"`
+	// NextCursor continues the list after this page, see listquery
+	NextCursor string `json:"next_cursor,omitempty"`
 }
@@ -0,0 +1,7 @@
+package model
+
+type Role string
+
+const (
+	ROLE_ADMIN_ROOT Role = "ADMIN_ROOT"
+)
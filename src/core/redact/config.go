@@ -0,0 +1,66 @@
+package redact
+
+import (
+	"github.com/spf13/viper"
+)
+
+// DefaultFields are redacted wherever they appear, unless Redaction.Fields is set
+var DefaultFields = []string{
+	"password",
+	"passwd",
+	"new_password",
+	"old_password",
+	"secret",
+	"client_secret",
+	"token",
+	"access_token",
+	"refresh_token",
+	"id_token",
+	"api_key",
+	"authorization",
+	"cookie",
+	"set-cookie",
+	"national_id",
+	"citizen_id",
+	"id_card_no",
+}
+
+// DefaultPatterns match Thai national ID numbers written with dashes. Bare
+// 13-digit numbers are left alone, as millisecond timestamps look the same;
+// fields such as national_id are masked by name.
+var DefaultPatterns = []string{
+	`\b\d-\d{4}-\d{5}-\d{2}-\d\b`,
+}
+
+const DefaultMask = "***"
+
+type Config struct {
+	Rules
+	Mask string
+}
+
+func InitConfig() (*Config, error) {
+	config := &Config{
+		Rules: Rules{
+			Fields:   viper.GetStringSlice("Redaction.Fields"),
+			Paths:    viper.GetStringSlice("Redaction.Paths"),
+			Patterns: viper.GetStringSlice("Redaction.Patterns"),
+		},
+		Mask: viper.GetString("Redaction.Mask"),
+	}
+
+	if len(config.Fields) == 0 {
+		config.Fields = DefaultFields
+	}
+	config.Fields = append(config.Fields, viper.GetStringSlice("Redaction.ExtraFields")...)
+
+	if len(config.Patterns) == 0 {
+		config.Patterns = DefaultPatterns
+	}
+
+	if config.Mask == "" {
+		config.Mask = DefaultMask
+	}
+
+	return config, nil
+}
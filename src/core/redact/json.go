@@ -0,0 +1,106 @@
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// object is a JSON object in document order, so a redacted body keeps the
+// key order of the original
+type object []member
+
+type member struct {
+	key   string
+	value interface{}
+}
+
+// decodeOrdered decodes the next value of decoder into objects,
+// []interface{}, strings, json.Number, bools and nil
+func decodeOrdered(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return token, nil
+	}
+
+	switch delim {
+	case '{':
+		o := make(object, 0)
+		for decoder.More() {
+			token, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, ok := token.(string)
+			if !ok {
+				return nil, fmt.Errorf("object key %v is not a string", token)
+			}
+			value, err := decodeOrdered(decoder)
+			if err != nil {
+				return nil, err
+			}
+			o = append(o, member{key: key, value: value})
+		}
+		// the closing brace
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		return o, nil
+
+	case '[':
+		a := make([]interface{}, 0)
+		for decoder.More() {
+			value, err := decodeOrdered(decoder)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, value)
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		return a, nil
+	}
+
+	return nil, fmt.Errorf("unexpected %v", delim)
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, err := encodeJSON(m.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := encodeJSON(m.value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// encodeJSON marshals value without escaping <, > and &, which the bodies
+// being redacted did not escape either
+func encodeJSON(value interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+}
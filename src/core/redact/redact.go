@@ -0,0 +1,281 @@
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Rules describe what to redact. Field names match anywhere in a document
+// and ignore case, "-" and "_" (so "accessToken" matches "access_token").
+// Paths are dotted JSON paths from the document root where "*" matches any
+// key or array index, e.g. "data.users.*.phone". Patterns are regular
+// expressions replaced inside string values.
+type Rules struct {
+	Fields   []string
+	Paths    []string
+	Patterns []string
+
+	// OmitRequestBody and OmitResponseBody replace the whole body with the
+	// mask, for routes whose payloads must never be stored
+	OmitRequestBody  bool
+	OmitResponseBody bool
+}
+
+// Redactor applies Rules to bodies, headers and logger fields
+type Redactor struct {
+	mask     string
+	rules    Rules
+	fields   map[string]struct{}
+	paths    [][]string
+	patterns []*regexp.Regexp
+	formPair *regexp.Regexp
+}
+
+func New(config *Config) (*Redactor, error) {
+	return newRedactor(config.Mask, config.Rules)
+}
+
+func newRedactor(mask string, rules Rules) (*Redactor, error) {
+	r := &Redactor{
+		mask:   mask,
+		rules:  rules,
+		fields: make(map[string]struct{}),
+	}
+
+	names := make([]string, 0, len(rules.Fields))
+	for _, field := range rules.Fields {
+		if field == "" {
+			continue
+		}
+		r.fields[normalizeField(field)] = struct{}{}
+		names = append(names, regexp.QuoteMeta(field))
+	}
+
+	for _, path := range rules.Paths {
+		if path == "" {
+			continue
+		}
+		r.paths = append(r.paths, strings.Split(strings.TrimPrefix(path, "$."), "."))
+	}
+
+	for _, pattern := range rules.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid redaction pattern %q", pattern)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+
+	// key=value pairs in form bodies and query strings
+	if len(names) > 0 {
+		r.formPair = regexp.MustCompile(`(?i)\b(` + strings.Join(names, "|") + `)=[^&\s]*`)
+	}
+
+	return r, nil
+}
+
+// WithRules returns a Redactor applying both r's rules and the given
+// overrides, e.g. those declared for a route. It compiles every pattern
+// again, so keep the result rather than calling it per request.
+func (r *Redactor) WithRules(overrides Rules) *Redactor {
+	if r == nil {
+		return nil
+	}
+
+	merged := Rules{
+		Fields:           append(append([]string{}, r.rules.Fields...), overrides.Fields...),
+		Paths:            append(append([]string{}, r.rules.Paths...), overrides.Paths...),
+		Patterns:         append(append([]string{}, r.rules.Patterns...), overrides.Patterns...),
+		OmitRequestBody:  r.rules.OmitRequestBody || overrides.OmitRequestBody,
+		OmitResponseBody: r.rules.OmitResponseBody || overrides.OmitResponseBody,
+	}
+
+	redactor, err := newRedactor(r.mask, merged)
+	if err != nil {
+		// invalid override patterns are ignored, base rules still apply
+		return r
+	}
+	return redactor
+}
+
+func normalizeField(field string) string {
+	field = strings.ToLower(field)
+	field = strings.ReplaceAll(field, "_", "")
+	field = strings.ReplaceAll(field, "-", "")
+	return field
+}
+
+func (r *Redactor) isSensitiveField(field string) bool {
+	_, ok := r.fields[normalizeField(field)]
+	return ok
+}
+
+// RequestBody redacts a request body, honouring OmitRequestBody
+func (r *Redactor) RequestBody(body []byte) []byte {
+	if r == nil {
+		return body
+	}
+	if r.rules.OmitRequestBody && len(body) > 0 {
+		return []byte(r.mask)
+	}
+	return r.Body(body)
+}
+
+// ResponseBody redacts a response body, honouring OmitResponseBody
+func (r *Redactor) ResponseBody(body []byte) []byte {
+	if r == nil {
+		return body
+	}
+	if r.rules.OmitResponseBody && len(body) > 0 {
+		return []byte(r.mask)
+	}
+	return r.Body(body)
+}
+
+// Body redacts a JSON document, keeping its key order and text as they
+// were. Anything that is not JSON is treated as text: sensitive key=value
+// pairs and patterns are masked.
+func (r *Redactor) Body(body []byte) []byte {
+	if r == nil || len(body) == 0 {
+		return body
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	document, err := decodeOrdered(decoder)
+	if err != nil || decoder.More() {
+		return []byte(r.Text(string(body)))
+	}
+
+	redacted, err := encodeJSON(r.value(document, nil))
+	if err != nil {
+		return []byte(r.mask)
+	}
+	return redacted
+}
+
+// Text masks sensitive key=value pairs and patterns in free text
+func (r *Redactor) Text(text string) string {
+	if r == nil {
+		return text
+	}
+
+	if r.formPair != nil {
+		text = r.formPair.ReplaceAllString(text, "${1}="+r.mask)
+	}
+	for _, pattern := range r.patterns {
+		text = pattern.ReplaceAllString(text, r.mask)
+	}
+	return text
+}
+
+// Headers returns a copy of headers with sensitive values masked
+func (r *Redactor) Headers(headers map[string]string) map[string]string {
+	redacted := make(map[string]string, len(headers))
+	for key, value := range headers {
+		if r != nil && r.isSensitiveField(key) {
+			redacted[key] = r.mask
+			continue
+		}
+		redacted[key] = r.Text(value)
+	}
+	return redacted
+}
+
+// Fields returns a copy of logger fields with sensitive values masked
+func (r *Redactor) Fields(fields map[string]interface{}) map[string]interface{} {
+	if r == nil {
+		return fields
+	}
+
+	redacted := make(map[string]interface{}, len(fields))
+	for key, value := range fields {
+		redacted[key] = r.field(key, value)
+	}
+	return redacted
+}
+
+func (r *Redactor) field(key string, value interface{}) interface{} {
+	if r.isSensitiveField(key) {
+		return r.mask
+	}
+
+	switch v := value.(type) {
+	case string:
+		return r.Text(v)
+	case []byte:
+		return string(r.Body(v))
+	case map[string]interface{}:
+		return r.value(v, nil)
+	}
+	return value
+}
+
+// value returns a redacted copy of value; maps and slices are copied, never
+// changed in place, since they belong to the caller
+func (r *Redactor) value(value interface{}, path []string) interface{} {
+	if r.matchesPath(path) {
+		return r.mask
+	}
+
+	switch v := value.(type) {
+	case object:
+		redacted := make(object, 0, len(v))
+		for _, m := range v {
+			if r.isSensitiveField(m.key) {
+				redacted = append(redacted, member{key: m.key, value: r.mask})
+				continue
+			}
+			redacted = append(redacted, member{key: m.key, value: r.value(m.value, append(path, m.key))})
+		}
+		return redacted
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			if r.isSensitiveField(key) {
+				redacted[key] = r.mask
+				continue
+			}
+			redacted[key] = r.value(child, append(path, key))
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, child := range v {
+			redacted[i] = r.value(child, append(path, "*"))
+		}
+		return redacted
+	case string:
+		return r.Text(v)
+	}
+	return value
+}
+
+func (r *Redactor) matchesPath(path []string) bool {
+	if len(path) == 0 {
+		return false
+	}
+
+	for _, candidate := range r.paths {
+		if len(candidate) != len(path) {
+			continue
+		}
+
+		matched := true
+		for i := range candidate {
+			if candidate[i] != "*" && candidate[i] != path[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
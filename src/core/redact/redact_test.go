@@ -0,0 +1,95 @@
+package redact
+
+import (
+	"testing"
+)
+
+func newTestRedactor(t *testing.T) *Redactor {
+	t.Helper()
+
+	r, err := New(&Config{
+		Rules: Rules{Fields: DefaultFields, Patterns: DefaultPatterns},
+		Mask:  DefaultMask,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestBody(t *testing.T) {
+	r := newTestRedactor(t)
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "keeps key order",
+			body: `{"z":1,"password":"x","a":{"token":"t","b":2}}`,
+			want: `{"z":1,"password":"***","a":{"token":"***","b":2}}`,
+		},
+		{
+			name: "does not escape html",
+			body: `{"url":"/a?b=1&c=<d>"}`,
+			want: `{"url":"/a?b=1&c=<d>"}`,
+		},
+		{
+			name: "keeps millisecond timestamps",
+			body: `{"path":"/files/1735689600000","at":1735689600000}`,
+			want: `{"path":"/files/1735689600000","at":1735689600000}`,
+		},
+		{
+			name: "masks dashed national ids",
+			body: `["id 1-2345-67890-12-3"]`,
+			want: `["id ***"]`,
+		},
+		{
+			name: "masks form pairs in text",
+			body: `user=a&password=secret`,
+			want: `user=a&password=***`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(r.Body([]byte(tt.body))); got != tt.want {
+				t.Errorf("Body(%s) = %s, want %s", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldsDoesNotModifyFields(t *testing.T) {
+	r := newTestRedactor(t)
+
+	nested := map[string]interface{}{"token": "t", "list": []interface{}{"a"}}
+	fields := map[string]interface{}{"request": nested}
+
+	redacted := r.Fields(fields)
+
+	if nested["token"] != "t" {
+		t.Errorf("Fields changed the caller's map: %v", nested)
+	}
+	got := redacted["request"].(map[string]interface{})
+	if got["token"] != DefaultMask {
+		t.Errorf("Fields did not mask the nested token: %v", got)
+	}
+}
+
+func TestWithRules(t *testing.T) {
+	r := newTestRedactor(t)
+
+	route := r.WithRules(Rules{Paths: []string{"data.*.phone"}, OmitResponseBody: true})
+
+	if got := string(route.RequestBody([]byte(`{"data":[{"phone":"1","name":"n"}]}`))); got != `{"data":[{"phone":"***","name":"n"}]}` {
+		t.Errorf("RequestBody = %s", got)
+	}
+	if got := string(route.ResponseBody([]byte(`{"a":1}`))); got != DefaultMask {
+		t.Errorf("ResponseBody = %s", got)
+	}
+	if got := string(r.ResponseBody([]byte(`{"a":1}`))); got != `{"a":1}` {
+		t.Errorf("WithRules changed the base redactor: %s", got)
+	}
+}
@@ -0,0 +1,81 @@
+package seed
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	ActionInsert    = "insert"
+	ActionUpdate    = "update"
+	ActionUnchanged = "unchanged"
+	// ActionSkipped is a table with if_exists that does not exist
+	ActionSkipped = "skipped"
+)
+
+// Change is what seeding did, or would do on a dry run, to one row
+type Change struct {
+	Table string `json:"table"`
+	// Key is the key of the row, e.g. "email_address=admin@example.com"
+	Key     string          `json:"key,omitempty"`
+	Action  string          `json:"action"`
+	Columns []*ColumnChange `json:"columns,omitempty"`
+}
+
+// ColumnChange holds the text form of a column before and after, Old is nil
+// for inserts
+type ColumnChange struct {
+	Column string  `json:"column"`
+	Old    *string `json:"old,omitempty"`
+	New    *string `json:"new"`
+}
+
+// WriteDiff writes changes as a diff: "+" inserted rows, "~" updated rows
+// with their changed columns, "=" unchanged rows and "!" skipped tables
+func WriteDiff(w io.Writer, changes []*Change) {
+	for _, change := range changes {
+		switch change.Action {
+		case ActionInsert:
+			fmt.Fprintf(w, "+ %s %s\n", change.Table, change.Key)
+			for _, column := range change.Columns {
+				fmt.Fprintf(w, "    %s: %s\n", column.Column, text(column.New))
+			}
+		case ActionUpdate:
+			fmt.Fprintf(w, "~ %s %s\n", change.Table, change.Key)
+			for _, column := range change.Columns {
+				fmt.Fprintf(w, "    %s: %s -> %s\n", column.Column, text(column.Old), text(column.New))
+			}
+		case ActionUnchanged:
+			fmt.Fprintf(w, "= %s %s\n", change.Table, change.Key)
+		case ActionSkipped:
+			fmt.Fprintf(w, "! %s does not exist, skipped\n", change.Table)
+		}
+	}
+}
+
+// Summary counts the changes by action, e.g. "2 insert, 1 update, 5 unchanged"
+func Summary(changes []*Change) string {
+	counts := make(map[string]int)
+	for _, change := range changes {
+		counts[change.Action]++
+	}
+
+	parts := make([]string, 0)
+	for _, action := range []string{ActionInsert, ActionUpdate, ActionUnchanged, ActionSkipped} {
+		if counts[action] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[action], action))
+		}
+	}
+	if len(parts) == 0 {
+		return "nothing to seed"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func text(value *string) string {
+	if value == nil {
+		return "NULL"
+	}
+	return fmt.Sprintf("%q", *value)
+}
@@ -0,0 +1,350 @@
+package seed
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed fixtures
+var defaultFixtures embed.FS
+
+// Fixture is one YAML or JSON fixture file:
+//
+//	profiles: [dev, uat]
+//	tables:
+//	  - table: users
+//	    key: [email_address]
+//	    if_exists: true
+//	    rows:
+//	      - email_address: admin@example.com
+//	        role_id: {$ref: roles, name: ADMIN_ROOT}
+//
+// A value {$ref: table, column: value, ...} is the id of the row of table
+// matching every other entry; $column selects another column than id.
+type Fixture struct {
+	// Profiles the file applies to, every profile when empty
+	Profiles []string `json:"profiles" yaml:"profiles"`
+	Tables   []*Table `json:"tables" yaml:"tables"`
+
+	name string
+}
+
+type Table struct {
+	Table string `json:"table" yaml:"table"`
+	// Key lists the columns identifying a row; rows are updated when a row
+	// with the same key exists and inserted otherwise
+	Key []string `json:"key" yaml:"key"`
+	// DependsOn lists tables seeded before this one, in addition to the
+	// tables referenced with $ref
+	DependsOn []string `json:"depends_on" yaml:"depends_on"`
+	// IfExists skips the table when it does not exist, for tables created
+	// outside this service's migrations
+	IfExists bool             `json:"if_exists" yaml:"if_exists"`
+	Rows     []map[string]any `json:"rows" yaml:"rows"`
+}
+
+// Ref is a $ref value
+type Ref struct {
+	Table  string
+	Column string
+	Where  map[string]any
+}
+
+// AsRef returns the reference value is, if any
+func AsRef(value any) (*Ref, bool, error) {
+	object, ok := value.(map[string]any)
+	if !ok {
+		return nil, false, nil
+	}
+	table, ok := object["$ref"].(string)
+	if !ok {
+		return nil, false, nil
+	}
+
+	ref := &Ref{
+		Table:  table,
+		Column: "id",
+		Where:  make(map[string]any),
+	}
+	for key, value := range object {
+		switch key {
+		case "$ref":
+		case "$column":
+			column, ok := value.(string)
+			if !ok {
+				return nil, true, fmt.Errorf("$column of a reference to %s must be a string", table)
+			}
+			ref.Column = column
+		default:
+			ref.Where[key] = value
+		}
+	}
+	if len(ref.Where) == 0 {
+		return nil, true, fmt.Errorf("reference to %s has no column to match", table)
+	}
+
+	return ref, true, nil
+}
+
+// Load reads the embedded default fixtures, unless skipDefaults, and the
+// fixture files and directories of paths, keeps those for profile and
+// returns their tables merged by name in dependency order
+func Load(paths []string, profile string, skipDefaults bool) ([]*Table, error) {
+	fixtures := make([]*Fixture, 0)
+
+	if !skipDefaults {
+		defaults, err := readDir(defaultFixtures, "fixtures")
+		if err != nil {
+			return nil, err
+		}
+		fixtures = append(fixtures, defaults...)
+	}
+
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read fixtures")
+		}
+
+		if info.IsDir() {
+			dirFixtures, err := readDir(os.DirFS(p), ".")
+			if err != nil {
+				return nil, err
+			}
+			fixtures = append(fixtures, dirFixtures...)
+			continue
+		}
+
+		fixture, err := readFile(os.DirFS(filepath.Dir(p)), filepath.Base(p))
+		if err != nil {
+			return nil, err
+		}
+		fixtures = append(fixtures, fixture)
+	}
+
+	return merge(fixtures, profile)
+}
+
+func readDir(fsys fs.FS, dir string) ([]*Fixture, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read fixtures")
+	}
+
+	fixtures := make([]*Fixture, 0)
+	for _, entry := range entries {
+		if entry.IsDir() || !isFixtureFile(entry.Name()) {
+			continue
+		}
+		fixture, err := readFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		fixtures = append(fixtures, fixture)
+	}
+	return fixtures, nil
+}
+
+func isFixtureFile(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+func readFile(fsys fs.FS, name string) (*Fixture, error) {
+	content, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read fixture %s", name)
+	}
+
+	fixture := &Fixture{name: name}
+	if strings.ToLower(path.Ext(name)) == ".json" {
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.UseNumber()
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(fixture)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		err = decoder.Decode(fixture)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid fixture %s", name)
+	}
+
+	for _, table := range fixture.Tables {
+		if table.Table == "" {
+			return nil, fmt.Errorf("fixture %s: table name is required", name)
+		}
+		if len(table.Key) == 0 {
+			return nil, fmt.Errorf("fixture %s: table %s has no key", name, table.Table)
+		}
+		for i, row := range table.Rows {
+			for _, key := range table.Key {
+				if _, ok := row[key]; !ok {
+					return nil, fmt.Errorf("fixture %s: row %d of %s has no key column %s", name, i+1, table.Table, key)
+				}
+			}
+			for column, value := range row {
+				if _, _, err := AsRef(value); err != nil {
+					return nil, fmt.Errorf("fixture %s: row %d of %s, column %s: %s", name, i+1, table.Table, column, err)
+				}
+			}
+		}
+	}
+
+	return fixture, nil
+}
+
+func (fixture *Fixture) appliesTo(profile string) bool {
+	if len(fixture.Profiles) == 0 {
+		return true
+	}
+	for _, p := range fixture.Profiles {
+		if p == profile {
+			return true
+		}
+	}
+	return false
+}
+
+// merge joins the tables of the fixtures for profile, later rows of the
+// same key replacing earlier ones, and orders them so referenced tables
+// come first
+func merge(fixtures []*Fixture, profile string) ([]*Table, error) {
+	tables := make(map[string]*Table)
+	names := make([]string, 0)
+
+	for _, fixture := range fixtures {
+		if !fixture.appliesTo(profile) {
+			continue
+		}
+
+		for _, table := range fixture.Tables {
+			merged, ok := tables[table.Table]
+			if !ok {
+				merged = &Table{
+					Table:    table.Table,
+					Key:      table.Key,
+					IfExists: table.IfExists,
+				}
+				tables[table.Table] = merged
+				names = append(names, table.Table)
+			}
+
+			if strings.Join(merged.Key, ",") != strings.Join(table.Key, ",") {
+				return nil, fmt.Errorf("fixture %s: table %s has key %v, other fixtures use %v", fixture.name, table.Table, table.Key, merged.Key)
+			}
+			merged.IfExists = merged.IfExists && table.IfExists
+			merged.DependsOn = append(merged.DependsOn, table.DependsOn...)
+
+			for _, row := range table.Rows {
+				merged.Rows = replaceRow(merged.Rows, merged.Key, row)
+			}
+		}
+	}
+
+	return order(tables, names)
+}
+
+func replaceRow(rows []map[string]any, key []string, row map[string]any) []map[string]any {
+	for i, existing := range rows {
+		same := true
+		for _, column := range key {
+			if fmt.Sprint(existing[column]) != fmt.Sprint(row[column]) {
+				same = false
+				break
+			}
+		}
+		if same {
+			rows[i] = row
+			return rows
+		}
+	}
+	return append(rows, row)
+}
+
+// order sorts tables topologically by DependsOn and $ref, keeping the file
+// order otherwise
+func order(tables map[string]*Table, names []string) ([]*Table, error) {
+	dependencies := make(map[string][]string)
+	for _, name := range names {
+		table := tables[name]
+		seen := make(map[string]bool)
+		add := func(dependency string) {
+			if dependency != name && !seen[dependency] {
+				seen[dependency] = true
+				dependencies[name] = append(dependencies[name], dependency)
+			}
+		}
+
+		for _, dependency := range table.DependsOn {
+			add(dependency)
+		}
+		for _, row := range table.Rows {
+			columns := make([]string, 0, len(row))
+			for column := range row {
+				columns = append(columns, column)
+			}
+			sort.Strings(columns)
+			for _, column := range columns {
+				if ref, ok, _ := AsRef(row[column]); ok {
+					add(ref.Table)
+				}
+			}
+		}
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int)
+	result := make([]*Table, 0, len(names))
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("fixtures reference each other in a cycle: %s", strings.Join(append(path, name), " -> "))
+		}
+
+		state[name] = visiting
+		for _, dependency := range dependencies[name] {
+			// references to tables without fixtures are resolved from
+			// existing rows
+			if _, ok := tables[dependency]; !ok {
+				continue
+			}
+			if err := visit(dependency, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		result = append(result, tables[name])
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,251 @@
+package seed
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestAsRef(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   any
+		want    *Ref
+		isRef   bool
+		wantErr bool
+	}{
+		{name: "scalar", value: "ADMIN_ROOT"},
+		{name: "object without $ref", value: map[string]any{"name": "ADMIN_ROOT"}},
+		{name: "non-string $ref", value: map[string]any{"$ref": 1, "name": "ADMIN_ROOT"}},
+		{
+			name:  "id of the matching row",
+			value: map[string]any{"$ref": "roles", "name": "ADMIN_ROOT"},
+			want:  &Ref{Table: "roles", Column: "id", Where: map[string]any{"name": "ADMIN_ROOT"}},
+			isRef: true,
+		},
+		{
+			name:  "other column",
+			value: map[string]any{"$ref": "roles", "$column": "name", "name": "ADMIN_ROOT", "active": true},
+			want:  &Ref{Table: "roles", Column: "name", Where: map[string]any{"name": "ADMIN_ROOT", "active": true}},
+			isRef: true,
+		},
+		{name: "nothing to match", value: map[string]any{"$ref": "roles"}, isRef: true, wantErr: true},
+		{name: "non-string $column", value: map[string]any{"$ref": "roles", "$column": 1, "name": "x"}, isRef: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref, isRef, err := AsRef(tt.value)
+			if isRef != tt.isRef {
+				t.Errorf("AsRef() is a reference = %v, want %v", isRef, tt.isRef)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AsRef() error = %v, want an error: %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(ref, tt.want) {
+				t.Errorf("AsRef() = %+v, want %+v", ref, tt.want)
+			}
+		})
+	}
+}
+
+func tableNames(tables []*Table) string {
+	names := make([]string, 0, len(tables))
+	for _, table := range tables {
+		names = append(names, table.Table)
+	}
+	return strings.Join(names, ",")
+}
+
+func ref(table string) map[string]any {
+	return map[string]any{"$ref": table, "name": "x"}
+}
+
+func TestMerge(t *testing.T) {
+	fixtures := []*Fixture{
+		{
+			name: "base.yaml",
+			Tables: []*Table{
+				{Table: "roles", Key: []string{"name"}, IfExists: true, Rows: []map[string]any{
+					{"name": "ADMIN", "level": 1},
+					{"name": "USER", "level": 2},
+				}},
+			},
+		},
+		{
+			name:     "dev.yaml",
+			Profiles: []string{"dev"},
+			Tables: []*Table{
+				{Table: "roles", Key: []string{"name"}, Rows: []map[string]any{
+					{"name": "ADMIN", "level": 9},
+					{"name": "DEV", "level": 3},
+				}},
+			},
+		},
+		{
+			name:     "prod.yaml",
+			Profiles: []string{"prod"},
+			Tables: []*Table{
+				{Table: "roles", Key: []string{"name"}, Rows: []map[string]any{{"name": "PROD"}}},
+			},
+		},
+	}
+
+	tables, err := merge(fixtures, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 1 {
+		t.Fatalf("merge() = %d tables, want 1", len(tables))
+	}
+
+	roles := tables[0]
+	want := []map[string]any{
+		{"name": "ADMIN", "level": 9},
+		{"name": "USER", "level": 2},
+		{"name": "DEV", "level": 3},
+	}
+	if !reflect.DeepEqual(roles.Rows, want) {
+		t.Errorf("merged rows = %v, want %v", roles.Rows, want)
+	}
+	if roles.IfExists {
+		t.Error("IfExists must hold only when every fixture of the table sets it")
+	}
+
+	tables, err = merge(fixtures, "uat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(tables[0].Rows); got != 2 {
+		t.Errorf("uat profile merged %d rows, want the 2 of the profile-less fixture", got)
+	}
+
+	_, err = merge([]*Fixture{
+		{name: "a.yaml", Tables: []*Table{{Table: "roles", Key: []string{"name"}}}},
+		{name: "b.yaml", Tables: []*Table{{Table: "roles", Key: []string{"id"}}}},
+	}, "dev")
+	if err == nil {
+		t.Error("merge() accepted the same table with different keys")
+	}
+}
+
+func TestOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		tables  []*Table
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "file order without dependencies",
+			tables: []*Table{
+				{Table: "b"}, {Table: "a"}, {Table: "c"},
+			},
+			want: "b,a,c",
+		},
+		{
+			name: "referenced table first",
+			tables: []*Table{
+				{Table: "users", Rows: []map[string]any{{"role_id": ref("roles")}}},
+				{Table: "roles"},
+			},
+			want: "roles,users",
+		},
+		{
+			name: "depends_on and transitive references",
+			tables: []*Table{
+				{Table: "user_roles", Rows: []map[string]any{{"user_id": ref("users"), "role_id": ref("roles")}}},
+				{Table: "users", DependsOn: []string{"departments"}},
+				{Table: "roles"},
+				{Table: "departments"},
+			},
+			want: "roles,departments,users,user_roles",
+		},
+		{
+			name: "reference to a table without fixtures",
+			tables: []*Table{
+				{Table: "users", Rows: []map[string]any{{"role_id": ref("roles")}}},
+			},
+			want: "users",
+		},
+		{
+			name: "self reference",
+			tables: []*Table{
+				{Table: "departments", Rows: []map[string]any{{"parent_id": ref("departments")}}},
+			},
+			want: "departments",
+		},
+		{
+			name: "cycle",
+			tables: []*Table{
+				{Table: "a", DependsOn: []string{"b"}},
+				{Table: "b", Rows: []map[string]any{{"c_id": ref("c")}}},
+				{Table: "c", DependsOn: []string{"a"}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tables := make(map[string]*Table)
+			names := make([]string, 0, len(tt.tables))
+			for _, table := range tt.tables {
+				tables[table.Table] = table
+				names = append(names, table.Table)
+			}
+
+			result, err := order(tables, names)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "cycle") {
+					t.Errorf("order() = %s, %v, want a cycle error", tableNames(result), err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tableNames(result); got != tt.want {
+				t.Errorf("order() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	fsys := fstest.MapFS{
+		"roles.yaml":           {Data: []byte("tables:\n  - table: roles\n    key: [name]\n    rows:\n      - name: ADMIN\n")},
+		"roles.json":           {Data: []byte(`{"profiles": ["dev"], "tables": [{"table": "roles", "key": ["name"], "rows": [{"name": "ADMIN", "level": 1}]}]}`)},
+		"unknown_field.yaml":   {Data: []byte("tables:\n  - table: roles\n    key: [name]\n    ifexists: true\n")},
+		"no_key.yaml":          {Data: []byte("tables:\n  - table: roles\n    rows:\n      - name: ADMIN\n")},
+		"row_without_key.yaml": {Data: []byte("tables:\n  - table: roles\n    key: [name]\n    rows:\n      - level: 1\n")},
+		"bad_ref.yaml":         {Data: []byte("tables:\n  - table: users\n    key: [email]\n    rows:\n      - email: a@example.com\n        role_id: {$ref: roles}\n")},
+	}
+
+	for _, name := range []string{"roles.yaml", "roles.json"} {
+		if _, err := readFile(fsys, name); err != nil {
+			t.Errorf("readFile(%s): %v", name, err)
+		}
+	}
+	for _, name := range []string{"unknown_field.yaml", "no_key.yaml", "row_without_key.yaml", "bad_ref.yaml"} {
+		if _, err := readFile(fsys, name); err == nil {
+			t.Errorf("readFile(%s) accepted an invalid fixture", name)
+		}
+	}
+}
+
+// the embedded defaults are installed on any database seed-db runs against
+func TestDefaultsHoldNoApiKeys(t *testing.T) {
+	for _, profile := range []string{"dev", "uat", "prod"} {
+		tables, err := Load(nil, profile, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, table := range tables {
+			if table.Table == "api_keys" {
+				t.Errorf("the embedded defaults of profile %s seed api_keys", profile)
+			}
+		}
+	}
+}
@@ -2,10 +2,12 @@ package utils
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/sha512"
 	"crypto/x509"
 	"encoding/csv"
@@ -488,3 +490,15 @@ func IsValidEmail(email string) bool {
 	// Use the MatchString method to check if the email matches the pattern
 	return re.MatchString(email)
 }
+
+// SignWithPrivateKey signs the SHA-256 digest of data with RSA-PSS
+func SignWithPrivateKey(data []byte, priv *rsa.PrivateKey) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	return rsa.SignPSS(rand.Reader, priv, crypto.SHA256, digest[:], nil)
+}
+
+// VerifyWithPublicKey checks a signature made by SignWithPrivateKey
+func VerifyWithPublicKey(data []byte, signature []byte, pub *rsa.PublicKey) error {
+	digest := sha256.Sum256(data)
+	return rsa.VerifyPSS(pub, crypto.SHA256, digest[:], signature, nil)
+}
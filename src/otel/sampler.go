@@ -0,0 +1,40 @@
+package otel
+
+import (
+	"context"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+type contextKey string
+
+const contextKeyAdoptedTrace contextKey = "AdoptedTrace"
+
+// ContextWithAdoptedTrace marks the traceparent of a request as built from
+// its request number rather than sent by an upstream tracer, so its unsampled
+// flag means no decision was made yet
+func ContextWithAdoptedTrace(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextKeyAdoptedTrace, true)
+}
+
+// adoptedTraceSampler lets root decide for adopted traces and, like
+// ParentBased, drops the other unsampled remote parents
+type adoptedTraceSampler struct {
+	root sdktrace.Sampler
+}
+
+func (s adoptedTraceSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if adopted, _ := p.ParentContext.Value(contextKeyAdoptedTrace).(bool); adopted {
+		return s.root.ShouldSample(p)
+	}
+	return sdktrace.NeverSample().ShouldSample(p)
+}
+
+func (s adoptedTraceSampler) Description() string {
+	return "AdoptedTraceSampler{" + s.root.Description() + "}"
+}
+
+func newSampler() sdktrace.Sampler {
+	root := sdktrace.AlwaysSample()
+	return sdktrace.ParentBased(root, sdktrace.WithRemoteParentNotSampled(adoptedTraceSampler{root: root}))
+}
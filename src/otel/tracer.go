@@ -34,6 +34,7 @@ func Init(ctx context.Context) (*sdktrace.TracerProvider, error) {
 
 	tp := sdktrace.NewTracerProvider(
 		sdktrace.WithBatcher(exp),
+		sdktrace.WithSampler(newSampler()),
 		sdktrace.WithResource(resource.NewWithAttributes(
 			semconv.SchemaURL,
 			semconv.ServiceNameKey.String(trackerName),
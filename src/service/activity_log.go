@@ -0,0 +1,81 @@
+package service
+
+import (
+	"time"
+
+	"go-template/src/core/listquery"
+	"go-template/src/core/model"
+	"go-template/src/custom_error"
+)
+
+// activityLogListSchema is what GET /admin/activity-logs accepts in
+// filter[...] and sort
+var activityLogListSchema = &listquery.Schema{
+	Fields: map[string]listquery.Field{
+		"id":               {Column: "id", Type: listquery.Int, Sortable: true},
+		"user_id":          {Column: "user_id", Type: listquery.Int},
+		"email_address":    {Column: "email_address", Type: listquery.String},
+		"service_code":     {Column: "service_code", Type: listquery.String},
+		"http_status_code": {Column: "http_status_code", Type: listquery.Int, Sortable: true},
+		"request_no":       {Column: "request_no", Type: listquery.String},
+		"trace_id":         {Column: "trace_id", Type: listquery.String},
+		"created_time":     {Column: "created_time", Type: listquery.Time, Sortable: true},
+	},
+	DefaultSort:  "-created_time",
+	TieBreaker:   "id",
+	DefaultLimit: 20,
+	MaxLimit:     500,
+}
+
+func (ctx *Context) InquiryActivityLog(params model.InquiryActivityLogParams, request *listquery.Request) ([]*model.ActivityLog, *model.Pagination, error) {
+	logger := ctx.getLogger("InquiryActivityLog")
+	logger.Infof("Begin")
+	defer logger.Infof("End")
+
+	if err := ValidateInput(params); err != nil {
+		logger.Errorf("ValidateInput error : %s", err)
+		return nil, nil, err
+	}
+
+	filter := model.ActivityLogFilter{
+		UserID:         params.UserID,
+		EmailAddress:   params.EmailAddress,
+		ServiceCode:    params.ServiceCode,
+		HTTPStatusCode: params.HTTPStatusCode,
+		RequestNo:      params.RequestNo,
+		TraceID:        params.TraceID,
+	}
+
+	// the validator has already checked the format
+	if params.StartTime != "" {
+		startTime, _ := time.Parse(time.RFC3339, params.StartTime)
+		filter.StartTime = &startTime
+	}
+	if params.EndTime != "" {
+		endTime, _ := time.Parse(time.RFC3339, params.EndTime)
+		filter.EndTime = &endTime
+	}
+	if filter.StartTime != nil && filter.EndTime != nil && !filter.StartTime.Before(*filter.EndTime) {
+		return nil, nil, &custom_error.ValidationError{
+			Code:    custom_error.InvalidParameter,
+			Message: "start_time must be before end_time",
+		}
+	}
+
+	list, err := activityLogListSchema.Compile(request)
+	if err != nil {
+		logger.Errorf("Compile list error : %s", err)
+		return nil, nil, err
+	}
+
+	result, pagination, err := ctx.DB.InquiryActivityLog(ctx.DBContext(), filter, list)
+	if err != nil {
+		logger.Errorf("InquiryActivityLog error : %s", err)
+		return nil, nil, &custom_error.InternalError{
+			Code:    custom_error.DBError,
+			Message: err.Error(),
+		}
+	}
+
+	return result, pagination, nil
+}
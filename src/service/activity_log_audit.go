@@ -0,0 +1,291 @@
+package service
+
+import (
+	"compress/gzip"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"go-template/src/core/audit"
+	"go-template/src/core/minio"
+	"go-template/src/core/model"
+	"go-template/src/core/utils"
+)
+
+// CheckpointActivityLog signs the head of every activity log chain that
+// moved since its last checkpoint
+func (ctx *Context) CheckpointActivityLog() {
+	logger := ctx.getLogger("CheckpointActivityLog")
+	logger.Infof("Begin")
+	defer logger.Infof("End")
+
+	if ctx.Config.ActivityLogSigningKeyPath == "" {
+		logger.Warnf("Database.ActivityLog.Checkpoint.PrivateKeyPath is not set, activity log checkpoints are not signed")
+		return
+	}
+
+	privateKey, err := utils.ReadRSAPrivateKey(ctx.Config.ActivityLogSigningKeyPath)
+	if err != nil || privateKey == nil {
+		logger.Errorf("Unable to read activity log signing key: %+v", err)
+		return
+	}
+
+	heads, err := ctx.DB.ListUncheckpointedActivityLogChainHeads(ctx.DBContext())
+	if err != nil {
+		logger.Errorf("ListUncheckpointedActivityLogChainHeads error: %+v", err)
+		return
+	}
+
+	for _, head := range heads {
+		checkpoint := &model.ActivityLogCheckpoint{
+			ChainDate: head.ChainDate,
+			ChainSeq:  head.LastSeq,
+			RowHash:   head.LastHash,
+		}
+		if err := audit.SignCheckpoint(checkpoint, ctx.Config.ActivityLogSigningKeyID, privateKey); err != nil {
+			logger.Errorf("SignCheckpoint error: %+v", err)
+			return
+		}
+		if err := ctx.DB.CreateActivityLogCheckpoint(ctx.DBContext(), checkpoint); err != nil {
+			logger.Errorf("CreateActivityLogCheckpoint error: %+v", err)
+			return
+		}
+	}
+
+	if len(heads) > 0 {
+		logger.Infof("Signed %d activity log checkpoints", len(heads))
+	}
+}
+
+// VerifyActivityLogChain walks the chains of the days in [from, to] and
+// stops at the first broken link. Zero times leave the range open. Days
+// whose partition has been dropped are checked against its archive in MinIO
+// instead. Without a public key the hash links are still verified, the
+// checkpoints are reported unverified.
+func (ctx *Context) VerifyActivityLogChain(from, to time.Time) (*model.AuditVerifyResult, error) {
+	logger := ctx.getLogger("VerifyActivityLogChain")
+	logger.Infof("Begin")
+	defer logger.Infof("End")
+
+	var publicKey *rsa.PublicKey
+	if ctx.Config.ActivityLogVerifyKeyPath == "" {
+		logger.Warnf("Database.ActivityLog.Checkpoint.PublicKeyPath is not set, checkpoint signatures are not verified")
+	} else {
+		var err error
+		publicKey, err = utils.ReadRSAPublicKey(ctx.Config.ActivityLogVerifyKeyPath)
+		if err != nil || publicKey == nil {
+			return nil, errors.Errorf("unable to read activity log verify key %q: %v", ctx.Config.ActivityLogVerifyKeyPath, err)
+		}
+	}
+
+	heads, err := ctx.DB.ListActivityLogChainHeads(ctx.DBContext(), from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	// a chain head deleted together with its rows leaves its checkpoints
+	checkpointDays, err := ctx.DB.ListActivityLogCheckpointDays(ctx.DBContext(), from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	partitions, err := ctx.DB.ListActivityLogPartitions(ctx.DBContext())
+	if err != nil {
+		return nil, err
+	}
+	var oldestMonth time.Time
+	if len(partitions) > 0 {
+		oldestMonth = partitions[0].Month
+	}
+
+	headByDay := make(map[string]*model.ActivityLogChainHead)
+	days := make([]time.Time, 0, len(heads))
+	for _, head := range heads {
+		headByDay[head.ChainDate.Format(time.DateOnly)] = head
+		days = append(days, head.ChainDate)
+	}
+	for _, day := range checkpointDays {
+		if _, ok := headByDay[day.Format(time.DateOnly)]; !ok {
+			days = append(days, day)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Before(days[j])
+	})
+
+	result := &model.AuditVerifyResult{}
+	// the reason a dropped month can not be trusted, "" when its archive is intact
+	archivedMonths := make(map[string]string)
+	for _, day := range days {
+		var brokenLink *model.AuditBrokenLink
+		head, ok := headByDay[day.Format(time.DateOnly)]
+
+		switch {
+		case !ok:
+			brokenLink = &model.AuditBrokenLink{
+				ChainDate: day,
+				Reason:    "signed checkpoints but no chain head",
+			}
+
+		case !oldestMonth.IsZero() && day.Before(oldestMonth):
+			month := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+			reason, checked := archivedMonths[month.Format("2006-01")]
+			if !checked {
+				reason, err = ctx.checkActivityLogArchive(month)
+				if err != nil {
+					return nil, err
+				}
+				archivedMonths[month.Format("2006-01")] = reason
+			}
+			if reason != "" {
+				brokenLink = &model.AuditBrokenLink{
+					ChainDate: day,
+					Reason:    reason,
+				}
+				break
+			}
+			result.ArchivedDays++
+
+		default:
+			result.Days++
+			brokenLink, err = ctx.verifyActivityLogDay(head, publicKey, result)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if brokenLink != nil {
+			result.BrokenLink = brokenLink
+			logger.Errorf("Activity log chain broken on %s at %d: %s",
+				brokenLink.ChainDate.Format(time.DateOnly), brokenLink.ChainSeq, brokenLink.Reason)
+			return result, nil
+		}
+	}
+
+	return result, nil
+}
+
+// checkActivityLogArchive returns why the archive of the dropped partition
+// of month can not be trusted, or "" when it matches its manifest. Failing
+// to reach MinIO is an error rather than a broken link.
+func (ctx *Context) checkActivityLogArchive(month time.Time) (string, error) {
+	objectName := ctx.activityLogArchiveObjectName(month)
+	manifestBody, err := ctx.MinIO.DownloadFile(ctx.RequestContext(), activityLogManifestObjectName(objectName))
+	if minio.IsNotFound(err) {
+		return fmt.Sprintf("partition of %s dropped without an archive", month.Format("2006-01")), nil
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to read the archive manifest of %s", month.Format("2006-01"))
+	}
+
+	manifest := &model.ActivityLogArchiveManifest{}
+	if err := json.Unmarshal(manifestBody, manifest); err != nil {
+		return fmt.Sprintf("invalid archive manifest of %s: %v", month.Format("2006-01"), err), nil
+	}
+
+	err = ctx.verifyActivityLogArchive(manifest)
+	switch {
+	case err == nil:
+		return "", nil
+	case minio.IsNotFound(err):
+		return fmt.Sprintf("archive of %s is missing, only its manifest is left", month.Format("2006-01")), nil
+	case errors.Is(err, errArchiveMismatch), errors.Is(err, gzip.ErrHeader), errors.Is(err, gzip.ErrChecksum):
+		return err.Error(), nil
+	default:
+		return "", errors.Wrapf(err, "unable to read the archive of %s", month.Format("2006-01"))
+	}
+}
+
+func (ctx *Context) verifyActivityLogDay(head *model.ActivityLogChainHead, publicKey *rsa.PublicKey, result *model.AuditVerifyResult) (*model.AuditBrokenLink, error) {
+	checkpoints, err := ctx.DB.ListActivityLogCheckpoints(ctx.DBContext(), head.ChainDate)
+	if err != nil {
+		return nil, err
+	}
+
+	checkpointBySeq := make(map[int64]*model.ActivityLogCheckpoint)
+	for _, checkpoint := range checkpoints {
+		if publicKey == nil {
+			checkpointBySeq[checkpoint.ChainSeq] = checkpoint
+			continue
+		}
+		if err := audit.VerifyCheckpoint(checkpoint, publicKey); err != nil {
+			return &model.AuditBrokenLink{
+				ChainDate: head.ChainDate,
+				ChainSeq:  checkpoint.ChainSeq,
+				Reason:    fmt.Sprintf("invalid signature on checkpoint %d", checkpoint.ID),
+			}, nil
+		}
+		checkpointBySeq[checkpoint.ChainSeq] = checkpoint
+	}
+
+	var brokenLink *model.AuditBrokenLink
+	var lastSeq int64
+	lastHash := ""
+
+	errStop := errors.New("stop")
+	err = ctx.DB.WalkActivityLogChain(ctx.DBContext(), head.ChainDate, func(activityLog *model.ActivityLog) error {
+		broken := func(reason string) error {
+			brokenLink = &model.AuditBrokenLink{
+				ChainDate:     head.ChainDate,
+				ChainSeq:      activityLog.ChainSeq,
+				ActivityLogID: activityLog.ID,
+				Reason:        reason,
+			}
+			return errStop
+		}
+
+		switch {
+		case activityLog.ChainSeq != lastSeq+1:
+			return broken(fmt.Sprintf("expected row %d, found %d", lastSeq+1, activityLog.ChainSeq))
+		case activityLog.PrevHash != lastHash:
+			return broken("prev_hash does not match the previous row")
+		case audit.Hash(lastHash, activityLog) != activityLog.RowHash:
+			return broken("row content does not match row_hash")
+		}
+
+		if checkpoint, ok := checkpointBySeq[activityLog.ChainSeq]; ok {
+			if checkpoint.RowHash != activityLog.RowHash {
+				return broken(fmt.Sprintf("row_hash differs from signed checkpoint %d", checkpoint.ID))
+			}
+			if publicKey == nil {
+				result.UnverifiedCheckpoints++
+			} else {
+				result.Checkpoints++
+			}
+		}
+
+		lastSeq = activityLog.ChainSeq
+		lastHash = activityLog.RowHash
+		result.Rows++
+		return nil
+	})
+	if err != nil && err != errStop {
+		return nil, err
+	}
+	if brokenLink != nil {
+		return brokenLink, nil
+	}
+
+	if lastSeq != head.LastSeq || lastHash != head.LastHash {
+		return &model.AuditBrokenLink{
+			ChainDate: head.ChainDate,
+			ChainSeq:  lastSeq + 1,
+			Reason:    fmt.Sprintf("chain ends at %d but its head is %d", lastSeq, head.LastSeq),
+		}, nil
+	}
+
+	for seq := range checkpointBySeq {
+		if seq > lastSeq {
+			return &model.AuditBrokenLink{
+				ChainDate: head.ChainDate,
+				ChainSeq:  seq,
+				Reason:    "signed checkpoint points past the end of the chain",
+			}, nil
+		}
+	}
+
+	return nil, nil
+}
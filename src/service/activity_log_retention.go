@@ -0,0 +1,284 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"path"
+	"time"
+
+	"github.com/pkg/errors"
+	"go-template/src/core/model"
+)
+
+// MaintainActivityLog creates the upcoming activity_log partitions and
+// archives the partitions older than the retention to MinIO
+func (ctx *Context) MaintainActivityLog() {
+	logger := ctx.getLogger("MaintainActivityLog")
+	logger.Infof("Begin")
+	defer logger.Infof("End")
+
+	if err := ctx.EnsureActivityLogPartitions(); err != nil {
+		logger.Errorf("EnsureActivityLogPartitions error: %+v", err)
+	}
+
+	if ctx.Config.ActivityLogRetentionMonths <= 0 {
+		return
+	}
+
+	if err := ctx.ArchiveExpiredActivityLog(time.Now()); err != nil {
+		logger.Errorf("ArchiveExpiredActivityLog error: %+v", err)
+	}
+}
+
+// EnsureActivityLogPartitions creates the partitions of the current month and
+// the next Database.ActivityLog.PartitionMonthsAhead months
+func (ctx *Context) EnsureActivityLogPartitions() error {
+	logger := ctx.getLogger("EnsureActivityLogPartitions")
+
+	names, err := ctx.DB.EnsureActivityLogPartitions(ctx.DBContext(), time.Now(), ctx.Config.ActivityLogPartitionMonthsAhead)
+	if err != nil {
+		return err
+	}
+
+	logger.Debugf("Activity log partitions ready: %v", names)
+	return nil
+}
+
+// ArchiveExpiredActivityLog archives and drops every partition whose month
+// ended more than Database.ActivityLog.RetentionMonths months before now.
+// It stops at the first partition that fails so nothing is dropped unverified.
+func (ctx *Context) ArchiveExpiredActivityLog(now time.Time) error {
+	logger := ctx.getLogger("ArchiveExpiredActivityLog")
+
+	now = now.UTC()
+	cutoff := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).
+		AddDate(0, -ctx.Config.ActivityLogRetentionMonths, 0)
+
+	partitions, err := ctx.DB.ListActivityLogPartitions(ctx.DBContext())
+	if err != nil {
+		return err
+	}
+
+	for _, partition := range partitions {
+		if !partition.Month.Before(cutoff) {
+			continue
+		}
+
+		manifest, err := ctx.archiveActivityLogPartition(partition)
+		if err != nil {
+			return errors.Wrapf(err, "unable to archive %s", partition.Name)
+		}
+
+		logger.Infof("Archived %s to %s (%d rows, %d bytes)", partition.Name, manifest.ObjectName, manifest.Rows, manifest.Bytes)
+	}
+
+	return nil
+}
+
+// archiveActivityLogPartition streams the export of partition through gzip
+// into MinIO, hashing and counting it on the way, so a month is never held
+// in memory
+func (ctx *Context) archiveActivityLogPartition(partition *model.ActivityLogPartition) (*model.ActivityLogArchiveManifest, error) {
+	hash := sha256.New()
+	counter := &byteCounter{}
+	reader, writer := io.Pipe()
+
+	var rows int64
+	exported := make(chan error, 1)
+	go func() {
+		compressor := gzip.NewWriter(writer)
+		var err error
+		rows, err = ctx.DB.ExportActivityLogPartition(ctx.DBContext(), partition.Month, io.MultiWriter(compressor, hash, counter))
+		if err == nil {
+			err = compressor.Close()
+		}
+		// a failed export fails the upload instead of ending the object
+		_ = writer.CloseWithError(err)
+		exported <- err
+	}()
+
+	objectName := ctx.activityLogArchiveObjectName(partition.Month)
+	uploadErr := ctx.MinIO.PutObject(ctx.RequestContext(), objectName, reader, "application/gzip")
+	// stops the export when the upload gave up reading
+	_ = reader.CloseWithError(errors.New("archive upload stopped"))
+	if err := <-exported; err != nil {
+		return nil, err
+	}
+	if uploadErr != nil {
+		return nil, uploadErr
+	}
+
+	manifest := &model.ActivityLogArchiveManifest{
+		Partition:    partition.Name,
+		Month:        partition.Month.Format("2006-01"),
+		ObjectName:   objectName,
+		Rows:         rows,
+		SHA256:       hex.EncodeToString(hash.Sum(nil)),
+		Bytes:        counter.n,
+		ArchivedTime: time.Now(),
+	}
+
+	// read the object back before anything is dropped
+	if err := ctx.verifyActivityLogArchive(manifest); err != nil {
+		return nil, errors.Wrap(err, "archive verification failed")
+	}
+
+	manifestBody, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.MinIO.CreateObject(ctx.RequestContext(), activityLogManifestObjectName(objectName), manifestBody); err != nil {
+		return nil, err
+	}
+
+	if err := ctx.DB.DropActivityLogPartition(ctx.DBContext(), partition.Month); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// RestoreActivityLogArchive loads the archived month into tableName, by
+// default activity_log_restore_YYYYMM, and returns the table and row count
+func (ctx *Context) RestoreActivityLogArchive(month time.Time, tableName string) (string, int64, error) {
+	logger := ctx.getLogger("RestoreActivityLogArchive")
+	logger.Infof("Begin")
+	defer logger.Infof("End")
+
+	if tableName == "" {
+		tableName = "activity_log_restore_" + month.Format("200601")
+	}
+
+	objectName := ctx.activityLogArchiveObjectName(month)
+	manifestBody, err := ctx.MinIO.DownloadFile(ctx.RequestContext(), activityLogManifestObjectName(objectName))
+	if err != nil {
+		return "", 0, errors.Wrapf(err, "no archive manifest for %s", month.Format("2006-01"))
+	}
+
+	manifest := &model.ActivityLogArchiveManifest{}
+	if err := json.Unmarshal(manifestBody, manifest); err != nil {
+		return "", 0, errors.Wrap(err, "invalid archive manifest")
+	}
+
+	archive, err := ctx.openActivityLogArchive(manifest)
+	if err != nil {
+		return "", 0, err
+	}
+	defer archive.Close()
+
+	// a checksum mismatch fails the last read, the restore is rolled back
+	rows, err := ctx.DB.RestoreActivityLogArchive(ctx.DBContext(), tableName, archive)
+	if err != nil {
+		return "", 0, err
+	}
+
+	logger.Infof("Restored %d rows of %s into %s", rows, manifest.Month, tableName)
+	return tableName, rows, nil
+}
+
+// verifyActivityLogArchive reads the archive of manifest back from MinIO and
+// checks it
+func (ctx *Context) verifyActivityLogArchive(manifest *model.ActivityLogArchiveManifest) error {
+	archive, err := ctx.openActivityLogArchive(manifest)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	_, err = io.Copy(io.Discard, archive)
+	return err
+}
+
+// openActivityLogArchive streams the uncompressed archive of manifest. Reading
+// it fails at the end, instead of returning io.EOF, when it does not match
+// manifest.
+func (ctx *Context) openActivityLogArchive(manifest *model.ActivityLogArchiveManifest) (io.ReadCloser, error) {
+	object, err := ctx.MinIO.OpenObject(ctx.RequestContext(), manifest.ObjectName)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := gzip.NewReader(object)
+	if err != nil {
+		_ = object.Close()
+		return nil, err
+	}
+
+	return &archiveReader{
+		reader:   reader,
+		object:   object,
+		manifest: manifest,
+		hash:     sha256.New(),
+	}, nil
+}
+
+// archiveReader checks the checksum, size and row count of an archive as it
+// is read
+type archiveReader struct {
+	reader   *gzip.Reader
+	object   io.Closer
+	manifest *model.ActivityLogArchiveManifest
+	hash     hash.Hash
+	bytes    int64
+	rows     int64
+}
+
+func (a *archiveReader) Read(p []byte) (int, error) {
+	n, err := a.reader.Read(p)
+	a.hash.Write(p[:n])
+	a.bytes += int64(n)
+	a.rows += int64(bytes.Count(p[:n], []byte("\n")))
+
+	if err == io.EOF {
+		if err := a.verify(); err != nil {
+			return n, err
+		}
+	}
+	return n, err
+}
+
+// errArchiveMismatch is wrapped by the errors of archives that do not match
+// their manifest
+var errArchiveMismatch = errors.New("archive does not match its manifest")
+
+func (a *archiveReader) verify() error {
+	if hex.EncodeToString(a.hash.Sum(nil)) != a.manifest.SHA256 || a.bytes != a.manifest.Bytes {
+		return errors.Wrapf(errArchiveMismatch, "checksum mismatch for %s", a.manifest.ObjectName)
+	}
+	if a.rows != a.manifest.Rows {
+		return errors.Wrapf(errArchiveMismatch, "%s holds %d rows, expected %d", a.manifest.ObjectName, a.rows, a.manifest.Rows)
+	}
+	return nil
+}
+
+func (a *archiveReader) Close() error {
+	_ = a.reader.Close()
+	return a.object.Close()
+}
+
+// activityLogArchiveObjectName is <prefix>/YYYY/activity_log-YYYY-MM.jsonl.gz
+func (ctx *Context) activityLogArchiveObjectName(month time.Time) string {
+	return path.Join(
+		ctx.Config.ActivityLogArchivePrefix,
+		month.Format("2006"),
+		"activity_log-"+month.Format("2006-01")+".jsonl.gz",
+	)
+}
+
+func activityLogManifestObjectName(objectName string) string {
+	return objectName + ".manifest.json"
+}
+
+type byteCounter struct {
+	n int64
+}
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
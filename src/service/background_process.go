@@ -36,6 +36,25 @@ func (ctx *Context) StartTimer() error {
 		return err
 	}
 
+	_, err = s.NewJob(
+		gocron.DurationJob(ctx.Config.ActivityLogMaintenanceInterval),
+		gocron.NewTask(ctx.MaintainActivityLog),
+		gocron.WithStartAt(gocron.WithStartImmediately()),
+	)
+	if err != nil {
+		ctx.Logger.Errorf("Cannot MaintainActivityLog job: %v", err)
+		return err
+	}
+
+	_, err = s.NewJob(
+		gocron.DurationJob(ctx.Config.ActivityLogCheckpointInterval),
+		gocron.NewTask(ctx.CheckpointActivityLog),
+	)
+	if err != nil {
+		ctx.Logger.Errorf("Cannot CheckpointActivityLog job: %v", err)
+		return err
+	}
+
 	s.Start()
 	ctx.Logger.Infof("Background process scheduler started successfully")
 
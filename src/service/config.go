@@ -2,6 +2,7 @@ package service
 
 import (
 	"errors"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -10,6 +11,19 @@ type Config struct {
 	AdminUsername string
 	AdminPassword string
 	AdminEmail    string
+
+	// ActivityLogRetentionMonths is how many whole months of activity_log are
+	// kept in Postgres before being archived to MinIO, 0 keeps everything
+	ActivityLogRetentionMonths      int
+	ActivityLogPartitionMonthsAhead int
+	ActivityLogArchivePrefix        string
+	ActivityLogMaintenanceInterval  time.Duration
+
+	// RSA keys signing the activity log hash chain checkpoints, see audit
+	ActivityLogSigningKeyID       string
+	ActivityLogSigningKeyPath     string
+	ActivityLogVerifyKeyPath      string
+	ActivityLogCheckpointInterval time.Duration
 }
 
 func InitConfig() (*Config, error) {
@@ -33,6 +47,32 @@ func InitConfig() (*Config, error) {
 		AdminUsername: adminUsername,
 		AdminPassword: adminPassword,
 		AdminEmail:    adminEmail,
+
+		ActivityLogRetentionMonths:      viper.GetInt("Database.ActivityLog.RetentionMonths"),
+		ActivityLogPartitionMonthsAhead: viper.GetInt("Database.ActivityLog.PartitionMonthsAhead"),
+		ActivityLogArchivePrefix:        viper.GetString("Database.ActivityLog.ArchivePrefix"),
+		ActivityLogMaintenanceInterval:  viper.GetDuration("Database.ActivityLog.MaintenanceInterval"),
+
+		ActivityLogSigningKeyID:       viper.GetString("Database.ActivityLog.Checkpoint.KeyID"),
+		ActivityLogSigningKeyPath:     viper.GetString("Database.ActivityLog.Checkpoint.PrivateKeyPath"),
+		ActivityLogVerifyKeyPath:      viper.GetString("Database.ActivityLog.Checkpoint.PublicKeyPath"),
+		ActivityLogCheckpointInterval: viper.GetDuration("Database.ActivityLog.Checkpoint.Interval"),
+	}
+
+	if config.ActivityLogPartitionMonthsAhead <= 0 {
+		config.ActivityLogPartitionMonthsAhead = 3
+	}
+	if config.ActivityLogArchivePrefix == "" {
+		config.ActivityLogArchivePrefix = "activity_log"
+	}
+	if config.ActivityLogMaintenanceInterval <= 0 {
+		config.ActivityLogMaintenanceInterval = 24 * time.Hour
+	}
+	if config.ActivityLogSigningKeyID == "" {
+		config.ActivityLogSigningKeyID = "activity-log"
+	}
+	if config.ActivityLogCheckpointInterval <= 0 {
+		config.ActivityLogCheckpointInterval = time.Hour
 	}
 
 	if config.AdminUsername == "" {
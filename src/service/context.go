@@ -2,14 +2,20 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"go-template/src/core/audit"
 	"go-template/src/core/azure_ad"
 	"go-template/src/core/db"
 	"go-template/src/core/log"
 	"go-template/src/core/minio"
+	"go-template/src/core/model"
 	"go-template/src/core/smtp_service"
 	"go-template/src/puppeteer"
 )
@@ -42,13 +48,24 @@ type Context struct {
 	ProfilePic   string
 	SpanID       string
 	TraceID      string
+	RequestNo    string
 	SmtpService  *smtp_service.SmtpServiceClient
 	MinIO        minio.MinIO
+	AuditSink    audit.Sink
+}
+
+// AuthUser is the caller identity stored in the request locals under UserKey
+type AuthUser struct {
+	UserID       int64
+	AzureUserID  string
+	Role         []string
+	EmailAddress string
+	ProfilePic   string
 }
 
 // New new custom fiber context
 func (service *Service) NewContext(c *fiber.Ctx) *Context {
-	return &Context{
+	ctx := &Context{
 		Ctx:          c,
 		Config:       service.Config,
 		Logger:       service.Logger,
@@ -60,31 +77,145 @@ func (service *Service) NewContext(c *fiber.Ctx) *Context {
 		Role:         service.Role,
 		EmailAddress: service.EmailAddress,
 		ProfilePic:   service.ProfilePic,
-		SpanID:       service.SpanID,
-		TraceID:      service.TraceID,
 		DpisService:  service.DpisService,
 		MinIO:        service.Minio,
 		SmtpService:  service.SmtpService,
+		AuditSink:    service.AuditSink,
+	}
+
+	// identity of the authenticated caller, set by RequiredAuth
+	if c != nil {
+		if user, ok := c.Locals(UserKey).(*AuthUser); ok {
+			ctx.UserID = user.UserID
+			ctx.AzureUserID = user.AzureUserID
+			ctx.Role = user.Role
+			ctx.EmailAddress = user.EmailAddress
+			ctx.ProfilePic = user.ProfilePic
+		}
 	}
+
+	requestCtx := ctx.RequestContext()
+	ctx.TraceID, ctx.SpanID = log.TraceIDsFromContext(requestCtx)
+	ctx.RequestNo = log.RequestNoFromContext(requestCtx)
+
+	return ctx
+}
+
+// RequestContext returns the context of the current request, carrying the
+// active trace span set by the otel middleware. Pass it to DB, MinIO and
+// outbound HTTP calls so their spans are recorded under the request.
+func (ctx *Context) RequestContext() context.Context {
+	if ctx.Ctx == nil {
+		return context.Background()
+	}
+	return ctx.UserContext()
+}
+
+// DBContext returns RequestContext tagged with the caller, so changes made
+// through it are attributed to them in data_change_log
+func (ctx *Context) DBContext() context.Context {
+	actor := db.Actor{
+		RequestNo: ctx.RequestNo,
+	}
+	if ctx.UserID != 0 {
+		actor.UserID = strconv.FormatInt(ctx.UserID, 10)
+	} else if ctx.AzureUserID != "" {
+		actor.UserID = ctx.AzureUserID
+	}
+	return db.ContextWithActor(ctx.RequestContext(), actor)
 }
 
 func (ctx *Context) getLogger(funcName string) log.Logger {
-	return ctx.Logger.WithFields(log.Fields{
-		"func":     funcName,
-		"span_id":  ctx.SpanID,
-		"trace_id": ctx.TraceID,
+	return ctx.Logger.WithContext(ctx.RequestContext()).WithFields(log.Fields{
+		"func": funcName,
 	})
 }
 
-func (ctx *Context) CreateActivityLog(uniqueNo string, reqBody, resBody []byte) error {
-	err := ctx.DB.CreateActivityLog(ctx.GetServiceCode(), uniqueNo, reqBody, resBody)
+// CreateActivityLog stores activityLog, filling in who made the request, the
+// service code and the correlation IDs
+func (ctx *Context) CreateActivityLog(activityLog *model.ActivityLog) error {
+	activityLog.ServiceCode = ctx.GetServiceCode()
+	activityLog.RequestNo = ctx.RequestNo
+	activityLog.TraceID = ctx.TraceID
+	activityLog.SpanID = ctx.SpanID
+
+	if ctx.UserID != 0 {
+		userID := ctx.UserID
+		activityLog.UserID = &userID
+	}
+	if ctx.AzureUserID != "" {
+		azureUserID := ctx.AzureUserID
+		activityLog.AzureUserID = &azureUserID
+	}
+	if ctx.EmailAddress != "" {
+		emailAddress := ctx.EmailAddress
+		activityLog.EmailAddress = &emailAddress
+	}
+	activityLog.UserRoles = ctx.Role
+
+	// written after the response, keep it when the request was cancelled
+	err := ctx.DB.CreateActivityLog(context.WithoutCancel(ctx.DBContext()), activityLog)
 	if err != nil {
 		return err
 	}
 
+	if ctx.isAdminRoute() {
+		ctx.EmitAuditEvent(audit.AdminActionEvent(activityLog))
+	}
+
 	return nil
 }
 
+// EmitAuditEvent forwards a security event to the audit sinks, filling in
+// the caller and the correlation IDs it does not set
+func (ctx *Context) EmitAuditEvent(event *audit.Event) {
+	if ctx.AuditSink == nil {
+		return
+	}
+
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if event.Outcome == "" {
+		event.Outcome = audit.OutcomeSuccess
+	}
+	if event.UserID == "" && ctx.UserID != 0 {
+		event.UserID = strconv.FormatInt(ctx.UserID, 10)
+	}
+	if event.UserName == "" {
+		event.UserName = ctx.AzureUserID
+	}
+	if event.EmailAddress == "" {
+		event.EmailAddress = ctx.EmailAddress
+	}
+	if event.UserRoles == nil {
+		event.UserRoles = ctx.Role
+	}
+	if event.RequestNo == "" {
+		event.RequestNo = ctx.RequestNo
+	}
+	if event.TraceID == "" {
+		event.TraceID = ctx.TraceID
+	}
+	if ctx.Ctx != nil {
+		if event.SourceIP == "" {
+			event.SourceIP = ctx.IP()
+		}
+		if event.UserAgent == "" {
+			event.UserAgent = ctx.Get(fiber.HeaderUserAgent)
+		}
+		if event.ServiceCode == "" {
+			event.ServiceCode = ctx.GetServiceCode()
+		}
+	}
+
+	ctx.AuditSink.Send(event)
+}
+
+func (ctx *Context) isAdminRoute() bool {
+	return ctx.Ctx != nil && strings.HasPrefix(ctx.Route().Path, "/api/admin")
+}
+
 func (ctx *Context) GetServiceCode() string {
 	name := ctx.Route().Name
 	if len(name) == 0 {
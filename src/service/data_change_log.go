@@ -0,0 +1,39 @@
+package service
+
+import (
+	"go-template/src/core/model"
+	"go-template/src/custom_error"
+)
+
+const (
+	defaultDataChangeLogLimit = 20
+)
+
+func (ctx *Context) InquiryDataChangeLog(params model.InquiryDataChangeLogParams) ([]*model.DataChangeLog, *model.Pagination, error) {
+	logger := ctx.getLogger("InquiryDataChangeLog")
+	logger.Infof("Begin")
+	defer logger.Infof("End")
+
+	if err := ValidateInput(params); err != nil {
+		logger.Errorf("ValidateInput error : %s", err)
+		return nil, nil, err
+	}
+
+	if params.Page == 0 {
+		params.Page = 1
+	}
+	if params.Limit == 0 {
+		params.Limit = defaultDataChangeLogLimit
+	}
+
+	result, pagination, err := ctx.DB.InquiryDataChangeLog(ctx.DBContext(), params.TableName, params.RecordID, params.Page, params.Limit)
+	if err != nil {
+		logger.Errorf("InquiryDataChangeLog error : %s", err)
+		return nil, nil, &custom_error.InternalError{
+			Code:    custom_error.DBError,
+			Message: err.Error(),
+		}
+	}
+
+	return result, pagination, nil
+}
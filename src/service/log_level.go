@@ -0,0 +1,69 @@
+package service
+
+import (
+	"time"
+
+	"go-template/src/core/log"
+	"go-template/src/custom_error"
+)
+
+type SetLogLevelParams struct {
+	Level      string `json:"level" validate:"required,oneof=debug info warn error fatal"`
+	Module     string `json:"module"`
+	TTLSeconds int64  `json:"ttl_seconds" validate:"gte=0"`
+}
+
+type ResetLogLevelParams struct {
+	Module string `json:"module"`
+}
+
+func (ctx *Context) GetLogLevel() log.LevelStatus {
+	return log.Levels().Status()
+}
+
+func (ctx *Context) SetLogLevel(params SetLogLevelParams) (*log.LevelStatus, error) {
+	logger := ctx.getLogger("SetLogLevel")
+	logger.Infof("Begin")
+	defer logger.Infof("End")
+
+	if err := ValidateInput(params); err != nil {
+		logger.Errorf("ValidateInput error : %s", err)
+		return nil, err
+	}
+
+	ttl := time.Duration(params.TTLSeconds) * time.Second
+	err := log.Levels().SetModuleLevel(params.Module, params.Level, ttl)
+	if err != nil {
+		return nil, &custom_error.ValidationError{
+			Code:    custom_error.InvalidParameter,
+			Message: err.Error(),
+		}
+	}
+
+	logger.Warn("log level changed",
+		"level", params.Level,
+		"target_module", params.Module,
+		"ttl_seconds", params.TTLSeconds,
+		"changed_by", ctx.EmailAddress,
+	)
+
+	status := log.Levels().Status()
+	return &status, nil
+}
+
+func (ctx *Context) ResetLogLevel(params ResetLogLevelParams) *log.LevelStatus {
+	logger := ctx.getLogger("ResetLogLevel")
+	logger.Infof("Begin")
+	defer logger.Infof("End")
+
+	if params.Module == "" {
+		log.Levels().Reset()
+	} else {
+		log.Levels().ResetModule(params.Module)
+	}
+
+	logger.Warn("log level reset", "target_module", params.Module, "changed_by", ctx.EmailAddress)
+
+	status := log.Levels().Status()
+	return &status
+}
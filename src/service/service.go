@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"reflect"
 	"strings"
 
@@ -13,10 +14,12 @@ import (
 	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
 	en_translations "github.com/go-playground/validator/v10/translations/en"
+	"go-template/src/core/audit"
 	"go-template/src/core/azure_ad"
 	"go-template/src/core/db"
 	"go-template/src/core/dpis_service"
 	"go-template/src/core/log"
+	"go-template/src/core/redact"
 	"go-template/src/custom_error"
 )
 
@@ -65,15 +68,17 @@ type Service struct {
 	Role         []string
 	EmailAddress string
 	ProfilePic   string
-	SpanID       string
-	TraceID      string
 	DpisService  dpis_service.DpisService
 	Minio        minio.MinIO
 	SmtpService  *smtp_service.SmtpServiceClient
 	Puppeteer    puppeteer.Puppeteer
+	Redactor     *redact.Redactor
+	AuditSink    audit.Sink
 }
 
-func NewService(logger log.Logger) (service *Service, err error) {
+// NewService connects every dependency. Startup calls, such as creating the
+// default bucket, are traced as children of the span in ctx.
+func NewService(ctx context.Context, logger log.Logger) (service *Service, err error) {
 	service = &Service{
 		Logger: logger,
 	}
@@ -82,6 +87,26 @@ func NewService(logger log.Logger) (service *Service, err error) {
 		return nil, err
 	}
 
+	redactConfig, err := redact.InitConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	service.Redactor, err = redact.New(redactConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	auditConfig, err := audit.InitConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	service.AuditSink, err = audit.New(auditConfig, logger)
+	if err != nil {
+		return nil, err
+	}
+
 	dbConfig, err := db.InitConfig()
 	if err != nil {
 		return nil, err
@@ -112,7 +137,7 @@ func NewService(logger log.Logger) (service *Service, err error) {
 		return nil, err
 	}
 
-	err = service.Minio.CreateDefaultBucket()
+	err = service.Minio.CreateDefaultBucket(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -120,6 +145,23 @@ func NewService(logger log.Logger) (service *Service, err error) {
 	return service, nil
 }
 
+// Close releases the service resources. Pending activity log entries are
+// written before the database pool is closed, queued audit events are sent
+// before returning.
+func (service *Service) Close() error {
+	if service.AuditSink != nil {
+		if err := service.AuditSink.Close(); err != nil {
+			return err
+		}
+	}
+	if service.DB != nil {
+		if err := service.DB.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func ValidateInput(input interface{}) *custom_error.ValidationError {
 	err := validate.Struct(input)
 	if err != nil {
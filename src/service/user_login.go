@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"time"
 
+	"go-template/src/core/audit"
 	"go-template/src/core/model"
 	"go-template/src/core/utils"
 	"go-template/src/custom_error"
@@ -45,7 +46,7 @@ type LoginRootParams struct {
 func (ctx *Context) LoginRoot(params LoginRootParams) (*LoginResponse, error) {
 	logger := ctx.getLogger("LoginRoot")
 	logger.Infof("Begin")
-	logger.Debugf("params: %v", params)
+	logger.Debug("params", "username", params.Username)
 	defer logger.Infof("End")
 
 	if err := ValidateInput(params); err != nil {
@@ -70,7 +71,7 @@ func (ctx *Context) LoginRoot(params LoginRootParams) (*LoginResponse, error) {
 			})
 		}
 
-		err := ctx.DB.InsertApiKeys(userApiKeys, true)
+		err := ctx.DB.InsertApiKeys(ctx.DBContext(), userApiKeys, true)
 		if err != nil {
 			ctx.Logger.Errorf("Login error : %s", err)
 			return nil, &custom_error.InternalError{
@@ -78,8 +79,21 @@ func (ctx *Context) LoginRoot(params LoginRootParams) (*LoginResponse, error) {
 				Message: err.Error(),
 			}
 		}
+
+		ctx.EmitAuditEvent(&audit.Event{
+			Type:         audit.EventLogin,
+			UserName:     params.Username,
+			EmailAddress: "root@mail.com",
+			UserRoles:    userInternalRole,
+		})
 	} else {
 		ctx.Logger.Errorf("Inactive user")
+		ctx.EmitAuditEvent(&audit.Event{
+			Type:     audit.EventLoginFailed,
+			Outcome:  audit.OutcomeFailure,
+			Message:  "Invalid Username or Password",
+			UserName: params.Username,
+		})
 		return nil, &custom_error.UserError{
 			Code:           custom_error.InvalidUsernameOrPassword,
 			Message:        "Invalid Username or Password",
@@ -110,7 +124,7 @@ func (ctx *Context) GetMe() (*GetMeResponse, error) {
 	fullName := "ผู้ดูแลระบบ"
 	department := "ผู้ดูแลระบบ"
 	if ctx.UserID != 0 {
-		//user, err := ctx.DB.GetUserByUserID(ctx.UserID)
+		//user, err := ctx.DB.GetUserByUserID(ctx.DBContext(), ctx.UserID)
 		//if err != nil {
 		//	return nil, &custom_error.InternalError{
 		//		Code:    custom_error.DBError,
@@ -139,7 +153,7 @@ func (ctx *Context) Logout(token string) error {
 	logger.Infof("Begin")
 	defer logger.Infof("End")
 
-	err := ctx.DB.DeleteApiKey(token)
+	err := ctx.DB.DeleteApiKey(ctx.DBContext(), token)
 	if err != nil {
 		return &custom_error.InternalError{
 			Code:    custom_error.DBError,
@@ -147,6 +161,11 @@ func (ctx *Context) Logout(token string) error {
 		}
 	}
 
+	ctx.EmitAuditEvent(&audit.Event{
+		Type:    audit.EventSessionRevoked,
+		Message: "User logged out",
+	})
+
 	return nil
 }
 
@@ -155,7 +174,7 @@ func (ctx *Context) RemoveExpireApiKey() {
 	logger.Infof("Begin")
 	defer logger.Infof("End")
 
-	err := ctx.DB.DeleteExpireApiKey()
+	err := ctx.DB.DeleteExpireApiKey(ctx.DBContext())
 	if err != nil {
 		logger.Errorf("DeleteExpireApiKey error: %+v", err)
 	}